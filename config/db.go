@@ -2,19 +2,35 @@ package config
 
 import (
 	"RyanForce/models"
+	"fmt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // DB is the global GORM database connection used throughout the app.
 // It’s initialized once in Connect() and shared across all packages.
 var DB *gorm.DB
 
+// foreignKeys lists the constraints whose ON DELETE behavior is declared on the models. Databases
+// created before the behavior was declared have the constraints without it, so Migrate rebuilds them.
+var foreignKeys = []struct {
+	model interface{} // Model declaring the relationship
+	table string      // Table holding the constraint
+	name  string
+}{
+	{&models.Ticket{}, "tickets", "fk_tickets_client"},             // Restrict: a user who raised tickets cannot be removed
+	{&models.Ticket{}, "tickets", "fk_tickets_assigned_tech"},      // Set null: the tickets become unassigned
+	{&models.Ticket{}, "comments", "fk_tickets_comments"},          // Cascade: comments go with their ticket
+	{&models.Account{}, "users", "fk_accounts_users"},              // Set null: the users are left without an account
+	{&models.Directory{}, "directories", "fk_directories_account"}, // Cascade: a directory goes with its account
+}
+
 // Connect sets up the SQLite database connection, ensures the directory exists,
-// and runs auto-migration to apply model schemas (User, Ticket).
+// and runs auto-migration to apply model schemas (users, tickets, comments, accounts, and supporting tables).
 func Connect() {
 	// Ensure the 'database/' directory exists
 	err := os.MkdirAll(filepath.Join(".", "database"), os.ModePerm)
@@ -24,18 +40,110 @@ func Connect() {
 
 	// Open a connection to the SQLite DB and assign it to the global DB variable
 	var dbErr error
-	DB, dbErr = gorm.Open(sqlite.Open("database/ryanforce.db"), &gorm.Config{})
+	DB, dbErr = OpenSQLite("database/ryanforce.db")
 	if dbErr != nil {
 		log.Fatalf("failed to connect to database: %v", dbErr)
 	}
 
 	// Automatically create or update database tables to match model structs.
-	if err := DB.AutoMigrate(
+	if err := Migrate(DB,
 		&models.User{},
 		&models.Ticket{},
-		&models.Comment{}, // <-- Add this!
+		&models.Comment{},
+		&models.Account{},
+		&models.RetentionPolicy{},
+		&models.LoginEvent{},
+		&models.Role{},
+		&models.MFARecoveryCode{},
+		&models.PasswordHistory{},
+		&models.PasswordResetToken{},
+		&models.RoleLoginPolicy{},
+		&models.Directory{},
+		&models.DataKey{},
+		&models.KnownLogin{},
+		&models.Job{},
+		&models.JobSchedule{},
+		&models.OutboxEvent{},
 	); err != nil {
 		log.Fatalf("failed to migrate database: %v", err)
 	}
 
 }
+
+// OpenSQLite opens the SQLite database at path with foreign key enforcement turned on for every
+// connection. SQLite leaves foreign keys unenforced unless each connection asks for them.
+func OpenSQLite(path string) (*gorm.DB, error) {
+	return gorm.Open(sqlite.Open(path+"?_foreign_keys=on"), &gorm.Config{})
+}
+
+// Migrate creates or updates the tables for the given models and brings their foreign keys up to
+// date. SQLite changes a table's constraints by copying it into a new table and dropping the old
+// one, which enforced foreign keys would refuse, so migrations run on a single connection with
+// enforcement off. Rows that break a constraint afterwards are logged rather than deleted.
+func Migrate(db *gorm.DB, dst ...interface{}) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		conn = conn.Session(&gorm.Session{}) // Start each statement afresh on the pinned connection
+		if err := conn.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+			return err
+		}
+		defer conn.Exec("PRAGMA foreign_keys = ON")
+
+		// Rebuilding a table drops its indexes, so upgrade first and let AutoMigrate recreate them
+		if err := upgradeForeignKeys(conn); err != nil {
+			return fmt.Errorf("failed to upgrade foreign keys: %w", err)
+		}
+		if err := conn.AutoMigrate(dst...); err != nil {
+			return err
+		}
+		reportForeignKeyViolations(conn)
+		return nil
+	})
+}
+
+// upgradeForeignKeys rebuilds constraints that were created without an ON DELETE clause.
+func upgradeForeignKeys(conn *gorm.DB) error {
+	migrator := conn.Migrator()
+	for _, fk := range foreignKeys {
+		var ddl string
+		if err := conn.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", fk.table).Scan(&ddl).Error; err != nil {
+			return err
+		}
+		start := strings.Index(ddl, "CONSTRAINT `"+fk.name+"`")
+		if start < 0 {
+			continue // Table not migrated here, or the constraint is missing and AutoMigrate adds it
+		}
+		clause := ddl[start:]
+		if end := strings.Index(clause[1:], "CONSTRAINT"); end >= 0 {
+			clause = clause[:end+1]
+		}
+		if strings.Contains(clause, "ON DELETE") {
+			continue
+		}
+
+		if err := migrator.DropConstraint(fk.model, fk.name); err != nil {
+			return fmt.Errorf("%s: %w", fk.name, err)
+		}
+		if err := migrator.CreateConstraint(fk.model, fk.name); err != nil {
+			return fmt.Errorf("%s: %w", fk.name, err)
+		}
+		log.Printf("Upgraded foreign key %s on %s", fk.name, fk.table)
+	}
+	return nil
+}
+
+// reportForeignKeyViolations logs rows that reference a missing parent, which older versions
+// could leave behind before foreign keys were enforced.
+func reportForeignKeyViolations(conn *gorm.DB) {
+	var violations []struct {
+		Table  string
+		RowID  int64 `gorm:"column:rowid"`
+		Parent string
+	}
+	if err := conn.Raw("PRAGMA foreign_key_check").Scan(&violations).Error; err != nil {
+		log.Printf("Could not check foreign keys: %v", err)
+		return
+	}
+	for _, v := range violations {
+		log.Printf("Warning: %s row %d references a missing %s row", v.Table, v.RowID, v.Parent)
+	}
+}
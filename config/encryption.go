@@ -0,0 +1,54 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// EncryptionSettings configures encryption of sensitive fields at rest (see the fieldcrypt package).
+type EncryptionSettings struct {
+	MasterKeyID        string            // Names the master key that wraps new data keys
+	MasterKey          []byte            // 32 bytes; field encryption is off when unset
+	PreviousMasterKeys map[string][]byte // Retired master keys by ID, still used to unwrap data keys
+}
+
+// LoadEncryptionSettings reads the master keys from environment variables. Keys are base64
+// encoded; RYANFORCE_PREVIOUS_MASTER_KEYS is a comma-separated list of id=key pairs.
+func LoadEncryptionSettings() (EncryptionSettings, error) {
+	s := EncryptionSettings{
+		MasterKeyID:        GetEnv("RYANFORCE_MASTER_KEY_ID", "master-1"),
+		PreviousMasterKeys: map[string][]byte{},
+	}
+	if encoded := GetEnv("RYANFORCE_MASTER_KEY", ""); encoded != "" {
+		key, err := decodeMasterKey(encoded)
+		if err != nil {
+			return s, fmt.Errorf("RYANFORCE_MASTER_KEY: %w", err)
+		}
+		s.MasterKey = key
+	}
+	for _, pair := range strings.Split(GetEnv("RYANFORCE_PREVIOUS_MASTER_KEYS", ""), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(pair, "=") // Base64 padding also uses '=', so split at the first
+		if !ok {
+			return s, fmt.Errorf("RYANFORCE_PREVIOUS_MASTER_KEYS: expected comma-separated id=key pairs")
+		}
+		key, err := decodeMasterKey(encoded)
+		if err != nil {
+			return s, fmt.Errorf("RYANFORCE_PREVIOUS_MASTER_KEYS %s: %w", id, err)
+		}
+		s.PreviousMasterKeys[strings.TrimSpace(id)] = key
+	}
+	return s, nil
+}
+
+// decodeMasterKey decodes a base64 master key and checks its length.
+func decodeMasterKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("must be 32 bytes, base64 encoded (e.g. openssl rand -base64 32)")
+	}
+	return key, nil
+}
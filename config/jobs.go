@@ -0,0 +1,50 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// JobSettings controls the background job runner started with the WebUI and server.
+type JobSettings struct {
+	Workers      int           // Jobs this instance runs at once; 0 leaves running jobs to other instances
+	PollInterval time.Duration // How often to queue due schedules and look for queued jobs
+	Lease        time.Duration // How long a job stays claimed without a heartbeat before another instance retries it
+	RetryBase    time.Duration // Delay before the first retry of a failed job; each further retry doubles it
+	RetryMax     time.Duration // Upper bound for the doubled delay
+}
+
+// LoadJobSettings reads the job runner options from RYANFORCE_JOB_* environment variables.
+func LoadJobSettings() JobSettings {
+	return JobSettings{
+		Workers:      GetEnvInt("RYANFORCE_JOB_WORKERS", 2),
+		PollInterval: seconds("RYANFORCE_JOB_POLL_SECONDS", 5),
+		Lease:        seconds("RYANFORCE_JOB_LEASE_SECONDS", 60),
+		RetryBase:    seconds("RYANFORCE_JOB_RETRY_BASE_SECONDS", 30),
+		RetryMax:     seconds("RYANFORCE_JOB_RETRY_MAX_SECONDS", 3600),
+	}
+}
+
+// JobSchedule returns the schedule for the named job: RYANFORCE_JOB_SCHEDULE_<NAME>, with the
+// name upper-cased and dashes as underscores, or fallback when that is unset. "off" disables it.
+func JobSchedule(name, fallback string) string {
+	key := "RYANFORCE_JOB_SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	return strings.TrimSpace(GetEnv(key, fallback))
+}
+
+// AutoCloseDays is how long a ticket may wait on the customer before the auto-close job closes
+// it. Set RYANFORCE_AUTO_CLOSE_DAYS to override; zero turns auto-close off.
+func AutoCloseDays() int {
+	return GetEnvInt("RYANFORCE_AUTO_CLOSE_DAYS", 14)
+}
+
+// BackupDir is where the backup job writes database snapshots. Set RYANFORCE_BACKUP_DIR to override.
+func BackupDir() string {
+	return GetEnv("RYANFORCE_BACKUP_DIR", "database/backups")
+}
+
+// BackupKeep is how many snapshots the backup job keeps, deleting the oldest beyond that.
+// Set RYANFORCE_BACKUP_KEEP to override.
+func BackupKeep() int {
+	return GetEnvInt("RYANFORCE_BACKUP_KEEP", 7)
+}
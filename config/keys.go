@@ -0,0 +1,35 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// KeySettings configures where token signing keys come from (see the keyring package).
+type KeySettings struct {
+	File            string        // JSON key set, created on first use and rewritten by rotate-keys
+	Secret          string        // HS256 secret from the environment; overrides File when set
+	PreviousSecrets []string      // Retired environment secrets still accepted for verification
+	Algorithm       string        // HS256, EdDSA, or RS256 for keys generated into File
+	Overlap         time.Duration // How long a retired key keeps verifying tokens
+}
+
+// LoadKeySettings reads the signing key options from environment variables.
+// RYANFORCE_JWT_PREVIOUS_SECRETS is a comma-separated list.
+func LoadKeySettings() KeySettings {
+	s := KeySettings{
+		File:      GetEnv("RYANFORCE_JWT_KEYS_FILE", "database/jwt_keys.json"),
+		Secret:    GetEnv("RYANFORCE_JWT_SECRET", ""),
+		Algorithm: strings.ToUpper(GetEnv("RYANFORCE_JWT_ALG", "HS256")),
+		Overlap:   time.Duration(GetEnvInt("RYANFORCE_JWT_KEY_OVERLAP_HOURS", 24)) * time.Hour,
+	}
+	if s.Algorithm == "EDDSA" {
+		s.Algorithm = "EdDSA"
+	}
+	for _, secret := range strings.Split(GetEnv("RYANFORCE_JWT_PREVIOUS_SECRETS", ""), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			s.PreviousSecrets = append(s.PreviousSecrets, secret)
+		}
+	}
+	return s
+}
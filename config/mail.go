@@ -0,0 +1,29 @@
+package config
+
+import "strings"
+
+// MailSettings holds the SMTP server used for outgoing email such as password reset links.
+type MailSettings struct {
+	Host     string // Empty disables sending; messages are logged instead
+	Port     int
+	Username string // Optional; PLAIN auth is used when set
+	Password string
+	From     string
+}
+
+// LoadMailSettings reads the SMTP options from RYANFORCE_SMTP_* environment variables.
+// For local testing point them at an SMTP sink such as MailHog (localhost:1025).
+func LoadMailSettings() MailSettings {
+	return MailSettings{
+		Host:     GetEnv("RYANFORCE_SMTP_HOST", ""),
+		Port:     GetEnvInt("RYANFORCE_SMTP_PORT", 25),
+		Username: GetEnv("RYANFORCE_SMTP_USERNAME", ""),
+		Password: GetEnv("RYANFORCE_SMTP_PASSWORD", ""),
+		From:     GetEnv("RYANFORCE_SMTP_FROM", "RyanForce <no-reply@ryanforce.local>"),
+	}
+}
+
+// BaseURL is the externally visible address of the WebUI, used to build links in emails.
+func BaseURL() string {
+	return strings.TrimRight(GetEnv("RYANFORCE_BASE_URL", "http://localhost:8080"), "/")
+}
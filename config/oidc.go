@@ -0,0 +1,53 @@
+package config
+
+import "strings"
+
+// OIDCSettings configures OpenID Connect single sign-on for the WebUI.
+type OIDCSettings struct {
+	Issuer       string // Empty disables SSO
+	ClientID     string
+	ClientSecret string // Optional for public clients; PKCE is always used
+	RedirectURL  string
+	Scopes       []string
+	ProviderName string // Shown on the login button
+
+	EmailClaim  string
+	GroupsClaim string
+	RoleMap     []OIDCRoleMapping // Checked in order; the first group the user has wins
+	DefaultRole string            // Role for users matching no mapping; empty refuses them
+}
+
+// OIDCRoleMapping gives users in an identity provider group (or with a claim value) a role.
+type OIDCRoleMapping struct {
+	Group string
+	Role  string
+}
+
+// LoadOIDCSettings reads the SSO options from RYANFORCE_OIDC_* environment variables.
+// RYANFORCE_OIDC_ROLE_MAP is a comma-separated list of group=role pairs, e.g.
+// "rf-admins=admin,it-staff=tech".
+func LoadOIDCSettings() OIDCSettings {
+	s := OIDCSettings{
+		Issuer:       strings.TrimRight(GetEnv("RYANFORCE_OIDC_ISSUER", ""), "/"),
+		ClientID:     GetEnv("RYANFORCE_OIDC_CLIENT_ID", ""),
+		ClientSecret: GetEnv("RYANFORCE_OIDC_CLIENT_SECRET", ""),
+		RedirectURL:  GetEnv("RYANFORCE_OIDC_REDIRECT_URL", BaseURL()+"/login/oidc/callback"),
+		Scopes:       strings.Fields(GetEnv("RYANFORCE_OIDC_SCOPES", "openid email profile")),
+		ProviderName: GetEnv("RYANFORCE_OIDC_PROVIDER_NAME", "Single Sign-On"),
+		EmailClaim:   GetEnv("RYANFORCE_OIDC_EMAIL_CLAIM", "email"),
+		GroupsClaim:  GetEnv("RYANFORCE_OIDC_GROUPS_CLAIM", "groups"),
+		DefaultRole:  GetEnv("RYANFORCE_OIDC_DEFAULT_ROLE", ""),
+	}
+	for _, pair := range strings.Split(GetEnv("RYANFORCE_OIDC_ROLE_MAP", ""), ",") {
+		group, role, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(group) != "" && strings.TrimSpace(role) != "" {
+			s.RoleMap = append(s.RoleMap, OIDCRoleMapping{Group: strings.TrimSpace(group), Role: strings.TrimSpace(role)})
+		}
+	}
+	return s
+}
+
+// Enabled reports whether SSO is configured.
+func (s OIDCSettings) Enabled() bool {
+	return s.Issuer != "" && s.ClientID != ""
+}
@@ -0,0 +1,13 @@
+package config
+
+// SCIMToken is the bearer token identity providers use for the SCIM provisioning API.
+// SCIM is disabled while RYANFORCE_SCIM_TOKEN is unset.
+func SCIMToken() string {
+	return GetEnv("RYANFORCE_SCIM_TOKEN", "")
+}
+
+// SCIMDefaultRole is the role given to users provisioned over SCIM, and the role users fall
+// back to when they are removed from a role group. Set RYANFORCE_SCIM_DEFAULT_ROLE to override.
+func SCIMDefaultRole() string {
+	return GetEnv("RYANFORCE_SCIM_DEFAULT_ROLE", "client")
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultCSP allows only same-origin resources. Inline scripts and styles are still permitted
+// because several templates use small inline handlers; data: images cover the MFA QR code.
+const defaultCSP = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+// CookieSettings controls the attributes of every cookie the WebUI sets.
+type CookieSettings struct {
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string // Empty means a host-only cookie
+}
+
+// LoadCookieSettings reads the cookie options from RYANFORCE_COOKIE_* environment variables.
+// Secure defaults to on when serve mode has a TLS certificate configured.
+func LoadCookieSettings() CookieSettings {
+	return CookieSettings{
+		Secure:   GetEnvBool("RYANFORCE_COOKIE_SECURE", LoadServerSettings().TLSEnabled()),
+		SameSite: parseSameSite(GetEnv("RYANFORCE_COOKIE_SAMESITE", "lax")),
+		Domain:   GetEnv("RYANFORCE_COOKIE_DOMAIN", ""),
+	}
+}
+
+// ContentSecurityPolicy returns the Content-Security-Policy header sent with every response.
+// Set RYANFORCE_CSP to override it.
+func ContentSecurityPolicy() string {
+	return GetEnv("RYANFORCE_CSP", defaultCSP)
+}
+
+// parseSameSite maps "strict", "lax", or "none" to the matching SameSite mode, defaulting to lax.
+func parseSameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// LockoutPolicy controls how repeated login failures are throttled. The same policy applies to
+// CLI, WebUI, and API logins.
+type LockoutPolicy struct {
+	AccountThreshold int           // Failures on one account before it is locked
+	BaseLockout      time.Duration // First lockout; each further lockout doubles it
+	MaxLockout       time.Duration // Upper bound for the doubled lockout
+	IPThreshold      int           // Failures from one IP within IPWindow before it is throttled
+	IPWindow         time.Duration
+}
+
+// LoadLockoutPolicy reads the lockout options from RYANFORCE_LOCKOUT_* environment variables.
+func LoadLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		AccountThreshold: GetEnvInt("RYANFORCE_LOCKOUT_THRESHOLD", 5),
+		BaseLockout:      seconds("RYANFORCE_LOCKOUT_BASE_SECONDS", 60),
+		MaxLockout:       seconds("RYANFORCE_LOCKOUT_MAX_SECONDS", 3600),
+		IPThreshold:      GetEnvInt("RYANFORCE_LOCKOUT_IP_THRESHOLD", 20),
+		IPWindow:         time.Duration(GetEnvInt("RYANFORCE_LOCKOUT_IP_WINDOW_MINUTES", 15)) * time.Minute,
+	}
+}
+
+// StepUpPolicy controls extra verification for logins from networks a user has not signed in
+// from before.
+type StepUpPolicy struct {
+	Failures int           // Failed logins for a user from an unknown IP within Window before step-up is required; 0 disables
+	Window   time.Duration // Window for counting those failures
+}
+
+// LoadStepUpPolicy reads the step-up options from RYANFORCE_STEP_UP_* environment variables.
+func LoadStepUpPolicy() StepUpPolicy {
+	return StepUpPolicy{
+		Failures: GetEnvInt("RYANFORCE_STEP_UP_FAILURES", 3),
+		Window:   time.Duration(GetEnvInt("RYANFORCE_STEP_UP_WINDOW_MINUTES", 60)) * time.Minute,
+	}
+}
+
+// Backoff returns the lockout for the nth consecutive lockout (1-based): the base duration
+// doubled n-1 times, capped at MaxLockout.
+func (p LockoutPolicy) Backoff(n int) time.Duration {
+	d := p.BaseLockout
+	for i := 1; i < n && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > p.MaxLockout {
+		return p.MaxLockout
+	}
+	return d
+}
+
+// PasswordPolicy is the single set of password rules enforced wherever a password is created,
+// changed, or reset.
+type PasswordPolicy struct {
+	MinLength      int
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	HistorySize    int           // Previous passwords a user may not reuse (0 disables the check)
+	MaxAge         time.Duration // Passwords older than this must be changed at next login (0 disables expiry)
+	CheckCommon    bool          // Reject passwords found in the bundled common-password list
+}
+
+// LoadPasswordPolicy reads the password rules from RYANFORCE_PASSWORD_* environment variables.
+// The defaults match the historical rule: 8–32 characters with a capital letter, a digit, and
+// a special character.
+func LoadPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:      GetEnvInt("RYANFORCE_PASSWORD_MIN_LENGTH", 8),
+		MaxLength:      GetEnvInt("RYANFORCE_PASSWORD_MAX_LENGTH", 32),
+		RequireUpper:   GetEnvBool("RYANFORCE_PASSWORD_REQUIRE_UPPER", true),
+		RequireLower:   GetEnvBool("RYANFORCE_PASSWORD_REQUIRE_LOWER", false),
+		RequireDigit:   GetEnvBool("RYANFORCE_PASSWORD_REQUIRE_DIGIT", true),
+		RequireSpecial: GetEnvBool("RYANFORCE_PASSWORD_REQUIRE_SPECIAL", true),
+		HistorySize:    GetEnvInt("RYANFORCE_PASSWORD_HISTORY", 5),
+		MaxAge:         time.Duration(GetEnvInt("RYANFORCE_PASSWORD_MAX_AGE_DAYS", 0)) * 24 * time.Hour,
+		CheckCommon:    GetEnvBool("RYANFORCE_PASSWORD_CHECK_COMMON", true),
+	}
+}
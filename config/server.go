@@ -0,0 +1,59 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// ServerSettings holds the options used by the production "serve" mode.
+type ServerSettings struct {
+	Addr              string
+	TLSCertFile       string
+	TLSKeyFile        string
+	TrustedProxies    []string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	DrainDelay        time.Duration // How long /readyz reports draining before connections close
+	ShutdownTimeout   time.Duration
+}
+
+// LoadServerSettings reads the serve-mode options from RYANFORCE_* environment variables.
+func LoadServerSettings() ServerSettings {
+	return ServerSettings{
+		Addr:              GetEnv("RYANFORCE_ADDR", ":8080"),
+		TLSCertFile:       GetEnv("RYANFORCE_TLS_CERT_FILE", ""),
+		TLSKeyFile:        GetEnv("RYANFORCE_TLS_KEY_FILE", ""),
+		TrustedProxies:    TrustedProxies(),
+		ReadHeaderTimeout: seconds("RYANFORCE_READ_HEADER_TIMEOUT_SECONDS", 5),
+		ReadTimeout:       seconds("RYANFORCE_READ_TIMEOUT_SECONDS", 15),
+		WriteTimeout:      seconds("RYANFORCE_WRITE_TIMEOUT_SECONDS", 30),
+		IdleTimeout:       seconds("RYANFORCE_IDLE_TIMEOUT_SECONDS", 120),
+		DrainDelay:        seconds("RYANFORCE_DRAIN_SECONDS", 5),
+		ShutdownTimeout:   seconds("RYANFORCE_SHUTDOWN_TIMEOUT_SECONDS", 30),
+	}
+}
+
+// TLSEnabled reports whether both a certificate and key file were configured.
+func (s ServerSettings) TLSEnabled() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+// TrustedProxies returns the comma-separated IPs/CIDRs in RYANFORCE_TRUSTED_PROXIES.
+// Only these proxies may set X-Forwarded-For, so c.ClientIP() reports the real client
+// behind a reverse proxy. An empty list trusts no proxy.
+func TrustedProxies() []string {
+	var proxies []string
+	for _, p := range strings.Split(GetEnv("RYANFORCE_TRUSTED_PROXIES", ""), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
+
+// seconds reads an environment variable as a whole number of seconds.
+func seconds(key string, fallback int) time.Duration {
+	return time.Duration(GetEnvInt(key, fallback)) * time.Second
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Runtime settings are read from environment variables so the same binary can be
+// configured differently per deployment without a config file.
+
+// GetEnv returns the value of an environment variable, or fallback if it is unset or blank.
+func GetEnv(key, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetEnvInt returns an environment variable parsed as an integer, or fallback if it is
+// unset or not a valid number.
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(GetEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvBool returns an environment variable parsed as a boolean ("true", "1", "false", "0", ...),
+// or fallback if it is unset or not a valid boolean.
+func GetEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(GetEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// TrashRetentionDays is how long soft-deleted tickets, comments and accounts stay in the
+// trash before they are permanently purged. Set RYANFORCE_TRASH_RETENTION_DAYS to override.
+func TrashRetentionDays() int {
+	return GetEnvInt("RYANFORCE_TRASH_RETENTION_DAYS", 30)
+}
+
+// DefaultRetentionDays is the global retention period for one kind of record
+// ("closed_ticket", "comment", or "log") used until an admin saves a global
+// policy. Set e.g. RYANFORCE_RETENTION_CLOSED_TICKET_DAYS to override. Zero keeps records forever.
+func DefaultRetentionDays(kind string) int {
+	return GetEnvInt("RYANFORCE_RETENTION_"+strings.ToUpper(kind)+"_DAYS", 0)
+}
+
+// RetentionIntervalHours is how often the retention-purge and trash-purge jobs run.
+// Set RYANFORCE_RETENTION_INTERVAL_HOURS to override; zero turns both schedules off.
+func RetentionIntervalHours() int {
+	return GetEnvInt("RYANFORCE_RETENTION_INTERVAL_HOURS", 24)
+}
+
+// DirectorySyncIntervalMinutes is how often the directory-sync job syncs users from LDAP directories.
+// Set RYANFORCE_DIRECTORY_SYNC_INTERVAL_MINUTES to override; zero disables the scheduled sync.
+func DirectorySyncIntervalMinutes() int {
+	return GetEnvInt("RYANFORCE_DIRECTORY_SYNC_INTERVAL_MINUTES", 60)
+}
+
+// ImpersonationMinutes is how long an admin's "view as user" session lasts before it expires.
+// Set RYANFORCE_IMPERSONATION_MINUTES to override.
+func ImpersonationMinutes() int {
+	return GetEnvInt("RYANFORCE_IMPERSONATION_MINUTES", 30)
+}
+
+// EventOutboxEnabled reports whether domain events are also written to the outbox table, in the
+// same transaction as the change they describe. Set RYANFORCE_EVENT_OUTBOX to turn it on.
+func EventOutboxEnabled() bool {
+	return GetEnvBool("RYANFORCE_EVENT_OUTBOX", false)
+}
+
+// EventOutboxKeepDays is how long delivered outbox events are kept before the outbox job deletes
+// them. Set RYANFORCE_EVENT_OUTBOX_KEEP_DAYS to override; zero keeps them forever.
+func EventOutboxKeepDays() int {
+	return GetEnvInt("RYANFORCE_EVENT_OUTBOX_KEEP_DAYS", 7)
+}
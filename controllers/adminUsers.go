@@ -2,13 +2,17 @@ package controllers
 
 import (
 	"RyanForce/config"
+	"RyanForce/events"
 	"RyanForce/models"
 	"RyanForce/utils"
 	"bufio"
+	"errors"
 	"fmt"
+	"gorm.io/gorm"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ListUsers prints all users in the system.
@@ -31,48 +35,166 @@ func ListUsers() {
 	}
 }
 
-// DeleteUser removes a user by their ID.
-func DeleteUser(userID uint) {
-	var user models.User
-	if err := config.DB.First(&user, userID).Error; err != nil {
-		fmt.Println("[Error] User not found.")
-		utils.LogWarning(fmt.Sprintf("[Admin] Attempted to delete non-existent user ID %d", userID))
-		return
+// ErrUserNotFound is returned when deleting a user who does not exist or was already deleted.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrDeleteSelf is returned when an admin tries to delete their own user.
+var ErrDeleteSelf = errors.New("you cannot delete your own account")
+
+// ErrUserHasOpenTickets is returned when deleting a user who raised tickets that are still open,
+// which would leave those tickets with nobody to follow up with.
+var ErrUserHasOpenTickets = errors.New("the user raised tickets that are still open; close them or move them to the trash first")
+
+// ErrAccountNotFound is returned when deleting an account that does not exist or is already in the trash.
+var ErrAccountNotFound = errors.New("account not found")
+
+// ErrAccountHasUsers is returned when deleting an account that users are still assigned to.
+var ErrAccountHasUsers = errors.New("users are still assigned to the account")
+
+// UserDeletion reports what deleting a user did with the open tickets assigned to them.
+type UserDeletion struct {
+	User       models.User
+	Reassigned int64 // Open tickets moved to the replacement technician
+	Unassigned int64 // Open tickets left without a technician
+}
+
+// OpenTicketsAssignedTo returns the open tickets assigned to a technician, so deleting them can
+// offer to hand these to someone else.
+func OpenTicketsAssignedTo(techID uint) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	if err := config.DB.Where("tech_id = ? AND status <> ?", techID, StatusClosed).Order("id").Find(&tickets).Error; err != nil {
+		return nil, fmt.Errorf("failed to load assigned tickets: %w", err)
 	}
+	return tickets, nil
+}
 
-	if err := config.DB.Delete(&user).Error; err != nil {
-		fmt.Println("[Error] Could not delete user.")
-		return
+// DeleteUser deletes a user and, in the same transaction, hands their open tickets to the
+// technician reassignTo, or leaves them unassigned when reassignTo is 0. Users who raised
+// tickets that are still open cannot be deleted. adminID is the admin performing the deletion.
+func DeleteUser(userID, reassignTo, adminID uint, ip string) (*UserDeletion, error) {
+	if userID == adminID {
+		return nil, ErrDeleteSelf
+	}
+	if reassignTo != 0 && reassignTo == userID {
+		return nil, ErrInvalidTechnician
 	}
 
-	fmt.Printf("User ID %d deleted.\n", userID)
-	utils.LogInfo(fmt.Sprintf("[Admin] Deleted user ID %d (%s)", user.ID, user.Email))
+	result := &UserDeletion{}
+	err := events.Transaction(config.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		if err := tx.Where("id = ?", userID).Limit(1).Find(&result.User).Error; err != nil {
+			return err
+		}
+		if result.User.ID == 0 {
+			return ErrUserNotFound
+		}
+
+		var raised int64
+		if err := tx.Model(&models.Ticket{}).Where("client_id = ? AND status <> ?", userID, StatusClosed).Count(&raised).Error; err != nil {
+			return err
+		}
+		if raised > 0 {
+			return ErrUserHasOpenTickets
+		}
+
+		assigned := map[string]interface{}{"tech_id": nil, "version": gorm.Expr("version + 1")}
+		if reassignTo != 0 {
+			if _, err := findActiveTechnician(tx, reassignTo); err != nil {
+				return err
+			}
+			assigned["tech_id"] = reassignTo
+		}
+		var open []uint
+		if err := tx.Model(&models.Ticket{}).Where("tech_id = ? AND status <> ?", userID, StatusClosed).Pluck("id", &open).Error; err != nil {
+			return err
+		}
+		if len(open) > 0 {
+			if err := tx.Model(&models.Ticket{}).Where("id IN ?", open).Updates(assigned).Error; err != nil {
+				return err
+			}
+		}
+		previous := userID
+		for _, id := range open {
+			if reassignTo != 0 {
+				emit(events.TicketAssigned{TicketID: id, TechID: reassignTo, PreviousTechID: &previous, ActorID: adminID})
+			} else {
+				emit(events.TicketUnassigned{TicketID: id, PreviousTechID: &previous, ActorID: adminID})
+			}
+		}
+		if reassignTo != 0 {
+			result.Reassigned = int64(len(open))
+		} else {
+			result.Unassigned = int64(len(open))
+		}
+
+		if err := tx.Model(&result.User).Update("sessions_revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&result.User).Error; err != nil {
+			return err
+		}
+		emit(events.UserDeleted{UserID: userID, ActorID: adminID})
+		return nil
+	})
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrUserHasOpenTickets) || errors.Is(err, ErrInvalidTechnician) {
+		utils.LogWarningIP(fmt.Sprintf("[Admin] Admin %d could not delete user %d: %v", adminID, userID, err), ip)
+		return nil, err
+	}
+	if err != nil {
+		utils.LogErrorIP(fmt.Sprintf("[Admin] Failed to delete user %d", userID), err, ip)
+		return nil, fmt.Errorf("failed to delete user")
+	}
+
+	utils.LogAudit(fmt.Sprintf("[Admin] Admin %d deleted user %d (%s); %d open ticket(s) reassigned to user %d, %d unassigned",
+		adminID, userID, result.User.Email, result.Reassigned, reassignTo, result.Unassigned))
+	return result, nil
 }
 
-// HandleListUsers displays a list of all users (admin only).
-func HandleListUsers() {
-	claims, err := utils.LoadClaims()
-	if err != nil || claims == nil {
-		return
+// DeleteAccount moves an account to the trash. The check that no users are assigned and the
+// deletion run in one transaction, so a user cannot be added in between.
+func DeleteAccount(accountID, adminID uint, ip string) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var account models.Account
+		if err := tx.Where("id = ?", accountID).Limit(1).Find(&account).Error; err != nil {
+			return err
+		}
+		if account.ID == 0 {
+			return ErrAccountNotFound
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("account_id = ?", accountID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: reassign or remove the %d user(s) first", ErrAccountHasUsers, count)
+		}
+		return tx.Delete(&account).Error
+	})
+	if errors.Is(err, ErrAccountNotFound) || errors.Is(err, ErrAccountHasUsers) {
+		utils.LogWarningIP(fmt.Sprintf("[Admin] Admin %d could not delete account %d: %v", adminID, accountID, err), ip)
+		return err
 	}
-	if claims.Role != "admin" {
-		fmt.Println("[Error] Only admins can view user list.")
-		return
+	if err != nil {
+		utils.LogErrorIP(fmt.Sprintf("[Admin] Failed to delete account %d", accountID), err, ip)
+		return fmt.Errorf("failed to delete account")
 	}
 
+	utils.LogAudit(fmt.Sprintf("[Admin] Admin %d deleted account %d", adminID, accountID))
+	return nil
+}
+
+// HandleListUsers displays a list of all users (requires users.view, checked by the CLI guard).
+func HandleListUsers() {
 	ListUsers()
 }
 
-// HandleDeleteUser prompts for a user ID and deletes that user (admin only).
+// HandleDeleteUser prompts for a user ID and deletes that user (requires users.manage, checked by
+// the CLI guard). When the user is a technician with open tickets, it offers to reassign them.
 func HandleDeleteUser() {
 	claims, err := utils.LoadClaims()
 	if err != nil || claims == nil {
 		return
 	}
-	if claims.Role != "admin" {
-		fmt.Println("[Error] Only admins can delete users.")
-		return
-	}
 
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Print("User ID to delete: ")
@@ -83,9 +205,51 @@ func HandleDeleteUser() {
 		fmt.Println("[Error] Invalid user ID.")
 		return
 	}
+	userID := uint(id64)
 
-	utils.LogInfo(fmt.Sprintf("[Audit] Admin %d attempting to delete user ID %d", claims.UserID, id64))
-	DeleteUser(uint(id64))
+	var reassignTo uint
+	open, err := OpenTicketsAssignedTo(userID)
+	if err != nil {
+		fmt.Println("[Error] Could not load the user's tickets.")
+		utils.LogError("[Admin] Failed to load tickets before deleting a user", err)
+		return
+	}
+	if len(open) > 0 {
+		fmt.Printf("User %d is assigned %d open ticket(s):\n", userID, len(open))
+		for _, t := range open {
+			fmt.Printf("  #%d %s [%s]\n", t.ID, t.Title, t.Status)
+		}
+		fmt.Print("Technician ID to reassign them to (leave blank to unassign them): ")
+		input, _ = reader.ReadString('\n')
+		if input = strings.TrimSpace(input); input != "" {
+			tech64, err := strconv.ParseUint(input, 10, 64)
+			if err != nil {
+				fmt.Println("[Error] Invalid technician ID.")
+				return
+			}
+			reassignTo = uint(tech64)
+		}
+	}
+
+	fmt.Printf("Are you sure you want to delete user %d? Type 'yes' to confirm: ", userID)
+	confirm, _ := reader.ReadString('\n')
+	if strings.ToLower(strings.TrimSpace(confirm)) != "yes" {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	deletion, err := DeleteUser(userID, reassignTo, claims.UserID, "CLI-Local")
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("User ID %d deleted.\n", userID)
+	if deletion.Reassigned > 0 {
+		fmt.Printf("%d open ticket(s) reassigned to technician %d.\n", deletion.Reassigned, reassignTo)
+	}
+	if deletion.Unassigned > 0 {
+		fmt.Printf("%d open ticket(s) are now unassigned.\n", deletion.Unassigned)
+	}
 }
 
 // CreateAccount adds a new account to the database.
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeleteUserReassignsOpenTickets(t *testing.T) {
+	testutil.ResetDB(t)
+	admin := models.User{Email: "admin@delete.test", Role: rbac.RoleAdmin}
+	client := models.User{Email: "client@delete.test", Role: rbac.RoleClient}
+	leaving := models.User{Email: "leaving@delete.test", Role: rbac.RoleTech}
+	staying := models.User{Email: "staying@delete.test", Role: rbac.RoleTech}
+	now := time.Now()
+	inactive := models.User{Email: "inactive@delete.test", Role: rbac.RoleTech, DeactivatedAt: &now}
+	for _, u := range []*models.User{&admin, &client, &leaving, &staying, &inactive} {
+		config.DB.Create(u)
+	}
+	open := models.Ticket{Title: "Open", Status: TicketStatuses[0], ClientID: client.ID, TechID: &leaving.ID}
+	closed := models.Ticket{Title: "Closed", Status: StatusClosed, ClientID: client.ID, TechID: &leaving.ID}
+	config.DB.Create(&open)
+	config.DB.Create(&closed)
+
+	if _, err := DeleteUser(admin.ID, 0, admin.ID, "127.0.0.1"); !errors.Is(err, ErrDeleteSelf) {
+		t.Fatalf("deleting yourself: got %v", err)
+	}
+	if _, err := DeleteUser(client.ID, 0, admin.ID, "127.0.0.1"); !errors.Is(err, ErrUserHasOpenTickets) {
+		t.Fatalf("deleting a client with open tickets: got %v", err)
+	}
+	if _, err := DeleteUser(leaving.ID, inactive.ID, admin.ID, "127.0.0.1"); !errors.Is(err, ErrInvalidTechnician) {
+		t.Fatalf("reassigning to a deactivated tech: got %v", err)
+	}
+	var count int64
+	config.DB.Model(&models.User{}).Where("id = ?", leaving.ID).Count(&count)
+	if count != 1 {
+		t.Fatal("failed deletion was not rolled back")
+	}
+
+	deletion, err := DeleteUser(leaving.ID, staying.ID, admin.ID, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if deletion.Reassigned != 1 || deletion.Unassigned != 0 {
+		t.Fatalf("deletion = %+v", deletion)
+	}
+	config.DB.First(&open, open.ID)
+	config.DB.First(&closed, closed.ID)
+	if open.TechID == nil || *open.TechID != staying.ID || open.Version != 2 {
+		t.Fatalf("open ticket not reassigned: tech %v, version %d", open.TechID, open.Version)
+	}
+	if closed.TechID == nil || *closed.TechID != leaving.ID {
+		t.Fatal("closed ticket lost its technician")
+	}
+
+	// The client's last open ticket is closed, so they can go now; the tech's ticket is unassigned.
+	config.DB.Model(&open).Update("status", StatusClosed)
+	second := models.Ticket{Title: "Second", Status: TicketStatuses[0], ClientID: admin.ID, TechID: &staying.ID}
+	config.DB.Create(&second)
+	if _, err := DeleteUser(client.ID, 0, admin.ID, "127.0.0.1"); err != nil {
+		t.Fatalf("deleting a client with only closed tickets: %v", err)
+	}
+	if deletion, err = DeleteUser(staying.ID, 0, admin.ID, "127.0.0.1"); err != nil || deletion.Unassigned != 1 {
+		t.Fatalf("unassigning on delete: %+v, %v", deletion, err)
+	}
+	config.DB.First(&second, second.ID)
+	if second.TechID != nil {
+		t.Fatal("ticket still assigned to a deleted tech")
+	}
+}
+
+func TestForeignKeysEnforced(t *testing.T) {
+	testutil.ResetDB(t)
+	client := models.User{Email: "client@fk.test", Role: rbac.RoleClient}
+	tech := models.User{Email: "tech@fk.test", Role: rbac.RoleTech}
+	config.DB.Create(&client)
+	config.DB.Create(&tech)
+	ticket := models.Ticket{Title: "FK", Status: TicketStatuses[0], ClientID: client.ID, TechID: &tech.ID}
+	config.DB.Create(&ticket)
+	comment := models.Comment{TicketID: ticket.ID, AuthorID: tech.ID, AuthorEmail: tech.Email, Content: "On it"}
+	config.DB.Create(&comment)
+
+	if err := config.DB.Create(&models.Ticket{Title: "Orphan", ClientID: client.ID + 1000}).Error; err == nil {
+		t.Fatal("ticket for a missing client was saved")
+	}
+	if err := config.DB.Unscoped().Delete(&client).Error; err == nil {
+		t.Fatal("client with tickets was hard-deleted")
+	}
+	if err := config.DB.Unscoped().Delete(&tech).Error; err != nil {
+		t.Fatalf("hard-deleting the tech: %v", err)
+	}
+	config.DB.First(&ticket, ticket.ID)
+	if ticket.TechID != nil {
+		t.Fatal("tech_id not cleared when the tech was hard-deleted")
+	}
+	if err := config.DB.Unscoped().Delete(&ticket).Error; err != nil {
+		t.Fatalf("hard-deleting the ticket: %v", err)
+	}
+	var count int64
+	config.DB.Unscoped().Model(&models.Comment{}).Where("id = ?", comment.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("comment not removed with its ticket")
+	}
+}
+
+func TestDeleteAccountWithUsers(t *testing.T) {
+	testutil.ResetDB(t)
+	account := models.Account{Name: "Delete Co"}
+	config.DB.Create(&account)
+	member := models.User{Email: "member@deleteco.test", Role: rbac.RoleClient, AccountID: &account.ID}
+	config.DB.Create(&member)
+
+	if err := DeleteAccount(account.ID, 1, "127.0.0.1"); !errors.Is(err, ErrAccountHasUsers) {
+		t.Fatalf("deleting an account with users: got %v", err)
+	}
+	config.DB.Model(&member).Update("account_id", nil)
+	if err := DeleteAccount(account.ID, 1, "127.0.0.1"); err != nil {
+		t.Fatalf("DeleteAccount failed: %v", err)
+	}
+	if err := DeleteAccount(account.ID, 1, "127.0.0.1"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("deleting a trashed account: got %v", err)
+	}
+}
+
+func TestPurgeAccountWithDirectory(t *testing.T) {
+	testutil.ResetDB(t)
+	account := models.Account{Name: "Purge Directory Co"}
+	config.DB.Create(&account)
+	dir := models.Directory{AccountID: account.ID, URL: "ldap://purge.test:389"}
+	config.DB.Create(&dir)
+	client := models.User{Email: "client@purgedirectory.test", Role: rbac.RoleClient}
+	config.DB.Create(&client)
+	ticket := models.Ticket{Title: "Trashed", Status: TicketStatuses[0], ClientID: client.ID}
+	config.DB.Create(&ticket)
+	config.DB.Delete(&ticket)
+
+	if err := DeleteAccount(account.ID, 1, "127.0.0.1"); err != nil {
+		t.Fatalf("DeleteAccount failed: %v", err)
+	}
+	result, err := PurgeTrash(0)
+	if err != nil {
+		t.Fatalf("PurgeTrash failed: %v", err)
+	}
+	if result.Accounts < 1 || result.Tickets < 1 {
+		t.Fatalf("purge left trashed items behind: %+v", result)
+	}
+	var count int64
+	config.DB.Unscoped().Model(&models.Directory{}).Where("id = ?", dir.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("directory not removed with its account")
+	}
+}
+
+func TestSetUserRoleGuards(t *testing.T) {
+	testutil.ResetDB(t)
+	admin := models.User{Email: "admin@setrole.test", Role: rbac.RoleAdmin}
+	manager := models.User{Email: "manager@setrole.test", Role: rbac.RoleTech}
+	client := models.User{Email: "client@setrole.test", Role: rbac.RoleClient}
+	for _, u := range []*models.User{&admin, &manager, &client} {
+		config.DB.Create(u)
+	}
+
+	if err := rbac.SetUserRole(manager.ID, rbac.RoleAdmin, manager.ID); err == nil {
+		t.Fatal("a user promoted themselves to admin")
+	}
+	if err := rbac.SetUserRole(admin.ID, rbac.RoleClient, admin.ID); err == nil {
+		t.Fatal("a user changed their own role")
+	}
+	if err := rbac.SetUserRole(client.ID, rbac.RoleAdmin, manager.ID); err == nil {
+		t.Fatal("a non-admin granted the admin role")
+	}
+	if err := rbac.SetUserRole(admin.ID, rbac.RoleClient, manager.ID); err == nil {
+		t.Fatal("a non-admin removed the admin role")
+	}
+	if err := rbac.SetUserRole(client.ID, rbac.RoleTech, manager.ID); err != nil {
+		t.Fatalf("changing a non-admin role: %v", err)
+	}
+	if err := rbac.SetUserRole(client.ID, rbac.RoleAdmin, admin.ID); err != nil {
+		t.Fatalf("an admin granting the admin role: %v", err)
+	}
+}
+
+func TestRoleChangeAndDeletionEndSessions(t *testing.T) {
+	testutil.ResetDB(t)
+	admin := models.User{Email: "admin@endsession.test", Role: rbac.RoleAdmin}
+	demoted := models.User{Email: "demoted@endsession.test", Role: rbac.RoleTech}
+	deleted := models.User{Email: "deleted@endsession.test", Role: rbac.RoleTech}
+	for _, u := range []*models.User{&admin, &demoted, &deleted} {
+		config.DB.Create(u)
+	}
+	demotedToken, deletedToken := earlierSession(t, demoted), earlierSession(t, deleted)
+
+	if err := rbac.SetUserRole(demoted.ID, rbac.RoleClient, admin.ID); err != nil {
+		t.Fatalf("SetUserRole failed: %v", err)
+	}
+	if _, err := utils.ParseJWT(demotedToken); err == nil {
+		t.Fatal("a session kept its old role after the role was changed")
+	}
+	if _, err := DeleteUser(deleted.ID, 0, admin.ID, "127.0.0.1"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if _, err := utils.ParseJWT(deletedToken); err == nil {
+		t.Fatal("a deleted user's session still works")
+	}
+	if _, err := utils.ParseJWT(earlierSession(t, models.User{ID: 999999, Email: "ghost@endsession.test", Role: rbac.RoleAdmin})); err == nil {
+		t.Fatal("a session for a user who does not exist works")
+	}
+}
+
+func TestSaveRoleGuards(t *testing.T) {
+	testutil.ResetDB(t)
+	admin := models.User{Email: "admin@saverole.test", Role: rbac.RoleAdmin}
+	config.DB.Create(&admin)
+	if err := rbac.SaveRole("rolekeeper", "Edits roles", []rbac.Permission{rbac.RolesManage, rbac.TicketsViewAll}, admin.ID); err != nil {
+		t.Fatalf("an admin saving a role: %v", err)
+	}
+	keeper := models.User{Email: "keeper@saverole.test", Role: "rolekeeper"}
+	config.DB.Create(&keeper)
+
+	if err := rbac.SaveRole("rolekeeper", "", []rbac.Permission{rbac.RolesManage, rbac.SystemManage}, keeper.ID); err == nil {
+		t.Fatal("a user edited the role they hold")
+	}
+	if err := rbac.SaveRole("helper", "", []rbac.Permission{rbac.UsersManage}, keeper.ID); err == nil {
+		t.Fatal("a user granted a permission their role lacks")
+	}
+	if err := rbac.SaveRole("helper", "", []rbac.Permission{rbac.TicketsViewAll}, keeper.ID); err != nil {
+		t.Fatalf("granting a permission the actor holds: %v", err)
+	}
+	if rbac.Can("rolekeeper", rbac.SystemManage) || rbac.Can("helper", rbac.UsersManage) {
+		t.Fatal("a refused permission was granted")
+	}
+}
@@ -2,13 +2,17 @@ package controllers
 
 import (
 	"RyanForce/config"
+	"RyanForce/events"
+	"RyanForce/metrics"
 	"RyanForce/models"
+	"RyanForce/pwpolicy"
+	"RyanForce/rbac"
 	"RyanForce/utils"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"net/http"
-	"regexp"
 	"strings"
 	"time"
 )
@@ -16,15 +20,14 @@ import (
 // Register creates a new user in the system with a hashed password and a specified role.
 // This is typically used by an admin to add a new user manually.
 func Register(email, password, role string) {
-	hash, err := utils.HashPassword(password)
-	if err != nil {
-		utils.LogErrorIP("[Register] Failed to hash password", err, "CLI-Local")
-		fmt.Println("[Error] Failed to hash password.")
+	user := models.User{Email: email, Role: role}
+	if err := pwpolicy.Assign(&user, password); err != nil {
+		utils.LogWarningIP("[Register] Password rejected for "+email+": "+err.Error(), "CLI-Local")
+		fmt.Println("[Error]", err)
 		return
 	}
 
-	user := models.User{Email: email, PasswordHash: hash, Role: role}
-	if err := config.DB.Create(&user).Error; err != nil {
+	if err := createUserWithPassword(&user, "admin"); err != nil {
 		utils.LogErrorIP("[Register] Failed to create user", err, "CLI-Local")
 		fmt.Println("[Error] Failed to create user.")
 		return
@@ -34,69 +37,117 @@ func Register(email, password, role string) {
 	utils.LogInfoIP(fmt.Sprintf("[Register] User created: %s (%s)", user.Email, user.Role), "CLI-Local")
 }
 
-// Authenticate attempts to log in a user by checking their email and password.
+// Authenticate attempts to log in a CLI user by checking their email and password.
 // If successful, it returns a JWT token that can be used for future authenticated actions.
+// It shares Login's rate limiting and lockout policy, using "CLI-Local" as the client IP, which
+// network allowlists and step-up verification do not apply to.
+// For users with MFA it instead returns an MFA challenge together with ErrMFARequired
+// (or ErrMFAEnrollmentRequired), which CompleteMFALogin exchanges for a session token.
 func Authenticate(email, password string) (string, error) {
-	var user models.User
-
 	if config.DB == nil {
 		return "", errors.New("database not connected")
 	}
+	return Login(email, password, "CLI-Local", CLIUserAgent)
+}
 
-	result := config.DB.Where("email = ?", email).First(&user)
-	if result.Error != nil {
-		utils.LogWarningIP(fmt.Sprintf("[Auth] Login failed for unknown email: %s", email), "CLI-Local")
-		return "", errors.New("user not found")
+// Login is used by the WebUI, API, and CLI to validate credentials and return a JWT.
+// It logs all login attempts for auditing purposes. Attempts from throttled IPs fail with a
+// *LockoutError; locked and deactivated accounts fail like a wrong password. Members of roles
+// that must use single sign-on get ErrPasswordLoginDisabled once their password is verified.
+// Logins from outside the user's role or account allowlist get ErrNetworkNotAllowed. Users with
+// MFA get an MFA challenge and ErrMFARequired or ErrMFAEnrollmentRequired instead of a session token, as do users who must
+// confirm an emailed code after repeated failures from a new network (ErrStepUpRequired).
+// userAgent is recorded with the user's known logins.
+func Login(email, password, ip, userAgent string) (string, error) {
+	var user models.User
+	cleanedEmail := strings.TrimSpace(email)
+
+	if err := config.DB.Where("email = ?", cleanedEmail).First(&user).Error; err != nil {
+		if err := checkLoginAllowed(nil, cleanedEmail, ip); err != nil {
+			return "", err
+		}
+		utils.CheckPasswordHash(password, dummyPasswordHash())
+		utils.LogWarningIP("[Login] Failed login: user not found — "+cleanedEmail, ip)
+		recordLoginEvent(nil, cleanedEmail, ip, false, "user not found")
+		return "", errInvalidCredentials
 	}
 
-	if !utils.CheckPasswordHash(password, user.PasswordHash) {
-		utils.LogWarningIP(fmt.Sprintf("[Auth] Invalid password for user: %s", email), "CLI-Local")
-		return "", errors.New("invalid password")
+	if err := checkLoginAllowed(&user, cleanedEmail, ip); err != nil {
+		if errors.Is(err, errInvalidCredentials) {
+			utils.CheckPasswordHash(password, dummyPasswordHash())
+		}
+		return "", err
 	}
 
-	token, err := utils.GenerateJWT(user.ID, user.Email, user.Role)
+	valid, err := checkLoginPassword(&user, password)
 	if err != nil {
-		utils.LogErrorIP("[Auth] Failed to generate token", err, "CLI-Local")
-		return "", errors.New("failed to generate token")
+		recordLoginEvent(&user, cleanedEmail, ip, false, "directory unavailable")
+		return "", err
+	}
+	if !valid {
+		registerFailedAttempt(&user)
+		utils.LogWarningIP("[Login] Failed login: wrong password — "+cleanedEmail, ip)
+		recordLoginEvent(&user, cleanedEmail, ip, false, "invalid password")
+		return "", errInvalidCredentials
 	}
 
-	utils.LogInfoIP(fmt.Sprintf("[Auth] User logged in: %s (%s)", user.Email, user.Role), "CLI-Local")
-	return token, nil
+	if !rbac.PasswordLoginAllowed(user.Role) {
+		utils.LogWarningIP("[Login] Password login disabled for role "+user.Role+" — "+cleanedEmail, ip)
+		recordLoginEvent(&user, cleanedEmail, ip, false, "password login disabled")
+		return "", ErrPasswordLoginDisabled
+	}
+	if err := checkNetworkAllowed(&user, ip); err != nil {
+		return "", err
+	}
+
+	if !user.MFAEnabled && stepUpRequired(&user, ip) {
+		return startStepUp(&user, ip)
+	}
+	if challenge, err := mfaChallengeFor(&user); challenge != "" || err != nil {
+		utils.LogInfoIP("[Login] Password accepted, MFA pending — "+user.Email, ip)
+		return challenge, err
+	}
+
+	return completeLogin(&user, ip, userAgent)
 }
 
-// Login is used by the WebUI and API to validate credentials and return a JWT.
-// It logs all login attempts for auditing purposes.
-func Login(email, password, ip string) (string, error) {
-	var user models.User
-	cleanedEmail := strings.TrimSpace(email)
+// CLIUserAgent is recorded as the user agent of CLI logins.
+const CLIUserAgent = "RyanForce CLI"
 
-	if err := config.DB.Where("email = ?", cleanedEmail).First(&user).Error; err != nil {
-		utils.LogWarningIP("[Login] Failed login: user not found — "+cleanedEmail, ip)
-		return "", fmt.Errorf("invalid credentials")
+// checkLoginPassword verifies a password against the user's directory, or against the local
+// hash for everyone else.
+func checkLoginPassword(user *models.User, password string) (bool, error) {
+	if user.DirectoryID != nil {
+		return checkDirectoryPassword(user, password)
 	}
+	return utils.CheckPasswordHash(password, user.PasswordHash), nil
+}
 
-	if user.IsLocked {
-		utils.LogWarningIP("[Login] Account locked: "+cleanedEmail, ip)
-		return "", fmt.Errorf("account is locked due to repeated failed attempts")
+// completeLogin resets the lockout counter, records the login, and issues a session token.
+// Users whose password has expired get a password change token and ErrPasswordChangeRequired.
+func completeLogin(user *models.User, ip, userAgent string) (string, error) {
+	// Reset failed attempts and the lockout backoff on success
+	user.FailedAttempts = 0
+	user.LockoutCount = 0
+	user.LockedUntil = nil
+	if pwpolicy.Expired(user) {
+		config.DB.Save(user)
+		return passwordChangeFor(user, ip)
 	}
+	return issueSession(user, ip, userAgent)
+}
 
-	if !utils.CheckPasswordHash(password, user.PasswordHash) {
-		user.FailedAttempts++
-		if user.FailedAttempts >= 5 {
-			user.IsLocked = true
-			utils.LogWarning("[Login] Account locked due to too many failed attempts — " + cleanedEmail)
-		}
-		config.DB.Save(&user)
-		utils.LogWarningIP("[Login] Failed login: wrong password — "+cleanedEmail, ip)
-		return "", fmt.Errorf("invalid credentials")
+// issueSession records a successful login and returns a session token. Callers must already
+// have cleared the user's failed attempts. The network allowlists are checked again here so
+// they also cover single sign-on and logins finished from another address.
+func issueSession(user *models.User, ip, userAgent string) (string, error) {
+	if err := checkNetworkAllowed(user, ip); err != nil {
+		return "", err
 	}
 
-	// Reset failed attempts on success
-	user.FailedAttempts = 0
-	user.IsLocked = false
 	now := time.Now()
 	user.LastLogin = &now
-	config.DB.Save(&user)
+	config.DB.Save(user)
 
 	token, err := utils.GenerateJWT(user.ID, user.Email, user.Role)
 	if err != nil {
@@ -104,35 +155,57 @@ func Login(email, password, ip string) (string, error) {
 		return "", fmt.Errorf("token generation failed")
 	}
 
+	recordLoginEvent(user, user.Email, ip, true, "")
+	rememberLogin(user, ip, userAgent)
 	utils.LogInfoIP("[Login] Successful login — "+user.Email, ip)
 	return token, nil
 }
 
-// ResetPassword allows a user to change their password if they provide the correct current password.
-func ResetPassword(email, oldPassword, newPassword string) error {
-	if !isValidPassword(newPassword) {
-		return fmt.Errorf("password must be 8–32 characters and include a capital letter, number, and special character")
+// mfaChallengeFor returns an MFA challenge when the user has MFA enabled, an enrollment challenge
+// when they are required to enroll, and an empty string when the password alone is enough.
+func mfaChallengeFor(user *models.User) (string, error) {
+	if !user.MFAEnabled && !user.MFARequired {
+		return "", nil
 	}
 
+	if user.MFAEnabled {
+		challenge, err := utils.GenerateMFAChallenge(user.ID)
+		if err != nil {
+			utils.LogError("[Login] Failed to generate MFA challenge", err)
+			return "", fmt.Errorf("token generation failed")
+		}
+		return challenge, ErrMFARequired
+	}
+	challenge, err := utils.GenerateMFAEnrollmentChallenge(user.ID)
+	if err != nil {
+		utils.LogError("[Login] Failed to generate MFA enrollment challenge", err)
+		return "", fmt.Errorf("token generation failed")
+	}
+	return challenge, ErrMFAEnrollmentRequired
+}
+
+// ResetPassword allows a user to change their password if they provide the correct current password.
+func ResetPassword(email, oldPassword, newPassword string) error {
 	var user models.User
 	if err := config.DB.First(&user, "email = ?", email).Error; err != nil {
 		utils.LogWarningIP(fmt.Sprintf("[Reset] User not found: %s", email), "CLI-Local")
 		return fmt.Errorf("user not found")
 	}
 
+	if user.DirectoryID != nil {
+		return pwpolicy.ErrDirectoryManaged
+	}
 	if !utils.CheckPasswordHash(oldPassword, user.PasswordHash) {
 		utils.LogWarningIP(fmt.Sprintf("[Reset] Incorrect old password for %s", email), "CLI-Local")
 		return fmt.Errorf("old password is incorrect")
 	}
 
-	hash, err := utils.HashPassword(newPassword)
-	if err != nil {
-		utils.LogErrorIP("[Reset] Failed to hash new password", err, "CLI-Local")
-		return fmt.Errorf("failed to hash new password")
+	if err := pwpolicy.Assign(&user, newPassword); err != nil {
+		utils.LogWarningIP(fmt.Sprintf("[Reset] New password rejected for %s: %s", email, err), "CLI-Local")
+		return err
 	}
 
-	user.PasswordHash = hash
-	if err := config.DB.Save(&user).Error; err != nil {
+	if err := saveUserWithPassword(config.DB, &user); err != nil {
 		utils.LogErrorIP("[Reset] Failed to update password", err, "CLI-Local")
 		return fmt.Errorf("failed to update password")
 	}
@@ -143,24 +216,18 @@ func ResetPassword(email, oldPassword, newPassword string) error {
 
 // AdminResetPassword allows an administrator to reset another user's password.
 func AdminResetPassword(adminID uint, userEmail, newPassword string) error {
-	if !isValidPassword(newPassword) {
-		return fmt.Errorf("password must be 8–32 characters and include a capital letter, number, and special character")
-	}
-
 	var user models.User
 	if err := config.DB.First(&user, "email = ?", userEmail).Error; err != nil {
 		utils.LogWarningIP(fmt.Sprintf("[AdminReset] Target user not found: %s", userEmail), "CLI-Local")
 		return fmt.Errorf("user not found")
 	}
 
-	hash, err := utils.HashPassword(newPassword)
-	if err != nil {
-		utils.LogErrorIP("[AdminReset] Failed to hash new password", err, "CLI-Local")
-		return fmt.Errorf("failed to hash new password")
+	if err := pwpolicy.Assign(&user, newPassword); err != nil {
+		utils.LogWarningIP(fmt.Sprintf("[AdminReset] New password rejected for %s: %s", userEmail, err), "CLI-Local")
+		return err
 	}
 
-	user.PasswordHash = hash
-	if err := config.DB.Save(&user).Error; err != nil {
+	if err := saveUserWithPassword(config.DB, &user); err != nil {
 		utils.LogErrorIP(fmt.Sprintf("[AdminReset] Failed to update password for %s", userEmail), err, "CLI-Local")
 		return fmt.Errorf("failed to update password")
 	}
@@ -169,12 +236,69 @@ func AdminResetPassword(adminID uint, userEmail, newPassword string) error {
 	return nil
 }
 
+// CreateUser validates the password against the password policy and creates the user with it.
+// It is used by every path that creates a user with a password; source says which, as in
+// events.UserCreated.
+func CreateUser(user *models.User, password, source string) error {
+	if err := pwpolicy.Assign(user, password); err != nil {
+		return err
+	}
+	if err := createUserWithPassword(user, source); err != nil {
+		utils.LogError("[Register] Failed to create user "+user.Email, err)
+		return fmt.Errorf("failed to create user")
+	}
+	return nil
+}
+
+// createUserWithPassword inserts a user whose password was set by pwpolicy.Assign and starts
+// their password history.
+func createUserWithPassword(user *models.User, source string) error {
+	return events.Transaction(config.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if err := pwpolicy.Remember(tx, user); err != nil {
+			return err
+		}
+		emit(events.UserCreated{UserID: user.ID, Role: user.Role, Source: source})
+		return nil
+	})
+}
+
+// createUser inserts a user who signs in without a RyanForce password, through SSO, a
+// directory, or a SCIM-provisioned identity provider.
+func createUser(user *models.User, source string) error {
+	return events.Transaction(config.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		emit(events.UserCreated{UserID: user.ID, Role: user.Role, Source: source})
+		return nil
+	})
+}
+
+// saveUserWithPassword stores a password set by pwpolicy.Assign and adds it to the history. The
+// user's existing sessions are revoked, so whoever knew the old password is signed out.
+func saveUserWithPassword(db *gorm.DB, user *models.User) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"password_hash":       user.PasswordHash,
+			"password_changed_at": user.PasswordChangedAt,
+			"sessions_revoked_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return pwpolicy.Remember(tx, user)
+	})
+}
+
 // LoginAPI handles JSON-based login and returns a JWT on success.
 // This is used by CLI clients or API.
 func LoginAPI(c *gin.Context) {
 	type LoginRequest struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		OTP      string `json:"otp"` // Optional TOTP or recovery code for MFA users
 	}
 
 	var req LoginRequest
@@ -187,7 +311,40 @@ func LoginAPI(c *gin.Context) {
 	ip := c.ClientIP()
 	utils.LogInfoIP("[API] Login attempt — "+req.Email, ip)
 
-	token, err := Login(req.Email, req.Password, ip)
+	token, err := Login(req.Email, req.Password, ip, c.Request.UserAgent())
+	if errors.Is(err, ErrStepUpRequired) {
+		// The emailed code cannot have been known when the request was sent, so ignore otp
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Verification code required; a code was emailed to you",
+			"mfa_required": true, "step_up_required": true, "mfa_token": token})
+		return
+	}
+	if errors.Is(err, ErrMFARequired) {
+		if req.OTP == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "MFA code required", "mfa_required": true, "mfa_token": token})
+			return
+		}
+		token, err = CompleteMFALogin(token, req.OTP, ip, c.Request.UserAgent())
+	}
+	if errors.Is(err, ErrMFAEnrollmentRequired) {
+		utils.LogWarningIP("[API] Login blocked until MFA enrollment — "+req.Email, ip)
+		c.JSON(http.StatusForbidden, gin.H{"error": "MFA enrollment required; sign in to the WebUI or CLI to enroll", "mfa_enrollment_required": true})
+		return
+	}
+	if respondLockout(c, err) || respondPasswordChange(c, token, err) {
+		return
+	}
+	if errors.Is(err, ErrPasswordLoginDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Password login is disabled for this account; use single sign-on in the WebUI"})
+		return
+	}
+	if errors.Is(err, errDirectoryUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrNetworkNotAllowed) || errors.Is(err, ErrStepUpUnavailable) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		utils.LogWarningIP("[API] Login failed — "+req.Email, ip)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
@@ -198,12 +355,12 @@ func LoginAPI(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"token": token})
 }
 
-// RegisterAPI creates a new user entry with hashed password.
+// RegisterAPI creates a new user entry with hashed password. Anyone can call it, so the new user
+// is always a client; other roles are granted by someone who manages users.
 func RegisterAPI(c *gin.Context) {
 	type RegisterRequest struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
-		Role     string `json:"role"`
 	}
 
 	var req RegisterRequest
@@ -212,19 +369,16 @@ func RegisterAPI(c *gin.Context) {
 		return
 	}
 
-	hash, err := utils.HashPassword(req.Password)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
-		return
-	}
-
 	user := models.User{
-		Email:        req.Email,
-		PasswordHash: hash,
-		Role:         req.Role,
+		Email: req.Email,
+		Role:  rbac.RoleClient,
+	}
+	if err := pwpolicy.Assign(&user, req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
+	if err := createUserWithPassword(&user, "register"); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
@@ -232,14 +386,21 @@ func RegisterAPI(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
 }
 
-// IsValidPassword checks for minimum password complexity
-func isValidPassword(pw string) bool {
-	if len(pw) < 8 || len(pw) > 32 {
-		return false
-	}
-	hasUpper := regexp.MustCompile(`[A-Z]`).MatchString(pw)
-	hasDigit := regexp.MustCompile(`[0-9]`).MatchString(pw)
-	hasSpecial := regexp.MustCompile(`[!@#%^&*()_+\-=\[\]{};':"\\|,.<>/?]`).MatchString(pw)
+// recordLoginEvent stores a login attempt in the login history.
+// Failures to record are logged but never block the login itself.
+func recordLoginEvent(user *models.User, email, ip string, success bool, reason string) {
+	metrics.RecordLogin(success, reason)
 
-	return hasUpper && hasDigit && hasSpecial
+	event := models.LoginEvent{
+		Email:   email,
+		IP:      ip,
+		Success: success,
+		Reason:  reason,
+	}
+	if user != nil {
+		event.UserID = &user.ID
+	}
+	if err := config.DB.Create(&event).Error; err != nil {
+		utils.LogError("[Login] Failed to record login event", err)
+	}
 }
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/keyring"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// earlierSession returns a session token for the user issued a few seconds ago, before any
+// revocation the test goes on to make.
+func earlierSession(t *testing.T, user models.User) string {
+	t.Helper()
+	claims := &utils.Claims{UserID: user.ID, Email: user.Email, Role: user.Role, RegisteredClaims: jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(time.Now().Add(-2 * time.Second)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	token, err := keyring.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRegisterAlwaysCreatesClients(t *testing.T) {
+	testutil.ResetDB(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/register", RegisterAPI)
+
+	body := `{"email":"eve@register.test","password":"Str0ng!Pass","role":"admin"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: %d %s", w.Code, w.Body)
+	}
+
+	var user models.User
+	config.DB.First(&user, "email = ?", "eve@register.test")
+	if user.Role != "client" {
+		t.Fatalf("self-registration created a %s", user.Role)
+	}
+}
+
+func TestPasswordChangesRevokeSessions(t *testing.T) {
+	testutil.ResetDB(t)
+	user := models.User{Email: "changer@password.test", Role: "client"}
+	if err := CreateUser(&user, "First!Pass1", "admin"); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	token := earlierSession(t, user)
+	if err := ResetPassword(user.Email, "First!Pass1", "Second!Pass2"); err != nil {
+		t.Fatalf("ResetPassword failed: %v", err)
+	}
+	if _, err := utils.ParseJWT(token); err == nil {
+		t.Fatal("a session survived the user changing their password")
+	}
+
+	config.DB.Model(&user).Update("sessions_revoked_at", nil)
+	token = earlierSession(t, user)
+	if err := AdminResetPassword(1, user.Email, "Third!Pass3"); err != nil {
+		t.Fatalf("AdminResetPassword failed: %v", err)
+	}
+	if _, err := utils.ParseJWT(token); err == nil {
+		t.Fatal("a session survived an admin resetting the password")
+	}
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrEditConflict is wrapped by a ConflictError, for checking with errors.Is.
+var ErrEditConflict = errors.New("changed by someone else")
+
+// ErrVersionRequired is returned for an edit that does not say which version it is based on.
+// Every edit must, so none can overwrite a change its author never saw.
+var ErrVersionRequired = errors.New("the version this change is based on is required; reload and try again")
+
+// FieldChange is a field an edit would set to a different value than the one now saved.
+type FieldChange struct {
+	Field   string `json:"field"`
+	Yours   string `json:"yours"`
+	Current string `json:"current"`
+}
+
+// ConflictError is returned when an edit was based on an older version of a ticket or comment
+// than the one saved, so applying it would silently undo someone else's change.
+type ConflictError struct {
+	Resource       string // "ticket" or "comment"
+	ID             uint
+	CurrentVersion uint          // The version now saved
+	Current        interface{}   // The saved *models.Ticket or *models.Comment
+	Changes        []FieldChange // Fields the edit sets that now hold something else
+}
+
+// Error implements error.
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %d was changed by someone else since you loaded it (now version %d); review it and try again",
+		e.Resource, e.ID, e.CurrentVersion)
+}
+
+// Unwrap lets errors.Is match ErrEditConflict.
+func (e *ConflictError) Unwrap() error {
+	return ErrEditConflict
+}
+
+// Diff describes the conflicting fields on one line each, for the CLI and WebUI.
+func (e *ConflictError) Diff() []string {
+	lines := make([]string, 0, len(e.Changes))
+	for _, ch := range e.Changes {
+		lines = append(lines, fmt.Sprintf("%s: yours %q, current %q", ch.Field, ch.Yours, ch.Current))
+	}
+	return lines
+}
+
+// Summary is the error followed by its diff, on one line, for flash messages.
+func (e *ConflictError) Summary() string {
+	if len(e.Changes) == 0 {
+		return e.Error() + "."
+	}
+	return e.Error() + ". " + strings.Join(e.Diff(), "; ")
+}
+
+// addChange records a field whose value in the edit differs from the saved one.
+func addChange(changes []FieldChange, field, yours, current string) []FieldChange {
+	if yours == current {
+		return changes
+	}
+	return append(changes, FieldChange{Field: field, Yours: yours, Current: current})
+}
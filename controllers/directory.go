@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/directory"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errDirectoryUnavailable is shown when a directory user signs in while their directory is down.
+var errDirectoryUnavailable = errors.New("your directory is unavailable, try again later")
+
+// DirectorySyncReport describes what one directory sync changed.
+type DirectorySyncReport struct {
+	AccountID   uint
+	Entries     int      // Users the directory returned
+	Created     []string // Emails of newly provisioned or linked users
+	Updated     []string // Users whose name or email changed
+	Deactivated []string // Users no longer in the directory
+	Reactivated []string // Deactivated users who are back in the directory
+	Skipped     []string // Entries that could not be synced, with the reason
+}
+
+// ListDirectories returns every configured directory with its account, for display. Bind
+// passwords are blanked; SyncDirectory loads the directory it binds with itself.
+func ListDirectories() ([]models.Directory, error) {
+	var directories []models.Directory
+	err := config.DB.Preload("Account").Order("account_id").Find(&directories).Error
+	for i := range directories {
+		directories[i].BindPassword = ""
+	}
+	return directories, err
+}
+
+// SaveDirectory creates or replaces the directory for d.AccountID. Empty attribute names fall
+// back to the OpenLDAP defaults. An empty bind password keeps the saved one.
+func SaveDirectory(d models.Directory) error {
+	var account models.Account
+	if err := config.DB.First(&account, d.AccountID).Error; err != nil {
+		return fmt.Errorf("account not found")
+	}
+	u, err := url.Parse(d.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return fmt.Errorf("directory URL must look like ldap://host:389 or ldaps://host:636")
+	}
+	if strings.TrimSpace(d.BaseDN) == "" {
+		return fmt.Errorf("a base DN is required")
+	}
+
+	var existing models.Directory
+	if err := config.DB.Where("account_id = ?", d.AccountID).Limit(1).Find(&existing).Error; err != nil {
+		return fmt.Errorf("failed to load directory: %w", err)
+	}
+	if d.BindPassword == "" {
+		d.BindPassword = existing.BindPassword
+	}
+	d.Model = existing.Model
+	d.LastSyncAt = existing.LastSyncAt
+	d.LastSyncError = existing.LastSyncError
+	if d.UserFilter == "" {
+		d.UserFilter = "(objectClass=person)"
+	}
+	if d.IDAttribute == "" {
+		d.IDAttribute = "entryUUID"
+	}
+	if d.EmailAttribute == "" {
+		d.EmailAttribute = "mail"
+	}
+	if d.NameAttribute == "" {
+		d.NameAttribute = "cn"
+	}
+
+	if err := config.DB.Save(&d).Error; err != nil {
+		return fmt.Errorf("failed to save directory: %w", err)
+	}
+	utils.LogAudit(fmt.Sprintf("[Directory] Directory for account %d (%s) set to %s, base %s, filter %s%s",
+		account.ID, account.Name, d.URL, d.BaseDN, d.UserFilter, d.GroupFilter))
+	return nil
+}
+
+// DeleteDirectory removes an account's directory. Its users are kept but detached, so they
+// have no password until an admin resets one.
+func DeleteDirectory(accountID uint) error {
+	var d models.Directory
+	if err := config.DB.Where("account_id = ?", accountID).First(&d).Error; err != nil {
+		return fmt.Errorf("no directory configured for account %d", accountID)
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("directory_id = ?", d.ID).
+			Updates(map[string]interface{}{"directory_id": nil, "directory_uid": ""}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&d).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete directory: %w", err)
+	}
+	utils.LogAudit(fmt.Sprintf("[Directory] Directory for account %d removed", accountID))
+	return nil
+}
+
+// SyncDirectory pulls the users of an account's directory and creates, updates, deactivates,
+// or reactivates client users to match. The outcome is saved on the directory.
+func SyncDirectory(accountID uint) (DirectorySyncReport, error) {
+	var d models.Directory
+	if err := config.DB.Where("account_id = ?", accountID).First(&d).Error; err != nil {
+		return DirectorySyncReport{}, fmt.Errorf("no directory configured for account %d", accountID)
+	}
+
+	entries, err := directory.Entries(&d)
+	if err == nil {
+		var report DirectorySyncReport
+		report, err = applyDirectoryEntries(&d, entries)
+		if err == nil {
+			recordDirectorySync(&d, "")
+			return report, nil
+		}
+	}
+	utils.LogError(fmt.Sprintf("[Directory] Sync failed for account %d", accountID), err)
+	recordDirectorySync(&d, err.Error())
+	return DirectorySyncReport{AccountID: accountID}, err
+}
+
+// applyDirectoryEntries reconciles the directory's users with entries. New entries become
+// client users of the directory's account; an existing local client of the same account with
+// the same email is linked instead. Linked users missing from entries are deactivated and
+// their sessions revoked. An empty result never deactivates anyone, since it more likely
+// means a broken filter than an empty directory.
+func applyDirectoryEntries(d *models.Directory, entries []directory.Entry) (DirectorySyncReport, error) {
+	report := DirectorySyncReport{AccountID: d.AccountID, Entries: len(entries)}
+
+	var linked []models.User
+	if err := config.DB.Where("directory_id = ?", d.ID).Find(&linked).Error; err != nil {
+		return report, fmt.Errorf("failed to load directory users: %w", err)
+	}
+	byUID := make(map[string]*models.User, len(linked))
+	for i := range linked {
+		byUID[linked[i].DirectoryUID] = &linked[i]
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if seen[e.UID] {
+			continue
+		}
+		seen[e.UID] = true
+
+		user := byUID[e.UID]
+		if user == nil {
+			if msg := provisionDirectoryUser(d, e); msg != "" {
+				report.Skipped = append(report.Skipped, msg)
+			} else {
+				report.Created = append(report.Created, e.Email)
+			}
+			continue
+		}
+
+		updates := map[string]interface{}{}
+		if e.Name != "" && e.Name != user.Name {
+			updates["name"] = e.Name
+		}
+		if e.Email != strings.ToLower(user.Email) {
+			if emailTaken(e.Email, user.ID) {
+				report.Skipped = append(report.Skipped, e.Email+": email belongs to another user")
+			} else {
+				updates["email"] = e.Email
+			}
+		}
+		changed := len(updates) > 0
+		if user.DeactivatedAt != nil {
+			updates["deactivated_at"] = nil
+			report.Reactivated = append(report.Reactivated, e.Email)
+			utils.LogAudit(fmt.Sprintf("[Directory] Reactivated user %d (%s)", user.ID, e.Email))
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		if err := config.DB.Model(user).Updates(updates).Error; err != nil {
+			return report, fmt.Errorf("failed to update user %d: %w", user.ID, err)
+		}
+		if changed {
+			report.Updated = append(report.Updated, e.Email)
+		}
+	}
+
+	if len(entries) == 0 {
+		return report, nil
+	}
+	for i := range linked {
+		user := &linked[i]
+		if seen[user.DirectoryUID] || user.DeactivatedAt != nil {
+			continue
+		}
+		if err := config.DB.Model(user).Updates(map[string]interface{}{
+			"deactivated_at":      now,
+			"sessions_revoked_at": now,
+		}).Error; err != nil {
+			return report, fmt.Errorf("failed to deactivate user %d: %w", user.ID, err)
+		}
+		report.Deactivated = append(report.Deactivated, user.Email)
+		utils.LogAudit(fmt.Sprintf("[Directory] Deactivated user %d (%s), no longer in the directory", user.ID, user.Email))
+	}
+
+	utils.LogAudit(fmt.Sprintf("[Directory] Sync for account %d: %d entries, %d created, %d updated, %d deactivated, %d reactivated, %d skipped",
+		d.AccountID, len(entries), len(report.Created), len(report.Updated), len(report.Deactivated), len(report.Reactivated), len(report.Skipped)))
+	return report, nil
+}
+
+// provisionDirectoryUser links or creates the user for a new directory entry. It returns a
+// reason when the entry had to be skipped.
+func provisionDirectoryUser(d *models.Directory, e directory.Entry) string {
+	var user models.User
+	if err := config.DB.Where("LOWER(email) = ?", e.Email).Limit(1).Find(&user).Error; err != nil {
+		return e.Email + ": lookup failed"
+	}
+
+	if user.ID != 0 {
+		if user.DirectoryID != nil || user.Role != rbac.RoleClient ||
+			user.AccountID == nil || *user.AccountID != d.AccountID {
+			return e.Email + ": email belongs to a user outside this directory"
+		}
+		if err := config.DB.Model(&user).Updates(map[string]interface{}{
+			"directory_id": d.ID, "directory_uid": e.UID, "deactivated_at": nil,
+		}).Error; err != nil {
+			return e.Email + ": failed to link user"
+		}
+		utils.LogAudit(fmt.Sprintf("[Directory] Linked existing user %d (%s) to the account %d directory", user.ID, e.Email, d.AccountID))
+		return ""
+	}
+
+	accountID := d.AccountID
+	user = models.User{
+		Email:        e.Email,
+		Name:         e.Name,
+		Role:         rbac.RoleClient,
+		AccountID:    &accountID,
+		DirectoryID:  &d.ID,
+		DirectoryUID: e.UID,
+	}
+	if err := createUser(&user, "directory"); err != nil {
+		utils.LogError("[Directory] Failed to provision user "+e.Email, err)
+		return e.Email + ": failed to create user"
+	}
+	utils.LogAudit(fmt.Sprintf("[Directory] Provisioned user %d (%s) for account %d", user.ID, e.Email, d.AccountID))
+	return ""
+}
+
+// emailTaken reports whether a user other than userID already has email.
+func emailTaken(email string, userID uint) bool {
+	var count int64
+	config.DB.Model(&models.User{}).Where("LOWER(email) = ? AND id <> ?", email, userID).Count(&count)
+	return count > 0
+}
+
+// recordDirectorySync saves the time and error of the latest sync on the directory.
+func recordDirectorySync(d *models.Directory, syncErr string) {
+	config.DB.Model(d).Updates(map[string]interface{}{"last_sync_at": time.Now(), "last_sync_error": syncErr})
+}
+
+// checkDirectoryPassword verifies a directory user's password with an LDAP bind. It returns
+// false for a wrong password and errDirectoryUnavailable when the directory cannot be reached.
+func checkDirectoryPassword(user *models.User, password string) (bool, error) {
+	var d models.Directory
+	if err := config.DB.First(&d, *user.DirectoryID).Error; err != nil {
+		return false, nil
+	}
+
+	entry, err := directory.Authenticate(&d, user.Email, password)
+	if errors.Is(err, directory.ErrInvalidCredentials) {
+		return false, nil
+	}
+	if err != nil {
+		utils.LogError(fmt.Sprintf("[Directory] Authentication against account %d directory failed", d.AccountID), err)
+		return false, errDirectoryUnavailable
+	}
+	return entry.UID == user.DirectoryUID, nil
+}
+
+// PrintDirectorySyncReport writes a directory sync report to the CLI.
+func PrintDirectorySyncReport(report DirectorySyncReport) {
+	fmt.Printf("\nDirectory Sync Results (account %d)\n", report.AccountID)
+	fmt.Println("------------------------------")
+	fmt.Printf("Directory entries : %d\n", report.Entries)
+	fmt.Printf("Created           : %d %v\n", len(report.Created), report.Created)
+	fmt.Printf("Updated           : %d %v\n", len(report.Updated), report.Updated)
+	fmt.Printf("Deactivated       : %d %v\n", len(report.Deactivated), report.Deactivated)
+	fmt.Printf("Reactivated       : %d %v\n", len(report.Reactivated), report.Reactivated)
+	for _, s := range report.Skipped {
+		fmt.Println("Skipped           :", s)
+	}
+}
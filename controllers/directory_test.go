@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/directory"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"testing"
+)
+
+func TestApplyDirectoryEntries(t *testing.T) {
+	testutil.ResetDB(t)
+	account := models.Account{Name: "Directory Co", Domain: "directory.test"}
+	config.DB.Create(&account)
+	d := models.Directory{AccountID: account.ID, URL: "ldap://localhost:389", BaseDN: "ou=people,dc=directory,dc=test"}
+	config.DB.Create(&d)
+
+	local := models.User{Email: "local@directory.test", Name: "Local", Role: rbac.RoleClient, AccountID: &account.ID}
+	outsider := models.User{Email: "tech@directory.test", Name: "Tech", Role: rbac.RoleTech}
+	config.DB.Create(&local)
+	config.DB.Create(&outsider)
+
+	report, err := applyDirectoryEntries(&d, []directory.Entry{
+		{UID: "u1", Email: "ann@directory.test", Name: "Ann"},
+		{UID: "u2", Email: "local@directory.test", Name: "Local"},
+		{UID: "u3", Email: "tech@directory.test", Name: "Tech"},
+	})
+	if err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	if len(report.Created) != 2 || len(report.Skipped) != 1 {
+		t.Fatalf("first sync: created %v, skipped %v", report.Created, report.Skipped)
+	}
+
+	var ann models.User
+	config.DB.Where("email = ?", "ann@directory.test").First(&ann)
+	if ann.Role != rbac.RoleClient || ann.AccountID == nil || *ann.AccountID != account.ID || ann.DirectoryID == nil {
+		t.Fatalf("provisioned user not a directory client of the account: %+v", ann)
+	}
+	config.DB.First(&local, local.ID)
+	if local.DirectoryUID != "u2" {
+		t.Fatalf("existing client was not linked, uid %q", local.DirectoryUID)
+	}
+	config.DB.First(&outsider, outsider.ID)
+	if outsider.DirectoryID != nil {
+		t.Fatal("user outside the account was linked")
+	}
+
+	// Ann is renamed and the linked local user leaves the directory.
+	report, err = applyDirectoryEntries(&d, []directory.Entry{
+		{UID: "u1", Email: "ann.new@directory.test", Name: "Ann New"},
+	})
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if len(report.Updated) != 1 || len(report.Deactivated) != 1 {
+		t.Fatalf("second sync: updated %v, deactivated %v", report.Updated, report.Deactivated)
+	}
+	config.DB.First(&ann, ann.ID)
+	if ann.Email != "ann.new@directory.test" || ann.Name != "Ann New" {
+		t.Fatalf("user not updated: %s %s", ann.Email, ann.Name)
+	}
+	config.DB.First(&local, local.ID)
+	if local.DeactivatedAt == nil || local.SessionsRevokedAt == nil {
+		t.Fatal("missing user was not deactivated")
+	}
+	if err := checkLoginAllowed(&local, local.Email, "127.0.0.1"); err == nil {
+		t.Fatal("deactivated user was allowed to log in")
+	}
+
+	// An empty result deactivates nobody.
+	if report, _ = applyDirectoryEntries(&d, nil); len(report.Deactivated) != 0 {
+		t.Fatalf("empty sync deactivated %v", report.Deactivated)
+	}
+
+	// The local user returns.
+	report, _ = applyDirectoryEntries(&d, []directory.Entry{
+		{UID: "u1", Email: "ann.new@directory.test", Name: "Ann New"},
+		{UID: "u2", Email: "local@directory.test", Name: "Local"},
+	})
+	if len(report.Reactivated) != 1 {
+		t.Fatalf("reactivated %v", report.Reactivated)
+	}
+}
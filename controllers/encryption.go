@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/fieldcrypt"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrEncryptionOff is returned by ReencryptFields when no master key is configured.
+var ErrEncryptionOff = errors.New("field encryption is off; set RYANFORCE_MASTER_KEY to turn it on")
+
+// ReencryptReport summarises a re-encryption run.
+type ReencryptReport struct {
+	DataKeyID string           // The new current data key
+	Rewrapped int              // Older data keys moved under the current master key
+	Rows      map[string]int64 // Rows rewritten per table
+}
+
+// InitFieldEncryption loads the data keys at startup, creating the first one when a master key
+// is configured. Without a master key sensitive fields are stored in the clear, which is refused
+// once any data key exists so encrypted data is never silently left unreadable.
+func InitFieldEncryption() error {
+	settings, err := config.LoadEncryptionSettings()
+	if err != nil {
+		return err
+	}
+	if settings.MasterKey == nil {
+		var count int64
+		if err := config.DB.Model(&models.DataKey{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("the database holds encrypted fields but RYANFORCE_MASTER_KEY is not set")
+		}
+		utils.LogWarning("[Encryption] RYANFORCE_MASTER_KEY is not set; sensitive fields are stored unencrypted")
+		return fieldcrypt.Install("", nil)
+	}
+
+	current, keys, err := loadDataKeys(settings)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		if current, err = createDataKey(settings); err != nil {
+			return err
+		}
+		if _, keys, err = loadDataKeys(settings); err != nil {
+			return err
+		}
+		utils.LogAudit(fmt.Sprintf("[Encryption] Created data key %s wrapped by master key %s", current, settings.MasterKeyID))
+	}
+	if err := fieldcrypt.Install(current, keys); err != nil {
+		return err
+	}
+	fieldcrypt.OnUnknownKey(reloadDataKeys)
+	return nil
+}
+
+// reloadDataKeys picks up data keys created by another process, such as reencrypt-fields run
+// from the CLI while the web server is up.
+func reloadDataKeys() error {
+	settings, err := config.LoadEncryptionSettings()
+	if err != nil {
+		return err
+	}
+	current, keys, err := loadDataKeys(settings)
+	if err != nil {
+		return err
+	}
+	return fieldcrypt.Install(current, keys)
+}
+
+// loadDataKeys unwraps every stored data key with the master key that wrapped it. It returns
+// the ID of the current (newest unretired) key and the keys by ID.
+func loadDataKeys(settings config.EncryptionSettings) (string, map[string][]byte, error) {
+	var rows []models.DataKey
+	if err := config.DB.Order("created_at DESC").Find(&rows).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to load data keys: %w", err)
+	}
+
+	current := ""
+	keys := make(map[string][]byte, len(rows))
+	for _, row := range rows {
+		master := settings.PreviousMasterKeys[row.MasterKeyID]
+		if row.MasterKeyID == settings.MasterKeyID {
+			master = settings.MasterKey
+		}
+		if master == nil {
+			return "", nil, fmt.Errorf("data key %s is wrapped by master key %q, which is not configured", row.ID, row.MasterKeyID)
+		}
+		key, err := fieldcrypt.Unwrap(master, row.WrappedKey)
+		if err != nil {
+			return "", nil, fmt.Errorf("cannot unwrap data key %s with master key %q; is the key correct?", row.ID, row.MasterKeyID)
+		}
+		keys[row.ID] = key
+		if current == "" && row.RetiredAt == nil {
+			current = row.ID
+		}
+	}
+	return current, keys, nil
+}
+
+// createDataKey stores a new data key wrapped by the current master key and retires the others.
+func createDataKey(settings config.EncryptionSettings) (string, error) {
+	id, key, err := fieldcrypt.NewKey()
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := fieldcrypt.Wrap(settings.MasterKey, key)
+	if err != nil {
+		return "", err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.DataKey{}).Where("retired_at IS NULL").Update("retired_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.DataKey{ID: id, MasterKeyID: settings.MasterKeyID, WrappedKey: wrapped}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store data key: %w", err)
+	}
+	return id, nil
+}
+
+// ReencryptFields rotates field encryption: it makes a new current data key, moves every older
+// data key under the current master key (so a retired master key can then be removed from
+// RYANFORCE_PREVIOUS_MASTER_KEYS), and rewrites every encrypted field with the new data key.
+// Values stored before encryption was turned on are encrypted too. Retired data keys are kept
+// so values written meanwhile by a server that has not been restarted stay readable.
+func ReencryptFields(actorID uint) (*ReencryptReport, error) {
+	settings, err := config.LoadEncryptionSettings()
+	if err != nil {
+		return nil, err
+	}
+	if settings.MasterKey == nil {
+		return nil, ErrEncryptionOff
+	}
+	_, keys, err := loadDataKeys(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReencryptReport{Rows: map[string]int64{}}
+	var stale []models.DataKey
+	config.DB.Where("master_key_id <> ?", settings.MasterKeyID).Find(&stale)
+	for _, row := range stale {
+		wrapped, err := fieldcrypt.Wrap(settings.MasterKey, keys[row.ID])
+		if err != nil {
+			return nil, err
+		}
+		if err := config.DB.Model(&row).Updates(models.DataKey{MasterKeyID: settings.MasterKeyID, WrappedKey: wrapped}).Error; err != nil {
+			return nil, fmt.Errorf("failed to rewrap data key %s: %w", row.ID, err)
+		}
+		report.Rewrapped++
+	}
+
+	if report.DataKeyID, err = createDataKey(settings); err != nil {
+		return nil, err
+	}
+	if err := reloadDataKeys(); err != nil {
+		return nil, err
+	}
+
+	tables := []struct {
+		name    string
+		rewrite func() (int64, error)
+	}{
+		{"tickets", func() (int64, error) { return rewriteEncrypted("tickets", "description") }},
+		{"comments", func() (int64, error) { return rewriteEncrypted("comments", "content") }},
+		{"accounts", func() (int64, error) { return rewriteEncrypted("accounts", "notes") }},
+		{"directories", func() (int64, error) { return rewriteEncrypted("directories", "bind_password") }},
+	}
+	for _, t := range tables {
+		count, err := t.rewrite()
+		report.Rows[t.name] = count
+		if err != nil {
+			return report, fmt.Errorf("re-encrypting %s: %w", t.name, err)
+		}
+	}
+
+	utils.LogAudit(fmt.Sprintf("[Encryption] User %d re-encrypted fields with data key %s (rewrapped %d keys; tickets %d, comments %d, accounts %d, directories %d)",
+		actorID, report.DataKeyID, report.Rewrapped, report.Rows["tickets"], report.Rows["comments"], report.Rows["accounts"], report.Rows["directories"]))
+	return report, nil
+}
+
+// encryptedValue is a row's stored, still encrypted, value of one column.
+type encryptedValue struct {
+	ID    uint
+	Value *string
+}
+
+// rewriteEncrypted re-encrypts one column of every row in a table, trashed rows included, with the
+// current data key. It works on the stored values rather than loading models, so timestamps are
+// left alone and each value can be rewritten only while it is still the one that was read.
+func rewriteEncrypted(table, column string) (int64, error) {
+	var count int64
+	var lastID uint
+	for {
+		var rows []encryptedValue
+		if err := config.DB.Table(table).Select("id", column+" AS value").
+			Where("id > ?", lastID).Order("id").Limit(200).Scan(&rows).Error; err != nil {
+			return count, err
+		}
+		if len(rows) == 0 {
+			return count, nil
+		}
+		for _, row := range rows {
+			if err := rewriteEncryptedValue(table, column, row); err != nil {
+				return count, err
+			}
+			count++
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// rewriteEncryptedValue re-encrypts one stored value. If the row was edited since it was read the
+// update matches nothing, so the edit is kept; the new value is read and re-encrypted instead.
+func rewriteEncryptedValue(table, column string, row encryptedValue) error {
+	context := table + "." + column
+	for attempt := 0; attempt < 5; attempt++ {
+		if row.Value == nil || *row.Value == "" {
+			return nil
+		}
+		plaintext, err := fieldcrypt.Decrypt(*row.Value, context)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", row.ID, err)
+		}
+		sealed, err := fieldcrypt.Encrypt(plaintext, context)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", row.ID, err)
+		}
+		result := config.DB.Table(table).Where("id = ? AND "+column+" = ?", row.ID, *row.Value).UpdateColumn(column, sealed)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil
+		}
+
+		row.Value = nil // Stays nil if the row has since been purged
+		if err := config.DB.Table(table).Select(column+" AS value").Where("id = ?", row.ID).Scan(&row).Error; err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("row %d kept changing while it was re-encrypted", row.ID)
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/fieldcrypt"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestFieldEncryptionRotation(t *testing.T) {
+	testutil.ResetDB(t)
+	t.Cleanup(func() {
+		fieldcrypt.Install("", nil)
+		config.DB.Where("1 = 1").Delete(&models.DataKey{})
+	})
+	first := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 32)))
+	second := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", 32)))
+
+	client := models.User{Email: "client@encryption.test", Role: "client"}
+	config.DB.Create(&client)
+
+	// A ticket written before encryption is turned on.
+	legacy := models.Ticket{Title: "Legacy", Description: "old plaintext", Status: "open", ClientID: client.ID}
+	config.DB.Create(&legacy)
+
+	t.Setenv("RYANFORCE_MASTER_KEY", first)
+	t.Setenv("RYANFORCE_MASTER_KEY_ID", "first")
+	if err := InitFieldEncryption(); err != nil {
+		t.Fatalf("InitFieldEncryption failed: %v", err)
+	}
+	ticket := models.Ticket{Title: "Firewall", Description: "admin console at 192.168.1.1", Status: "open", ClientID: client.ID}
+	config.DB.Create(&ticket)
+	rawDescription := func(id uint) string {
+		var raw string
+		config.DB.Raw("SELECT description FROM tickets WHERE id = ?", id).Scan(&raw)
+		return raw
+	}
+	firstKey := fieldcrypt.KeyID(rawDescription(ticket.ID))
+	if firstKey == "" || strings.Contains(rawDescription(ticket.ID), "192.168") {
+		t.Fatal("description stored unencrypted")
+	}
+
+	// Rotate the master key: the old one stays available only until re-encryption finishes.
+	t.Setenv("RYANFORCE_MASTER_KEY", second)
+	t.Setenv("RYANFORCE_MASTER_KEY_ID", "second")
+	t.Setenv("RYANFORCE_PREVIOUS_MASTER_KEYS", "first="+first)
+	report, err := ReencryptFields(1)
+	if err != nil {
+		t.Fatalf("ReencryptFields failed: %v", err)
+	}
+	if report.Rewrapped != 1 || report.Rows["tickets"] < 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	for _, id := range []uint{legacy.ID, ticket.ID} {
+		if got := fieldcrypt.KeyID(rawDescription(id)); got != report.DataKeyID {
+			t.Fatalf("ticket %d encrypted with %q, want the new key %s", id, got, report.DataKeyID)
+		}
+	}
+
+	t.Setenv("RYANFORCE_PREVIOUS_MASTER_KEYS", "")
+	if err := InitFieldEncryption(); err != nil {
+		t.Fatalf("restart without the old master key failed: %v", err)
+	}
+	var reloaded models.Ticket
+	config.DB.First(&reloaded, legacy.ID)
+	if reloaded.Description != "old plaintext" {
+		t.Fatalf("description after rotation: %q", reloaded.Description)
+	}
+
+	t.Setenv("RYANFORCE_MASTER_KEY", "")
+	if err := InitFieldEncryption(); err == nil {
+		t.Fatal("started without a master key while encrypted data exists")
+	}
+}
+
+func TestReencryptKeepsConcurrentEdit(t *testing.T) {
+	testutil.ResetDB(t)
+	t.Cleanup(func() {
+		fieldcrypt.Install("", nil)
+		config.DB.Where("1 = 1").Delete(&models.DataKey{})
+	})
+	t.Setenv("RYANFORCE_MASTER_KEY", base64.StdEncoding.EncodeToString([]byte(strings.Repeat("c", 32))))
+	t.Setenv("RYANFORCE_MASTER_KEY_ID", "concurrent")
+	if err := InitFieldEncryption(); err != nil {
+		t.Fatalf("InitFieldEncryption failed: %v", err)
+	}
+
+	client := models.User{Email: "client@reencrypt.test", Role: "client"}
+	config.DB.Create(&client)
+	ticket := models.Ticket{Title: "Printer", Description: "before the edit", Status: "open", ClientID: client.ID}
+	config.DB.Create(&ticket)
+
+	// Re-encryption read the row, then the ticket was edited before it wrote the row back
+	var stale string
+	config.DB.Raw("SELECT description FROM tickets WHERE id = ?", ticket.ID).Scan(&stale)
+	config.DB.Model(&ticket).Update("description", "after the edit")
+	if err := rewriteEncryptedValue("tickets", "description", encryptedValue{ID: ticket.ID, Value: &stale}); err != nil {
+		t.Fatalf("rewriteEncryptedValue failed: %v", err)
+	}
+
+	var reloaded models.Ticket
+	config.DB.First(&reloaded, ticket.ID)
+	if reloaded.Description != "after the edit" {
+		t.Fatalf("re-encryption overwrote a concurrent edit: %q", reloaded.Description)
+	}
+}
+
+func TestDirectoryBindPasswordEncrypted(t *testing.T) {
+	testutil.ResetDB(t)
+	t.Cleanup(func() {
+		fieldcrypt.Install("", nil)
+		config.DB.Where("1 = 1").Delete(&models.DataKey{})
+	})
+	t.Setenv("RYANFORCE_MASTER_KEY", base64.StdEncoding.EncodeToString([]byte(strings.Repeat("d", 32))))
+	t.Setenv("RYANFORCE_MASTER_KEY_ID", "directory")
+	if err := InitFieldEncryption(); err != nil {
+		t.Fatalf("InitFieldEncryption failed: %v", err)
+	}
+
+	account := models.Account{Name: "Bind Password Co"}
+	config.DB.Create(&account)
+	if err := SaveDirectory(models.Directory{AccountID: account.ID, URL: "ldaps://ldap.bind.test:636", BaseDN: "dc=bind,dc=test", BindPassword: "s3rvice-secret"}); err != nil {
+		t.Fatalf("SaveDirectory failed: %v", err)
+	}
+
+	var raw string
+	config.DB.Raw("SELECT bind_password FROM directories WHERE account_id = ?", account.ID).Scan(&raw)
+	if fieldcrypt.KeyID(raw) == "" || strings.Contains(raw, "s3rvice") {
+		t.Fatalf("bind password stored unencrypted: %q", raw)
+	}
+	var saved models.Directory
+	config.DB.Where("account_id = ?", account.ID).First(&saved)
+	if saved.BindPassword != "s3rvice-secret" {
+		t.Fatalf("bind password did not decrypt: %q", saved.BindPassword)
+	}
+
+	directories, err := ListDirectories()
+	if err != nil {
+		t.Fatalf("ListDirectories failed: %v", err)
+	}
+	for _, d := range directories {
+		if d.BindPassword != "" {
+			t.Fatalf("ListDirectories returned the bind password of directory %d", d.ID)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"RyanForce/events"
+	"RyanForce/metrics"
+	"context"
+)
+
+// RegisterEventSubscribers subscribes the built-in handlers to the domain event bus. Call it once
+// at startup, before any request or job can publish.
+func RegisterEventSubscribers() {
+	events.SubscribeAll("metrics", events.Sync, func(ctx context.Context, e events.Event) error {
+		metrics.DomainEvents.WithLabelValues(e.EventName()).Inc()
+		return nil
+	})
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// draining is set once the server starts a graceful shutdown so load balancers stop
+// sending new traffic while in-flight requests finish.
+var draining atomic.Bool
+
+// SetDraining marks the server as shutting down; /readyz reports unavailable from then on.
+func SetDraining() {
+	draining.Store(true)
+}
+
+// Healthz handles GET /healthz
+// Liveness probe: the process is up and able to serve HTTP.
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz handles GET /readyz
+// Readiness probe: the server isn't draining and the database answers a ping.
+func Readyz(c *gin.Context) {
+	if draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "database": "unavailable"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "database": "unreachable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "database": "ok"})
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadinessFailsWhileDraining(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/healthz", Healthz)
+	r.GET("/readyz", Readyz)
+	status := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if code := status("/readyz"); code != http.StatusOK {
+		t.Fatalf("/readyz before shutdown = %d", code)
+	}
+	SetDraining()
+	defer draining.Store(false)
+	if code := status("/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("/readyz while draining = %d", code)
+	}
+	// Liveness is unaffected, so the orchestrator doesn't kill the process mid-drain
+	if code := status("/healthz"); code != http.StatusOK {
+		t.Fatalf("/healthz while draining = %d", code)
+	}
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"testing"
+	"time"
+)
+
+func TestStartImpersonation(t *testing.T) {
+	testutil.ResetDB(t)
+	admin := models.User{Email: "impersonator@example.com", Name: "Admin", Role: rbac.RoleAdmin}
+	otherAdmin := models.User{Email: "other.admin@example.com", Name: "Other", Role: rbac.RoleAdmin}
+	client := models.User{Email: "viewed@example.com", Name: "Client", Role: rbac.RoleClient}
+	config.DB.Create(&admin)
+	config.DB.Create(&otherAdmin)
+	config.DB.Create(&client)
+	actor := &utils.Claims{UserID: admin.ID, Email: admin.Email, Role: admin.Role}
+
+	if _, _, err := StartImpersonation(actor, admin.ID, "127.0.0.1"); err == nil {
+		t.Fatal("admin impersonated themselves")
+	}
+	if _, _, err := StartImpersonation(actor, otherAdmin.ID, "127.0.0.1"); err == nil {
+		t.Fatal("admin impersonated another admin")
+	}
+	if _, _, err := StartImpersonation(&utils.Claims{UserID: client.ID, Role: rbac.RoleClient}, admin.ID, "127.0.0.1"); err == nil {
+		t.Fatal("client was allowed to impersonate")
+	}
+
+	token, _, err := StartImpersonation(actor, client.ID, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("impersonation failed: %v", err)
+	}
+	claims, err := utils.ParseJWT(token)
+	if err != nil {
+		t.Fatalf("impersonation token rejected: %v", err)
+	}
+	if claims.UserID != client.ID || claims.Role != rbac.RoleClient || claims.ImpersonatorID != admin.ID {
+		t.Fatalf("claims do not record both users: %+v", claims)
+	}
+	if !claims.ExpiresAt.Time.Before(time.Now().Add(ImpersonationTTL() + time.Minute)) {
+		t.Fatalf("impersonation token expires at %s", claims.ExpiresAt.Time)
+	}
+	if _, _, err := StartImpersonation(claims, otherAdmin.ID, "127.0.0.1"); err == nil {
+		t.Fatal("impersonation was nested")
+	}
+
+	// Revoking the admin's own sessions ends the impersonation too.
+	time.Sleep(time.Second)
+	config.DB.Model(&admin).Update("sessions_revoked_at", time.Now())
+	if _, err := utils.ParseJWT(token); err == nil {
+		t.Fatal("impersonation token outlived the admin's sessions")
+	}
+}
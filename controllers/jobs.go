@@ -0,0 +1,333 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/events"
+	"RyanForce/jobs"
+	"RyanForce/mailer"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// systemActor performs the ticket changes made by background jobs.
+var systemActor = Actor{Email: "system", Role: rbac.RoleAdmin, IP: "Job-Runner"}
+
+// RegisterJobs registers the built-in background jobs. Each schedule can be changed with
+// RYANFORCE_JOB_SCHEDULE_<NAME> or turned off with the value "off".
+func RegisterJobs() {
+	jobs.Register(jobs.Definition{
+		Name:        "retention-purge",
+		Description: "Delete closed tickets, comments, and log lines past their retention period",
+		Schedule:    everyHours(config.RetentionIntervalHours()),
+		Run: func(ctx context.Context, job *models.Job) (string, error) {
+			report, err := RunRetentionPurge(false)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d tickets, %d comments, %d log lines removed", len(report.TicketIDs), len(report.CommentIDs), report.LogLines), nil
+		},
+	})
+	jobs.Register(jobs.Definition{
+		Name:        "trash-purge",
+		Description: "Permanently delete items that have been in the trash past the retention period",
+		Schedule:    everyHours(config.RetentionIntervalHours()),
+		Run: func(ctx context.Context, job *models.Job) (string, error) {
+			result, err := PurgeExpiredTrash()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d tickets, %d comments, %d accounts removed", result.Tickets, result.Comments, result.Accounts), nil
+		},
+	})
+	jobs.Register(jobs.Definition{
+		Name:        "directory-sync",
+		Description: "Sync users from every configured LDAP directory",
+		Schedule:    everyMinutes(config.DirectorySyncIntervalMinutes()),
+		Run:         runDirectorySync,
+	})
+	jobs.Register(jobs.Definition{
+		Name:        "sla-check",
+		Description: "Email technicians, or admins for unassigned tickets, when tickets pass their SLA",
+		Schedule:    "*/15 * * * *",
+		Run:         runSLACheck,
+	})
+	jobs.Register(jobs.Definition{
+		Name:        "auto-close",
+		Description: fmt.Sprintf("Close tickets left with the customer to follow up for %d days", config.AutoCloseDays()),
+		Schedule:    "@hourly",
+		Run:         runAutoClose,
+	})
+	jobs.Register(jobs.Definition{
+		Name:        "report-email",
+		Description: "Email admins a weekly ticket summary",
+		Schedule:    "0 7 * * 1",
+		Run:         runReportEmail,
+	})
+	jobs.Register(jobs.Definition{
+		Name:        "backup",
+		Description: fmt.Sprintf("Snapshot the database to %s, keeping the latest %d", config.BackupDir(), config.BackupKeep()),
+		Schedule:    "0 2 * * *",
+		MaxAttempts: 2,
+		Run:         runBackup,
+	})
+	jobs.Register(jobs.Definition{
+		Name:        "event-outbox",
+		Description: "Deliver outbox events to durable subscribers and prune delivered ones",
+		Schedule:    "@every 30s",
+		Run:         runEventOutbox,
+	})
+}
+
+// StartJobRunner starts this instance's job runner, which stops when ctx is cancelled. The caller
+// waits on the returned runner before closing the database.
+func StartJobRunner(ctx context.Context) *jobs.Runner {
+	runner := jobs.NewRunner(config.LoadJobSettings())
+	runner.Start(ctx)
+	return runner
+}
+
+// everyHours turns an interval setting into a schedule, with zero or less turning it off.
+func everyHours(hours int) string {
+	if hours <= 0 {
+		return "off"
+	}
+	return fmt.Sprintf("@every %dh", hours)
+}
+
+// everyMinutes turns an interval setting into a schedule, with zero or less turning it off.
+func everyMinutes(minutes int) string {
+	if minutes <= 0 {
+		return "off"
+	}
+	return fmt.Sprintf("@every %dm", minutes)
+}
+
+// runDirectorySync syncs each directory in turn, failing the job if any of them failed.
+func runDirectorySync(ctx context.Context, job *models.Job) (string, error) {
+	directories, err := ListDirectories()
+	if err != nil {
+		return "", err
+	}
+	failed := 0
+	for _, d := range directories {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if _, err := SyncDirectory(d.AccountID); err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return "", fmt.Errorf("%d of %d directories failed to sync", failed, len(directories))
+	}
+	return fmt.Sprintf("%d directories synced", len(directories)), nil
+}
+
+// runSLACheck emails about open tickets that passed their SLA since the last successful check,
+// or in the last day if there has not been one. Tickets go to their technician; unassigned
+// tickets go to every active admin.
+func runSLACheck(ctx context.Context, job *models.Job) (string, error) {
+	now := time.Now()
+	if job.StartedAt != nil {
+		now = *job.StartedAt
+	}
+	since := now.Add(-24 * time.Hour)
+	var last models.Job
+	if config.DB.Where("name = ? AND status = ? AND id != ?", job.Name, jobs.StatusSucceeded, job.ID).
+		Order("started_at DESC").First(&last).Error == nil && last.StartedAt != nil {
+		since = *last.StartedAt
+	}
+
+	var open []models.Ticket
+	if err := config.DB.Preload("AssignedTech").Where("status != ?", StatusClosed).Find(&open).Error; err != nil {
+		return "", err
+	}
+	var breached []models.Ticket
+	for _, t := range overdueTickets(open, now) {
+		if t.CreatedAt.Add(slaTargets[t.Priority]).After(since) {
+			breached = append(breached, t)
+		}
+	}
+	if len(breached) == 0 {
+		return "no new SLA breaches", nil
+	}
+
+	admins, err := activeAdminEmails()
+	if err != nil {
+		return "", err
+	}
+	byRecipient := map[string][]models.Ticket{}
+	for _, t := range breached {
+		if t.AssignedTech != nil && t.AssignedTech.DeactivatedAt == nil {
+			byRecipient[t.AssignedTech.Email] = append(byRecipient[t.AssignedTech.Email], t)
+			continue
+		}
+		for _, email := range admins {
+			byRecipient[email] = append(byRecipient[email], t)
+		}
+	}
+
+	var failures []string
+	for email, tickets := range byRecipient {
+		var body strings.Builder
+		body.WriteString("These RyanForce tickets have passed their SLA deadline:\n\n")
+		for _, t := range tickets {
+			deadline := t.CreatedAt.Add(slaTargets[t.Priority])
+			fmt.Fprintf(&body, "#%d %s (%s priority, %s), due %s\n", t.ID, t.Title, t.Priority, t.Status, deadline.Format(time.RFC1123))
+		}
+		if err := mailer.Send(email, fmt.Sprintf("%d RyanForce ticket(s) overdue", len(tickets)), body.String()); err != nil {
+			failures = append(failures, email)
+		}
+	}
+	if len(failures) > 0 {
+		return "", fmt.Errorf("could not email %s", strings.Join(failures, ", "))
+	}
+	return fmt.Sprintf("%d new SLA breaches sent to %d recipient(s)", len(breached), len(byRecipient)), nil
+}
+
+// runAutoClose closes tickets that have waited on the customer, with no change or comment, for
+// config.AutoCloseDays, leaving a comment to say why.
+func runAutoClose(ctx context.Context, job *models.Job) (string, error) {
+	days := config.AutoCloseDays()
+	if days <= 0 {
+		return "auto-close disabled", nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	var idle []models.Ticket
+	if err := config.DB.Where("status = ? AND updated_at < ?", StatusAwaitingCustomer, cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM comments WHERE comments.ticket_id = tickets.id AND comments.created_at >= ? AND comments.deleted_at IS NULL)", cutoff).
+		Find(&idle).Error; err != nil {
+		return "", err
+	}
+
+	service := NewTicketService(config.DB)
+	closed := StatusClosed
+	count := 0
+	for _, t := range idle {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		_, err := service.Update(systemActor, t.ID, UpdateTicketInput{
+			Status:  &closed,
+			Version: t.Version,
+			Comment: fmt.Sprintf("Closed automatically after %d days without a reply from the customer. Reopen it if you still need help.", days),
+		})
+		if err != nil {
+			continue // Changed since it was loaded, so no longer idle
+		}
+		count++
+	}
+	return fmt.Sprintf("%d of %d idle tickets closed", count, len(idle)), nil
+}
+
+// runReportEmail emails every active admin a summary of the past week's tickets.
+func runReportEmail(ctx context.Context, job *models.Job) (string, error) {
+	if config.LoadMailSettings().Host == "" {
+		return "skipped: SMTP is not configured", nil
+	}
+	now := time.Now()
+	weekAgo := now.AddDate(0, 0, -7)
+
+	var opened, closed int64
+	config.DB.Model(&models.Ticket{}).Where("created_at >= ?", weekAgo).Count(&opened)
+	config.DB.Model(&models.Ticket{}).Where("closed_at >= ?", weekAgo).Count(&closed)
+	var open []models.Ticket
+	if err := config.DB.Where("status != ?", StatusClosed).Find(&open).Error; err != nil {
+		return "", err
+	}
+	byStatus := map[string]int{}
+	for _, t := range open {
+		byStatus[t.Status]++
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "RyanForce weekly summary, %s to %s\n\n", weekAgo.Format("2006-01-02"), now.Format("2006-01-02"))
+	fmt.Fprintf(&body, "Opened this week : %d\nClosed this week : %d\nOpen now         : %d\nOverdue now      : %d\n\n",
+		opened, closed, len(open), len(overdueTickets(open, now)))
+	body.WriteString("Open tickets by status:\n")
+	for _, status := range TicketStatuses {
+		if status != StatusClosed {
+			fmt.Fprintf(&body, "  %-22s %d\n", status, byStatus[status])
+		}
+	}
+
+	admins, err := activeAdminEmails()
+	if err != nil {
+		return "", err
+	}
+	sent := 0
+	for _, email := range admins {
+		if err := mailer.Send(email, "RyanForce weekly summary", body.String()); err == nil {
+			sent++
+		}
+	}
+	if sent == 0 && len(admins) > 0 {
+		return "", fmt.Errorf("could not email any of %d admins", len(admins))
+	}
+	return fmt.Sprintf("summary sent to %d admin(s)", sent), nil
+}
+
+// runBackup writes a consistent snapshot of the database with VACUUM INTO, then deletes the
+// oldest snapshots beyond config.BackupKeep.
+func runBackup(ctx context.Context, job *models.Job) (string, error) {
+	dir := config.BackupDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("could not create backup directory: %w", err)
+	}
+	path := filepath.Join(dir, "ryanforce-"+time.Now().Format("20060102-150405")+".db")
+	if err := config.DB.WithContext(ctx).Exec("VACUUM INTO ?", path).Error; err != nil {
+		return "", fmt.Errorf("could not write backup: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		return "", err
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "ryanforce-*.db"))
+	sort.Strings(backups) // Timestamped names sort oldest first
+	removed := 0
+	if keep := config.BackupKeep(); keep > 0 {
+		for len(backups)-removed > keep {
+			if err := os.Remove(backups[removed]); err != nil {
+				return "", fmt.Errorf("backup written to %s, but pruning failed: %w", path, err)
+			}
+			removed++
+		}
+	}
+	return fmt.Sprintf("wrote %s, removed %d old backup(s)", path, removed), nil
+}
+
+// activeAdminEmails returns the email addresses of admins who can still sign in.
+func activeAdminEmails() ([]string, error) {
+	var emails []string
+	err := config.DB.Model(&models.User{}).Where("role = ? AND deactivated_at IS NULL", rbac.RoleAdmin).
+		Order("id").Pluck("email", &emails).Error
+	return emails, err
+}
+
+// runEventOutbox relays pending outbox events, then deletes delivered ones older than the
+// configured number of days.
+func runEventOutbox(ctx context.Context, job *models.Job) (string, error) {
+	if !config.EventOutboxEnabled() {
+		return "outbox disabled", nil
+	}
+	result, err := events.RelayOutbox(ctx, 500)
+	if err != nil {
+		return "", err
+	}
+	summary := fmt.Sprintf("%d delivered, %d to retry", result.Delivered, result.Failed)
+	if days := config.EventOutboxKeepDays(); days > 0 {
+		pruned, err := events.PruneOutbox(time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			return "", err
+		}
+		summary += fmt.Sprintf(", %d pruned", pruned)
+	}
+	return summary, nil
+}
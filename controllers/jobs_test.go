@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAutoCloseIdleTickets(t *testing.T) {
+	testutil.ResetDB(t)
+	client := models.User{Email: "client@autoclose.test", Role: rbac.RoleClient}
+	config.DB.Create(&client)
+	old := time.Now().AddDate(0, 0, -config.AutoCloseDays()-1)
+	idle := models.Ticket{Title: "Idle", Status: StatusAwaitingCustomer, ClientID: client.ID}
+	replied := models.Ticket{Title: "Replied", Status: StatusAwaitingCustomer, ClientID: client.ID}
+	working := models.Ticket{Title: "Working", Status: "working", ClientID: client.ID}
+	for _, ticket := range []*models.Ticket{&idle, &replied, &working} {
+		config.DB.Create(ticket)
+		config.DB.Model(ticket).UpdateColumn("updated_at", old)
+	}
+	config.DB.Create(&models.Comment{TicketID: replied.ID, AuthorID: client.ID, AuthorEmail: client.Email, Content: "Still broken"})
+
+	if _, err := runAutoClose(context.Background(), &models.Job{}); err != nil {
+		t.Fatalf("auto-close failed: %v", err)
+	}
+	config.DB.First(&idle, idle.ID)
+	config.DB.First(&replied, replied.ID)
+	config.DB.First(&working, working.ID)
+	if idle.Status != StatusClosed || idle.ClosedAt == nil {
+		t.Fatalf("idle ticket not closed: %s", idle.Status)
+	}
+	if replied.Status != StatusAwaitingCustomer || working.Status != "working" {
+		t.Fatalf("active tickets closed: %s, %s", replied.Status, working.Status)
+	}
+	var comments int64
+	config.DB.Model(&models.Comment{}).Where("ticket_id = ?", idle.ID).Count(&comments)
+	if comments != 1 {
+		t.Fatal("auto-close did not explain itself in a comment")
+	}
+}
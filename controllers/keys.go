@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"RyanForce/keyring"
+	"RyanForce/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS handles GET /.well-known/jwks.json
+// Publishes the public keys that verify session tokens, so other services can check RyanForce
+// tokens without sharing a secret. The set is empty while tokens are signed with HS256.
+func JWKS(c *gin.Context) {
+	set, err := keyring.JWKS()
+	if err != nil {
+		utils.LogError("[Keys] Failed to load signing keys for JWKS", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "signing keys unavailable"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
+
+// RotateSigningKeys makes a new signing key current. Tokens signed with the previous key stay
+// valid until its overlap period ends. actorID is the admin rotating the keys.
+func RotateSigningKeys(actorID uint) (*keyring.Key, []string, error) {
+	next, removed, err := keyring.Rotate()
+	if err != nil {
+		utils.LogError("[Keys] Key rotation failed", err)
+		return nil, nil, err
+	}
+
+	utils.LogAudit(fmt.Sprintf("[Keys] User %d rotated signing keys: new %s key %s, removed [%s]",
+		actorID, next.Algorithm, next.ID, strings.Join(removed, ", ")))
+	return next, removed, nil
+}
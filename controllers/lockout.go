@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/metrics"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// reasonRateLimited marks login events refused before the password was checked. They are not
+// counted as failures, so a throttled client cannot extend its own backoff indefinitely.
+const reasonRateLimited = "rate limited"
+
+// errInvalidCredentials is the answer to a wrong password, an unknown email, and a locked or
+// deactivated account alike, so a login attempt does not reveal which accounts exist.
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// LockoutError is returned when a login is refused because the account or the client IP has
+// too many recent failures. RetryAfter is how long the caller must wait.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("too many failed attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// FailedLoginSummary groups recent failed logins from one IP for the admin security view.
+type FailedLoginSummary struct {
+	IP           string
+	Failures     int64
+	LastAttempt  time.Time
+	Emails       []string
+	ThrottledFor time.Duration // Zero when the IP may try again now
+}
+
+// respondLockout answers an API request with 429 and a Retry-After header if err is a
+// *LockoutError, and reports whether it did.
+func respondLockout(c *gin.Context, err error) bool {
+	var lockout *LockoutError
+	if !errors.As(err, &lockout) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": lockout.Error()})
+	return true
+}
+
+// checkLoginAllowed refuses a login attempt while the client IP is throttled or the account is
+// locked or deactivated. user may be nil when the email did not match anyone. A throttled IP gets
+// a *LockoutError; a refused account gets errInvalidCredentials, as a wrong password would, with
+// the real reason only in the login events. Refusals do not count as new failures.
+func checkLoginAllowed(user *models.User, email, ip string) error {
+	if wait := ipRetryAfter(ip); wait > 0 {
+		utils.LogWarningIP(fmt.Sprintf("[Login] IP throttled for %s — %s", wait.Round(time.Second), email), ip)
+		recordLoginEvent(user, email, ip, false, reasonRateLimited)
+		return &LockoutError{RetryAfter: wait}
+	}
+	if user == nil {
+		return nil
+	}
+
+	if user.DeactivatedAt != nil {
+		utils.LogWarningIP("[Login] Account deactivated: "+user.Email, ip)
+		recordLoginEvent(user, email, ip, false, "account deactivated")
+		return errInvalidCredentials
+	}
+	if user.IsLocked {
+		utils.LogWarningIP("[Login] Account locked: "+user.Email, ip)
+		recordLoginEvent(user, email, ip, false, "account locked")
+		return errInvalidCredentials
+	}
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		utils.LogWarningIP(fmt.Sprintf("[Login] Account locked for another %s: %s", time.Until(*user.LockedUntil).Round(time.Second), user.Email), ip)
+		recordLoginEvent(user, email, ip, false, "account locked")
+		return errInvalidCredentials
+	}
+	return nil
+}
+
+// dummyPasswordHash is compared against when there is no password to check, so refused and
+// unknown logins take as long as a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashPassword("not the password of any account")
+	return hash
+})
+
+// MigrateLegacyLocks turns the permanent locks that failed logins set before lockouts expired on
+// their own into a first temporary lockout. Deactivated and erased users keep their lock, the
+// only ones IsLocked is still set for.
+func MigrateLegacyLocks() error {
+	until := time.Now().Add(config.LoadLockoutPolicy().Backoff(1))
+	result := config.DB.Model(&models.User{}).
+		Where("is_locked = ? AND deactivated_at IS NULL AND email NOT LIKE ?", true, "%@erased.invalid").
+		Updates(map[string]interface{}{"is_locked": false, "locked_until": until, "lockout_count": 1, "failed_attempts": 0})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		utils.LogAudit(fmt.Sprintf("[Lockout] Moved %d legacy locked accounts to a temporary lockout until %s",
+			result.RowsAffected, until.Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// registerFailedAttempt counts a failed password or MFA code. Reaching the threshold locks the
+// account for the policy's backoff, which doubles with every lockout until a successful login.
+func registerFailedAttempt(user *models.User) {
+	policy := config.LoadLockoutPolicy()
+
+	user.FailedAttempts++
+	if user.FailedAttempts >= policy.AccountThreshold {
+		user.LockoutCount++
+		until := time.Now().Add(policy.Backoff(user.LockoutCount))
+		user.LockedUntil = &until
+		user.FailedAttempts = 0
+		metrics.AccountLockouts.Inc()
+		utils.LogWarning(fmt.Sprintf("[Login] Account locked until %s after repeated failed attempts — %s",
+			until.Format(time.RFC3339), user.Email))
+	}
+	config.DB.Save(user)
+}
+
+// ipRetryAfter returns how long an IP must wait before trying again, or zero. Once an IP passes
+// the failure threshold within the window, each further failure doubles its wait.
+func ipRetryAfter(ip string) time.Duration {
+	policy := config.LoadLockoutPolicy()
+	if ip == "" || policy.IPThreshold <= 0 {
+		return 0
+	}
+
+	failures := func() *gorm.DB {
+		return config.DB.Model(&models.LoginEvent{}).
+			Where("ip = ? AND success = ? AND reason <> ? AND created_at > ?", ip, false, reasonRateLimited, time.Now().Add(-policy.IPWindow))
+	}
+
+	var count int64
+	if err := failures().Count(&count).Error; err != nil {
+		utils.LogError("[Login] Failed to count failures by IP", err)
+		return 0
+	}
+	if count < int64(policy.IPThreshold) {
+		return 0
+	}
+
+	var last models.LoginEvent
+	if err := failures().Order("created_at desc").First(&last).Error; err != nil {
+		return 0
+	}
+	wait := time.Until(last.CreatedAt.Add(policy.Backoff(int(count) - policy.IPThreshold + 1)))
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// UnlockUser clears both the admin lock and any temporary lockout on an account.
+// It reports false if the account was not locked.
+func UnlockUser(adminID uint, user *models.User) (bool, error) {
+	locked := user.IsLocked || (user.LockedUntil != nil && time.Now().Before(*user.LockedUntil))
+
+	user.IsLocked = false
+	user.LockedUntil = nil
+	user.FailedAttempts = 0
+	user.LockoutCount = 0
+	if err := config.DB.Save(user).Error; err != nil {
+		return false, fmt.Errorf("failed to update user")
+	}
+
+	if locked {
+		utils.LogAudit(fmt.Sprintf("[Lockout] Admin %d unlocked user %d (%s)", adminID, user.ID, user.Email))
+	}
+	return locked, nil
+}
+
+// RecentFailedLogins summarizes failed logins by IP over the given period, most failures first.
+func RecentFailedLogins(since time.Duration) ([]FailedLoginSummary, error) {
+	type row struct {
+		IP       string
+		Failures int64
+	}
+
+	var rows []row
+	err := config.DB.Model(&models.LoginEvent{}).
+		Select("ip, COUNT(*) AS failures").
+		Where("success = ? AND created_at > ?", false, time.Now().Add(-since)).
+		Group("ip").Order("failures DESC").Limit(100).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load failed logins: %w", err)
+	}
+
+	summaries := make([]FailedLoginSummary, 0, len(rows))
+	for _, r := range rows {
+		s := FailedLoginSummary{IP: r.IP, Failures: r.Failures, ThrottledFor: ipRetryAfter(r.IP)}
+		var last models.LoginEvent
+		if err := config.DB.Where("ip = ? AND success = ?", r.IP, false).Order("created_at desc").First(&last).Error; err == nil {
+			s.LastAttempt = last.CreatedAt
+		}
+		config.DB.Model(&models.LoginEvent{}).
+			Where("ip = ? AND success = ? AND created_at > ?", r.IP, false, time.Now().Add(-since)).
+			Distinct("email").Limit(10).Pluck("email", &s.Emails)
+		summaries = append(summaries, s)
+	}
+	return summaries, nil
+}
+
+// LockedUsers returns accounts that are currently locked, temporarily or by an admin.
+func LockedUsers() ([]models.User, error) {
+	var users []models.User
+	err := config.DB.Where("is_locked = ? OR locked_until > ?", true, time.Now()).Order("email").Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load locked users: %w", err)
+	}
+	return users, nil
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockedAccountsAnswerLikeUnknownEmails(t *testing.T) {
+	testutil.ResetDB(t)
+	hash, _ := utils.HashPassword("Correct123!")
+	until := time.Now().Add(time.Hour)
+	locked := models.User{Email: "locked@lockout.test", Role: "client", PasswordHash: hash, LockedUntil: &until}
+	config.DB.Create(&locked)
+
+	_, unknown := Login("nobody@lockout.test", "Correct123!", "203.0.113.7", "test")
+	_, lockedErr := Login(locked.Email, "Correct123!", "203.0.113.7", "test")
+	if !errors.Is(unknown, errInvalidCredentials) || !errors.Is(lockedErr, errInvalidCredentials) {
+		t.Fatalf("unknown email got %v, locked account got %v; want the same invalid credentials error", unknown, lockedErr)
+	}
+	var lockout *LockoutError
+	if errors.As(lockedErr, &lockout) {
+		t.Fatal("a locked account revealed its lockout")
+	}
+}
+
+func TestMigrateLegacyLocks(t *testing.T) {
+	testutil.ResetDB(t)
+	now := time.Now()
+	legacy := models.User{Email: "legacy@lockout.test", Role: "client", IsLocked: true}
+	deactivated := models.User{Email: "gone@lockout.test", Role: "client", IsLocked: true, DeactivatedAt: &now}
+	config.DB.Create(&legacy)
+	config.DB.Create(&deactivated)
+
+	if err := MigrateLegacyLocks(); err != nil {
+		t.Fatalf("MigrateLegacyLocks failed: %v", err)
+	}
+	config.DB.First(&legacy, legacy.ID)
+	if legacy.IsLocked || legacy.LockedUntil == nil || !legacy.LockedUntil.After(now) {
+		t.Fatalf("legacy lock not moved to a temporary lockout: %+v", legacy)
+	}
+	config.DB.First(&deactivated, deactivated.ID)
+	if !deactivated.IsLocked {
+		t.Fatal("a deactivated user was unlocked")
+	}
+}
+
+func TestAccountLockoutBacksOff(t *testing.T) {
+	testutil.ResetDB(t)
+	t.Setenv("RYANFORCE_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("RYANFORCE_LOCKOUT_BASE_SECONDS", "60")
+	t.Setenv("RYANFORCE_LOCKOUT_IP_THRESHOLD", "0")
+	t.Setenv("RYANFORCE_STEP_UP_FAILURES", "0")
+	user := models.User{Email: "backoff@lockout.test", Role: "client"}
+	if err := CreateUser(&user, "Client123!", "admin"); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	failUntilLocked := func() time.Duration {
+		t.Helper()
+		for i := 0; i < 3; i++ {
+			Login(user.Email, "wrong", "203.0.113.7", "test")
+		}
+		if _, err := Login(user.Email, "Client123!", "203.0.113.7", "test"); !errors.Is(err, errInvalidCredentials) {
+			t.Fatalf("login to a locked account returned %v", err)
+		}
+		config.DB.First(&user, user.ID)
+		if user.LockedUntil == nil {
+			t.Fatal("account not locked")
+		}
+		return time.Until(*user.LockedUntil)
+	}
+
+	if first := failUntilLocked(); first <= 0 || first > time.Minute {
+		t.Fatalf("first lockout lasts %s, want up to a minute", first)
+	}
+	if locked, _ := LockedUsers(); len(locked) != 1 || locked[0].ID != user.ID {
+		t.Fatalf("LockedUsers = %+v", locked)
+	}
+	// Once the lockout expires, the next one is twice as long
+	config.DB.Model(&user).Update("locked_until", time.Now().Add(-time.Second))
+	if second := failUntilLocked(); second <= time.Minute || second > 2*time.Minute {
+		t.Fatalf("second lockout lasts %s, want one to two minutes", second)
+	}
+
+	if unlocked, err := UnlockUser(1, &user); err != nil || !unlocked {
+		t.Fatalf("UnlockUser = %t, %v", unlocked, err)
+	}
+	if _, err := Login(user.Email, "Client123!", "203.0.113.7", "test"); err != nil {
+		t.Fatalf("login after unlock failed: %v", err)
+	}
+}
+
+func TestIPThrottling(t *testing.T) {
+	testutil.ResetDB(t)
+	t.Setenv("RYANFORCE_LOCKOUT_IP_THRESHOLD", "3")
+	for i := 0; i < 3; i++ {
+		Login("nobody@lockout.test", "guess", "203.0.113.9", "test")
+	}
+	var lockout *LockoutError
+	if _, err := Login("nobody@lockout.test", "guess", "203.0.113.9", "test"); !errors.As(err, &lockout) || lockout.RetryAfter <= 0 {
+		t.Fatalf("login from a throttled IP returned %v", err)
+	}
+	if _, err := Login("nobody@lockout.test", "guess", "203.0.113.10", "test"); errors.As(err, &lockout) {
+		t.Fatal("another IP was throttled")
+	}
+}
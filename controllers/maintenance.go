@@ -5,6 +5,7 @@ import (
 	"RyanForce/utils"
 	"bufio"
 	"fmt"
+	"gorm.io/gorm"
 	"os"
 	"strings"
 	"time"
@@ -28,25 +29,20 @@ func ClearDatabase(confirm bool) {
 		}
 	}
 
-	// Continue deleting
-	if err := config.DB.Exec("DELETE FROM comments;").Error; err != nil {
-		utils.LogError("[Maintenance] Failed to delete comments", err)
-		fmt.Println("[Error] Could not delete comments.")
-	}
-
-	if err := config.DB.Exec("DELETE FROM tickets;").Error; err != nil {
-		utils.LogError("[Maintenance] Failed to delete tickets", err)
-		fmt.Println("[Error] Could not delete tickets.")
-	}
-
-	if err := config.DB.Exec("DELETE FROM users;").Error; err != nil {
-		utils.LogError("[Maintenance] Failed to delete users", err)
-		fmt.Println("[Error] Could not delete users.")
-	}
-
-	if err := config.DB.Exec("DELETE FROM accounts;").Error; err != nil {
-		utils.LogError("[Maintenance] Failed to delete accounts", err)
-		fmt.Println("[Error] Could not delete accounts.")
+	// Delete children before parents so no statement breaks a foreign key, all in one
+	// transaction so a failure leaves the database as it was
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []string{"comments", "tickets", "users", "accounts"} {
+			if err := tx.Exec("DELETE FROM " + table).Error; err != nil {
+				return fmt.Errorf("%s: %w", table, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		utils.LogError("[Maintenance] Database wipe failed and was rolled back", err)
+		fmt.Println("[Error] Could not clear the database; nothing was deleted.")
+		return
 	}
 
 	utils.LogInfo("[Maintenance] Database cleared successfully.")
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/metrics"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	openTicketsDesc = prometheus.NewDesc(
+		"ryanforce_open_tickets",
+		"Tickets that are not closed, by status, priority, and client account.",
+		[]string{"status", "priority", "account"}, nil,
+	)
+	unassignedTicketsDesc = prometheus.NewDesc(
+		"ryanforce_unassigned_tickets",
+		"Open tickets with no technician assigned.",
+		nil, nil,
+	)
+	slaBreachedTicketsDesc = prometheus.NewDesc(
+		"ryanforce_sla_breached_tickets",
+		"Open tickets that have been open longer than their priority's SLA target, by priority.",
+		[]string{"priority"}, nil,
+	)
+)
+
+// ticketCollector computes the ticket gauges from the database on every scrape,
+// using the same rules as the CLI reports.
+type ticketCollector struct{}
+
+// Describe sends the descriptors of the ticket gauges.
+func (ticketCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- openTicketsDesc
+	ch <- unassignedTicketsDesc
+	ch <- slaBreachedTicketsDesc
+}
+
+// Collect queries open tickets and emits the current gauge values.
+func (ticketCollector) Collect(ch chan<- prometheus.Metric) {
+	if config.DB == nil {
+		return
+	}
+
+	type openCount struct {
+		Status   string
+		Priority string
+		Account  string
+		Count    int64
+	}
+	var counts []openCount
+	err := config.DB.Model(&models.Ticket{}).
+		Select("tickets.status, tickets.priority, COALESCE(accounts.name, '') AS account, COUNT(*) AS count").
+		Joins("LEFT JOIN users ON users.id = tickets.client_id").
+		Joins("LEFT JOIN accounts ON accounts.id = users.account_id AND accounts.deleted_at IS NULL").
+		Where("tickets.status != ?", StatusClosed).
+		Group("tickets.status, tickets.priority, account").
+		Scan(&counts).Error
+	if err != nil {
+		utils.LogError("[Metrics] Failed to count open tickets", err)
+	} else {
+		for _, c := range counts {
+			ch <- prometheus.MustNewConstMetric(openTicketsDesc, prometheus.GaugeValue, float64(c.Count), c.Status, c.Priority, c.Account)
+		}
+	}
+
+	var unassigned int64
+	if err := config.DB.Model(&models.Ticket{}).
+		Where("tech_id IS NULL AND status != ?", StatusClosed).
+		Count(&unassigned).Error; err != nil {
+		utils.LogError("[Metrics] Failed to count unassigned tickets", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(unassignedTicketsDesc, prometheus.GaugeValue, float64(unassigned))
+	}
+
+	var open []models.Ticket
+	if err := config.DB.Select("id", "priority", "created_at").
+		Where("status != ?", StatusClosed).
+		Find(&open).Error; err != nil {
+		utils.LogError("[Metrics] Failed to load open tickets for SLA check", err)
+		return
+	}
+	breached := make(map[string]int, len(slaTargets))
+	for priority := range slaTargets {
+		breached[priority] = 0
+	}
+	now := time.Now()
+	for _, t := range open {
+		if sla, ok := slaTargets[t.Priority]; ok && now.Sub(t.CreatedAt) > sla {
+			breached[t.Priority]++
+		}
+	}
+	for priority, n := range breached {
+		ch <- prometheus.MustNewConstMetric(slaBreachedTicketsDesc, prometheus.GaugeValue, float64(n), priority)
+	}
+}
+
+var registerTicketMetrics sync.Once
+
+// MetricsHandler serves GET /metrics in the Prometheus text format. Scrapers must send
+// RYANFORCE_METRICS_TOKEN as a bearer token; the gauges name client accounts, so without a token
+// configured the endpoint is disabled rather than left open.
+func MetricsHandler() gin.HandlerFunc {
+	token := config.GetEnv("RYANFORCE_METRICS_TOKEN", "")
+	if token == "" {
+		utils.LogInfo("[Metrics] RYANFORCE_METRICS_TOKEN is not set; /metrics is disabled")
+		return func(c *gin.Context) {
+			c.String(http.StatusNotFound, "Not Found")
+		}
+	}
+
+	registerTicketMetrics.Do(func() {
+		metrics.MustRegister(ticketCollector{})
+	})
+	handler := promhttp.Handler()
+	return func(c *gin.Context) {
+		given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.String(http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
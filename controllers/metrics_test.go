@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"RyanForce/internal/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetricsRequireToken(t *testing.T) {
+	testutil.ResetDB(t)
+	gin.SetMode(gin.TestMode)
+	scrape := func(handler gin.HandlerFunc, authorization string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.GET("/metrics", handler)
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Setenv("RYANFORCE_METRICS_TOKEN", "")
+	if w := scrape(MetricsHandler(), "Bearer anything"); w.Code != http.StatusNotFound {
+		t.Fatalf("/metrics without a configured token = %d", w.Code)
+	}
+
+	t.Setenv("RYANFORCE_METRICS_TOKEN", "scrape-token")
+	handler := MetricsHandler()
+	for _, authorization := range []string{"", "Bearer wrong-token", "scrape-token-and-more"} {
+		if w := scrape(handler, authorization); w.Code != http.StatusUnauthorized {
+			t.Fatalf("/metrics with Authorization %q = %d", authorization, w.Code)
+		}
+	}
+	w := scrape(handler, "Bearer scrape-token")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "ryanforce_unassigned_tickets") {
+		t.Fatalf("/metrics with the token = %d:\n%s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,373 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrMFARequired is returned by Login and Authenticate when the password was correct but the
+// user must still enter a TOTP or recovery code. The accompanying token is an MFA challenge.
+var ErrMFARequired = errors.New("mfa code required")
+
+// ErrMFAEnrollmentRequired is returned when an admin requires MFA for a user who has not yet
+// enrolled. The accompanying token is an MFA challenge that can be used to enroll.
+var ErrMFAEnrollmentRequired = errors.New("mfa enrollment required")
+
+// mfaIssuer is the name shown next to the account in authenticator apps.
+const mfaIssuer = "RyanForce"
+
+// recoveryCodeCount is the number of recovery codes issued at enrollment.
+const recoveryCodeCount = 10
+
+// totpPeriod is the length in seconds of a TOTP time step, the default authenticator apps use.
+const totpPeriod = 30
+
+// totpCodePattern matches a 6-digit TOTP code; anything else is treated as a recovery code.
+var totpCodePattern = regexp.MustCompile(`^[0-9]{6}$`)
+
+// BeginMFAEnrollment generates a new TOTP secret for the user and stores it unconfirmed.
+// MFA is not enforced until ConfirmMFAEnrollment succeeds with a code from the new secret.
+func BeginMFAEnrollment(userID uint) (*otp.Key, error) {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.MFAEnabled {
+		return nil, fmt.Errorf("MFA is already enabled for this account")
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: mfaIssuer, AccountName: user.Email})
+	if err != nil {
+		utils.LogError("[MFA] Failed to generate TOTP secret", err)
+		return nil, fmt.Errorf("failed to generate MFA secret")
+	}
+
+	if err := config.DB.Model(&user).Update("mfa_secret", key.Secret()).Error; err != nil {
+		utils.LogError("[MFA] Failed to store TOTP secret", err)
+		return nil, fmt.Errorf("failed to start MFA enrollment")
+	}
+	return key, nil
+}
+
+// ConfirmMFAEnrollment enables MFA once the user proves their authenticator works, and returns
+// a fresh set of recovery codes. The plaintext codes are shown once and never stored.
+func ConfirmMFAEnrollment(userID uint, code string) ([]string, error) {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.MFAEnabled {
+		return nil, fmt.Errorf("MFA is already enabled for this account")
+	}
+	if user.MFASecret == "" {
+		return nil, fmt.Errorf("MFA enrollment has not been started")
+	}
+	if !acceptTOTP(&user, normalizeMFACode(code)) {
+		return nil, fmt.Errorf("invalid authentication code")
+	}
+
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("mfa_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		utils.LogError(fmt.Sprintf("[MFA] Failed to enable MFA for user %d", userID), err)
+		return nil, fmt.Errorf("failed to enable MFA")
+	}
+
+	utils.LogAudit(fmt.Sprintf("[MFA] User %d enabled MFA", userID))
+	return codes, nil
+}
+
+// DisableMFA turns off MFA for a user who can still produce a valid code.
+// Users an admin has required to use MFA cannot disable it themselves.
+func DisableMFA(userID uint, code string) error {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if !user.MFAEnabled {
+		return fmt.Errorf("MFA is not enabled for this account")
+	}
+	if user.MFARequired {
+		return fmt.Errorf("an administrator requires MFA for this account")
+	}
+	if !verifyMFACode(&user, code) {
+		return fmt.Errorf("invalid authentication code")
+	}
+
+	if err := clearMFA(user.ID); err != nil {
+		utils.LogError(fmt.Sprintf("[MFA] Failed to disable MFA for user %d", userID), err)
+		return fmt.Errorf("failed to disable MFA")
+	}
+
+	utils.LogAudit(fmt.Sprintf("[MFA] User %d disabled MFA", userID))
+	return nil
+}
+
+// ResetMFA removes a user's TOTP secret and recovery codes so they can enroll again,
+// e.g. after losing their phone. adminID is the admin performing the reset.
+func ResetMFA(adminID, userID uint) error {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := clearMFA(user.ID); err != nil {
+		utils.LogError(fmt.Sprintf("[MFA] Failed to reset MFA for user %d", userID), err)
+		return fmt.Errorf("failed to reset MFA")
+	}
+
+	utils.LogAudit(fmt.Sprintf("[MFA] Admin %d reset MFA for user %d (%s)", adminID, userID, user.Email))
+	return nil
+}
+
+// SetMFARequired makes MFA mandatory (or optional again) for a user. A user who is required
+// to use MFA but has not enrolled is sent through enrollment at their next login.
+func SetMFARequired(adminID, userID uint, required bool) error {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := config.DB.Model(&user).Update("mfa_required", required).Error; err != nil {
+		utils.LogError(fmt.Sprintf("[MFA] Failed to update MFA requirement for user %d", userID), err)
+		return fmt.Errorf("failed to update MFA requirement")
+	}
+
+	utils.LogAudit(fmt.Sprintf("[MFA] Admin %d set MFA required=%t for user %d (%s)", adminID, required, userID, user.Email))
+	return nil
+}
+
+// CompleteMFALogin exchanges a login challenge and a code for a session token. An MFA challenge
+// takes a TOTP or recovery code; a step-up challenge takes the code emailed to the user, after
+// which users with MFA, or required to enroll, continue to that step. Wrong codes count towards
+// the same lockout as wrong passwords.
+func CompleteMFALogin(challenge, code, ip, userAgent string) (string, error) {
+	userID, err := utils.ParseMFAChallenge(challenge)
+	stepUp := false
+	if err != nil {
+		userID, err = utils.ParseStepUpChallenge(challenge)
+		stepUp = err == nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("your login has expired, please sign in again")
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return "", fmt.Errorf("invalid credentials")
+	}
+	if err := checkLoginAllowed(&user, user.Email, ip); err != nil {
+		return "", err
+	}
+
+	if stepUp {
+		if !verifyStepUpCode(&user, code) {
+			registerFailedAttempt(&user)
+			utils.LogWarningIP("[Login] Failed login: wrong verification code — "+user.Email, ip)
+			recordLoginEvent(&user, user.Email, ip, false, "invalid verification code")
+			return "", fmt.Errorf("invalid verification code")
+		}
+		if challenge, err := mfaChallengeFor(&user); challenge != "" || err != nil {
+			return challenge, err
+		}
+		return completeLogin(&user, ip, userAgent)
+	}
+
+	if !user.MFAEnabled || !verifyMFACode(&user, code) {
+		registerFailedAttempt(&user)
+		utils.LogWarningIP("[Login] Failed login: wrong MFA code — "+user.Email, ip)
+		recordLoginEvent(&user, user.Email, ip, false, "invalid mfa code")
+		return "", fmt.Errorf("invalid authentication code")
+	}
+
+	return completeLogin(&user, ip, userAgent)
+}
+
+// MFAEnrollmentUserID returns the user an enrollment challenge was issued for, so enrollment
+// pages can act on behalf of a user who must enroll before they get a session. Other login
+// challenges are refused: a step-up or MFA challenge does not let anyone enroll an authenticator.
+func MFAEnrollmentUserID(challenge string) (uint, error) {
+	return utils.ParseMFAEnrollmentChallenge(challenge)
+}
+
+// FinishEnrollmentLogin issues a session token after a user enrolled from an enrollment challenge.
+func FinishEnrollmentLogin(challenge, ip, userAgent string) (string, error) {
+	userID, err := utils.ParseMFAEnrollmentChallenge(challenge)
+	if err != nil {
+		return "", fmt.Errorf("your login has expired, please sign in again")
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return "", fmt.Errorf("invalid credentials")
+	}
+	if !user.MFAEnabled {
+		return "", fmt.Errorf("MFA enrollment is not complete")
+	}
+	return completeLogin(&user, ip, userAgent)
+}
+
+// LoginMFAAPI handles POST /api/login/mfa, the second step of an API login for MFA users.
+func LoginMFAAPI(c *gin.Context) {
+	type MFARequest struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	var req MFARequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.MFAToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	token, err := CompleteMFALogin(req.MFAToken, req.Code, c.ClientIP(), c.Request.UserAgent())
+	if respondLockout(c, err) || respondPasswordChange(c, token, err) {
+		return
+	}
+	if errors.Is(err, ErrMFAEnrollmentRequired) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "MFA enrollment required; sign in to the WebUI or CLI to enroll", "mfa_enrollment_required": true})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// verifyMFACode checks a TOTP code, falling back to the user's unused recovery codes.
+// Neither a TOTP code nor a recovery code can be used twice.
+func verifyMFACode(user *models.User, code string) bool {
+	code = normalizeMFACode(code)
+	if code == "" || user.MFASecret == "" {
+		return false
+	}
+	if totpCodePattern.MatchString(code) {
+		return acceptTOTP(user, code)
+	}
+
+	var recovery []models.MFARecoveryCode
+	if err := config.DB.Where("user_id = ? AND used_at IS NULL", user.ID).Find(&recovery).Error; err != nil {
+		utils.LogError("[MFA] Failed to load recovery codes", err)
+		return false
+	}
+	for _, rc := range recovery {
+		if !utils.CheckPasswordHash(code, rc.CodeHash) {
+			continue
+		}
+		// Claim the code only if it is still unused, so two logins racing with it cannot both succeed
+		now := time.Now()
+		result := config.DB.Model(&models.MFARecoveryCode{}).Where("id = ? AND used_at IS NULL", rc.ID).Update("used_at", &now)
+		if result.Error != nil {
+			utils.LogError("[MFA] Failed to mark recovery code used", result.Error)
+			return false
+		}
+		if result.RowsAffected != 1 {
+			utils.LogWarning(fmt.Sprintf("[MFA] Recovery code for user %d was redeemed twice at once; refused", user.ID))
+			return false
+		}
+		utils.LogAudit(fmt.Sprintf("[MFA] User %d signed in with a recovery code (%d left)", user.ID, len(recovery)-1))
+		return true
+	}
+	return false
+}
+
+// acceptTOTP checks a TOTP code against the current time step and one either side, as
+// totp.Validate does, and records the step it matched. A code for that step or an earlier one is
+// then refused, so a code seen over someone's shoulder or in a log cannot be replayed.
+func acceptTOTP(user *models.User, code string) bool {
+	now := time.Now()
+	for _, skew := range []int64{0, -1, 1} {
+		at := now.Add(time.Duration(skew*totpPeriod) * time.Second)
+		want, err := totp.GenerateCode(user.MFASecret, at)
+		if err != nil {
+			utils.LogError(fmt.Sprintf("[MFA] Failed to generate TOTP code for user %d", user.ID), err)
+			return false
+		}
+		if subtle.ConstantTimeCompare([]byte(code), []byte(want)) != 1 {
+			continue
+		}
+
+		step := at.Unix() / totpPeriod
+		result := config.DB.Model(&models.User{}).Where("id = ? AND mfa_last_step < ?", user.ID, step).Update("mfa_last_step", step)
+		if result.Error != nil {
+			utils.LogError("[MFA] Failed to record TOTP step", result.Error)
+			return false
+		}
+		if result.RowsAffected != 1 {
+			utils.LogWarning(fmt.Sprintf("[MFA] Refused a reused TOTP code for user %d", user.ID))
+			return false
+		}
+		user.MFALastStep = step
+		return true
+	}
+	return false
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and stores a new hashed set,
+// returning the plaintext codes.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 6)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+		code := raw[:5] + "-" + raw[5:]
+
+		hash, err := utils.HashPassword(code)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.MFARecoveryCode{UserID: userID, CodeHash: hash}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// clearMFA disables MFA and removes the secret and recovery codes for a user.
+func clearMFA(userID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"mfa_enabled": false,
+			"mfa_secret":  "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error
+	})
+}
+
+// normalizeMFACode trims whitespace and lowercases a code so recovery codes can be typed
+// in any case. Spaces inside TOTP codes ("123 456") are removed.
+func normalizeMFACode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestMFACodesCannotBeReused(t *testing.T) {
+	testutil.ResetDB(t)
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: mfaIssuer, AccountName: "replay@mfa.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Email: "replay@mfa.test", MFAEnabled: true, MFASecret: key.Secret()}
+	config.DB.Create(&user)
+
+	code, _ := totp.GenerateCode(user.MFASecret, time.Now())
+	if !verifyMFACode(&user, code) {
+		t.Fatal("valid TOTP code refused")
+	}
+	if verifyMFACode(&user, code) {
+		t.Fatal("TOTP code accepted twice")
+	}
+	earlier, _ := totp.GenerateCode(user.MFASecret, time.Now().Add(-totpPeriod*time.Second))
+	if earlier != code && verifyMFACode(&user, earlier) {
+		t.Fatal("TOTP code for an earlier step accepted after a later one")
+	}
+
+	hash, _ := utils.HashPassword("abcde-fghij")
+	config.DB.Create(&models.MFARecoveryCode{UserID: user.ID, CodeHash: hash})
+	if !verifyMFACode(&user, "ABCDE-FGHIJ") {
+		t.Fatal("unused recovery code refused")
+	}
+	if verifyMFACode(&user, "abcde-fghij") {
+		t.Fatal("recovery code accepted twice")
+	}
+}
+
+func TestOnlyEnrollmentChallengesEnroll(t *testing.T) {
+	testutil.ResetDB(t)
+	required := models.User{Email: "required@mfa.test", Role: "tech", MFARequired: true}
+	if err := CreateUser(&required, "Tech123!x", "admin"); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	challenge, err := Login(required.Email, "Tech123!x", "198.51.100.7", "Browser/1.0")
+	if !errors.Is(err, ErrMFAEnrollmentRequired) {
+		t.Fatalf("login of a user required to enroll returned %v", err)
+	}
+	if id, err := MFAEnrollmentUserID(challenge); err != nil || id != required.ID {
+		t.Fatalf("enrollment challenge refused: %d, %v", id, err)
+	}
+	if _, err := CompleteMFALogin(challenge, "123456", "198.51.100.7", "Browser/1.0"); err == nil {
+		t.Fatal("enrollment challenge accepted as an MFA challenge")
+	}
+
+	enrolled, _ := utils.GenerateMFAChallenge(required.ID)
+	if _, err := MFAEnrollmentUserID(enrolled); err == nil {
+		t.Fatal("MFA challenge accepted for enrollment")
+	}
+}
+
+func TestMFALoginRefusesReplayedCode(t *testing.T) {
+	testutil.ResetDB(t)
+	user := models.User{Email: "relogin@mfa.test", Role: rbac.RoleTech}
+	if err := CreateUser(&user, "Tech123!x", "admin"); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	key, _ := totp.Generate(totp.GenerateOpts{Issuer: mfaIssuer, AccountName: user.Email})
+	config.DB.Model(&user).Updates(map[string]interface{}{"mfa_enabled": true, "mfa_secret": key.Secret()})
+
+	challenge := func() string {
+		t.Helper()
+		challenge, err := Login(user.Email, "Tech123!x", "198.51.100.7", "test")
+		if !errors.Is(err, ErrMFARequired) {
+			t.Fatalf("password login returned %v", err)
+		}
+		return challenge
+	}
+	// A second server holding the user loaded before the first login must refuse it too
+	var stale models.User
+	config.DB.First(&stale, user.ID)
+
+	code, _ := totp.GenerateCode(key.Secret(), time.Now())
+	if _, err := CompleteMFALogin(challenge(), code, "198.51.100.7", "test"); err != nil {
+		t.Fatalf("first use of the code refused: %v", err)
+	}
+	if _, err := CompleteMFALogin(challenge(), code, "198.51.100.7", "test"); err == nil {
+		t.Fatal("the same code finished a second login")
+	}
+	if verifyMFACode(&stale, code) {
+		t.Fatal("code accepted against a stale copy of the user")
+	}
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/mailer"
+	"RyanForce/models"
+	"RyanForce/netpolicy"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ErrNetworkNotAllowed is returned when the user's role or account restricts sign-in to
+// networks that do not include the client IP.
+var ErrNetworkNotAllowed = errors.New("sign-in is not allowed from this network")
+
+// ErrStepUpRequired is returned by Login after repeated failures from a network the user has
+// not signed in from before. The accompanying token is an MFA challenge, completed through
+// CompleteMFALogin with the code emailed to the user.
+var ErrStepUpRequired = errors.New("verification code required")
+
+// ErrStepUpUnavailable is returned when step-up verification is required but no verification
+// code can be emailed because SMTP is not configured.
+var ErrStepUpUnavailable = errors.New("too many failed attempts from a new network; sign in from a network " +
+	"you have used before or contact an administrator")
+
+// reasonNetworkNotAllowed marks login events refused by a network allowlist.
+const reasonNetworkNotAllowed = "network not allowed"
+
+// stepUpCodeTTL matches the lifetime of the MFA challenge an emailed code completes.
+const stepUpCodeTTL = 5 * time.Minute
+
+// maxUserAgentLength bounds the user agent stored with a known login.
+const maxUserAgentLength = 512
+
+// checkNetworkAllowed refuses a login from outside the allowlists of the user's role and
+// account. Logins from the local CLI have no IP and are not restricted.
+func checkNetworkAllowed(user *models.User, ip string) error {
+	if !netpolicy.IsIP(ip) {
+		return nil
+	}
+
+	refusedBy := ""
+	if !netpolicy.Allows(rbac.AllowedNetworks(user.Role), ip) {
+		refusedBy = "role " + user.Role
+	} else if user.AccountID != nil {
+		var account models.Account
+		err := config.DB.Select("id", "name", "allowed_networks").Where("id = ?", *user.AccountID).Limit(1).Find(&account).Error
+		if err != nil {
+			utils.LogError(fmt.Sprintf("[Login] Failed to load account %d for network check", *user.AccountID), err)
+		} else if !netpolicy.Allows(account.AllowedNetworks, ip) {
+			refusedBy = "account " + account.Name
+		}
+	}
+	if refusedBy == "" {
+		return nil
+	}
+
+	utils.LogWarningIP(fmt.Sprintf("[Login] Network not allowed for %s — %s", refusedBy, user.Email), ip)
+	recordLoginEvent(user, user.Email, ip, false, reasonNetworkNotAllowed)
+	return ErrNetworkNotAllowed
+}
+
+// SetAccountAllowedNetworks restricts the networks an account's users may sign in from to a
+// comma-separated list of IPs and CIDR ranges. An empty list removes the restriction.
+func SetAccountAllowedNetworks(account *models.Account, list string, actorID uint) error {
+	normalized, err := netpolicy.Normalize(list)
+	if err != nil {
+		return err
+	}
+	if normalized == account.AllowedNetworks {
+		return nil
+	}
+
+	if err := config.DB.Model(account).Update("allowed_networks", normalized).Error; err != nil {
+		utils.LogError(fmt.Sprintf("[Admin] Failed to update allowed networks for account %d", account.ID), err)
+		return fmt.Errorf("failed to update allowed networks")
+	}
+	account.AllowedNetworks = normalized
+	if normalized == "" {
+		normalized = "any"
+	}
+	utils.LogAudit(fmt.Sprintf("[Admin] User %d set allowed networks for account %d (%s) to %s", actorID, account.ID, account.Name, normalized))
+	return nil
+}
+
+// rememberLogin records the network of a successful login. The first login from a network
+// other than the user's known ones raises a new-network alert; a user's very first login does
+// not, since every network is new to them.
+func rememberLogin(user *models.User, ip, userAgent string) {
+	network := netpolicy.NetworkOf(ip)
+	if network == "" {
+		return
+	}
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	var known models.KnownLogin
+	if err := config.DB.Where("user_id = ? AND network = ?", user.ID, network).Limit(1).Find(&known).Error; err != nil {
+		utils.LogError("[Login] Failed to load known logins", err)
+		return
+	}
+	now := time.Now()
+	if known.ID == 0 {
+		var count int64
+		config.DB.Model(&models.KnownLogin{}).Where("user_id = ?", user.ID).Count(&count)
+		if count > 0 {
+			alertNewNetwork(user, ip, userAgent)
+		}
+		known = models.KnownLogin{UserID: user.ID, Network: network, CreatedAt: now}
+	}
+
+	known.IP, known.UserAgent, known.LastSeenAt = ip, userAgent, now
+	if err := config.DB.Save(&known).Error; err != nil {
+		utils.LogError("[Login] Failed to record known login", err)
+	}
+}
+
+// alertNewNetwork writes a login from a new network to the audit log and emails the user.
+func alertNewNetwork(user *models.User, ip, userAgent string) {
+	if userAgent == "" {
+		userAgent = "unknown client"
+	}
+	utils.LogAudit(fmt.Sprintf("[Login] User %d (%s) signed in from new network %s using %q", user.ID, user.Email, ip, userAgent))
+
+	body := fmt.Sprintf("Your RyanForce account %s was just signed in to from a network it has not used before.\n\n"+
+		"IP address: %s\nClient: %s\nTime: %s\n\n"+
+		"If this was you, there is nothing to do. If not, reset your password and tell your administrator.\n",
+		user.Email, ip, userAgent, time.Now().Format(time.RFC1123))
+	go func() {
+		if err := mailer.Send(user.Email, "New sign-in to your RyanForce account", body); err != nil {
+			utils.LogError(fmt.Sprintf("[Login] Failed to send new network alert to user %d", user.ID), err)
+		}
+	}()
+}
+
+// stepUpRequired reports whether a login with a correct password must still be confirmed
+// because the client IP is on a network the user has not signed in from and has failed
+// repeatedly for them. Users with no known logins yet are never stepped up.
+func stepUpRequired(user *models.User, ip string) bool {
+	policy := config.LoadStepUpPolicy()
+	network := netpolicy.NetworkOf(ip)
+	if policy.Failures <= 0 || network == "" {
+		return false
+	}
+
+	var known []models.KnownLogin
+	if err := config.DB.Where("user_id = ?", user.ID).Find(&known).Error; err != nil || len(known) == 0 {
+		return false
+	}
+	for _, k := range known {
+		if k.Network == network {
+			return false
+		}
+	}
+
+	var failures int64
+	err := config.DB.Model(&models.LoginEvent{}).
+		Where("user_id = ? AND ip = ? AND success = ? AND reason <> ? AND created_at > ?",
+			user.ID, ip, false, reasonRateLimited, time.Now().Add(-policy.Window)).
+		Count(&failures).Error
+	if err != nil {
+		utils.LogError("[Login] Failed to count failures for step-up", err)
+		return false
+	}
+	return failures >= int64(policy.Failures)
+}
+
+// startStepUp emails the user a one-time code and returns a step-up challenge that the code
+// completes, together with ErrStepUpRequired.
+func startStepUp(user *models.User, ip string) (string, error) {
+	if config.LoadMailSettings().Host == "" {
+		utils.LogWarningIP("[Login] Step-up required but email is not configured — "+user.Email, ip)
+		recordLoginEvent(user, user.Email, ip, false, "step-up unavailable")
+		return "", ErrStepUpUnavailable
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		utils.LogError("[Login] Failed to generate step-up code", err)
+		return "", fmt.Errorf("token generation failed")
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	hash, err := utils.HashPassword(code)
+	if err != nil {
+		utils.LogError("[Login] Failed to hash step-up code", err)
+		return "", fmt.Errorf("token generation failed")
+	}
+	expires := time.Now().Add(stepUpCodeTTL)
+	user.StepUpCodeHash, user.StepUpExpiresAt = hash, &expires
+	if err := config.DB.Model(user).Updates(map[string]interface{}{"step_up_code_hash": hash, "step_up_expires_at": expires}).Error; err != nil {
+		utils.LogError("[Login] Failed to store step-up code", err)
+		return "", fmt.Errorf("token generation failed")
+	}
+
+	challenge, err := utils.GenerateStepUpChallenge(user.ID)
+	if err != nil {
+		utils.LogError("[Login] Failed to generate step-up challenge", err)
+		return "", fmt.Errorf("token generation failed")
+	}
+
+	body := fmt.Sprintf("Someone signed in to your RyanForce account %s from %s after several failed attempts.\n\n"+
+		"If this was you, enter this code within %d minutes to finish signing in:\n\n%s\n\n"+
+		"If it was not you, someone knows your password: reset it and tell your administrator.\n",
+		user.Email, ip, int(stepUpCodeTTL.Minutes()), code)
+	go func() {
+		if err := mailer.Send(user.Email, "Your RyanForce verification code", body); err != nil {
+			utils.LogError(fmt.Sprintf("[Login] Failed to email step-up code to user %d", user.ID), err)
+		}
+	}()
+
+	utils.LogAudit(fmt.Sprintf("[Login] Step-up verification required for user %d (%s) after repeated failures from %s", user.ID, user.Email, ip))
+	return challenge, ErrStepUpRequired
+}
+
+// verifyStepUpCode checks an emailed step-up code and consumes it on success.
+func verifyStepUpCode(user *models.User, code string) bool {
+	if user.StepUpCodeHash == "" || user.StepUpExpiresAt == nil || time.Now().After(*user.StepUpExpiresAt) {
+		return false
+	}
+	if !utils.CheckPasswordHash(strings.TrimSpace(code), user.StepUpCodeHash) {
+		return false
+	}
+
+	user.StepUpCodeHash, user.StepUpExpiresAt = "", nil
+	config.DB.Model(user).Updates(map[string]interface{}{"step_up_code_hash": "", "step_up_expires_at": nil})
+	return true
+}
+
+// StepUpPending reports whether a login challenge is waiting for an emailed step-up code rather
+// than an authenticator code, so the prompt can say where to find it.
+func StepUpPending(challenge string) bool {
+	_, err := utils.ParseStepUpChallenge(challenge)
+	return err == nil
+}
+
+// KnownLogins returns the networks a user has signed in from, most recently used first.
+func KnownLogins(userID uint) ([]models.KnownLogin, error) {
+	var known []models.KnownLogin
+	if err := config.DB.Where("user_id = ?", userID).Order("last_seen_at DESC").Find(&known).Error; err != nil {
+		return nil, fmt.Errorf("failed to load known logins: %w", err)
+	}
+	return known, nil
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"errors"
+	"testing"
+)
+
+func TestNetworkAllowlists(t *testing.T) {
+	testutil.ResetDB(t)
+	account := models.Account{Name: "Network Co", Domain: "network.test"}
+	config.DB.Create(&account)
+	user := models.User{Email: "ops@network.test", Role: rbac.RoleClient, AccountID: &account.ID}
+	if err := CreateUser(&user, "Client123!", "admin"); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	if err := SetAccountAllowedNetworks(&account, "198.51.100.0/24", 1); err != nil {
+		t.Fatalf("SetAccountAllowedNetworks failed: %v", err)
+	}
+	if _, err := Login(user.Email, "Client123!", "203.0.113.5", "test"); !errors.Is(err, ErrNetworkNotAllowed) {
+		t.Fatalf("login from outside the account allowlist returned %v", err)
+	}
+	if _, err := Login(user.Email, "Client123!", "198.51.100.7", "test"); err != nil {
+		t.Fatalf("login from inside the account allowlist failed: %v", err)
+	}
+	if _, err := Authenticate(user.Email, "Client123!"); err != nil {
+		t.Fatalf("CLI login refused by the network allowlist: %v", err)
+	}
+
+	if err := SetAccountAllowedNetworks(&account, "office", 1); err == nil {
+		t.Fatal("invalid allowlist accepted")
+	}
+	if err := rbac.SetAllowedNetworks(rbac.RoleClient, "192.0.2.1", 1); err != nil {
+		t.Fatalf("SetAllowedNetworks failed: %v", err)
+	}
+	defer rbac.SetAllowedNetworks(rbac.RoleClient, "", 1)
+	if _, err := Login(user.Email, "Client123!", "198.51.100.7", "test"); !errors.Is(err, ErrNetworkNotAllowed) {
+		t.Fatalf("login from outside the role allowlist returned %v", err)
+	}
+}
+
+func TestKnownLoginsAndStepUp(t *testing.T) {
+	testutil.ResetDB(t)
+	user := models.User{Email: "roamer@network.test", Role: rbac.RoleTech}
+	if err := CreateUser(&user, "Tech123!x", "admin"); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	// The first login is from a new network but there is nothing to compare it with
+	if _, err := Login(user.Email, "Tech123!x", "198.51.100.7", "Browser/1.0"); err != nil {
+		t.Fatalf("first login failed: %v", err)
+	}
+	if _, err := Login(user.Email, "Tech123!x", "198.51.100.99", "Browser/2.0"); err != nil {
+		t.Fatalf("login from the same /24 failed: %v", err)
+	}
+	known, err := KnownLogins(user.ID)
+	if err != nil || len(known) != 1 || known[0].IP != "198.51.100.99" || known[0].UserAgent != "Browser/2.0" {
+		t.Fatalf("KnownLogins = %+v, %v", known, err)
+	}
+
+	// Repeated failures from a new network require step-up even with the right password
+	for i := 0; i < config.LoadStepUpPolicy().Failures; i++ {
+		Login(user.Email, "wrong", "203.0.113.5", "Scripted/1.0")
+	}
+	if _, err := Login(user.Email, "Tech123!x", "203.0.113.5", "Scripted/1.0"); !errors.Is(err, ErrStepUpUnavailable) {
+		t.Fatalf("step-up without email returned %v", err)
+	}
+
+	t.Setenv("RYANFORCE_SMTP_HOST", "127.0.0.1")
+	t.Setenv("RYANFORCE_SMTP_PORT", "1")
+	challenge, err := Login(user.Email, "Tech123!x", "203.0.113.5", "Scripted/1.0")
+	if !errors.Is(err, ErrStepUpRequired) || !StepUpPending(challenge) {
+		t.Fatalf("step-up returned %v", err)
+	}
+	// The password alone must not let anyone bind their own authenticator and skip the code
+	if _, err := MFAEnrollmentUserID(challenge); err == nil {
+		t.Fatal("step-up challenge accepted for MFA enrollment")
+	}
+	if _, err := FinishEnrollmentLogin(challenge, "203.0.113.5", "Scripted/1.0"); err == nil {
+		t.Fatal("step-up challenge finished an enrollment login")
+	}
+	// Replace the emailed code with one the test knows
+	hash, _ := utils.HashPassword("123456")
+	config.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("step_up_code_hash", hash)
+
+	if _, err := CompleteMFALogin(challenge, "654321", "203.0.113.5", "Scripted/1.0"); err == nil {
+		t.Fatal("wrong step-up code accepted")
+	}
+	if _, err := CompleteMFALogin(challenge, "123456", "203.0.113.5", "Scripted/1.0"); err != nil {
+		t.Fatalf("step-up code rejected: %v", err)
+	}
+	if _, err := CompleteMFALogin(challenge, "123456", "203.0.113.5", "Scripted/1.0"); err == nil {
+		t.Fatal("step-up code accepted twice")
+	}
+	if known, _ := KnownLogins(user.ID); len(known) != 2 {
+		t.Fatalf("new network not remembered after step-up: %+v", known)
+	}
+	if _, err := Login(user.Email, "Tech123!x", "203.0.113.5", "Scripted/1.0"); err != nil {
+		t.Fatalf("login from the now-known network failed: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/pwpolicy"
+	"RyanForce/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ErrPasswordChangeRequired is returned by Login and the MFA login steps when every check passed
+// but the user's password has expired. The accompanying token is a password change token that
+// ChangeExpiredPassword exchanges for a session token.
+var ErrPasswordChangeRequired = errors.New("password change required")
+
+// passwordChangeFor issues a password change token instead of a session for a user whose
+// password has expired.
+func passwordChangeFor(user *models.User, ip string) (string, error) {
+	token, err := utils.GeneratePasswordChangeToken(user.ID)
+	if err != nil {
+		utils.LogError("[Login] Failed to generate password change token", err)
+		return "", fmt.Errorf("token generation failed")
+	}
+	utils.LogInfoIP("[Login] Password expired, change required — "+user.Email, ip)
+	return token, ErrPasswordChangeRequired
+}
+
+// ChangeExpiredPassword sets a new password for a user whose password expired at login and
+// returns a session token. The new password must satisfy the password policy.
+func ChangeExpiredPassword(token, newPassword, ip, userAgent string) (string, error) {
+	userID, err := utils.ParsePasswordChangeToken(token)
+	if err != nil {
+		return "", fmt.Errorf("your login has expired, please sign in again")
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return "", fmt.Errorf("invalid credentials")
+	}
+
+	if err := pwpolicy.Assign(&user, newPassword); err != nil {
+		return "", err
+	}
+	if err := saveUserWithPassword(config.DB, &user); err != nil {
+		utils.LogErrorIP("[Login] Failed to store changed password", err, ip)
+		return "", fmt.Errorf("failed to update password")
+	}
+
+	utils.LogAudit(fmt.Sprintf("[Password] User %d changed their expired password from %s", user.ID, ip))
+	return completeLogin(&user, ip, userAgent)
+}
+
+// ChangeExpiredPasswordAPI handles POST /api/login/password, the last step of an API login
+// whose password has expired.
+func ChangeExpiredPasswordAPI(c *gin.Context) {
+	var req struct {
+		PasswordToken string `json:"password_token"`
+		Password      string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.PasswordToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	token, err := ChangeExpiredPassword(req.PasswordToken, req.Password, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// respondPasswordChange answers an API login with 403 and a password change token if err is
+// ErrPasswordChangeRequired, and reports whether it did.
+func respondPasswordChange(c *gin.Context, token string, err error) bool {
+	if !errors.Is(err, ErrPasswordChangeRequired) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":                    "Password expired; choose a new one with POST /api/login/password",
+		"password_change_required": true,
+		"password_token":           token,
+	})
+	return true
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/mailer"
+	"RyanForce/models"
+	"RyanForce/pwpolicy"
+	"RyanForce/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ForgotPasswordMessage is shown after every reset request, whether or not the email exists.
+const ForgotPasswordMessage = "If an account exists for that email, a password reset link has been sent."
+
+// resetRequestCooldown stops the same account from being sent a new link more than once a minute.
+const resetRequestCooldown = time.Minute
+
+// errInvalidResetToken covers unknown, used, and expired tokens alike.
+var errInvalidResetToken = errors.New("this reset link is invalid or has expired")
+
+// RequestPasswordReset emails a single-use reset link to the user with this email. It behaves
+// the same whether or not the email exists: the email is sent in the background and any
+// failure is only logged, so neither the response nor its timing reveals registered emails.
+func RequestPasswordReset(email, ip string) {
+	email = strings.TrimSpace(email)
+
+	var user models.User
+	if err := config.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		utils.LogWarningIP("[PasswordReset] Reset requested for unknown email — "+email, ip)
+		return
+	}
+	if user.PasswordHash == "" {
+		utils.LogWarningIP(fmt.Sprintf("[PasswordReset] Reset requested for erased user %d", user.ID), ip)
+		return
+	}
+	if user.DirectoryID != nil {
+		utils.LogWarningIP(fmt.Sprintf("[PasswordReset] Reset requested for directory user %d", user.ID), ip)
+		return
+	}
+
+	var recent int64
+	config.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-resetRequestCooldown)).
+		Count(&recent)
+	if recent > 0 {
+		utils.LogWarningIP(fmt.Sprintf("[PasswordReset] Ignoring repeat request for user %d", user.ID), ip)
+		return
+	}
+
+	token, err := issueResetToken(user.ID)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("[PasswordReset] Failed to issue token for user %d", user.ID), err)
+		return
+	}
+
+	link := config.BaseURL() + "/password-reset?token=" + url.QueryEscape(token)
+	ttl := resetTokenTTL()
+	body := fmt.Sprintf("Someone asked to reset the RyanForce password for %s.\n\n"+
+		"Open this link within %d minutes to choose a new password:\n\n%s\n\n"+
+		"The link works once. If you did not ask for this, you can ignore this email.\n",
+		user.Email, int(ttl.Minutes()), link)
+
+	go func() {
+		if err := mailer.Send(user.Email, "Reset your RyanForce password", body); err != nil {
+			utils.LogError(fmt.Sprintf("[PasswordReset] Failed to email user %d", user.ID), err)
+		}
+	}()
+	utils.LogInfoIP(fmt.Sprintf("[PasswordReset] Reset link issued for user %d", user.ID), ip)
+}
+
+// ValidateResetToken reports whether a reset token is known, unused, and unexpired.
+func ValidateResetToken(token string) bool {
+	_, err := findResetToken(config.DB, token)
+	return err == nil
+}
+
+// CompletePasswordReset sets a new password using a reset token. The token is consumed, every
+// other outstanding token for the user is discarded, the lockout is cleared, and all existing
+// sessions are revoked.
+func CompletePasswordReset(token, newPassword, ip string) error {
+	if err := pwpolicy.Validate(newPassword); err != nil {
+		return err
+	}
+
+	var userID uint
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := findResetToken(tx, token)
+		if err != nil {
+			return err
+		}
+		userID = record.UserID
+
+		var user models.User
+		if err := tx.First(&user, record.UserID).Error; err != nil {
+			return err
+		}
+		if err := pwpolicy.Assign(&user, newPassword); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"password_hash":       user.PasswordHash,
+			"password_changed_at": user.PasswordChangedAt,
+			"failed_attempts":     0,
+			"lockout_count":       0,
+			"locked_until":        nil,
+			"sessions_revoked_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := pwpolicy.Remember(tx, &user); err != nil {
+			return err
+		}
+		return tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", record.UserID).
+			Update("used_at", now).Error
+	})
+	if errors.Is(err, errInvalidResetToken) {
+		utils.LogWarningIP("[PasswordReset] Invalid or expired token used", ip)
+		return err
+	}
+	if errors.Is(err, pwpolicy.ErrReusedPassword) {
+		return err
+	}
+	if err != nil {
+		utils.LogErrorIP("[PasswordReset] Failed to reset password", err, ip)
+		return fmt.Errorf("failed to reset password")
+	}
+
+	utils.LogAudit(fmt.Sprintf("[PasswordReset] User %d reset their password by email link from %s; sessions revoked", userID, ip))
+	return nil
+}
+
+// ForgotPasswordAPI handles POST /api/password/forgot
+func ForgotPasswordAPI(c *gin.Context) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	RequestPasswordReset(req.Email, c.ClientIP())
+	c.JSON(http.StatusAccepted, gin.H{"message": ForgotPasswordMessage})
+}
+
+// ResetPasswordAPI handles POST /api/password/reset
+func ResetPasswordAPI(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := CompletePasswordReset(req.Token, req.Password, c.ClientIP()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated. Please log in with your new password."})
+}
+
+// issueResetToken creates a new token for the user, discarding any unused older ones.
+func issueResetToken(userID uint) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", userID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    userID,
+			TokenHash: hashResetToken(token),
+			ExpiresAt: time.Now().Add(resetTokenTTL()),
+		}).Error
+	})
+	return token, err
+}
+
+// findResetToken loads a usable token by its plaintext value.
+func findResetToken(db *gorm.DB, token string) (*models.PasswordResetToken, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, errInvalidResetToken
+	}
+
+	var record models.PasswordResetToken
+	err := db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashResetToken(token), time.Now()).
+		Limit(1).Find(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	if record.ID == 0 {
+		return nil, errInvalidResetToken
+	}
+	return &record, nil
+}
+
+// hashResetToken returns the hex SHA-256 of a token. Tokens are long and random, so a fast
+// hash is enough and allows lookup by hash.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// resetTokenTTL is how long an emailed link stays valid (RYANFORCE_PASSWORD_RESET_TTL_MINUTES).
+func resetTokenTTL() time.Duration {
+	return time.Duration(config.GetEnvInt("RYANFORCE_PASSWORD_RESET_TTL_MINUTES", 30)) * time.Minute
+}
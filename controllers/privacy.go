@@ -0,0 +1,335 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// erasedName replaces a user's name once their personal data has been erased.
+const erasedName = "Erased User"
+
+// erasedIP replaces an erased user's IP addresses in the log files.
+const erasedIP = "erased-ip"
+
+// erasableLogs are the log files scrubbed when a user's personal data is erased. Unlike the
+// retention purge this includes the audit log: its lines are kept, with the user pseudonymized.
+var erasableLogs = append([]string{utils.AuditLogPath}, prunableLogs...)
+
+// userProfileExport is the profile section of a personal data export.
+// PasswordHash is deliberately left out.
+type userProfileExport struct {
+	ID        uint       `json:"id"`
+	Email     string     `json:"email"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	Skills    string     `json:"skills,omitempty"`
+	IsLocked  bool       `json:"is_locked"`
+	LastLogin *time.Time `json:"last_login"`
+	Account   string     `json:"account,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ticketExport is a ticket the user raised or was assigned to.
+type ticketExport struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Priority    string     `json:"priority"`
+	Status      string     `json:"status"`
+	Relation    string     `json:"relation"` // "client" or "assigned_tech"
+	CreatedAt   time.Time  `json:"created_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+	Deleted     bool       `json:"deleted"`
+}
+
+// commentExport is a comment the user wrote.
+type commentExport struct {
+	ID        uint      `json:"id"`
+	TicketID  uint      `json:"ticket_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	Deleted   bool      `json:"deleted"`
+}
+
+// loginEventExport is one entry in the user's login history.
+type loginEventExport struct {
+	IP        string    `json:"ip"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// knownLoginExport is a network the user has signed in from.
+type knownLoginExport struct {
+	Network    string    `json:"network"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeenAt time.Time `json:"last_seen"`
+}
+
+// ExportUserData gathers everything stored about a user (profile, tickets, comments, login
+// history, and known login networks) and returns it as a ZIP archive of JSON files. Trashed
+// records are included.
+func ExportUserData(userID uint) ([]byte, error) {
+	var user models.User
+	if err := config.DB.Preload("Account").First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	profile := userProfileExport{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      user.Role,
+		Skills:    user.Skills,
+		IsLocked:  user.IsLocked,
+		LastLogin: user.LastLogin,
+		Account:   user.Account.Name,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+
+	var tickets []models.Ticket
+	if err := config.DB.Unscoped().Where("client_id = ? OR tech_id = ?", userID, userID).Order("id").Find(&tickets).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tickets: %w", err)
+	}
+	ticketData := make([]ticketExport, 0, len(tickets))
+	for _, t := range tickets {
+		relation := "client"
+		if t.ClientID != userID {
+			relation = "assigned_tech"
+		}
+		ticketData = append(ticketData, ticketExport{
+			ID:          t.ID,
+			Title:       t.Title,
+			Description: t.Description,
+			Priority:    t.Priority,
+			Status:      t.Status,
+			Relation:    relation,
+			CreatedAt:   t.CreatedAt,
+			ClosedAt:    t.ClosedAt,
+			Deleted:     t.DeletedAt.Valid,
+		})
+	}
+
+	var comments []models.Comment
+	if err := config.DB.Unscoped().Where("author_id = ?", userID).Order("id").Find(&comments).Error; err != nil {
+		return nil, fmt.Errorf("failed to load comments: %w", err)
+	}
+	commentData := make([]commentExport, 0, len(comments))
+	for _, c := range comments {
+		commentData = append(commentData, commentExport{
+			ID:        c.ID,
+			TicketID:  c.TicketID,
+			Content:   c.Content,
+			CreatedAt: c.CreatedAt,
+			Deleted:   c.DeletedAt.Valid,
+		})
+	}
+
+	var events []models.LoginEvent
+	if err := config.DB.Where("user_id = ?", userID).Order("created_at").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to load login history: %w", err)
+	}
+	loginData := make([]loginEventExport, 0, len(events))
+	for _, e := range events {
+		loginData = append(loginData, loginEventExport{
+			IP:        e.IP,
+			Success:   e.Success,
+			Reason:    e.Reason,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+
+	known, err := KnownLogins(userID)
+	if err != nil {
+		return nil, err
+	}
+	knownData := make([]knownLoginExport, 0, len(known))
+	for _, k := range known {
+		knownData = append(knownData, knownLoginExport{
+			Network:    k.Network,
+			IP:         k.IP,
+			UserAgent:  k.UserAgent,
+			FirstSeen:  k.CreatedAt,
+			LastSeenAt: k.LastSeenAt,
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	archive := zip.NewWriter(buf)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile},
+		{"tickets.json", ticketData},
+		{"comments.json", commentData},
+		{"login_history.json", loginData},
+		{"known_logins.json", knownData},
+	}
+	for _, f := range files {
+		w, err := archive.Create(f.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", f.name, err)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(f.data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish export archive: %w", err)
+	}
+
+	utils.LogAudit(fmt.Sprintf("[Privacy] Personal data exported for user %d", userID))
+	return buf.Bytes(), nil
+}
+
+// AnonymizeUser erases a user's personal data while keeping their tickets, comments, and IDs
+// so reports and ticket history stay intact. Email, name, and comment author emails are
+// replaced with placeholders, login history, known login networks, and MFA secrets are
+// scrubbed, and the account is locked with no usable password and signed out everywhere.
+// In the log files their email becomes the placeholder and the IPs they logged in from become
+// erasedIP. adminID is the admin performing the erasure.
+func AnonymizeUser(userID, adminID uint) error {
+	if userID == adminID {
+		return fmt.Errorf("you cannot erase your own account")
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if user.Name == erasedName && user.PasswordHash == "" {
+		return fmt.Errorf("user %d has already been erased", userID)
+	}
+
+	placeholder := fmt.Sprintf("erased-user-%d@erased.invalid", userID)
+	oldEmail := user.Email
+	replacements := map[string]string{oldEmail: placeholder}
+	for _, ip := range userIPs(userID, oldEmail) {
+		replacements[ip] = erasedIP
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":               placeholder,
+			"name":                erasedName,
+			"password_hash":       "",
+			"skills":              "",
+			"is_locked":           true,
+			"failed_attempts":     0,
+			"mfa_enabled":         false,
+			"mfa_secret":          "",
+			"step_up_code_hash":   "",
+			"sessions_revoked_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.KnownLogin{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Comment{}).Where("author_id = ?", userID).
+			Update("author_email", placeholder).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.LoginEvent{}).Where("user_id = ? OR email = ?", userID, oldEmail).
+			Updates(map[string]interface{}{"email": placeholder, "ip": ""}).Error
+	})
+	if err != nil {
+		utils.LogError(fmt.Sprintf("[Privacy] Failed to erase user %d", userID), err)
+		return fmt.Errorf("failed to erase user data")
+	}
+
+	scrubbed := 0
+	var scrubErr error
+	for _, path := range erasableLogs {
+		changed, err := utils.ScrubLogFile(filepath.Clean(path), replacements)
+		if err != nil {
+			utils.LogError(fmt.Sprintf("[Privacy] Failed to scrub user %d from %s", userID, path), err)
+			scrubErr = fmt.Errorf("user data erased, but the log files could not all be scrubbed")
+			continue
+		}
+		scrubbed += changed
+	}
+
+	utils.LogAudit(fmt.Sprintf("[Privacy] Admin %d erased personal data for user %d (%d log lines scrubbed)", adminID, userID, scrubbed))
+	return scrubErr
+}
+
+// userIPs returns the IP addresses in a user's login history and known login networks.
+func userIPs(userID uint, email string) []string {
+	var eventIPs, knownIPs []string
+	config.DB.Model(&models.LoginEvent{}).Where("user_id = ? OR email = ?", userID, email).Distinct().Pluck("ip", &eventIPs)
+	config.DB.Model(&models.KnownLogin{}).Where("user_id = ?", userID).Distinct().Pluck("ip", &knownIPs)
+
+	var ips []string
+	for _, ip := range append(eventIPs, knownIPs...) {
+		// Skip placeholders such as CLI-Local, which would match other users' lines
+		if net.ParseIP(ip) != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// ExportUserDataAPI handles GET /api/users/:id/export (requires privacy.manage).
+// Responds with the user's personal data as a ZIP download.
+func ExportUserDataAPI(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	data, err := ExportUserData(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[PrivacyAPI] Admin %d exported data for user %d", claims.UserID, userID), c.ClientIP())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment;filename=user_%d_export.zip", userID))
+	c.Data(http.StatusOK, "application/zip", data)
+}
+
+// AnonymizeUserAPI handles POST /api/users/:id/anonymize (requires privacy.manage).
+func AnonymizeUserAPI(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := AnonymizeUser(uint(userID), claims.UserID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[PrivacyAPI] Admin %d erased user %d", claims.UserID, userID), c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "User data erased"})
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// readExport unzips a personal data export into its JSON files.
+func readExport(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("export is not a ZIP archive: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(r)
+		r.Close()
+		files[f.Name] = buf.String()
+	}
+	return files
+}
+
+func TestExportUserData(t *testing.T) {
+	testutil.ResetDB(t)
+	user := models.User{Email: "export@privacy.test", Name: "Ex Port", Role: rbac.RoleClient, PasswordHash: "secret-hash"}
+	config.DB.Create(&user)
+	ticket := models.Ticket{Title: "Printer on fire", Status: "open", ClientID: user.ID}
+	config.DB.Create(&ticket)
+	trashed := models.Comment{TicketID: ticket.ID, AuthorID: user.ID, Content: "Still smoking"}
+	config.DB.Create(&trashed)
+	config.DB.Delete(&trashed)
+	config.DB.Create(&models.LoginEvent{UserID: &user.ID, Email: user.Email, IP: "198.51.100.7", Success: true})
+
+	data, err := ExportUserData(user.ID)
+	if err != nil {
+		t.Fatalf("ExportUserData failed: %v", err)
+	}
+	files := readExport(t, data)
+	for _, name := range []string{"profile.json", "tickets.json", "comments.json", "login_history.json", "known_logins.json"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("export is missing %s: %v", name, files)
+		}
+	}
+	var profile map[string]interface{}
+	if err := json.Unmarshal([]byte(files["profile.json"]), &profile); err != nil || profile["email"] != user.Email {
+		t.Fatalf("profile.json = %s, %v", files["profile.json"], err)
+	}
+	if strings.Contains(string(data), "secret-hash") {
+		t.Fatal("export includes the password hash")
+	}
+	if !strings.Contains(files["tickets.json"], "Printer on fire") || !strings.Contains(files["comments.json"], "Still smoking") ||
+		!strings.Contains(files["login_history.json"], "198.51.100.7") {
+		t.Fatalf("export is missing the user's records: %v", files)
+	}
+
+	if _, err := ExportUserData(user.ID + 1000); err == nil {
+		t.Fatal("exported a user that does not exist")
+	}
+}
+
+func TestAnonymizeUserRevokesSessions(t *testing.T) {
+	testutil.ResetDB(t)
+	user := models.User{Email: "erase@privacy.test", Name: "Erin Erase", Role: rbac.RoleClient, PasswordHash: "x"}
+	config.DB.Create(&user)
+
+	if err := AnonymizeUser(user.ID, user.ID+1000); err != nil {
+		t.Fatalf("AnonymizeUser failed: %v", err)
+	}
+	var erased models.User
+	config.DB.First(&erased, user.ID)
+	if erased.SessionsRevokedAt == nil || !erased.IsLocked || erased.Email == user.Email {
+		t.Fatalf("erased user not locked and signed out: %+v", erased)
+	}
+	if err := AnonymizeUser(user.ID, user.ID+1000); err == nil {
+		t.Fatal("erased the same user twice")
+	}
+	if err := AnonymizeUser(user.ID+1000, user.ID+1000); err == nil {
+		t.Fatal("an admin erased their own account")
+	}
+}
+
+func TestAnonymizeUserScrubsLogs(t *testing.T) {
+	testutil.ResetDB(t)
+	t.Chdir(t.TempDir())
+	user := models.User{Email: "logged@privacy.test", Name: "Lou Logged", Role: rbac.RoleClient, PasswordHash: "x"}
+	config.DB.Create(&user)
+	config.DB.Create(&models.LoginEvent{UserID: &user.ID, Email: user.Email, IP: "198.51.100.7", Success: true})
+
+	if err := os.MkdirAll("logs", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lines := "INFO: 2026/01/02 10:00:00 (IP: 198.51.100.7) [Login] Successful login for logged@privacy.test\n" +
+		"INFO: 2026/01/02 10:05:00 (IP: 198.51.100.70) [Login] Successful login for notlogged@privacy.test\n"
+	for _, path := range []string{"logs/info.log", utils.AuditLogPath} {
+		if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := AnonymizeUser(user.ID, user.ID+1000); err != nil {
+		t.Fatalf("AnonymizeUser failed: %v", err)
+	}
+	for _, path := range []string{"logs/info.log", utils.AuditLogPath} {
+		data, _ := os.ReadFile(path)
+		got := string(data)
+		if strings.Contains(got, "198.51.100.7)") || strings.Contains(got, " logged@privacy.test") {
+			t.Fatalf("%s still names the erased user:\n%s", path, got)
+		}
+		if !strings.Contains(got, "(IP: erased-ip) [Login] Successful login for erased-user-") {
+			t.Fatalf("%s not pseudonymized:\n%s", path, got)
+		}
+		if !strings.Contains(got, "198.51.100.70") || !strings.Contains(got, "notlogged@privacy.test") {
+			t.Fatalf("%s lost another user's details:\n%s", path, got)
+		}
+	}
+}
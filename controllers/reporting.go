@@ -11,6 +11,14 @@ import (
 	"time"
 )
 
+// slaTargets is the maximum time a ticket of each priority may stay open before it breaches SLA.
+var slaTargets = map[string]time.Duration{
+	"low":      72 * time.Hour,
+	"medium":   48 * time.Hour,
+	"high":     24 * time.Hour,
+	"critical": 4 * time.Hour,
+}
+
 // ReportStatus prints the number of tickets in each status category.
 func ReportStatus() {
 	type Result struct {
@@ -83,13 +91,6 @@ func ReportResolutionMetrics() {
 		return
 	}
 
-	slaTargets := map[string]time.Duration{
-		"low":      72 * time.Hour,
-		"medium":   48 * time.Hour,
-		"high":     24 * time.Hour,
-		"critical": 4 * time.Hour,
-	}
-
 	var totalTime time.Duration
 	slaCompliance := map[string]struct {
 		total  int
@@ -127,7 +128,7 @@ func ReportResolutionMetrics() {
 // ReportOverdueTickets identifies open tickets that have exceeded their SLA deadline.
 func ReportOverdueTickets() {
 	var tickets []models.Ticket
-	config.DB.Where("status != ?", "closed").Find(&tickets)
+	config.DB.Where("status != ?", StatusClosed).Find(&tickets)
 
 	if len(tickets) == 0 {
 		fmt.Println("No open tickets found.")
@@ -135,25 +136,8 @@ func ReportOverdueTickets() {
 		return
 	}
 
-	slaTargets := map[string]time.Duration{
-		"low":      72 * time.Hour,
-		"medium":   48 * time.Hour,
-		"high":     24 * time.Hour,
-		"critical": 4 * time.Hour,
-	}
-
 	now := time.Now()
-	var overdue []models.Ticket
-
-	for _, t := range tickets {
-		sla, ok := slaTargets[t.Priority]
-		if !ok {
-			continue
-		}
-		if now.Sub(t.CreatedAt) > sla {
-			overdue = append(overdue, t)
-		}
-	}
+	overdue := overdueTickets(tickets, now)
 
 	fmt.Println("\nOverdue Tickets (open tickets past SLA deadline)")
 	fmt.Println("-----------------------------------------------------")
@@ -172,18 +156,32 @@ func ReportOverdueTickets() {
 	utils.LogInfo(fmt.Sprintf("[Report] Found %d overdue tickets", len(overdue)))
 }
 
+// overdueTickets returns the tickets that have been open longer than their priority's SLA.
+func overdueTickets(open []models.Ticket, now time.Time) []models.Ticket {
+	var overdue []models.Ticket
+	for _, t := range open {
+		sla, ok := slaTargets[t.Priority]
+		if ok && now.Sub(t.CreatedAt) > sla {
+			overdue = append(overdue, t)
+		}
+	}
+	return overdue
+}
+
 // ReportAll prints status, priority, SLA, and overdue reports.
 func ReportAll() {
-	fmt.Println("\n========== RYANFORCE REPORT SUMMARY ==========\n")
+	fmt.Print("\n========== RYANFORCE REPORT SUMMARY ==========\n\n")
 	ReportStatus()
 	ReportPriority()
 	ReportResolutionMetrics()
 	ReportOverdueTickets()
-	fmt.Println("\n================================================\n")
+	fmt.Print("\n================================================\n\n")
 	utils.LogInfo("[Report] Full summary report generated")
 }
 
-// ExportTicketsCSV writes all tickets to a CSV in the program's logs directory.
+// ExportTicketsCSV writes all tickets to a CSV in the program's working directory. Descriptions
+// are decrypted in the export, so the file is readable only by its owner and the export is
+// audited.
 func ExportTicketsCSV() {
 	var tickets []models.Ticket
 	config.DB.Find(&tickets)
@@ -194,7 +192,7 @@ func ExportTicketsCSV() {
 		return
 	}
 
-	file, err := os.Create("tickets_export.csv")
+	file, err := os.OpenFile("tickets_export.csv", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
 		fmt.Println("[Error] Unable to create file:", err)
 		utils.LogError("[Export] Failed to create export file", err)
@@ -236,4 +234,5 @@ func ExportTicketsCSV() {
 
 	fmt.Println("Exported tickets to tickets_export.csv")
 	utils.LogInfo("[Export] Ticket export completed")
+	utils.LogAudit(fmt.Sprintf("[Export] %d tickets exported to tickets_export.csv", len(tickets)))
 }
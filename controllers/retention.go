@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// prunableLogs are the application logs covered by the LogDays rule.
+// The audit log is deliberately excluded so the purge history itself is never lost.
+var prunableLogs = []string{"logs/info.log", "logs/warn.log", "logs/error.log", "logs/ryanforce.log"}
+
+// RetentionReport describes what a retention run removed, or would remove on a dry run.
+type RetentionReport struct {
+	DryRun          bool
+	TicketIDs       []uint // Closed tickets past their retention period
+	CommentIDs      []uint // Comments past their retention period (excluding those on purged tickets)
+	LogLines        int    // Log lines older than the log retention period
+	HeldTickets     int    // Tickets skipped because of a ticket or account legal hold
+	HeldComments    int    // Comments skipped because of a ticket or account legal hold
+	GlobalPolicy    models.RetentionPolicy
+	AccountPolicies int // Number of account-specific policies applied
+}
+
+// retentionRow is the slice of ticket/comment data the purge needs to decide what to keep.
+type retentionRow struct {
+	ID        uint
+	Title     string
+	TicketID  uint
+	Status    string
+	ClosedAt  *time.Time
+	CreatedAt time.Time
+	LegalHold bool
+	AccountID *uint
+}
+
+// GetGlobalRetentionPolicy returns the saved global policy, falling back to the
+// RYANFORCE_RETENTION_*_DAYS environment defaults when none has been saved.
+func GetGlobalRetentionPolicy() models.RetentionPolicy {
+	var policy models.RetentionPolicy
+	if err := config.DB.Where("account_id IS NULL").First(&policy).Error; err == nil {
+		return policy
+	}
+	return models.RetentionPolicy{
+		ClosedTicketDays: config.DefaultRetentionDays("closed_ticket"),
+		CommentDays:      config.DefaultRetentionDays("comment"),
+		LogDays:          config.DefaultRetentionDays("log"),
+	}
+}
+
+// ListRetentionPolicies returns every account-specific retention policy.
+func ListRetentionPolicies() ([]models.RetentionPolicy, error) {
+	var policies []models.RetentionPolicy
+	err := config.DB.Where("account_id IS NOT NULL").Find(&policies).Error
+	return policies, err
+}
+
+// SetRetentionPolicy creates or replaces the policy for an account, or the global policy
+// when accountID is nil. Negative values are rejected.
+func SetRetentionPolicy(accountID *uint, closedTicketDays, commentDays, logDays int) error {
+	if closedTicketDays < 0 || commentDays < 0 || logDays < 0 {
+		return fmt.Errorf("retention periods cannot be negative")
+	}
+
+	var policy models.RetentionPolicy
+	query := config.DB.Where("account_id IS NULL")
+	scope := "global"
+	if accountID != nil {
+		var account models.Account
+		if err := config.DB.First(&account, *accountID).Error; err != nil {
+			return fmt.Errorf("account not found")
+		}
+		query = config.DB.Where("account_id = ?", *accountID)
+		scope = fmt.Sprintf("account %d", *accountID)
+	}
+
+	if err := query.First(&policy).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to load retention policy: %w", err)
+	}
+
+	policy.AccountID = accountID
+	policy.ClosedTicketDays = closedTicketDays
+	policy.CommentDays = commentDays
+	policy.LogDays = logDays
+
+	if err := config.DB.Save(&policy).Error; err != nil {
+		return fmt.Errorf("failed to save retention policy: %w", err)
+	}
+
+	utils.LogAudit(fmt.Sprintf("[Retention] Policy for %s set: closed tickets %dd, comments %dd, logs %dd",
+		scope, closedTicketDays, commentDays, logDays))
+	return nil
+}
+
+// SetAccountLegalHold places or lifts a legal hold on every ticket raised by an account.
+func SetAccountLegalHold(accountID uint, hold bool) error {
+	result := config.DB.Model(&models.Account{}).Where("id = ?", accountID).Update("legal_hold", hold)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update legal hold: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("account not found")
+	}
+	utils.LogAudit(fmt.Sprintf("[Retention] Legal hold on account %d set to %t", accountID, hold))
+	return nil
+}
+
+// SetTicketLegalHold places or lifts a legal hold on a single ticket and its comments.
+func SetTicketLegalHold(ticketID uint, hold bool) error {
+	result := config.DB.Unscoped().Model(&models.Ticket{}).Where("id = ?", ticketID).Update("legal_hold", hold)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update legal hold: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("ticket not found")
+	}
+	utils.LogAudit(fmt.Sprintf("[Retention] Legal hold on ticket %d set to %t", ticketID, hold))
+	return nil
+}
+
+// RunRetentionPurge applies the retention policies. Closed tickets and comments past their
+// retention period are permanently deleted unless the ticket or its account is on legal hold,
+// and old log lines are pruned. With dryRun set nothing is deleted and the report lists what
+// would have been removed. Every deletion is written to the audit trail.
+func RunRetentionPurge(dryRun bool) (RetentionReport, error) {
+	report := RetentionReport{DryRun: dryRun, GlobalPolicy: GetGlobalRetentionPolicy()}
+	now := time.Now()
+
+	accountPolicies, err := ListRetentionPolicies()
+	if err != nil {
+		return report, fmt.Errorf("failed to load retention policies: %w", err)
+	}
+	report.AccountPolicies = len(accountPolicies)
+	byAccount := make(map[uint]models.RetentionPolicy, len(accountPolicies))
+	for _, p := range accountPolicies {
+		byAccount[*p.AccountID] = p
+	}
+
+	var heldAccounts []uint
+	if err := config.DB.Unscoped().Model(&models.Account{}).Where("legal_hold = ?", true).Pluck("id", &heldAccounts).Error; err != nil {
+		return report, fmt.Errorf("failed to load legal holds: %w", err)
+	}
+	held := make(map[uint]bool, len(heldAccounts))
+	for _, id := range heldAccounts {
+		held[id] = true
+	}
+
+	policyFor := func(accountID *uint) models.RetentionPolicy {
+		if accountID != nil {
+			if p, ok := byAccount[*accountID]; ok {
+				return p
+			}
+		}
+		return report.GlobalPolicy
+	}
+	onHold := func(row retentionRow) bool {
+		return row.LegalHold || (row.AccountID != nil && held[*row.AccountID])
+	}
+
+	// Closed tickets (including trashed ones) past their retention period
+	var tickets []retentionRow
+	if err := config.DB.Table("tickets").
+		Select("tickets.id, tickets.title, tickets.status, tickets.closed_at, tickets.legal_hold, users.account_id").
+		Joins("LEFT JOIN users ON users.id = tickets.client_id").
+		Where("tickets.status = ? AND tickets.closed_at IS NOT NULL", StatusClosed).
+		Scan(&tickets).Error; err != nil {
+		return report, fmt.Errorf("failed to scan closed tickets: %w", err)
+	}
+
+	purgedTickets := make(map[uint]bool)
+	var expiredTickets []retentionRow
+	for _, t := range tickets {
+		days := policyFor(t.AccountID).ClosedTicketDays
+		if days <= 0 || now.Sub(*t.ClosedAt) < time.Duration(days)*24*time.Hour {
+			continue
+		}
+		if onHold(t) {
+			report.HeldTickets++
+			continue
+		}
+		report.TicketIDs = append(report.TicketIDs, t.ID)
+		expiredTickets = append(expiredTickets, t)
+		purgedTickets[t.ID] = true
+	}
+
+	// Comments past their retention period, skipping those that go with a purged ticket
+	var comments []retentionRow
+	if err := config.DB.Table("comments").
+		Select("comments.id, comments.ticket_id, comments.created_at, tickets.legal_hold, users.account_id").
+		Joins("JOIN tickets ON tickets.id = comments.ticket_id").
+		Joins("LEFT JOIN users ON users.id = tickets.client_id").
+		Scan(&comments).Error; err != nil {
+		return report, fmt.Errorf("failed to scan comments: %w", err)
+	}
+
+	for _, c := range comments {
+		if purgedTickets[c.TicketID] {
+			continue
+		}
+		days := policyFor(c.AccountID).CommentDays
+		if days <= 0 || now.Sub(c.CreatedAt) < time.Duration(days)*24*time.Hour {
+			continue
+		}
+		if onHold(c) {
+			report.HeldComments++
+			continue
+		}
+		report.CommentIDs = append(report.CommentIDs, c.ID)
+	}
+
+	// Application log lines (global policy only — log files aren't tied to an account)
+	if days := report.GlobalPolicy.LogDays; days > 0 {
+		cutoff := now.Add(-time.Duration(days) * 24 * time.Hour)
+		for _, path := range prunableLogs {
+			removed, err := utils.PruneLogFile(filepath.Clean(path), cutoff, dryRun)
+			if err != nil {
+				utils.LogError(fmt.Sprintf("[Retention] Failed to prune %s", path), err)
+				continue
+			}
+			report.LogLines += removed
+			if removed > 0 && !dryRun {
+				utils.LogAudit(fmt.Sprintf("[Retention] Pruned %d lines older than %d days from %s", removed, days, path))
+			}
+		}
+	}
+
+	if dryRun {
+		utils.LogInfo(fmt.Sprintf("[Retention] Dry run: %d tickets, %d comments, %d log lines would be removed",
+			len(report.TicketIDs), len(report.CommentIDs), report.LogLines))
+		return report, nil
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if len(report.TicketIDs) > 0 {
+			if err := tx.Unscoped().Where("ticket_id IN ?", report.TicketIDs).Delete(&models.Comment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", report.TicketIDs).Delete(&models.Ticket{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(report.CommentIDs) > 0 {
+			if err := tx.Unscoped().Where("id IN ?", report.CommentIDs).Delete(&models.Comment{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		utils.LogError("[Retention] Purge failed", err)
+		return report, fmt.Errorf("retention purge failed: %w", err)
+	}
+
+	for _, t := range expiredTickets {
+		utils.LogAudit(fmt.Sprintf("[Retention] Purged closed ticket %d (%q) closed %s, with its comments",
+			t.ID, t.Title, t.ClosedAt.Format("2006-01-02")))
+	}
+	for _, id := range report.CommentIDs {
+		utils.LogAudit(fmt.Sprintf("[Retention] Purged comment %d", id))
+	}
+	utils.LogAudit(fmt.Sprintf("[Retention] Purge complete: %d tickets, %d comments, %d log lines removed; %d tickets and %d comments held",
+		len(report.TicketIDs), len(report.CommentIDs), report.LogLines, report.HeldTickets, report.HeldComments))
+
+	return report, nil
+}
+
+// PrintRetentionReport writes a retention report to the CLI.
+func PrintRetentionReport(report RetentionReport) {
+	if report.DryRun {
+		fmt.Println("\nRetention Dry Run (nothing has been deleted)")
+	} else {
+		fmt.Println("\nRetention Purge Results")
+	}
+	fmt.Println("------------------------------")
+	g := report.GlobalPolicy
+	fmt.Printf("Global policy     : closed tickets %s, comments %s, logs %s\n",
+		formatDays(g.ClosedTicketDays), formatDays(g.CommentDays), formatDays(g.LogDays))
+	fmt.Printf("Account overrides : %d\n", report.AccountPolicies)
+	fmt.Printf("Closed tickets    : %d %v\n", len(report.TicketIDs), report.TicketIDs)
+	fmt.Printf("Comments          : %d %v\n", len(report.CommentIDs), report.CommentIDs)
+	fmt.Printf("Log lines         : %d\n", report.LogLines)
+	fmt.Printf("On legal hold     : %d tickets, %d comments\n", report.HeldTickets, report.HeldComments)
+}
+
+// formatDays renders a retention period, treating zero as "keep forever".
+func formatDays(days int) string {
+	if days <= 0 {
+		return "forever"
+	}
+	return fmt.Sprintf("%dd", days)
+}
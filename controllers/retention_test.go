@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunRetentionPurge(t *testing.T) {
+	testutil.ResetDB(t)
+	t.Chdir(t.TempDir())
+	longAgo := time.Now().AddDate(0, 0, -40)
+	lately := time.Now().AddDate(0, 0, -1)
+
+	keepForever := models.Account{Name: "Forever Co", Domain: "forever.retention.test"}
+	onHold := models.Account{Name: "Held Co", Domain: "held.retention.test"}
+	config.DB.Create(&keepForever)
+	config.DB.Create(&onHold)
+	plain := models.User{Email: "plain@retention.test", Role: rbac.RoleClient}
+	forever := models.User{Email: "forever@retention.test", Role: rbac.RoleClient, AccountID: &keepForever.ID}
+	held := models.User{Email: "held@retention.test", Role: rbac.RoleClient, AccountID: &onHold.ID}
+	for _, user := range []*models.User{&plain, &forever, &held} {
+		config.DB.Create(user)
+	}
+
+	if err := SetRetentionPolicy(nil, 30, 0, 10); err != nil {
+		t.Fatalf("SetRetentionPolicy failed: %v", err)
+	}
+	if err := SetRetentionPolicy(&keepForever.ID, 0, 0, 0); err != nil {
+		t.Fatalf("SetRetentionPolicy for an account failed: %v", err)
+	}
+	if err := SetRetentionPolicy(nil, -1, 0, 0); err == nil {
+		t.Fatal("negative retention period accepted")
+	}
+	if err := SetAccountLegalHold(onHold.ID, true); err != nil {
+		t.Fatalf("SetAccountLegalHold failed: %v", err)
+	}
+
+	closed := func(title string, client models.User, closedAt time.Time) models.Ticket {
+		ticket := models.Ticket{Title: title, Status: StatusClosed, ClientID: client.ID, ClosedAt: &closedAt}
+		config.DB.Create(&ticket)
+		config.DB.Create(&models.Comment{TicketID: ticket.ID, AuthorID: client.ID, Content: "thanks"})
+		return ticket
+	}
+	expired := closed("Expired", plain, longAgo)
+	recent := closed("Recent", plain, lately)
+	overridden := closed("Kept by account policy", forever, longAgo)
+	accountHeld := closed("Account on hold", held, longAgo)
+	ticketHeld := closed("Ticket on hold", plain, longAgo)
+	if err := SetTicketLegalHold(ticketHeld.ID, true); err != nil {
+		t.Fatalf("SetTicketLegalHold failed: %v", err)
+	}
+
+	if err := os.MkdirAll("logs", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	logLines := "INFO: " + longAgo.Format("2006/01/02 15:04:05") + " old line\n" +
+		"goroutine 1 [running]:\n" +
+		"INFO: " + lately.Format("2006/01/02 15:04:05") + " new line\n"
+	for _, path := range []string{"logs/info.log", utils.AuditLogPath} {
+		if err := os.WriteFile(path, []byte(logLines), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := RunRetentionPurge(true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if !slices.Equal(report.TicketIDs, []uint{expired.ID}) || report.HeldTickets != 2 || report.LogLines != 1 {
+		t.Fatalf("dry run report = %+v", report)
+	}
+	if err := config.DB.First(&models.Ticket{}, expired.ID).Error; err != nil {
+		t.Fatalf("dry run deleted a ticket: %v", err)
+	}
+	if data, _ := os.ReadFile("logs/info.log"); string(data) != logLines {
+		t.Fatalf("dry run pruned the log:\n%s", data)
+	}
+
+	if _, err := RunRetentionPurge(false); err != nil {
+		t.Fatalf("RunRetentionPurge failed: %v", err)
+	}
+	var remaining []uint
+	config.DB.Unscoped().Model(&models.Ticket{}).Order("id").Pluck("id", &remaining)
+	if !slices.Equal(remaining, []uint{recent.ID, overridden.ID, accountHeld.ID, ticketHeld.ID}) {
+		t.Fatalf("tickets left after the purge = %v", remaining)
+	}
+	var orphaned int64
+	config.DB.Unscoped().Model(&models.Comment{}).Where("ticket_id = ?", expired.ID).Count(&orphaned)
+	if orphaned != 0 {
+		t.Fatalf("%d comments left on the purged ticket", orphaned)
+	}
+
+	info, _ := os.ReadFile("logs/info.log")
+	if strings.Contains(string(info), "old line") || !strings.Contains(string(info), "new line") ||
+		!strings.Contains(string(info), "goroutine 1") {
+		t.Fatalf("info.log after pruning:\n%s", info)
+	}
+	if audit, _ := os.ReadFile(utils.AuditLogPath); string(audit) != logLines {
+		t.Fatalf("the audit log was pruned:\n%s", audit)
+	}
+}
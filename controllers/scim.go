@@ -0,0 +1,700 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/pwpolicy"
+	"RyanForce/rbac"
+	"RyanForce/scim"
+	"RyanForce/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// scimMaxResults caps the page size of SCIM list requests.
+const scimMaxResults = 200
+
+// scimError is a failed SCIM operation, carrying the HTTP status and SCIM error type.
+type scimError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e *scimError) Error() string { return e.detail }
+
+// scimInvalid returns a 400 scimError of the given type.
+func scimInvalid(scimType, format string, args ...interface{}) *scimError {
+	return &scimError{status: http.StatusBadRequest, scimType: scimType, detail: fmt.Sprintf(format, args...)}
+}
+
+// SCIMServiceProviderConfig serves GET /scim/v2/ServiceProviderConfig.
+func SCIMServiceProviderConfig(c *gin.Context) {
+	supported := func(ok bool) gin.H { return gin.H{"supported": ok} }
+	writeSCIM(c, http.StatusOK, gin.H{
+		"schemas":        []string{scim.ConfigSchema},
+		"patch":          supported(true),
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scimMaxResults},
+		"changePassword": supported(true),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "The token set in RYANFORCE_SCIM_TOKEN",
+			"primary":     true,
+		}},
+	})
+}
+
+// SCIMResourceTypes serves GET /scim/v2/ResourceTypes.
+func SCIMResourceTypes(c *gin.Context) {
+	resources := []interface{}{
+		gin.H{"schemas": []string{scim.ResourceTypeSchema}, "id": "User", "name": "User",
+			"endpoint": "/Users", "schema": scim.UserSchema},
+		gin.H{"schemas": []string{scim.ResourceTypeSchema}, "id": "Group", "name": "Group",
+			"endpoint": "/Groups", "schema": scim.GroupSchema,
+			"description": "RyanForce roles (role-<name>) and client accounts (account-<id>)"},
+	}
+	writeSCIM(c, http.StatusOK, scim.ListResponse{
+		Schemas: []string{scim.ListSchema}, TotalResults: len(resources), StartIndex: 1,
+		ItemsPerPage: len(resources), Resources: resources,
+	})
+}
+
+// SCIMListUsers serves GET /scim/v2/Users with optional filter, startIndex, and count.
+func SCIMListUsers(c *gin.Context) {
+	filter, err := scim.ParseFilter(c.Query("filter"))
+	if err != nil {
+		respondSCIMError(c, scimInvalid(scim.ErrInvalidFilter, "%s", err))
+		return
+	}
+	query := config.DB.Model(&models.User{})
+	for _, cmp := range filter {
+		if query, err = scimUserFilter(query, cmp); err != nil {
+			respondSCIMError(c, scimInvalid(scim.ErrInvalidFilter, "%s", err))
+			return
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	start, count := scimPage(c)
+	var users []models.User
+	if err := query.Preload("Account").Order("id").Offset(start - 1).Limit(count).Find(&users).Error; err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	resources := make([]interface{}, 0, len(users))
+	for i := range users {
+		resources = append(resources, scimUserResource(&users[i]))
+	}
+	writeSCIM(c, http.StatusOK, scim.ListResponse{
+		Schemas: []string{scim.ListSchema}, TotalResults: int(total), StartIndex: start,
+		ItemsPerPage: len(resources), Resources: resources,
+	})
+}
+
+// SCIMGetUser serves GET /scim/v2/Users/:id.
+func SCIMGetUser(c *gin.Context) {
+	user, ok := loadSCIMUser(c)
+	if !ok {
+		return
+	}
+	writeSCIM(c, http.StatusOK, scimUserResource(user))
+}
+
+// SCIMCreateUser serves POST /scim/v2/Users. New users get RYANFORCE_SCIM_DEFAULT_ROLE and join
+// the account whose domain matches their email. Without a password they sign in with SSO or
+// set one through "forgot password".
+func SCIMCreateUser(c *gin.Context) {
+	var body scim.User
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondSCIMError(c, scimInvalid(scim.ErrInvalidSyntax, "invalid user: %s", err))
+		return
+	}
+
+	email := scimEmail(&body)
+	if !strings.Contains(email, "@") {
+		respondSCIMError(c, scimInvalid(scim.ErrInvalidValue, "userName or a primary email must be an email address"))
+		return
+	}
+	if emailTaken(email, 0) {
+		respondSCIMError(c, &scimError{status: http.StatusConflict, scimType: scim.ErrUniqueness, detail: "a user with this userName already exists"})
+		return
+	}
+	role := config.SCIMDefaultRole()
+	if !rbac.RoleExists(role) {
+		respondSCIMError(c, fmt.Errorf("RYANFORCE_SCIM_DEFAULT_ROLE names unknown role %s", role))
+		return
+	}
+	if rbac.IsPrivilegedRole(role) {
+		respondSCIMError(c, fmt.Errorf("RYANFORCE_SCIM_DEFAULT_ROLE names privileged role %s", role))
+		return
+	}
+
+	user := models.User{
+		Email:      email,
+		Name:       scimName(&body),
+		Role:       role,
+		ExternalID: body.ExternalID,
+		AccountID:  accountForEmail(email),
+	}
+	if body.Active != nil && !*body.Active {
+		now := time.Now()
+		user.IsLocked = true
+		user.DeactivatedAt = &now
+	}
+
+	var err error
+	if body.Password != "" {
+		err = CreateUser(&user, body.Password, "scim")
+		if err != nil {
+			err = scimInvalid(scim.ErrInvalidValue, "%s", err)
+		}
+	} else {
+		err = createUser(&user, "scim")
+	}
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	utils.LogAudit(fmt.Sprintf("[SCIM] Provisioned user %d (%s) with role %s from %s", user.ID, email, role, c.ClientIP()))
+	config.DB.Preload("Account").First(&user, user.ID)
+	c.Header("Location", scimLocation("Users", user.ID))
+	writeSCIM(c, http.StatusCreated, scimUserResource(&user))
+}
+
+// SCIMReplaceUser serves PUT /scim/v2/Users/:id. Attributes left out of the body are kept.
+func SCIMReplaceUser(c *gin.Context) {
+	user, ok := loadSCIMUser(c)
+	if !ok || !scimCanChange(c, user) {
+		return
+	}
+	var body scim.User
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondSCIMError(c, scimInvalid(scim.ErrInvalidSyntax, "invalid user: %s", err))
+		return
+	}
+
+	change := scimUserChange{active: body.Active}
+	if email := scimEmail(&body); email != "" {
+		change.email = &email
+	}
+	if name := scimName(&body); name != "" {
+		change.name = &name
+	}
+	if body.ExternalID != "" {
+		change.externalID = &body.ExternalID
+	}
+	if body.Password != "" {
+		change.password = &body.Password
+	}
+	finishSCIMUserChange(c, user, change)
+}
+
+// SCIMPatchUser serves PATCH /scim/v2/Users/:id. Attributes RyanForce does not store, such as
+// phone numbers or enterprise extension fields, are accepted and ignored.
+func SCIMPatchUser(c *gin.Context) {
+	user, ok := loadSCIMUser(c)
+	if !ok || !scimCanChange(c, user) {
+		return
+	}
+	ops, ok := bindSCIMPatch(c)
+	if !ok {
+		return
+	}
+
+	change := scimUserChange{}
+	given, family := splitName(user.Name)
+	nameParts := false
+	for _, op := range ops {
+		values, err := scimPatchTargets(op)
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		for _, v := range values {
+			if err := change.apply(op.Op, v.path, v.value, &given, &family, &nameParts); err != nil {
+				respondSCIMError(c, err)
+				return
+			}
+		}
+	}
+	if nameParts {
+		name := strings.TrimSpace(given + " " + family)
+		change.name = &name
+	}
+	finishSCIMUserChange(c, user, change)
+}
+
+// SCIMDeleteUser serves DELETE /scim/v2/Users/:id. Users are never hard-deleted over SCIM:
+// they are deactivated, which locks them and revokes their sessions.
+func SCIMDeleteUser(c *gin.Context) {
+	user, ok := loadSCIMUser(c)
+	if !ok || !scimCanChange(c, user) {
+		return
+	}
+	if err := setSCIMActive(user, false, c.ClientIP()); err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// scimUserChange collects the attributes a PUT or PATCH sets; nil fields are left alone.
+type scimUserChange struct {
+	email      *string
+	name       *string
+	externalID *string
+	active     *bool
+	password   *string
+}
+
+// apply records one PATCH value. Name parts are gathered separately so "name.givenName" and
+// "name.familyName" can arrive in different operations.
+func (ch *scimUserChange) apply(op string, path scim.Path, raw json.RawMessage, given, family *string, nameParts *bool) error {
+	if op == "remove" {
+		switch path.Attr {
+		case "externalid":
+			empty := ""
+			ch.externalID = &empty
+		case "username", "emails", "active", "password":
+			return &scimError{status: http.StatusBadRequest, scimType: scim.ErrMutability, detail: path.Attr + " cannot be removed"}
+		}
+		return nil
+	}
+
+	switch path.Attr {
+	case "username", "password", "externalid", "displayname":
+		s, err := scimString(raw)
+		if err != nil {
+			return err
+		}
+		switch path.Attr {
+		case "username":
+			s = strings.ToLower(strings.TrimSpace(s))
+			ch.email = &s
+		case "password":
+			ch.password = &s
+		case "externalid":
+			ch.externalID = &s
+		case "displayname":
+			ch.name = &s
+		}
+	case "active":
+		b, err := scimBool(raw)
+		if err != nil {
+			return err
+		}
+		ch.active = &b
+	case "emails":
+		var email string
+		if path.Sub == "value" {
+			s, err := scimString(raw)
+			if err != nil {
+				return err
+			}
+			email = s
+		} else {
+			var emails []scim.Email
+			if err := json.Unmarshal(raw, &emails); err != nil {
+				return scimInvalid(scim.ErrInvalidValue, "emails must be a list")
+			}
+			email = (&scim.User{Emails: emails}).PrimaryEmail()
+		}
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			ch.email = &email
+		}
+	case "name":
+		switch path.Sub {
+		case "":
+			var n scim.Name
+			if err := json.Unmarshal(raw, &n); err != nil {
+				return scimInvalid(scim.ErrInvalidValue, "name must be an object")
+			}
+			if n.Formatted != "" {
+				ch.name = &n.Formatted
+				return nil
+			}
+			*given, *family, *nameParts = n.GivenName, n.FamilyName, true
+		case "formatted":
+			s, err := scimString(raw)
+			if err != nil {
+				return err
+			}
+			ch.name = &s
+		case "givenname", "familyname":
+			s, err := scimString(raw)
+			if err != nil {
+				return err
+			}
+			if path.Sub == "givenname" {
+				*given = s
+			} else {
+				*family = s
+			}
+			*nameParts = true
+		}
+	}
+	return nil
+}
+
+// finishSCIMUserChange validates and saves a change and responds with the updated user.
+func finishSCIMUserChange(c *gin.Context, user *models.User, change scimUserChange) {
+	ip := c.ClientIP()
+	updates := map[string]interface{}{}
+	var changed []string
+
+	if change.email != nil && *change.email != strings.ToLower(user.Email) {
+		if !strings.Contains(*change.email, "@") {
+			respondSCIMError(c, scimInvalid(scim.ErrInvalidValue, "userName must be an email address"))
+			return
+		}
+		if emailTaken(*change.email, user.ID) {
+			respondSCIMError(c, &scimError{status: http.StatusConflict, scimType: scim.ErrUniqueness, detail: "a user with this userName already exists"})
+			return
+		}
+		updates["email"] = *change.email
+		changed = append(changed, "email")
+	}
+	if change.name != nil && *change.name != user.Name {
+		updates["name"] = *change.name
+		changed = append(changed, "name")
+	}
+	if change.externalID != nil && *change.externalID != user.ExternalID {
+		updates["external_id"] = *change.externalID
+		changed = append(changed, "externalId")
+	}
+
+	if change.password != nil {
+		if err := pwpolicy.Assign(user, *change.password); err != nil {
+			respondSCIMError(c, scimInvalid(scim.ErrInvalidValue, "%s", err))
+			return
+		}
+		if err := saveUserWithPassword(config.DB, user); err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		changed = append(changed, "password")
+	}
+	if len(updates) > 0 {
+		if err := config.DB.Model(user).Updates(updates).Error; err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+	}
+	if len(changed) > 0 {
+		utils.LogAudit(fmt.Sprintf("[SCIM] Updated %s of user %d (%s) from %s", strings.Join(changed, ", "), user.ID, user.Email, ip))
+	}
+
+	if change.active != nil {
+		if err := setSCIMActive(user, *change.active, ip); err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+	}
+
+	config.DB.Preload("Account").First(user, user.ID)
+	writeSCIM(c, http.StatusOK, scimUserResource(user))
+}
+
+// setSCIMActive deactivates a user (locking them and revoking their sessions) or reactivates
+// them. Nothing changes when the user is already in the requested state.
+func setSCIMActive(user *models.User, active bool, ip string) error {
+	if active == (user.DeactivatedAt == nil) {
+		return nil
+	}
+
+	var updates map[string]interface{}
+	if active {
+		updates = map[string]interface{}{"deactivated_at": nil, "is_locked": false,
+			"locked_until": nil, "failed_attempts": 0}
+	} else {
+		now := time.Now()
+		updates = map[string]interface{}{"deactivated_at": now, "is_locked": true, "sessions_revoked_at": now}
+	}
+	if err := config.DB.Model(user).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	action := map[bool]string{true: "Reactivated", false: "Deactivated"}[active]
+	utils.LogAudit(fmt.Sprintf("[SCIM] %s user %d (%s) from %s", action, user.ID, user.Email, ip))
+	return nil
+}
+
+// scimUserFilter narrows a user query by one filter comparison.
+func scimUserFilter(query *gorm.DB, cmp scim.Comparison) (*gorm.DB, error) {
+	columns := map[string]string{
+		"username":       "email",
+		"emails":         "email",
+		"emails.value":   "email",
+		"externalid":     "external_id",
+		"displayname":    "name",
+		"name.formatted": "name",
+	}
+
+	switch cmp.Attr {
+	case "id":
+		id, err := strconv.ParseUint(cmp.Value, 10, 64)
+		if err != nil || (cmp.Op != "eq" && cmp.Op != "ne") {
+			return nil, fmt.Errorf("id supports eq and ne with a numeric value")
+		}
+		if cmp.Op == "ne" {
+			return query.Where("id <> ?", id), nil
+		}
+		return query.Where("id = ?", id), nil
+	case "active":
+		if cmp.Op != "eq" && cmp.Op != "ne" {
+			return nil, fmt.Errorf("active supports eq and ne")
+		}
+		active := strings.EqualFold(cmp.Value, "true")
+		if cmp.Op == "ne" {
+			active = !active
+		}
+		if active {
+			return query.Where("deactivated_at IS NULL"), nil
+		}
+		return query.Where("deactivated_at IS NOT NULL"), nil
+	}
+
+	column, ok := columns[cmp.Attr]
+	if !ok {
+		return nil, fmt.Errorf("filtering on %s is not supported", cmp.Attr)
+	}
+	value := strings.ToLower(cmp.Value)
+	like := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	expr := "LOWER(" + column + ")"
+	switch cmp.Op {
+	case "eq":
+		return query.Where(expr+" = ?", value), nil
+	case "ne":
+		return query.Where(expr+" <> ?", value), nil
+	case "co":
+		return query.Where(expr+` LIKE ? ESCAPE '\'`, "%"+like+"%"), nil
+	case "sw":
+		return query.Where(expr+` LIKE ? ESCAPE '\'`, like+"%"), nil
+	case "ew":
+		return query.Where(expr+` LIKE ? ESCAPE '\'`, "%"+like), nil
+	case "pr":
+		return query.Where(column + " <> ''"), nil
+	}
+	return nil, fmt.Errorf("unsupported operator %s", cmp.Op)
+}
+
+// scimUserResource converts a user to its SCIM representation. Its groups are its role and,
+// for clients, its account.
+func scimUserResource(user *models.User) scim.User {
+	active := user.DeactivatedAt == nil
+	created, updated := user.CreatedAt, user.UpdatedAt
+	resource := scim.User{
+		Schemas:     []string{scim.UserSchema},
+		ID:          strconv.FormatUint(uint64(user.ID), 10),
+		ExternalID:  user.ExternalID,
+		UserName:    user.Email,
+		DisplayName: user.Name,
+		Emails:      []scim.Email{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Groups:      []scim.GroupRef{{Value: roleGroupID(user.Role), Display: user.Role, Ref: scimLocationID("Groups", roleGroupID(user.Role))}},
+		Meta: &scim.Meta{ResourceType: "User", Created: &created, LastModified: &updated,
+			Location: scimLocation("Users", user.ID)},
+	}
+	if user.Name != "" {
+		resource.Name = &scim.Name{Formatted: user.Name}
+	}
+	if user.AccountID != nil {
+		id := accountGroupID(*user.AccountID)
+		resource.Groups = append(resource.Groups, scim.GroupRef{Value: id, Display: user.Account.Name, Ref: scimLocationID("Groups", id)})
+	}
+	return resource
+}
+
+// loadSCIMUser loads the user named by the :id parameter, responding 404 if there is none.
+func loadSCIMUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err == nil {
+		err = config.DB.Preload("Account").First(&user, id).Error
+	}
+	if err != nil {
+		respondSCIMError(c, &scimError{status: http.StatusNotFound, detail: "user not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// errSCIMOutOfScope refuses a SCIM change to a privileged user or role (see rbac.IsPrivilegedRole).
+// The provisioning token manages the users an identity provider provisions; admins and other
+// privileged users are managed in RyanForce, so a leaked token cannot make anyone an admin or take
+// over one by setting their password or email.
+var errSCIMOutOfScope = &scimError{status: http.StatusForbidden, detail: "privileged roles and their members are outside the SCIM provisioning scope; manage them in RyanForce"}
+
+// scimCanChange reports whether SCIM may change a user, responding 403 if not.
+func scimCanChange(c *gin.Context, user *models.User) bool {
+	if !rbac.IsPrivilegedRole(user.Role) {
+		return true
+	}
+	utils.LogWarningIP(fmt.Sprintf("[SCIM] Refused %s of privileged user %d (%s)", c.Request.Method, user.ID, user.Email), c.ClientIP())
+	respondSCIMError(c, errSCIMOutOfScope)
+	return false
+}
+
+// scimEmail returns the lower-cased email for a user resource: the userName when it is an
+// email address, otherwise the primary email.
+func scimEmail(u *scim.User) string {
+	email := strings.TrimSpace(u.UserName)
+	if !strings.Contains(email, "@") {
+		if primary := strings.TrimSpace(u.PrimaryEmail()); primary != "" {
+			email = primary
+		}
+	}
+	return strings.ToLower(email)
+}
+
+// scimName returns the display name for a user resource.
+func scimName(u *scim.User) string {
+	if name := u.Name.Full(); name != "" {
+		return name
+	}
+	return strings.TrimSpace(u.DisplayName)
+}
+
+// splitName splits a stored name into a given name and the rest, for patching name parts.
+func splitName(name string) (string, string) {
+	given, family, _ := strings.Cut(strings.TrimSpace(name), " ")
+	return given, strings.TrimSpace(family)
+}
+
+// scimPatchTarget is one attribute a PATCH operation sets.
+type scimPatchTarget struct {
+	path  scim.Path
+	value json.RawMessage
+}
+
+// bindSCIMPatch reads a PATCH body and normalizes its operations.
+func bindSCIMPatch(c *gin.Context) ([]scim.PatchOperation, bool) {
+	var body scim.PatchRequest
+	if err := c.ShouldBindJSON(&body); err != nil || len(body.Operations) == 0 {
+		respondSCIMError(c, scimInvalid(scim.ErrInvalidSyntax, "a PatchOp body with Operations is required"))
+		return nil, false
+	}
+	for i := range body.Operations {
+		if err := body.Operations[i].Normalize(); err != nil {
+			respondSCIMError(c, scimInvalid(scim.ErrInvalidSyntax, "%s", err))
+			return nil, false
+		}
+	}
+	return body.Operations, true
+}
+
+// scimPatchTargets expands an operation into the attributes it sets. An operation without a
+// path carries an object whose keys are the paths.
+func scimPatchTargets(op scim.PatchOperation) ([]scimPatchTarget, error) {
+	if op.Path != "" {
+		path, err := scim.ParsePath(op.Path)
+		if err != nil {
+			return nil, scimInvalid(scim.ErrInvalidPath, "%s", err)
+		}
+		return []scimPatchTarget{{path: path, value: op.Value}}, nil
+	}
+	if op.Op == "remove" {
+		return nil, scimInvalid(scim.ErrNoTarget, "remove requires a path")
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(op.Value, &values); err != nil {
+		return nil, scimInvalid(scim.ErrInvalidValue, "an operation without a path needs an object value")
+	}
+	targets := make([]scimPatchTarget, 0, len(values))
+	for key, value := range values {
+		path, err := scim.ParsePath(key)
+		if err != nil {
+			return nil, scimInvalid(scim.ErrInvalidPath, "%s", err)
+		}
+		targets = append(targets, scimPatchTarget{path: path, value: value})
+	}
+	return targets, nil
+}
+
+// scimString decodes a JSON string value.
+func scimString(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", scimInvalid(scim.ErrInvalidValue, "expected a string value")
+	}
+	return s, nil
+}
+
+// scimBool decodes a JSON boolean, also accepting "true" and "false" strings, which some
+// identity providers send.
+func scimBool(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if v, err := strconv.ParseBool(s); err == nil {
+			return v, nil
+		}
+	}
+	return false, scimInvalid(scim.ErrInvalidValue, "expected a boolean value")
+}
+
+// scimPage reads the 1-based startIndex and count query parameters.
+func scimPage(c *gin.Context) (int, int) {
+	start, err := strconv.Atoi(c.Query("startIndex"))
+	if err != nil || start < 1 {
+		start = 1
+	}
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil || count < 0 || count > scimMaxResults {
+		count = scimMaxResults
+	}
+	return start, count
+}
+
+// scimLocation is the URL of a resource with a numeric ID.
+func scimLocation(resource string, id uint) string {
+	return scimLocationID(resource, strconv.FormatUint(uint64(id), 10))
+}
+
+// scimLocationID is the URL of a resource.
+func scimLocationID(resource, id string) string {
+	return config.BaseURL() + "/scim/v2/" + resource + "/" + id
+}
+
+// writeSCIM writes v as a SCIM JSON response.
+func writeSCIM(c *gin.Context, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		utils.LogError("[SCIM] Failed to encode response", err)
+		status, body = http.StatusInternalServerError, []byte(`{"status":"500"}`)
+	}
+	c.Data(status, scim.ContentType, body)
+}
+
+// respondSCIMError writes err as a SCIM error. Errors other than *scimError are logged and
+// reported as 500.
+func respondSCIMError(c *gin.Context, err error) {
+	var se *scimError
+	if !errors.As(err, &se) {
+		utils.LogError("[SCIM] Request failed", err)
+		se = &scimError{status: http.StatusInternalServerError, detail: "internal error"}
+	}
+	writeSCIM(c, se.status, scim.NewError(se.status, se.scimType, se.detail))
+}
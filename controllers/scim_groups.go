@@ -0,0 +1,554 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/scim"
+	"RyanForce/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SCIM groups are RyanForce roles and client accounts. Adding a user to a role group sets
+// their role; removing them falls back to RYANFORCE_SCIM_DEFAULT_ROLE. Adding a user to an
+// account group moves them into the account; removing them leaves them without one.
+const (
+	roleGroupPrefix    = "role-"
+	accountGroupPrefix = "account-"
+)
+
+// scimGroupInfo is a role or account seen as a SCIM group.
+type scimGroupInfo struct {
+	ID          string
+	DisplayName string
+	Role        string // Set for role groups
+	AccountID   uint   // Set for account groups
+	Created     *time.Time
+	Updated     *time.Time
+}
+
+// roleGroupID is the SCIM group ID of a role.
+func roleGroupID(role string) string { return roleGroupPrefix + role }
+
+// accountGroupID is the SCIM group ID of an account.
+func accountGroupID(id uint) string { return accountGroupPrefix + strconv.FormatUint(uint64(id), 10) }
+
+// SCIMListGroups serves GET /scim/v2/Groups. Filters may use id, displayName, and
+// members[value eq "..."].
+func SCIMListGroups(c *gin.Context) {
+	filter, err := scim.ParseFilter(c.Query("filter"))
+	if err != nil {
+		respondSCIMError(c, scimInvalid(scim.ErrInvalidFilter, "%s", err))
+		return
+	}
+	for _, cmp := range filter {
+		if cmp.Attr != "id" && cmp.Attr != "displayname" && cmp.Attr != "members.value" {
+			respondSCIMError(c, scimInvalid(scim.ErrInvalidFilter, "filtering groups on %s is not supported", cmp.Attr))
+			return
+		}
+	}
+
+	groups, err := scimGroups()
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	var matched []scimGroupInfo
+	for _, g := range groups {
+		ok := true
+		for _, cmp := range filter {
+			switch cmp.Attr {
+			case "id":
+				ok = ok && cmp.Matches(g.ID, true)
+			case "displayname":
+				ok = ok && cmp.Matches(g.DisplayName, true)
+			case "members.value":
+				ok = ok && scimGroupHasMember(g, cmp)
+			}
+		}
+		if ok {
+			matched = append(matched, g)
+		}
+	}
+
+	start, count := scimPage(c)
+	page := []scimGroupInfo{}
+	if start-1 < len(matched) {
+		page = matched[start-1:]
+		if len(page) > count {
+			page = page[:count]
+		}
+	}
+	withMembers := scimWantsMembers(c)
+	resources := make([]interface{}, 0, len(page))
+	for _, g := range page {
+		resource, err := scimGroupResource(g, withMembers)
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		resources = append(resources, resource)
+	}
+	writeSCIM(c, http.StatusOK, scim.ListResponse{
+		Schemas: []string{scim.ListSchema}, TotalResults: len(matched), StartIndex: start,
+		ItemsPerPage: len(resources), Resources: resources,
+	})
+}
+
+// SCIMGetGroup serves GET /scim/v2/Groups/:id.
+func SCIMGetGroup(c *gin.Context) {
+	g, ok := loadSCIMGroup(c)
+	if !ok {
+		return
+	}
+	resource, err := scimGroupResource(g, scimWantsMembers(c))
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, resource)
+}
+
+// SCIMCreateGroup serves POST /scim/v2/Groups by creating a client account. Roles need
+// permissions, so they are created in RyanForce and only their membership is managed here.
+func SCIMCreateGroup(c *gin.Context) {
+	var body scim.Group
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondSCIMError(c, scimInvalid(scim.ErrInvalidSyntax, "invalid group: %s", err))
+		return
+	}
+	name := strings.TrimSpace(body.DisplayName)
+	if name == "" {
+		respondSCIMError(c, scimInvalid(scim.ErrInvalidValue, "displayName is required"))
+		return
+	}
+	if rbac.RoleExists(name) {
+		respondSCIMError(c, &scimError{status: http.StatusConflict, scimType: scim.ErrUniqueness,
+			detail: fmt.Sprintf("a role with this name exists as group %s", roleGroupID(name))})
+		return
+	}
+	if scimAccountNameTaken(name, 0) {
+		respondSCIMError(c, &scimError{status: http.StatusConflict, scimType: scim.ErrUniqueness, detail: "an account with this name already exists"})
+		return
+	}
+
+	account := models.Account{Name: name}
+	if err := config.DB.Create(&account).Error; err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	utils.LogAudit(fmt.Sprintf("[SCIM] Created account %d (%s) from %s", account.ID, name, c.ClientIP()))
+
+	g := scimGroupInfo{ID: accountGroupID(account.ID), DisplayName: name, AccountID: account.ID,
+		Created: &account.CreatedAt, Updated: &account.UpdatedAt}
+	if body.Members != nil {
+		if err := setSCIMGroupMembers(g, *body.Members, c.ClientIP()); err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+	}
+	resource, err := scimGroupResource(g, true)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	c.Header("Location", scimLocationID("Groups", g.ID))
+	writeSCIM(c, http.StatusCreated, resource)
+}
+
+// SCIMReplaceGroup serves PUT /scim/v2/Groups/:id. Account groups can be renamed; role groups
+// keep their name. Members are replaced when the body lists them.
+func SCIMReplaceGroup(c *gin.Context) {
+	g, ok := loadSCIMGroup(c)
+	if !ok {
+		return
+	}
+	var body scim.Group
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondSCIMError(c, scimInvalid(scim.ErrInvalidSyntax, "invalid group: %s", err))
+		return
+	}
+
+	if name := strings.TrimSpace(body.DisplayName); name != "" && name != g.DisplayName {
+		if err := renameSCIMGroup(&g, name, c.ClientIP()); err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+	}
+	if body.Members != nil {
+		if err := setSCIMGroupMembers(g, *body.Members, c.ClientIP()); err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+	}
+	respondSCIMGroup(c, g)
+}
+
+// SCIMPatchGroup serves PATCH /scim/v2/Groups/:id: adding, removing, or replacing members,
+// and renaming account groups.
+func SCIMPatchGroup(c *gin.Context) {
+	g, ok := loadSCIMGroup(c)
+	if !ok {
+		return
+	}
+	ops, ok := bindSCIMPatch(c)
+	if !ok {
+		return
+	}
+
+	ip := c.ClientIP()
+	for _, op := range ops {
+		targets, err := scimPatchTargets(op)
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		for _, t := range targets {
+			switch t.path.Attr {
+			case "displayname":
+				name, err := scimString(t.value)
+				if err == nil && op.Op != "remove" {
+					err = renameSCIMGroup(&g, strings.TrimSpace(name), ip)
+				}
+				if err != nil {
+					respondSCIMError(c, err)
+					return
+				}
+			case "members":
+				if err := patchSCIMGroupMembers(g, op.Op, t, ip); err != nil {
+					respondSCIMError(c, err)
+					return
+				}
+			default:
+				respondSCIMError(c, scimInvalid(scim.ErrInvalidPath, "groups have no attribute %s", t.path.Attr))
+				return
+			}
+		}
+	}
+	respondSCIMGroup(c, g)
+}
+
+// SCIMDeleteGroup serves DELETE /scim/v2/Groups/:id, which is not supported: roles and
+// accounts hold tickets and permissions and are only deleted in RyanForce itself.
+func SCIMDeleteGroup(c *gin.Context) {
+	respondSCIMError(c, &scimError{status: http.StatusNotImplemented,
+		detail: "roles and accounts cannot be deleted over SCIM; remove their members instead"})
+}
+
+// patchSCIMGroupMembers applies one PATCH operation on a group's members.
+func patchSCIMGroupMembers(g scimGroupInfo, op string, t scimPatchTarget, ip string) error {
+	var members []scim.Member
+	if len(t.value) > 0 && string(t.value) != "null" {
+		if err := json.Unmarshal(t.value, &members); err != nil {
+			return scimInvalid(scim.ErrInvalidValue, "members must be a list of {\"value\": id}")
+		}
+	}
+	if t.path.Filter != nil {
+		if t.path.Filter.Attr != "value" || t.path.Filter.Op != "eq" {
+			return scimInvalid(scim.ErrInvalidPath, "only members[value eq \"id\"] is supported")
+		}
+		members = []scim.Member{{Value: t.path.Filter.Value}}
+	}
+
+	switch op {
+	case "add":
+		for _, m := range members {
+			if err := addSCIMGroupMember(g, m.Value, ip); err != nil {
+				return err
+			}
+		}
+	case "remove":
+		if t.path.Filter == nil && len(members) == 0 {
+			return setSCIMGroupMembers(g, nil, ip)
+		}
+		for _, m := range members {
+			if err := removeSCIMGroupMember(g, m.Value, ip); err != nil {
+				return err
+			}
+		}
+	case "replace":
+		return setSCIMGroupMembers(g, members, ip)
+	}
+	return nil
+}
+
+// setSCIMGroupMembers makes members the group's exact membership.
+func setSCIMGroupMembers(g scimGroupInfo, members []scim.Member, ip string) error {
+	want := map[string]bool{}
+	for _, m := range members {
+		want[m.Value] = true
+	}
+	current, err := scimGroupMemberIDs(g)
+	if err != nil {
+		return err
+	}
+	for _, id := range current {
+		if key := strconv.FormatUint(uint64(id), 10); !want[key] {
+			if err := removeSCIMGroupMember(g, key, ip); err != nil {
+				return err
+			}
+		}
+	}
+	for _, m := range members {
+		if err := addSCIMGroupMember(g, m.Value, ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSCIMGroupMember gives a user the group's role or moves them into its account.
+func addSCIMGroupMember(g scimGroupInfo, userID, ip string) error {
+	user, err := scimMember(userID)
+	if err != nil {
+		return err
+	}
+	if g.Role != "" {
+		if user.Role == g.Role {
+			return nil
+		}
+		if rbac.IsPrivilegedRole(user.Role) {
+			return errSCIMOutOfScope
+		}
+		if err := rbac.SetUserRole(user.ID, g.Role, 0); err != nil {
+			return scimRoleError(err)
+		}
+		utils.LogAudit(fmt.Sprintf("[SCIM] Role of user %d (%s) set to %s from %s", user.ID, user.Email, g.Role, ip))
+		return nil
+	}
+
+	if user.AccountID != nil && *user.AccountID == g.AccountID {
+		return nil
+	}
+	if rbac.IsPrivilegedRole(user.Role) {
+		return errSCIMOutOfScope
+	}
+	if err := config.DB.Model(user).Update("account_id", g.AccountID).Error; err != nil {
+		return err
+	}
+	utils.LogAudit(fmt.Sprintf("[SCIM] User %d (%s) added to account %d from %s", user.ID, user.Email, g.AccountID, ip))
+	return nil
+}
+
+// removeSCIMGroupMember takes a user out of a group. Users who do not belong are ignored.
+func removeSCIMGroupMember(g scimGroupInfo, userID, ip string) error {
+	user, err := scimMember(userID)
+	if err != nil {
+		return err
+	}
+	if g.Role != "" {
+		fallback := config.SCIMDefaultRole()
+		if user.Role != g.Role || g.Role == fallback {
+			return nil
+		}
+		if rbac.IsPrivilegedRole(user.Role) {
+			return errSCIMOutOfScope
+		}
+		if err := rbac.SetUserRole(user.ID, fallback, 0); err != nil {
+			return scimRoleError(err)
+		}
+		utils.LogAudit(fmt.Sprintf("[SCIM] User %d (%s) removed from role %s, now %s, from %s", user.ID, user.Email, g.Role, fallback, ip))
+		return nil
+	}
+
+	if user.AccountID == nil || *user.AccountID != g.AccountID {
+		return nil
+	}
+	if rbac.IsPrivilegedRole(user.Role) {
+		return errSCIMOutOfScope
+	}
+	if err := config.DB.Model(user).Update("account_id", nil).Error; err != nil {
+		return err
+	}
+	utils.LogAudit(fmt.Sprintf("[SCIM] User %d (%s) removed from account %d from %s", user.ID, user.Email, g.AccountID, ip))
+	return nil
+}
+
+// scimRoleError converts a failed role change to a SCIM error.
+func scimRoleError(err error) error {
+	if errors.Is(err, rbac.ErrPrivilegedRole) {
+		return errSCIMOutOfScope
+	}
+	return scimInvalid(scim.ErrInvalidValue, "%s", err)
+}
+
+// renameSCIMGroup renames an account group. Role groups cannot be renamed.
+func renameSCIMGroup(g *scimGroupInfo, name, ip string) error {
+	if name == g.DisplayName {
+		return nil
+	}
+	if g.Role != "" {
+		return &scimError{status: http.StatusBadRequest, scimType: scim.ErrMutability, detail: "role groups cannot be renamed"}
+	}
+	if name == "" {
+		return scimInvalid(scim.ErrInvalidValue, "displayName is required")
+	}
+	if scimAccountNameTaken(name, g.AccountID) {
+		return &scimError{status: http.StatusConflict, scimType: scim.ErrUniqueness, detail: "an account with this name already exists"}
+	}
+	if err := config.DB.Model(&models.Account{}).Where("id = ?", g.AccountID).Update("name", name).Error; err != nil {
+		return err
+	}
+	utils.LogAudit(fmt.Sprintf("[SCIM] Account %d renamed from %q to %q from %s", g.AccountID, g.DisplayName, name, ip))
+	g.DisplayName = name
+	return nil
+}
+
+// scimGroups lists every role SCIM may assign and every account as a group, roles first.
+func scimGroups() ([]scimGroupInfo, error) {
+	var groups []scimGroupInfo
+	for _, name := range rbac.RoleNames() {
+		if rbac.IsPrivilegedRole(name) {
+			continue
+		}
+		groups = append(groups, scimGroupInfo{ID: roleGroupID(name), DisplayName: name, Role: name})
+	}
+
+	var accounts []models.Account
+	if err := config.DB.Order("id").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	for i := range accounts {
+		a := &accounts[i]
+		groups = append(groups, scimGroupInfo{ID: accountGroupID(a.ID), DisplayName: a.Name, AccountID: a.ID,
+			Created: &a.CreatedAt, Updated: &a.UpdatedAt})
+	}
+	return groups, nil
+}
+
+// loadSCIMGroup resolves the :id parameter to a role or account, responding 404 if neither exists
+// and 403 for a privileged role.
+func loadSCIMGroup(c *gin.Context) (scimGroupInfo, bool) {
+	id := c.Param("id")
+	if role, ok := strings.CutPrefix(id, roleGroupPrefix); ok && rbac.RoleExists(role) {
+		if rbac.IsPrivilegedRole(role) {
+			utils.LogWarningIP(fmt.Sprintf("[SCIM] Refused %s of privileged role group %s", c.Request.Method, id), c.ClientIP())
+			respondSCIMError(c, errSCIMOutOfScope)
+			return scimGroupInfo{}, false
+		}
+		return scimGroupInfo{ID: id, DisplayName: role, Role: role}, true
+	}
+	if raw, ok := strings.CutPrefix(id, accountGroupPrefix); ok {
+		var account models.Account
+		if n, err := strconv.ParseUint(raw, 10, 64); err == nil && config.DB.First(&account, n).Error == nil {
+			return scimGroupInfo{ID: id, DisplayName: account.Name, AccountID: account.ID,
+				Created: &account.CreatedAt, Updated: &account.UpdatedAt}, true
+		}
+	}
+	respondSCIMError(c, &scimError{status: http.StatusNotFound, detail: "group not found"})
+	return scimGroupInfo{}, false
+}
+
+// respondSCIMGroup answers a successful group change with the group and its members.
+func respondSCIMGroup(c *gin.Context, g scimGroupInfo) {
+	resource, err := scimGroupResource(g, true)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, resource)
+}
+
+// scimGroupResource converts a group to its SCIM representation.
+func scimGroupResource(g scimGroupInfo, withMembers bool) (scim.Group, error) {
+	resource := scim.Group{
+		Schemas:     []string{scim.GroupSchema},
+		ID:          g.ID,
+		DisplayName: g.DisplayName,
+		Meta: &scim.Meta{ResourceType: "Group", Created: g.Created, LastModified: g.Updated,
+			Location: scimLocationID("Groups", g.ID)},
+	}
+	if !withMembers {
+		return resource, nil
+	}
+
+	var users []models.User
+	if err := scimGroupMembers(g).Order("id").Find(&users).Error; err != nil {
+		return resource, err
+	}
+	members := make([]scim.Member, 0, len(users))
+	for _, u := range users {
+		members = append(members, scim.Member{Value: strconv.FormatUint(uint64(u.ID), 10), Display: u.Email,
+			Ref: scimLocation("Users", u.ID)})
+	}
+	resource.Members = &members
+	return resource, nil
+}
+
+// scimGroupMemberIDs returns the IDs of a group's members.
+func scimGroupMemberIDs(g scimGroupInfo) ([]uint, error) {
+	var ids []uint
+	err := scimGroupMembers(g).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// scimGroupHasMember reports whether a member of the group matches a members.value comparison.
+func scimGroupHasMember(g scimGroupInfo, cmp scim.Comparison) bool {
+	ids, err := scimGroupMemberIDs(g)
+	if err != nil {
+		return false
+	}
+	for _, id := range ids {
+		if cmp.Matches(strconv.FormatUint(uint64(id), 10), true) {
+			return true
+		}
+	}
+	return false
+}
+
+// scimGroupMembers is the user query for a group's members.
+func scimGroupMembers(g scimGroupInfo) *gorm.DB {
+	if g.Role != "" {
+		return config.DB.Model(&models.User{}).Where("role = ?", g.Role)
+	}
+	return config.DB.Model(&models.User{}).Where("account_id = ?", g.AccountID)
+}
+
+// scimMember loads a user referenced by a member value.
+func scimMember(value string) (*models.User, error) {
+	var user models.User
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err == nil {
+		err = config.DB.First(&user, id).Error
+	}
+	if err != nil {
+		return nil, scimInvalid(scim.ErrInvalidValue, "member %q is not a user", value)
+	}
+	return &user, nil
+}
+
+// scimAccountNameTaken reports whether an account other than exceptID has this name.
+func scimAccountNameTaken(name string, exceptID uint) bool {
+	var count int64
+	config.DB.Model(&models.Account{}).Where("LOWER(name) = ? AND id <> ?", strings.ToLower(name), exceptID).Count(&count)
+	return count > 0
+}
+
+// scimWantsMembers reports whether the request asked to leave members out, as identity
+// providers do with excludedAttributes=members when only checking that a group exists.
+func scimWantsMembers(c *gin.Context) bool {
+	for _, attr := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if scim.NormalizeAttr(attr) == "members" {
+			return false
+		}
+	}
+	if attrs := c.Query("attributes"); attrs != "" {
+		for _, attr := range strings.Split(attrs, ",") {
+			if scim.NormalizeAttr(attr) == "members" {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
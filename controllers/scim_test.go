@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scimRouter mounts the SCIM handlers without the token middleware.
+func scimRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/Users", SCIMListUsers)
+	r.POST("/Users", SCIMCreateUser)
+	r.PATCH("/Users/:id", SCIMPatchUser)
+	r.DELETE("/Users/:id", SCIMDeleteUser)
+	r.GET("/Groups", SCIMListGroups)
+	r.PATCH("/Groups/:id", SCIMPatchGroup)
+	return r
+}
+
+func scimDo(t *testing.T, r *gin.Engine, method, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var out map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &out)
+	return w.Code, out
+}
+
+func TestSCIMUserLifecycle(t *testing.T) {
+	testutil.ResetDB(t)
+	r := scimRouter()
+	account := models.Account{Name: "Scim Corp", Domain: "scim.test"}
+	config.DB.Create(&account)
+
+	code, created := scimDo(t, r, http.MethodPost, "/Users",
+		`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"Bo@scim.test","externalId":"ext-1",
+		  "name":{"givenName":"Bo","familyName":"Builder"},"active":true}`)
+	if code != http.StatusCreated {
+		t.Fatalf("create: %d %v", code, created)
+	}
+	id := created["id"].(string)
+
+	var user models.User
+	config.DB.First(&user, id)
+	if user.Email != "bo@scim.test" || user.Name != "Bo Builder" || user.Role != "client" ||
+		user.AccountID == nil || *user.AccountID != account.ID {
+		t.Fatalf("provisioned user: %+v", user)
+	}
+
+	if code, _ := scimDo(t, r, http.MethodPost, "/Users", `{"userName":"bo@scim.test"}`); code != http.StatusConflict {
+		t.Errorf("duplicate create: %d", code)
+	}
+
+	code, list := scimDo(t, r, http.MethodGet, `/Users?filter=externalId+eq+%22ext-1%22`, "")
+	if code != http.StatusOK || list["totalResults"].(float64) != 1 {
+		t.Fatalf("filter: %d %v", code, list)
+	}
+	if code, _ := scimDo(t, r, http.MethodGet, `/Users?filter=title+eq+%22x%22`, ""); code != http.StatusBadRequest {
+		t.Errorf("unsupported filter: %d", code)
+	}
+
+	code, patched := scimDo(t, r, http.MethodPatch, "/Users/"+id,
+		`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[
+		  {"op":"Replace","path":"name.familyName","value":"Baker"},
+		  {"op":"replace","value":{"active":"False"}}]}`)
+	if code != http.StatusOK || patched["active"] != false {
+		t.Fatalf("patch: %d %v", code, patched)
+	}
+	config.DB.First(&user, id)
+	if user.Name != "Bo Baker" || !user.IsLocked || user.DeactivatedAt == nil || user.SessionsRevokedAt == nil {
+		t.Fatalf("deactivated user: %+v", user)
+	}
+
+	scimDo(t, r, http.MethodPatch, "/Users/"+id, `{"Operations":[{"op":"replace","path":"active","value":true}]}`)
+	user = models.User{}
+	config.DB.First(&user, id)
+	if user.IsLocked || user.DeactivatedAt != nil {
+		t.Fatal("user was not reactivated")
+	}
+
+	if code, _ := scimDo(t, r, http.MethodDelete, "/Users/"+id, ""); code != http.StatusNoContent {
+		t.Fatalf("delete: %d", code)
+	}
+	user = models.User{}
+	if err := config.DB.First(&user, id).Error; err != nil || user.DeactivatedAt == nil || !user.IsLocked {
+		t.Fatalf("delete should deactivate, not remove: %v %+v", err, user)
+	}
+}
+
+func TestSCIMGroupMembership(t *testing.T) {
+	testutil.ResetDB(t)
+	r := scimRouter()
+	account := models.Account{Name: "Group Corp"}
+	config.DB.Create(&account)
+	user := models.User{Email: "gail@group.test", Role: "client"}
+	config.DB.Create(&user)
+	uid := strconv.FormatUint(uint64(user.ID), 10)
+
+	code, _ := scimDo(t, r, http.MethodPatch, "/Groups/role-tech",
+		`{"Operations":[{"op":"add","path":"members","value":[{"value":"`+uid+`"}]}]}`)
+	if code != http.StatusOK {
+		t.Fatalf("add to role: %d", code)
+	}
+	scimDo(t, r, http.MethodPatch, "/Groups/"+accountGroupID(account.ID),
+		`{"Operations":[{"op":"add","path":"members","value":[{"value":"`+uid+`"}]}]}`)
+	config.DB.First(&user, user.ID)
+	if user.Role != "tech" || user.AccountID == nil || *user.AccountID != account.ID {
+		t.Fatalf("after add: role %s account %v", user.Role, user.AccountID)
+	}
+
+	code, list := scimDo(t, r, http.MethodGet, `/Groups?filter=displayName+eq+%22Group+Corp%22`, "")
+	if code != http.StatusOK || list["totalResults"].(float64) != 1 {
+		t.Fatalf("group filter: %d %v", code, list)
+	}
+
+	scimDo(t, r, http.MethodPatch, "/Groups/role-tech",
+		`{"Operations":[{"op":"remove","path":"members[value eq \"`+uid+`\"]"}]}`)
+	scimDo(t, r, http.MethodPatch, "/Groups/"+accountGroupID(account.ID),
+		`{"Operations":[{"op":"remove","path":"members[value eq \"`+uid+`\"]"}]}`)
+	user = models.User{}
+	config.DB.First(&user, uid)
+	if user.Role != "client" || user.AccountID != nil {
+		t.Fatalf("after remove: role %s account %v", user.Role, user.AccountID)
+	}
+}
+
+func TestSCIMCannotChangeAdmins(t *testing.T) {
+	testutil.ResetDB(t)
+	r := scimRouter()
+	admin := models.User{Email: "admin@scimscope.test", Role: "admin"}
+	client := models.User{Email: "client@scimscope.test", Role: "client"}
+	config.DB.Create(&admin)
+	config.DB.Create(&client)
+	adminPath := "/Users/" + strconv.FormatUint(uint64(admin.ID), 10)
+
+	patch := `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	  "Operations":[{"op":"replace","path":"password","value":"Takeover123!"},{"op":"replace","path":"userName","value":"evil@scimscope.test"}]}`
+	if code, _ := scimDo(t, r, http.MethodPatch, adminPath, patch); code != http.StatusForbidden {
+		t.Fatalf("patching an admin: %d, want 403", code)
+	}
+	if code, _ := scimDo(t, r, http.MethodDelete, adminPath, ""); code != http.StatusForbidden {
+		t.Fatalf("deactivating an admin: %d, want 403", code)
+	}
+	var reloaded models.User
+	config.DB.First(&reloaded, admin.ID)
+	if reloaded.Email != admin.Email || reloaded.PasswordHash != "" || reloaded.DeactivatedAt != nil {
+		t.Fatalf("admin changed over SCIM: %+v", reloaded)
+	}
+
+	clientPatch := `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	  "Operations":[{"op":"replace","path":"password","value":"Provisioned123!"}]}`
+	if code, out := scimDo(t, r, http.MethodPatch, "/Users/"+strconv.FormatUint(uint64(client.ID), 10), clientPatch); code != http.StatusOK {
+		t.Fatalf("setting a client's password: %d %v", code, out)
+	}
+	var provisioned models.User
+	config.DB.First(&provisioned, client.ID)
+	if provisioned.SessionsRevokedAt == nil {
+		t.Fatal("setting a password over SCIM did not revoke the user's sessions")
+	}
+}
+
+func TestSCIMCannotGrantPrivilegedRoles(t *testing.T) {
+	testutil.ResetDB(t)
+	r := scimRouter()
+	client := models.User{Email: "client@scimgrant.test", Role: "client"}
+	config.DB.Create(&client)
+	uid := strconv.FormatUint(uint64(client.ID), 10)
+
+	add := `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	  "Operations":[{"op":"add","path":"members","value":[{"value":"` + uid + `"}]}]}`
+	if code, _ := scimDo(t, r, http.MethodPatch, "/Groups/role-admin", add); code != http.StatusForbidden {
+		t.Fatalf("adding a member to role-admin: %d, want 403", code)
+	}
+	var reloaded models.User
+	config.DB.First(&reloaded, client.ID)
+	if reloaded.Role != "client" {
+		t.Fatalf("SCIM made a client %s", reloaded.Role)
+	}
+
+	_, out := scimDo(t, r, http.MethodGet, "/Groups", "")
+	for _, g := range out["Resources"].([]interface{}) {
+		if id := g.(map[string]interface{})["id"]; id == "role-admin" {
+			t.Fatal("role-admin listed as a SCIM group")
+		}
+	}
+	if err := rbac.SetUserRole(client.ID, rbac.RoleAdmin, 0); !errors.Is(err, rbac.ErrPrivilegedRole) {
+		t.Fatalf("provisioning the admin role: got %v", err)
+	}
+}
@@ -100,12 +100,12 @@ func SeedDemoData() {
 		ClientEmail       string
 		AssignedTechEmail string
 	}{
-		{"Setup VPN Access", "Client needs secure VPN access configured.", "high", "open", []string{"Networking", "Security"}, "cindy.client@acme.com", "alice.tech@example.com"},
-		{"Broken MacBook Pro", "Laptop not booting after update.", "medium", "open", []string{"MacOS", "Hardware Repair"}, "gary.client@globex.com", "bob.tech@example.com"},
-		{"Cloud Backup Failure", "Scheduled backups to cloud are failing nightly.", "critical", "open", []string{"Cloud", "Linux"}, "cindy.client@acme.com", "charlie.tech@example.com"},
-		{"Password Reset", "User forgot password and needs reset.", "low", "open", []string{"Customer Support"}, "gary.client@globex.com", "bob.tech@example.com"},
-		{"New Laptop Setup", "Prepare a new laptop for onboarding.", "medium", "open", []string{"Windows"}, "cindy.client@acme.com", ""},
-		{"Server Monitoring Scripts Broken", "Monitoring scripts aren't reporting server stats.", "high", "open", []string{"Scripting"}, "gary.client@globex.com", ""},
+		{"Setup VPN Access", "Client needs secure VPN access configured.", "high", "initially reported", []string{"Networking", "Security"}, "cindy.client@acme.com", "alice.tech@example.com"},
+		{"Broken MacBook Pro", "Laptop not booting after update.", "medium", "initially reported", []string{"MacOS", "Hardware Repair"}, "gary.client@globex.com", "bob.tech@example.com"},
+		{"Cloud Backup Failure", "Scheduled backups to cloud are failing nightly.", "critical", "initially reported", []string{"Cloud", "Linux"}, "cindy.client@acme.com", "charlie.tech@example.com"},
+		{"Password Reset", "User forgot password and needs reset.", "low", "initially reported", []string{"Customer Support"}, "gary.client@globex.com", "bob.tech@example.com"},
+		{"New Laptop Setup", "Prepare a new laptop for onboarding.", "medium", "initially reported", []string{"Windows"}, "cindy.client@acme.com", ""},
+		{"Server Monitoring Scripts Broken", "Monitoring scripts aren't reporting server stats.", "high", "initially reported", []string{"Scripting"}, "gary.client@globex.com", ""},
 	}
 
 	for _, t := range tickets {
@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/oidc"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// ErrPasswordLoginDisabled is returned by Login when the user's role must sign in with SSO.
+var ErrPasswordLoginDisabled = errors.New("password login is disabled for your role, use single sign-on")
+
+// errSSORefused is shown to users the identity provider vouched for but who may not sign in.
+var errSSORefused = errors.New("your single sign-on account is not allowed to use RyanForce")
+
+// errSSOLinkRequired is shown when an SSO login matches an existing user by email who may not be
+// linked automatically.
+var errSSOLinkRequired = errors.New("this account is not linked to single sign-on yet: sign in with your password and link it from Known Sign-ins, or ask an admin")
+
+// SSOEnabled reports whether OpenID Connect single sign-on is configured.
+func SSOEnabled() bool {
+	return config.LoadOIDCSettings().Enabled()
+}
+
+// SSOProviderName is the label for the single sign-on button, or empty when SSO is off.
+func SSOProviderName() string {
+	settings := config.LoadOIDCSettings()
+	if !settings.Enabled() {
+		return ""
+	}
+	return settings.ProviderName
+}
+
+// BeginSSOLogin starts an authorization code flow with PKCE. It returns the identity provider
+// URL to redirect to and a signed state value the caller must keep (in a cookie) until the
+// callback.
+func BeginSSOLogin(ctx context.Context) (string, string, error) {
+	return beginSSO(ctx, 0)
+}
+
+// BeginSSOLink starts the same flow for a signed-in user, whose account is linked to the identity
+// they sign in to the identity provider with, whatever its email.
+func BeginSSOLink(ctx context.Context, userID uint) (string, string, error) {
+	return beginSSO(ctx, userID)
+}
+
+// beginSSO starts a login, or a link for linkUser when it is not zero.
+func beginSSO(ctx context.Context, linkUser uint) (string, string, error) {
+	settings := config.LoadOIDCSettings()
+	if !settings.Enabled() {
+		return "", "", fmt.Errorf("single sign-on is not configured")
+	}
+
+	provider, err := oidc.Discover(ctx, settings.Issuer)
+	if err != nil {
+		utils.LogError("[SSO] Provider discovery failed", err)
+		return "", "", fmt.Errorf("single sign-on is unavailable, try again later")
+	}
+
+	var values [3]string
+	for i := range values {
+		if values[i], err = oidc.RandomString(); err != nil {
+			return "", "", fmt.Errorf("failed to start single sign-on")
+		}
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	stateToken, err := utils.GenerateOIDCState(state, nonce, verifier, linkUser)
+	if err != nil {
+		utils.LogError("[SSO] Failed to sign login state", err)
+		return "", "", fmt.Errorf("failed to start single sign-on")
+	}
+	return provider.AuthCodeURL(oidcConfig(settings), state, nonce, verifier), stateToken, nil
+}
+
+// CompleteSSOLogin handles the identity provider callback: it checks the state, exchanges the
+// code, verifies the ID token, provisions or links the user, and returns a session token.
+// Local MFA is not asked for; the identity provider is trusted to enforce its own. Network
+// allowlists still apply.
+func CompleteSSOLogin(ctx context.Context, stateToken, state, code, ip, userAgent string) (string, error) {
+	settings := config.LoadOIDCSettings()
+	if !settings.Enabled() {
+		return "", fmt.Errorf("single sign-on is not configured")
+	}
+
+	saved, err := utils.ParseOIDCState(stateToken)
+	if err != nil || state == "" || saved.State != state {
+		utils.LogWarningIP("[SSO] Callback with missing or mismatched state", ip)
+		return "", fmt.Errorf("your sign-in has expired, please try again")
+	}
+
+	provider, err := oidc.Discover(ctx, settings.Issuer)
+	if err != nil {
+		utils.LogError("[SSO] Provider discovery failed", err)
+		return "", fmt.Errorf("single sign-on is unavailable, try again later")
+	}
+	claims, err := provider.Exchange(ctx, oidcConfig(settings), code, saved.Verifier, saved.Nonce)
+	if err != nil {
+		utils.LogWarningIP("[SSO] Code exchange failed: "+err.Error(), ip)
+		return "", fmt.Errorf("single sign-on failed, please try again")
+	}
+
+	subject, _ := claims["sub"].(string)
+	email, _ := claims[settings.EmailClaim].(string)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if subject == "" || email == "" {
+		utils.LogWarningIP("[SSO] ID token without subject or email", ip)
+		return "", errSSORefused
+	}
+	verified, ok := claims["email_verified"].(bool)
+	if ok && !verified {
+		utils.LogWarningIP("[SSO] Refused unverified email — "+email, ip)
+		recordLoginEvent(nil, email, ip, false, "sso email not verified")
+		return "", errSSORefused
+	}
+
+	if saved.LinkUser != 0 {
+		if err := LinkSSOIdentity(saved.LinkUser, subject, fmt.Sprintf("user %d at %s", saved.LinkUser, ip)); err != nil {
+			recordLoginEvent(nil, email, ip, false, "sso link refused")
+			return "", err
+		}
+	}
+	user, err := ssoUser(settings, claims, subject, email, verified, ip)
+	if err != nil {
+		recordLoginEvent(nil, email, ip, false, "sso refused")
+		return "", err
+	}
+	if err := checkLoginAllowed(user, email, ip); err != nil {
+		return "", err
+	}
+
+	user.FailedAttempts = 0
+	user.LockoutCount = 0
+	user.LockedUntil = nil
+	utils.LogInfoIP("[SSO] Identity provider login — "+email, ip)
+	return issueSession(user, ip, userAgent)
+}
+
+// ssoUser finds the user for an SSO identity, linking an existing account with the same email
+// or creating one (just-in-time provisioning). Only client users are linked by email, and only
+// when the identity provider says the email is verified; anyone else must link explicitly, so an
+// identity provider account with a matching email cannot take over a staff account. A role from
+// the group mapping is applied on every login so IdP group changes take effect; the default role
+// is only used for new users.
+func ssoUser(settings config.OIDCSettings, claims jwt.MapClaims, subject, email string, verified bool, ip string) (*models.User, error) {
+	role, mapped := ssoRole(settings, claims)
+
+	var user models.User
+	err := config.DB.Where("oidc_subject = ?", subject).Limit(1).Find(&user).Error
+	byEmail := false
+	if err == nil && user.ID == 0 {
+		err = config.DB.Where("LOWER(email) = ?", email).Limit(1).Find(&user).Error
+		byEmail = user.ID != 0
+	}
+	if err != nil {
+		utils.LogError("[SSO] Failed to look up user", err)
+		return nil, fmt.Errorf("single sign-on failed, please try again")
+	}
+	if byEmail && user.OIDCSubject == "" && (!verified || user.Role != rbac.RoleClient) {
+		utils.LogWarningIP(fmt.Sprintf("[SSO] Refused to link user %d (%s, role %s) by email to subject %q: email verified %t",
+			user.ID, email, user.Role, subject, verified), ip)
+		return nil, errSSOLinkRequired
+	}
+
+	if user.ID == 0 {
+		if role == "" {
+			utils.LogWarningIP("[SSO] No role mapping for new user — "+email, ip)
+			return nil, errSSORefused
+		}
+		name, _ := claims["name"].(string)
+		user = models.User{
+			Email:       email,
+			Name:        name,
+			Role:        role,
+			OIDCSubject: subject,
+			AccountID:   accountForEmail(email),
+		}
+		if err := createUser(&user, "sso"); err != nil {
+			utils.LogError("[SSO] Failed to provision user "+email, err)
+			return nil, fmt.Errorf("single sign-on failed, please try again")
+		}
+		utils.LogAudit(fmt.Sprintf("[SSO] Provisioned user %d (%s) with role %s from %s", user.ID, email, role, ip))
+		return &user, nil
+	}
+
+	if user.OIDCSubject != "" && user.OIDCSubject != subject {
+		utils.LogWarningIP(fmt.Sprintf("[SSO] Subject mismatch for user %d — %s", user.ID, email), ip)
+		return nil, errSSORefused
+	}
+
+	updates := map[string]interface{}{}
+	if user.OIDCSubject == "" {
+		updates["oidc_subject"] = subject
+		utils.LogAudit(fmt.Sprintf("[SSO] Linked user %d (%s) to identity provider subject", user.ID, email))
+	}
+	if mapped && role != user.Role {
+		updates["role"] = role
+		updates["sessions_revoked_at"] = time.Now()
+		utils.LogAudit(fmt.Sprintf("[SSO] Role of user %d (%s) changed from %s to %s by group mapping", user.ID, email, user.Role, role))
+	}
+	if len(updates) > 0 {
+		if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
+			utils.LogError("[SSO] Failed to update user "+email, err)
+			return nil, fmt.Errorf("single sign-on failed, please try again")
+		}
+	}
+	return &user, nil
+}
+
+// LinkSSOIdentity links a user to an identity provider subject, so their SSO logins sign in as
+// them whatever email the identity provider reports. by describes who asked, for the audit log.
+// A user already linked to another subject, or a subject already linked to another user, is
+// refused.
+func LinkSSOIdentity(userID uint, subject, by string) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("id = ?", userID).Limit(1).Find(&user).Error; err != nil {
+			return err
+		}
+		if user.ID == 0 {
+			return ErrUserNotFound
+		}
+		if user.OIDCSubject == subject {
+			return nil
+		}
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("oidc_subject = ? AND id <> ?", subject, userID).Count(&taken).Error; err != nil {
+			return err
+		}
+		if user.OIDCSubject != "" || taken > 0 {
+			return errSSORefused
+		}
+		return tx.Model(&user).Update("oidc_subject", subject).Error
+	})
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, errSSORefused) {
+		utils.LogWarning(fmt.Sprintf("[SSO] Could not link user %d to subject %q, asked by %s: %v", userID, subject, by, err))
+		return err
+	}
+	if err != nil {
+		utils.LogError(fmt.Sprintf("[SSO] Failed to link user %d", userID), err)
+		return fmt.Errorf("single sign-on failed, please try again")
+	}
+	utils.LogAudit(fmt.Sprintf("[SSO] Linked user %d to identity provider subject %q, asked by %s", userID, subject, by))
+	return nil
+}
+
+// SSOLinked reports whether the user has been linked to an identity provider subject.
+func SSOLinked(userID uint) bool {
+	var count int64
+	config.DB.Model(&models.User{}).Where("id = ? AND oidc_subject <> ''", userID).Count(&count)
+	return count > 0
+}
+
+// ssoRole maps the user's groups claim to a role. mapped is false when the default role was used.
+// Roles named in the mapping that do not exist are ignored.
+func ssoRole(settings config.OIDCSettings, claims jwt.MapClaims) (role string, mapped bool) {
+	groups := map[string]bool{}
+	switch v := claims[settings.GroupsClaim].(type) {
+	case string:
+		groups[v] = true
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups[s] = true
+			}
+		}
+	}
+
+	for _, m := range settings.RoleMap {
+		if !groups[m.Group] {
+			continue
+		}
+		if !rbac.RoleExists(m.Role) {
+			utils.LogWarning("[SSO] Role mapping names unknown role " + m.Role)
+			continue
+		}
+		return m.Role, true
+	}
+	if settings.DefaultRole != "" && rbac.RoleExists(settings.DefaultRole) {
+		return settings.DefaultRole, false
+	}
+	return "", false
+}
+
+// accountForEmail returns the account whose domain matches the email's domain, if any.
+func accountForEmail(email string) *uint {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return nil
+	}
+	var account models.Account
+	if err := config.DB.Where("LOWER(domain) = ?", strings.ToLower(email[at+1:])).Limit(1).Find(&account).Error; err != nil || account.ID == 0 {
+		return nil
+	}
+	return &account.ID
+}
+
+// oidcConfig builds the relying party configuration from the SSO settings.
+func oidcConfig(settings config.OIDCSettings) oidc.Config {
+	return oidc.Config{
+		ClientID:     settings.ClientID,
+		ClientSecret: settings.ClientSecret,
+		RedirectURL:  settings.RedirectURL,
+		Scopes:       settings.Scopes,
+	}
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestSSOLinksOnlyVerifiedClientsByEmail(t *testing.T) {
+	testutil.ResetDB(t)
+	settings := config.OIDCSettings{DefaultRole: rbac.RoleClient}
+	client := models.User{Email: "client@ssolink.test", Role: rbac.RoleClient}
+	admin := models.User{Email: "admin@ssolink.test", Role: rbac.RoleAdmin}
+	config.DB.Create(&client)
+	config.DB.Create(&admin)
+
+	// An identity provider that leaves out email_verified cannot claim an account by email
+	if _, err := ssoUser(settings, jwt.MapClaims{}, "sub-client", client.Email, false, "127.0.0.1"); !errors.Is(err, errSSOLinkRequired) {
+		t.Fatalf("linking without email_verified: got %v", err)
+	}
+	if SSOLinked(client.ID) {
+		t.Fatal("client linked without a verified email")
+	}
+	user, err := ssoUser(settings, jwt.MapClaims{}, "sub-client", client.Email, true, "127.0.0.1")
+	if err != nil || user.ID != client.ID || !SSOLinked(client.ID) {
+		t.Fatalf("verified client not linked: %v", err)
+	}
+
+	// Staff are never linked by email, even when it is verified
+	groups := config.OIDCSettings{GroupsClaim: "groups", RoleMap: []config.OIDCRoleMapping{{Group: "clients", Role: rbac.RoleClient}}}
+	claims := jwt.MapClaims{"groups": []interface{}{"clients"}}
+	if _, err := ssoUser(groups, claims, "sub-admin", admin.Email, true, "127.0.0.1"); !errors.Is(err, errSSOLinkRequired) {
+		t.Fatalf("linking an admin by email: got %v", err)
+	}
+	config.DB.First(&admin, admin.ID)
+	if admin.OIDCSubject != "" || admin.Role != rbac.RoleAdmin {
+		t.Fatalf("admin changed by a refused SSO login: subject %q, role %s", admin.OIDCSubject, admin.Role)
+	}
+
+	// An explicit link works, and only once
+	if err := LinkSSOIdentity(admin.ID, "sub-admin", "test"); err != nil {
+		t.Fatalf("LinkSSOIdentity failed: %v", err)
+	}
+	if user, err := ssoUser(settings, jwt.MapClaims{}, "sub-admin", "other@ssolink.test", false, "127.0.0.1"); err != nil || user.ID != admin.ID {
+		t.Fatalf("linked admin could not sign in: %v", err)
+	}
+	if err := LinkSSOIdentity(admin.ID, "sub-other", "test"); !errors.Is(err, errSSORefused) {
+		t.Fatalf("relinking to another subject: got %v", err)
+	}
+	if err := LinkSSOIdentity(client.ID, "sub-admin", "test"); !errors.Is(err, errSSORefused) {
+		t.Fatalf("linking a subject already in use: got %v", err)
+	}
+}
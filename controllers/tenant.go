@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoAccount is returned for account self-service by a user who is not in a client account.
+var ErrNoAccount = errors.New("you are not a member of a client account")
+
+// ListColleagues returns the users in the actor's client account, including the actor.
+func ListColleagues(userID uint) ([]models.User, error) {
+	accountID := rbac.AccountOf(userID)
+	if accountID == 0 {
+		return nil, ErrNoAccount
+	}
+	var users []models.User
+	if err := config.DB.Where("account_id = ?", accountID).Order("email").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to load colleagues: %w", err)
+	}
+	return users, nil
+}
+
+// AddColleague creates a client user in the actor's own client account. Colleagues always get
+// the client role; an administrator designates further account contacts with set-role.
+func AddColleague(actorID uint, email, name, password string) (*models.User, error) {
+	accountID := rbac.AccountOf(actorID)
+	if accountID == 0 {
+		return nil, ErrNoAccount
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	var existing int64
+	config.DB.Unscoped().Model(&models.User{}).Where("LOWER(email) = ?", email).Count(&existing)
+	if existing > 0 {
+		return nil, errors.New("a user with that email already exists")
+	}
+
+	user := models.User{Email: email, Name: strings.TrimSpace(name), Role: rbac.RoleClient, AccountID: &accountID}
+	if err := CreateUser(&user, password, "account"); err != nil {
+		return nil, err
+	}
+	utils.LogAudit(fmt.Sprintf("[Account] User %d added colleague %d (%s) to account %d", actorID, user.ID, user.Email, accountID))
+	return &user, nil
+}
+
+// CountRow is one line of a grouped ticket count.
+type CountRow struct {
+	Value string
+	Count int64
+}
+
+// AccountReport summarises the tickets a user may report on (see rbac.ScopeReportTickets).
+type AccountReport struct {
+	AccountName string // Empty when the report covers every account
+	Statuses    []CountRow
+	Priorities  []CountRow
+	Overdue     []models.Ticket
+}
+
+// BuildAccountReport counts tickets by status and priority and lists the open tickets past
+// their SLA, limited to the actor's own account unless they may view every report.
+func BuildAccountReport(userID uint, role string) (*AccountReport, error) {
+	scoped := func() (*gorm.DB, bool) {
+		return rbac.ScopeReportTickets(config.DB.Model(&models.Ticket{}), userID, role)
+	}
+	query, ok := scoped()
+	if !ok {
+		return nil, ErrNoAccount
+	}
+
+	report := &AccountReport{}
+	if !rbac.Can(role, rbac.ReportsView) {
+		var account models.Account
+		if err := config.DB.First(&account, rbac.AccountOf(userID)).Error; err == nil {
+			report.AccountName = account.Name
+		}
+	}
+
+	if err := query.Select("status AS value, COUNT(*) AS count").Group("status").Order("status").Scan(&report.Statuses).Error; err != nil {
+		return nil, fmt.Errorf("failed to count tickets: %w", err)
+	}
+	query, _ = scoped()
+	if err := query.Select("priority AS value, COUNT(*) AS count").Group("priority").Order("priority").Scan(&report.Priorities).Error; err != nil {
+		return nil, fmt.Errorf("failed to count tickets: %w", err)
+	}
+
+	var open []models.Ticket
+	query, _ = scoped()
+	if err := query.Where("status != ?", StatusClosed).Order("created_at").Find(&open).Error; err != nil {
+		return nil, fmt.Errorf("failed to load open tickets: %w", err)
+	}
+	report.Overdue = overdueTickets(open, time.Now())
+	return report, nil
+}
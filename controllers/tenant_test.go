@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"testing"
+)
+
+func TestAccountScoping(t *testing.T) {
+	testutil.ResetDB(t)
+	acme := models.Account{Name: "Scope Acme", Domain: "acme.scope"}
+	globex := models.Account{Name: "Scope Globex", Domain: "globex.scope"}
+	config.DB.Create(&acme)
+	config.DB.Create(&globex)
+
+	contact := models.User{Email: "contact@acme.scope", Role: rbac.RoleAccountAdmin, AccountID: &acme.ID}
+	colleague := models.User{Email: "colleague@acme.scope", Role: rbac.RoleClient, AccountID: &acme.ID}
+	outsider := models.User{Email: "outsider@globex.scope", Role: rbac.RoleAccountAdmin, AccountID: &globex.ID}
+	for _, u := range []*models.User{&contact, &colleague, &outsider} {
+		config.DB.Create(u)
+	}
+	acmeTicket := models.Ticket{Title: "Acme printer", Priority: "critical", Status: "open", ClientID: colleague.ID}
+	globexTicket := models.Ticket{Title: "Globex VPN", Priority: "low", Status: "open", ClientID: outsider.ID}
+	config.DB.Create(&acmeTicket)
+	config.DB.Create(&globexTicket)
+	config.DB.Model(&acmeTicket).Update("created_at", acmeTicket.CreatedAt.AddDate(0, 0, -1))
+
+	visible := func(userID uint, role string) []uint {
+		query, ok := rbac.ScopeTickets(config.DB.Model(&models.Ticket{}), userID, role)
+		if !ok {
+			t.Fatalf("role %s cannot view tickets", role)
+		}
+		var ids []uint
+		query.Where("tickets.id IN ?", []uint{acmeTicket.ID, globexTicket.ID}).Pluck("id", &ids)
+		return ids
+	}
+	if ids := visible(contact.ID, contact.Role); len(ids) != 1 || ids[0] != acmeTicket.ID {
+		t.Fatalf("account contact sees %v, want only the Acme ticket", ids)
+	}
+	if ids := visible(outsider.ID, outsider.Role); len(ids) != 1 || ids[0] != globexTicket.ID {
+		t.Fatalf("other account's contact sees %v, want only the Globex ticket", ids)
+	}
+	if !rbac.CanUpdateTicket(contact.ID, contact.Role, &acmeTicket) || rbac.CanViewTicket(contact.ID, contact.Role, &globexTicket) {
+		t.Fatal("per-ticket checks do not follow the account")
+	}
+	if rbac.CanViewTicket(colleague.ID, colleague.Role, &models.Ticket{ClientID: contact.ID}) {
+		t.Fatal("ordinary client can view a colleague's ticket")
+	}
+
+	added, err := AddColleague(contact.ID, "New.Hire@acme.scope", "New Hire", "Client123!")
+	if err != nil {
+		t.Fatalf("AddColleague failed: %v", err)
+	}
+	if added.Role != rbac.RoleClient || added.AccountID == nil || *added.AccountID != acme.ID {
+		t.Fatalf("colleague not a client of the contact's account: %+v", added)
+	}
+	users, err := ListColleagues(contact.ID)
+	if err != nil || len(users) != 3 {
+		t.Fatalf("ListColleagues returned %d users, err %v", len(users), err)
+	}
+
+	report, err := BuildAccountReport(contact.ID, contact.Role)
+	if err != nil {
+		t.Fatalf("BuildAccountReport failed: %v", err)
+	}
+	if report.AccountName != acme.Name || len(report.Statuses) != 1 || report.Statuses[0].Count != 1 {
+		t.Fatalf("report not limited to the account: %+v", report)
+	}
+	if len(report.Overdue) != 1 || report.Overdue[0].ID != acmeTicket.ID {
+		t.Fatalf("overdue tickets: %+v", report.Overdue)
+	}
+}
@@ -2,59 +2,44 @@ package controllers
 
 import (
 	"RyanForce/config"
+	"RyanForce/events"
 	"RyanForce/models"
+	"RyanForce/rbac"
 	"RyanForce/utils"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// CreateTicket adds a new ticket to the database via CLI.
-// Logs success or failure to the console and log files.
-func CreateTicket(title, desc, priority, status string, clientID uint) {
-	ticket := models.Ticket{
-		Title:       title,
-		Description: desc,
-		Priority:    priority,
-		Status:      status,
-		ClientID:    clientID,
-	}
+// cliTickets returns the TicketService the CLI commands use.
+func cliTickets() *TicketService {
+	return NewTicketService(config.DB)
+}
 
-	if err := config.DB.Create(&ticket).Error; err != nil {
-		utils.LogErrorIP("[TicketCLI] Failed to create ticket", err, "CLI-Local")
-		fmt.Println("[Error] Failed to create ticket.")
+// CreateTicket raises a new ticket for the signed-in user via CLI.
+// Prints the new ticket's ID or why it could not be created.
+func CreateTicket(actor Actor, in CreateTicketInput) {
+	ticket, err := cliTickets().Create(actor, in)
+	if err != nil {
+		fmt.Println("[Error]", err)
 		return
 	}
 
-	fmt.Println("Ticket created successfully.")
-	utils.LogInfoIP(fmt.Sprintf("[TicketCLI] Ticket created by user %d — ID: %d", clientID, ticket.ID), "CLI-Local")
+	fmt.Printf("Ticket %d created successfully.\n", ticket.ID)
 }
 
-// ListTickets displays tickets to the CLI based on user role.
-// Supports client, technician, and admin views.
-func ListTickets(userID uint, role string) {
-	var tickets []models.Ticket
-	var err error
-
-	switch role {
-	case "client":
-		err = config.DB.Where("client_id = ?", userID).Find(&tickets).Error
-	case "tech":
-		err = config.DB.Where("tech_id = ?", userID).Find(&tickets).Error
-	case "admin":
-		err = config.DB.Find(&tickets).Error
-	default:
-		utils.LogWarning(fmt.Sprintf("[TicketCLI] Unauthorized list attempt by user %d (role: %s)", userID, role))
-		fmt.Println("[Error] Unauthorized access.")
-		return
-	}
-
+// ListTickets displays the tickets the user's role allows them to see via CLI.
+func ListTickets(actor Actor) {
+	tickets, err := cliTickets().List(actor, TicketFilter{})
 	if err != nil {
-		utils.LogError("[TicketCLI] Failed to retrieve tickets", err)
-		fmt.Println("[Error] Could not retrieve tickets.")
+		fmt.Println("[Error]", err)
 		return
 	}
 
@@ -67,63 +52,32 @@ func ListTickets(userID uint, role string) {
 		PrintTicketSummary(t)
 	}
 
-	utils.LogInfo(fmt.Sprintf("[TicketCLI] %d tickets listed for user %d (role: %s)", len(tickets), userID, role))
+	utils.LogInfo(fmt.Sprintf("[TicketCLI] %d tickets listed for user %d (role: %s)", len(tickets), actor.UserID, actor.Role))
 }
 
-/*
-// UpdateTicket updates a ticket's fields via CLI with role enforcement.
-// Allows clients, techs, and admins to modify their assigned tickets.
-func UpdateTicket(ticketID, userID uint, role, desc, priority, status string) {
-	var ticket models.Ticket
-	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
-		utils.LogErrorIP("[TicketCLI] Ticket not found", err, "CLI-Local")
-		fmt.Println("[Error] Ticket not found.")
-		return
-	}
-
-	// Role enforcement
-	switch role {
-	case "client":
-		if ticket.ClientID != userID {
-			fmt.Println("[Error] You cannot update someone else's ticket.")
-			return
-		}
-	case "tech":
-		if ticket.TechID == nil || *ticket.TechID != userID {
-			fmt.Println("[Error] This ticket is not assigned to you.")
-			return
-		}
-	}
-
-	// Update fields if provided
-	if desc != "" {
-		ticket.Description = desc
-	}
-	if priority != "" {
-		ticket.Priority = priority
-	}
-	if status != "" {
-		ticket.Status = status
-	}
-
-	// Handle closing tickets
-	if status == "closed" && ticket.ClosedAt == nil {
-		now := time.Now()
-		ticket.ClosedAt = &now
-	} else if status != "closed" {
-		ticket.ClosedAt = nil
+// UpdateTicket applies changes to a ticket via CLI.
+// Prints the result, including what differs when someone else changed the ticket first.
+func UpdateTicket(actor Actor, ticketID uint, in UpdateTicketInput) error {
+	_, err := cliTickets().Update(actor, ticketID, in)
+	var conflict *ConflictError
+	switch {
+	case errors.As(err, &conflict):
+		PrintConflict(conflict)
+	case err != nil:
+		fmt.Println("[Error]", err)
+	default:
+		fmt.Println("[Success] Ticket updated successfully.")
 	}
+	return err
+}
 
-	if err := config.DB.Save(&ticket).Error; err != nil {
-		utils.LogErrorIP("[TicketCLI] Failed to update ticket", err, "CLI-Local")
-		fmt.Println("[Error] Could not update ticket.")
-		return
+// PrintConflict prints a rejected edit and each field that differs in CLI format.
+func PrintConflict(conflict *ConflictError) {
+	fmt.Println("[Conflict]", conflict)
+	for _, line := range conflict.Diff() {
+		fmt.Println("  " + line)
 	}
-
-	fmt.Println("Ticket updated successfully.")
-	utils.LogInfoIP(fmt.Sprintf("[TicketCLI] Ticket %d updated by user %d (role: %s)", ticketID, userID, role), "CLI-Local")
 }
-*/
 
 // AddCommentToTicket creates a new comment for a ticket
 func AddCommentToTicket(ticketID uint, body string, authorID uint, authorEmail string, ip string) error {
@@ -134,7 +88,14 @@ func AddCommentToTicket(ticketID uint, body string, authorID uint, authorEmail s
 		Content:     body,
 		CreatedAt:   time.Now(),
 	}
-	if err := config.DB.Create(&comment).Error; err != nil {
+	err := events.Transaction(config.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		emit(events.CommentAdded{CommentID: comment.ID, TicketID: ticketID, AuthorID: authorID})
+		return nil
+	})
+	if err != nil {
 		utils.LogErrorIP("[Comment] Failed to add comment", err, ip)
 		return err
 	}
@@ -143,32 +104,65 @@ func AddCommentToTicket(ticketID uint, body string, authorID uint, authorEmail s
 	return nil
 }
 
-// EditComment updates the content of an existing comment
-func EditComment(commentID uint, newContent string, ip string) error {
+// EditComment updates the content of an existing comment. version is the one the edit is based
+// on and must match the saved one; an edit based on an older version returns a *ConflictError,
+// and one without a version ErrVersionRequired.
+func EditComment(commentID uint, newContent string, version uint, ip string) error {
+	if version == 0 {
+		return ErrVersionRequired
+	}
 	var comment models.Comment
 	if err := config.DB.First(&comment, commentID).Error; err != nil {
 		utils.LogWarningIP(fmt.Sprintf("[Comment] Edit failed — comment %d not found", commentID), ip)
 		return err
 	}
-	comment.Content = newContent
 
-	if err := config.DB.Save(&comment).Error; err != nil {
+	edited := comment
+	edited.Content, edited.Version = newContent, version+1
+	var updated int64
+	err := events.Transaction(config.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		res := tx.Model(&edited).Where("version = ?", version).Select("content", "version").Updates(&edited)
+		updated = res.RowsAffected
+		if res.Error != nil || updated == 0 {
+			return res.Error
+		}
+		emit(events.CommentEdited{CommentID: commentID, TicketID: comment.TicketID, Version: edited.Version})
+		return nil
+	})
+	if err != nil {
 		utils.LogErrorIP(fmt.Sprintf("[Comment] Failed to edit comment %d", commentID), err, ip)
 		return err
 	}
+	if updated == 0 {
+		config.DB.First(&comment, commentID)
+		utils.LogWarningIP(fmt.Sprintf("[Comment] Edit of comment %d rejected: based on an older version than %d", commentID, comment.Version), ip)
+		return &ConflictError{Resource: "comment", ID: commentID, CurrentVersion: comment.Version, Current: &comment,
+			Changes: addChange(nil, "content", newContent, comment.Content)}
+	}
 
-	utils.LogInfoIP(fmt.Sprintf("[Comment] Comment %d updated", commentID), ip)
+	utils.LogInfoIP(fmt.Sprintf("[Comment] Comment %d updated to version %d", commentID, edited.Version), ip)
 	return nil
 }
 
-// DeleteComment removes a comment by its ID
+// DeleteComment moves a comment to the trash by its ID
 func DeleteComment(commentID uint, ip string) error {
-	if err := config.DB.Delete(&models.Comment{}, commentID).Error; err != nil {
+	err := events.Transaction(config.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		var comment models.Comment
+		if err := tx.First(&comment, commentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&comment).Error; err != nil {
+			return err
+		}
+		emit(events.CommentDeleted{CommentID: commentID, TicketID: comment.TicketID})
+		return nil
+	})
+	if err != nil {
 		utils.LogErrorIP(fmt.Sprintf("[Comment] Failed to delete comment %d", commentID), err, ip)
 		return err
 	}
 
-	utils.LogInfoIP(fmt.Sprintf("[Comment] Comment %d deleted", commentID), ip)
+	utils.LogInfoIP(fmt.Sprintf("[Comment] Comment %d moved to trash", commentID), ip)
 	return nil
 }
 
@@ -181,48 +175,24 @@ func GetCommentsForTicket(ticketID uint) ([]models.Comment, error) {
 	return comments, nil
 }
 
-// AssignTicket assigns a technician to a ticket via CLI (admin only).
-// Logs the result of the assignment action.
-func AssignTicket(ticketID, techID uint) {
-	var ticket models.Ticket
-	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
-		utils.LogWarningIP(fmt.Sprintf("[TicketCLI] Assignment failed — ticket %d not found", ticketID), "CLI-Local")
-		fmt.Println("[Error] Ticket not found.")
-		return
-	}
-
-	ticket.TechID = &techID
-	if err := config.DB.Save(&ticket).Error; err != nil {
-		utils.LogErrorIP("[TicketCLI] Failed to assign technician", err, "CLI-Local")
-		fmt.Println("[Error] Failed to assign technician.")
+// AssignTicket assigns a technician to a ticket via CLI.
+// Prints the result of the assignment.
+func AssignTicket(actor Actor, ticketID, techID uint) {
+	if _, err := cliTickets().Assign(actor, ticketID, techID); err != nil {
+		fmt.Println("[Error]", err)
 		return
 	}
 
 	fmt.Printf("Ticket %d assigned to technician %d successfully.\n", ticketID, techID)
-	utils.LogInfoIP(fmt.Sprintf("[TicketCLI] Ticket %d assigned to technician %d", ticketID, techID), "CLI-Local")
 }
 
 // ViewTicket displays full ticket details via CLI with access control.
-// Supports client, technician, and admin roles.
-func ViewTicket(ticketID, userID uint, role string) {
-	var ticket models.Ticket
-	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
-		utils.LogWarning(fmt.Sprintf("[TicketCLI] View denied — ticket %d not found", ticketID))
-		fmt.Println("[Error] Ticket not found.")
-		return
-	}
-
-	switch role {
-	case "client":
-		if ticket.ClientID != userID {
-			fmt.Println("[Error] Unauthorized to view this ticket.")
-			return
-		}
-	case "tech":
-		if ticket.TechID == nil || *ticket.TechID != userID {
-			fmt.Println("[Error] Unauthorized to view this ticket.")
-			return
-		}
+// Returns false if the ticket doesn't exist or the user may not view it.
+func ViewTicket(actor Actor, ticketID uint) bool {
+	ticket, err := cliTickets().Get(actor, ticketID)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return false
 	}
 
 	fmt.Println("\n--- Ticket Details ---")
@@ -239,58 +209,27 @@ func ViewTicket(ticketID, userID uint, role string) {
 	}
 	fmt.Println("------------------------")
 
-	utils.LogInfo(fmt.Sprintf("[TicketCLI] Ticket %d viewed by user %d (role: %s)", ticket.ID, userID, role))
+	utils.LogInfo(fmt.Sprintf("[TicketCLI] Ticket %d viewed by user %d (role: %s)", ticket.ID, actor.UserID, actor.Role))
+	return true
 }
 
-// DeleteTicket permanently removes a ticket via CLI (admin only).
-// Logs the deletion action and provides CLI feedback.
-func DeleteTicket(ticketID uint) {
-	var ticket models.Ticket
-	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
-		utils.LogWarningIP(fmt.Sprintf("[TicketCLI] Deletion failed — ticket %d not found", ticketID), "CLI-Local")
-		fmt.Println("[Error] Ticket not found.")
-		return
-	}
-
-	if err := config.DB.Delete(&ticket).Error; err != nil {
-		utils.LogErrorIP("[TicketCLI] Failed to delete ticket", err, "CLI-Local")
-		fmt.Println("[Error] Failed to delete ticket.")
+// DeleteTicket moves a ticket to the trash via CLI.
+// Admins can restore it with 'restore'.
+func DeleteTicket(actor Actor, ticketID uint) {
+	if err := cliTickets().Delete(actor, ticketID); err != nil {
+		fmt.Println("[Error]", err)
 		return
 	}
 
-	fmt.Printf("Ticket %d deleted successfully.\n", ticketID)
-	utils.LogInfoIP(fmt.Sprintf("[TicketCLI] Ticket %d deleted", ticketID), "CLI-Local")
+	fmt.Printf("Ticket %d moved to trash.\n", ticketID)
 }
 
-// FilterTickets lists tickets based on priority/status filters via CLI.
+// FilterTickets lists tickets based on priority/status/keyword filters via CLI.
 // Applies role-based access control to filter results.
-func FilterTickets(userID uint, role, priority, status string) {
-	var tickets []models.Ticket
-	query := config.DB.Model(&models.Ticket{})
-
-	switch role {
-	case "client":
-		query = query.Where("client_id = ?", userID)
-	case "tech":
-		query = query.Where("tech_id = ?", userID)
-	case "admin":
-		// Admin can see all tickets
-	default:
-		fmt.Println("[Error] Unauthorized access.")
-		utils.LogWarning(fmt.Sprintf("[TicketCLI] Unauthorized filter attempt by user %d (role: %s)", userID, role))
-		return
-	}
-
-	if priority != "" {
-		query = query.Where("priority = ?", priority)
-	}
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
-
-	if err := query.Find(&tickets).Error; err != nil {
-		utils.LogError("[TicketCLI] Failed to filter tickets", err)
-		fmt.Println("[Error] Could not retrieve tickets.")
+func FilterTickets(actor Actor, filter TicketFilter) {
+	tickets, err := cliTickets().List(actor, filter)
+	if err != nil {
+		fmt.Println("[Error]", err)
 		return
 	}
 
@@ -303,7 +242,24 @@ func FilterTickets(userID uint, role, priority, status string) {
 		PrintTicketSummary(t)
 	}
 
-	utils.LogInfo(fmt.Sprintf("[TicketCLI] %d tickets listed for user %d (role: %s)", len(tickets), userID, role))
+	utils.LogInfo(fmt.Sprintf("[TicketCLI] %d tickets listed for user %d (role: %s)", len(tickets), actor.UserID, actor.Role))
+}
+
+// matchKeyword keeps the tickets whose title or description contains the keyword, ignoring
+// case. Descriptions are encrypted at rest, so the match runs on the decrypted tickets the
+// query already narrowed down rather than in SQL.
+func matchKeyword(tickets []models.Ticket, keyword string) []models.Ticket {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	if keyword == "" {
+		return tickets
+	}
+	matched := tickets[:0]
+	for _, t := range tickets {
+		if strings.Contains(strings.ToLower(t.Title), keyword) || strings.Contains(strings.ToLower(t.Description), keyword) {
+			matched = append(matched, t)
+		}
+	}
+	return matched
 }
 
 // PrintTicketSummary prints a brief summary of a ticket in CLI format.
@@ -318,135 +274,195 @@ func PrintTicketSummary(t models.Ticket) {
 		t.ID, t.Title, t.Priority, t.Status, assigned)
 }
 
-// SaveNewTicket saves a new ticket to the database.
-// Returns an error if creation fails.
-func SaveNewTicket(ticket *models.Ticket) error {
-	return config.DB.Create(ticket).Error
+// apiTickets returns the TicketService and actor for an API request.
+func apiTickets(c *gin.Context) (*TicketService, Actor) {
+	return NewTicketService(config.DB), ActorFromClaims(c.MustGet("user").(*utils.Claims), c.ClientIP())
+}
+
+// respondTicketError writes a TicketService error as a JSON error response.
+func respondTicketError(c *gin.Context, err error) {
+	c.JSON(TicketErrorStatus(err), gin.H{"error": err.Error()})
 }
 
-// ModifyTicket updates an existing ticket in the database.
-// Returns an error if update fails.
-func ModifyTicket(ticket *models.Ticket) error {
-	return config.DB.Save(ticket).Error
+// ticketRequest is the JSON body for creating a ticket, or for updating one where absent fields
+// are left unchanged.
+type ticketRequest struct {
+	Title        *string   `json:"title"`
+	Description  *string   `json:"description"`
+	Priority     *string   `json:"priority"`
+	Status       *string   `json:"status"`
+	SkillsNeeded *[]string `json:"skills_needed"`
+	Version      uint      `json:"version"` // Updates need this or If-Match, which takes precedence
 }
 
-// RemoveTicket deletes a ticket by its string ID.
-// Returns an error if deletion fails.
-func RemoveTicket(id string) error {
-	return config.DB.Delete(&models.Ticket{}, id).Error
+// ticketETag returns the entity tag of a ticket version.
+func ticketETag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// parseTicketETag reads the ticket version from an If-Match or If-None-Match header holding a
+// single entity tag. "*" matches any version and gives 0.
+func parseTicketETag(header string) (uint, bool) {
+	tag := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	if tag == "*" {
+		return 0, true
+	}
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(unquoted, 10, 64)
+	if err != nil || version == 0 {
+		return 0, false
+	}
+	return uint(version), true
 }
 
 // CreateTicketAPI handles creating a new ticket via POST (JSON input).
 // Returns the created ticket or an error response.
 func CreateTicketAPI(c *gin.Context) {
-	var ticket models.Ticket
-	if err := c.ShouldBindJSON(&ticket); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	svc, actor := apiTickets(c)
+
+	var body ticketRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	if err := SaveNewTicket(&ticket); err != nil {
-		utils.LogErrorIP("[TicketAPI] Failed to create ticket", err, c.ClientIP())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save ticket"})
-		return
+	var in CreateTicketInput
+	if body.Title != nil {
+		in.Title = *body.Title
+	}
+	if body.Description != nil {
+		in.Description = *body.Description
+	}
+	if body.Priority != nil {
+		in.Priority = *body.Priority
+	}
+	if body.SkillsNeeded != nil {
+		in.Skills = *body.SkillsNeeded
 	}
 
-	utils.LogInfoIP(fmt.Sprintf("[TicketAPI] Ticket created successfully — ID: %d", ticket.ID), c.ClientIP())
+	ticket, err := svc.Create(actor, in)
+	if err != nil {
+		respondTicketError(c, err)
+		return
+	}
+	c.Header("ETag", ticketETag(ticket.Version))
 	c.JSON(http.StatusCreated, ticket)
 }
 
-// UpdateTicketAPI updates an existing ticket via PUT/PATCH (JSON input).
-// Returns the updated ticket or an error response.
+// UpdateTicketAPI updates an existing ticket via PATCH (JSON input).
+// Send the ETag from GET /api/tickets/:id as If-Match, or its version in the body, so the update
+// is refused if the ticket changed since: 412 for a failed If-Match and 409 otherwise, with the
+// current ticket and the conflicting fields. An update with neither answers 428; If-Match: *
+// applies it to whatever is saved. Returns the updated ticket and its new ETag.
 func UpdateTicketAPI(c *gin.Context) {
-	id := c.Param("id")
-	var ticket models.Ticket
-
-	if err := config.DB.First(&ticket, id).Error; err != nil {
-		utils.LogWarningIP(fmt.Sprintf("[TicketAPI] Update failed — ticket %s not found", id), c.ClientIP())
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+	svc, actor := apiTickets(c)
+	id, err := ParseTicketID(c.Param("id"))
+	if err != nil {
+		respondTicketError(c, err)
 		return
 	}
 
-	if err := c.ShouldBindJSON(&ticket); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var body ticketRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	if err := ModifyTicket(&ticket); err != nil {
-		utils.LogErrorIP("[TicketAPI] Failed to update ticket", err, c.ClientIP())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update ticket"})
-		return
+	version := body.Version
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch != "" {
+		var ok bool
+		if version, ok = parseTicketETag(ifMatch); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match must be a single ticket ETag or *"})
+			return
+		}
 	}
 
-	utils.LogInfoIP(fmt.Sprintf("[TicketAPI] Ticket %d updated successfully", ticket.ID), c.ClientIP())
+	ticket, err := svc.Update(actor, id, UpdateTicketInput{
+		Title:       body.Title,
+		Description: body.Description,
+		Priority:    body.Priority,
+		Status:      body.Status,
+		Skills:      body.SkillsNeeded,
+		Version:     version,
+		AnyVersion:  ifMatch == "*",
+	})
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		status := http.StatusConflict
+		if ifMatch != "" {
+			status = http.StatusPreconditionFailed
+		}
+		c.Header("ETag", ticketETag(conflict.CurrentVersion))
+		c.JSON(status, gin.H{"error": conflict.Error(), "current": conflict.Current, "conflicts": conflict.Changes})
+		return
+	}
+	if err != nil {
+		respondTicketError(c, err)
+		return
+	}
+	c.Header("ETag", ticketETag(ticket.Version))
 	c.JSON(http.StatusOK, ticket)
 }
 
-// DeleteTicketAPI deletes a ticket by ID via REST API.
+// DeleteTicketAPI moves a ticket to the trash by ID via REST API.
 // Returns a success message or an error response.
 func DeleteTicketAPI(c *gin.Context) {
-	id := c.Param("id")
-
-	if err := RemoveTicket(id); err != nil {
-		utils.LogErrorIP(fmt.Sprintf("[TicketAPI] Failed to delete ticket %s", id), err, c.ClientIP())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ticket"})
+	svc, actor := apiTickets(c)
+	id, err := ParseTicketID(c.Param("id"))
+	if err == nil {
+		err = svc.Delete(actor, id)
+	}
+	if err != nil {
+		respondTicketError(c, err)
 		return
 	}
-
-	utils.LogInfoIP(fmt.Sprintf("[TicketAPI] Ticket %s deleted successfully", id), c.ClientIP())
-	c.JSON(http.StatusOK, gin.H{"message": "Ticket deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Ticket moved to trash"})
 }
 
 // ViewTicketAPI returns detailed ticket information via REST API.
-// Enforces role-based access control before displaying ticket data.
+// TicketService.Get checks the user may view the ticket. The ETag header carries the ticket's
+// version for If-Match on updates; a matching If-None-Match answers 304.
 func ViewTicketAPI(c *gin.Context) {
-	user := c.MustGet("user").(*utils.Claims)
-	ticketID := c.Param("id")
-
-	var ticket models.Ticket
-	if err := config.DB.
-		Preload("AssignedTech").
-		Preload("Client").
-		First(&ticket, ticketID).Error; err != nil {
-		utils.LogWarning(fmt.Sprintf("[TicketAPI] View failed — ticket %s not found", ticketID))
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+	svc, actor := apiTickets(c)
+	id, err := ParseTicketID(c.Param("id"))
+	if err != nil {
+		respondTicketError(c, err)
 		return
 	}
-
-	// Role-based access control
-	switch user.Role {
-	case "client":
-		if ticket.ClientID != user.UserID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized to view this ticket"})
-			return
-		}
-	case "tech":
-		if ticket.TechID == nil || *ticket.TechID != user.UserID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Ticket not assigned to you"})
-			return
-		}
-	case "admin":
-		// Admin allowed
-	default:
-		c.JSON(http.StatusForbidden, gin.H{"error": "Unknown role"})
+	ticket, err := svc.Get(actor, id)
+	if err != nil {
+		respondTicketError(c, err)
 		return
 	}
 
-	// Prepare response payload
+	var client models.User
+	config.DB.Select("id", "email").Where("id = ?", ticket.ClientID).Limit(1).Find(&client)
+	clientInfo := gin.H{
+		"id":    client.ID,
+		"email": client.Email,
+	}
+
 	assignedInfo := interface{}("(unassigned)")
-	if ticket.AssignedTech != nil {
+	if ticket.TechID != nil {
+		var tech models.User
+		config.DB.Select("id", "email").Where("id = ?", *ticket.TechID).Limit(1).Find(&tech)
 		assignedInfo = gin.H{
-			"id":    ticket.AssignedTech.ID,
-			"email": ticket.AssignedTech.Email,
+			"id":    tech.ID,
+			"email": tech.Email,
 		}
 	}
 
-	clientInfo := gin.H{
-		"id":    ticket.Client.ID,
-		"email": ticket.Client.Email,
+	c.Header("ETag", ticketETag(ticket.Version))
+	if version, ok := parseTicketETag(c.GetHeader("If-None-Match")); ok && (version == 0 || version == ticket.Version) {
+		c.Status(http.StatusNotModified)
+		return
 	}
 
-	utils.LogInfo(fmt.Sprintf("[TicketAPI] Ticket %d viewed by user %d (role: %s)", ticket.ID, user.UserID, user.Role))
+	utils.LogInfo(fmt.Sprintf("[TicketAPI] Ticket %d viewed by user %d (role: %s)", ticket.ID, actor.UserID, actor.Role))
 	c.JSON(http.StatusOK, gin.H{
 		"id":          ticket.ID,
 		"title":       ticket.Title,
@@ -458,113 +474,64 @@ func ViewTicketAPI(c *gin.Context) {
 		"created_at":  ticket.CreatedAt,
 		"updated_at":  ticket.UpdatedAt,
 		"closed_at":   ticket.ClosedAt,
+		"version":     ticket.Version,
 	})
 }
 
 // ListTicketsAPI lists tickets viewable by the authenticated user via REST API.
-// Supports client, technician, and admin role-based listing.
+// Results are scoped by the role's ticket view permissions.
 func ListTicketsAPI(c *gin.Context) {
-	user := c.MustGet("user").(*utils.Claims)
-	var tickets []models.Ticket
-
-	query := config.DB.Model(&models.Ticket{})
-
-	switch user.Role {
-	case "client":
-		query = query.Where("client_id = ?", user.UserID)
-	case "tech":
-		query = query.Where("tech_id = ?", user.UserID)
-	case "admin":
-		// Admin sees all tickets
-	default:
-		utils.LogWarning(fmt.Sprintf("[TicketAPI] Unauthorized list attempt by user %d (role: %s)", user.UserID, user.Role))
-		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized role"})
-		return
-	}
-
-	if err := query.Find(&tickets).Error; err != nil {
-		utils.LogError("[TicketAPI] Failed to list tickets", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tickets"})
+	svc, actor := apiTickets(c)
+	tickets, err := svc.List(actor, TicketFilter{})
+	if err != nil {
+		respondTicketError(c, err)
 		return
 	}
 
-	utils.LogInfo(fmt.Sprintf("[TicketAPI] %d tickets listed for user %d (role: %s)", len(tickets), user.UserID, user.Role))
+	utils.LogInfo(fmt.Sprintf("[TicketAPI] %d tickets listed for user %d (role: %s)", len(tickets), actor.UserID, actor.Role))
 	c.JSON(http.StatusOK, tickets)
 }
 
-// FilterTicketsAPI lists tickets with optional priority and status filters via REST API.
-// Applies role-based access control to results.
+// FilterTicketsAPI lists tickets with optional priority, status, and keyword (q) filters via
+// REST API. Applies role-based access control to results.
 func FilterTicketsAPI(c *gin.Context) {
-	user := c.MustGet("user").(*utils.Claims)
-	var tickets []models.Ticket
-
-	priority := c.Query("priority")
-	status := c.Query("status")
-
-	query := config.DB.Model(&models.Ticket{})
-
-	switch user.Role {
-	case "client":
-		query = query.Where("client_id = ?", user.UserID)
-	case "tech":
-		query = query.Where("tech_id = ?", user.UserID)
-	case "admin":
-		// Admin sees all
-	default:
-		utils.LogWarning(fmt.Sprintf("[TicketAPI] Unauthorized filter attempt by user %d (role: %s)", user.UserID, user.Role))
-		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized role"})
-		return
-	}
-
-	if priority != "" {
-		query = query.Where("priority = ?", priority)
-	}
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
-
-	if err := query.Find(&tickets).Error; err != nil {
-		utils.LogError("[TicketAPI] Failed to filter tickets", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to filter tickets"})
+	svc, actor := apiTickets(c)
+	tickets, err := svc.List(actor, TicketFilter{
+		Priority: c.Query("priority"),
+		Status:   c.Query("status"),
+		Keyword:  c.Query("q"),
+	})
+	if err != nil {
+		respondTicketError(c, err)
 		return
 	}
 
-	utils.LogInfo(fmt.Sprintf("[TicketAPI] %d tickets filtered for user %d (role: %s)", len(tickets), user.UserID, user.Role))
+	utils.LogInfo(fmt.Sprintf("[TicketAPI] %d tickets filtered for user %d (role: %s)", len(tickets), actor.UserID, actor.Role))
 	c.JSON(http.StatusOK, tickets)
 }
 
-// AssignTicketAPI assigns a technician to a ticket via REST API (admin only).
-// Requires techID field in JSON body.
+// AssignTicketAPI assigns a technician to a ticket via REST API.
+// Requires the tickets.assign permission and the tech_id of an active technician in the JSON body.
 func AssignTicketAPI(c *gin.Context) {
-	user := c.MustGet("user").(*utils.Claims)
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can assign technicians"})
+	svc, actor := apiTickets(c)
+	id, err := ParseTicketID(c.Param("id"))
+	if err != nil {
+		respondTicketError(c, err)
 		return
 	}
 
-	ticketID := c.Param("id")
 	var body struct {
-		TechID uint `json:"tech_id"`
+		TechID uint `json:"tech_id" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	var ticket models.Ticket
-	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+	if _, err := svc.Assign(actor, id, body.TechID); err != nil {
+		respondTicketError(c, err)
 		return
 	}
-
-	ticket.TechID = &body.TechID
-	if err := config.DB.Save(&ticket).Error; err != nil {
-		utils.LogErrorIP("[TicketAPI] Failed to assign technician", err, c.ClientIP())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign technician"})
-		return
-	}
-
-	utils.LogInfoIP(fmt.Sprintf("[TicketAPI] Ticket %s assigned to tech %d by admin %d", ticketID, body.TechID, user.UserID), c.ClientIP())
 	c.JSON(http.StatusOK, gin.H{"message": "Technician assigned successfully"})
 }
 
@@ -575,7 +542,7 @@ func AddComment(c *gin.Context) {
 	idStr := c.Param("id")
 	ticketID64, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
-		c.SetCookie("flash", "Invalid ticket ID", 3, "/", "", false, true)
+		utils.SetCookie(c, "flash", "Invalid ticket ID", 3)
 		c.Redirect(http.StatusSeeOther, "/dashboard")
 		return
 	}
@@ -583,7 +550,7 @@ func AddComment(c *gin.Context) {
 
 	content := c.PostForm("content")
 	if content == "" {
-		c.SetCookie("flash", "Comment content cannot be empty", 3, "/", "", false, true)
+		utils.SetCookie(c, "flash", "Comment content cannot be empty", 3)
 		c.Redirect(http.StatusSeeOther, "/tickets/"+idStr)
 		return
 	}
@@ -610,13 +577,13 @@ func AddComment(c *gin.Context) {
 
 	if err := config.DB.Create(&comment).Error; err != nil {
 		utils.LogError("[Comment] Failed to save", err)
-		c.SetCookie("flash", "Failed to save comment", 3, "/", "", false, true)
+		utils.SetCookie(c, "flash", "Failed to save comment", 3)
 		c.Redirect(http.StatusSeeOther, "/tickets/"+idStr)
 		return
 	}
 
 	utils.LogInfo(fmt.Sprintf("[Comment] User %d added comment to ticket %d", claims.UserID, ticketID))
-	c.SetCookie("flash", "Comment posted successfully", 3, "/", "", false, true)
+	utils.SetCookie(c, "flash", "Comment posted successfully", 3)
 	c.Redirect(http.StatusSeeOther, "/tickets/"+idStr)
 }
 */
@@ -646,8 +613,8 @@ func ShowEditCommentForm(c *gin.Context) {
 		return
 	}
 
-	if comment.AuthorID != claims.UserID && claims.Role != "admin" {
-		c.SetCookie("flash", "You are not allowed to edit this comment", 3, "/", "", false, true)
+	if !rbac.CanModifyComment(claims.UserID, claims.Role, comment.AuthorID) {
+		utils.SetCookie(c, "flash", "You are not allowed to edit this comment", 3)
 		c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
 		return
 	}
@@ -674,7 +641,7 @@ func UpdateComment(c *gin.Context) {
 	}
 
 	if newContent == "" {
-		c.SetCookie("flash", "Comment cannot be empty", 3, "/", "", false, true)
+		utils.SetCookie(c, "flash", "Comment cannot be empty", 3)
 		c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
 		return
 	}
@@ -690,8 +657,8 @@ func UpdateComment(c *gin.Context) {
 		return
 	}
 
-	if comment.AuthorID != claims.UserID && claims.Role != "admin" {
-		c.SetCookie("flash", "You are not allowed to update this comment", 3, "/", "", false, true)
+	if !rbac.CanModifyComment(claims.UserID, claims.Role, comment.AuthorID) {
+		utils.SetCookie(c, "flash", "You are not allowed to update this comment", 3)
 		c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
 		return
 	}
@@ -699,13 +666,13 @@ func UpdateComment(c *gin.Context) {
 	comment.Content = newContent
 	if err := config.DB.Save(&comment).Error; err != nil {
 		utils.LogError("[CommentWebUI] Failed to update comment", err)
-		c.SetCookie("flash", "Failed to update comment", 3, "/", "", false, true)
+		utils.SetCookie(c, "flash", "Failed to update comment", 3)
 		c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
 		return
 	}
 
 	utils.LogInfo(fmt.Sprintf("[CommentWebUI] Comment %d updated by user %d", comment.ID, claims.UserID))
-	c.SetCookie("flash", "Comment updated successfully", 3, "/", "", false, true)
+	utils.SetCookie(c, "flash", "Comment updated successfully", 3)
 	c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
 }
 */
@@ -732,7 +699,7 @@ func DeleteComment(c *gin.Context) {
 		return
 	}
 
-	if comment.AuthorID != claims.UserID && claims.Role != "admin" {
+	if !rbac.CanModifyComment(claims.UserID, claims.Role, comment.AuthorID) {
 		c.String(http.StatusForbidden, "You are not allowed to delete this comment")
 		return
 	}
@@ -743,7 +710,7 @@ func DeleteComment(c *gin.Context) {
 	}
 
 	utils.LogInfo(fmt.Sprintf("[Comment] Comment %d deleted by user %d", comment.ID, claims.UserID))
-	c.SetCookie("flash", "Comment deleted successfully", 3, "/", "", false, true)
+	utils.SetCookie(c, "flash", "Comment deleted successfully", 3)
 	c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
 }
 */
@@ -760,15 +727,18 @@ func FindMatchingTechs(c *gin.Context) {
 		return
 	}
 
-	neededSkills, err := utils.ParseSkills(ticket.SkillsNeeded)
-	if err != nil {
-		utils.LogError("[TicketAdmin] Failed to parse required skills", err)
-		c.String(http.StatusInternalServerError, "Invalid skills needed format")
-		return
+	var neededSkills []string
+	if ticket.SkillsNeeded != "" {
+		var err error
+		if neededSkills, err = utils.ParseSkills(ticket.SkillsNeeded); err != nil {
+			utils.LogError("[TicketAdmin] Failed to parse required skills", err)
+			c.String(http.StatusInternalServerError, "Invalid skills needed format")
+			return
+		}
 	}
 
 	var techs []models.User
-	if err := config.DB.Where("role = ?", "tech").Find(&techs).Error; err != nil {
+	if err := config.DB.Where("role = ? AND deactivated_at IS NULL", rbac.RoleTech).Find(&techs).Error; err != nil {
 		utils.LogError("[TicketAdmin] Failed to fetch technicians", err)
 		c.String(http.StatusInternalServerError, "Error fetching technicians")
 		return
@@ -808,55 +778,36 @@ func FindMatchingTechs(c *gin.Context) {
 // AssignTechToTicket assigns a technician to a ticket based on admin selection.
 // Handles POST requests from the assignment interface.
 func AssignTechToTicket(c *gin.Context) {
-	ticketID := c.Param("id")
-	techID := c.Param("tech_id")
-	var ticket models.Ticket
-
-	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
-		utils.LogWarning(fmt.Sprintf("[TicketAdmin] Assign failed — ticket %s not found", ticketID))
-		c.HTML(http.StatusNotFound, "404.html", nil)
-		return
+	svc, actor := apiTickets(c)
+	id, err := ParseTicketID(c.Param("id"))
+	if err == nil {
+		techID, parseErr := strconv.ParseUint(c.Param("tech_id"), 10, 64)
+		if parseErr != nil {
+			err = ErrInvalidTechnician
+		} else {
+			_, err = svc.Assign(actor, id, uint(techID))
+		}
 	}
-
-	parsedTechID, err := strconv.ParseUint(techID, 10, 64)
 	if err != nil {
-		c.SetCookie("flash", "Invalid technician ID", 3, "/", "", false, true)
-		c.Redirect(http.StatusSeeOther, "/admin/unassigned-tickets")
-		return
-	}
-
-	techIDUint := uint(parsedTechID)
-	ticket.TechID = &techIDUint
-
-	if err := config.DB.Save(&ticket).Error; err != nil {
-		utils.LogError("[TicketAdmin] Failed to assign technician", err)
-		c.SetCookie("flash", "Failed to assign technician", 3, "/", "", false, true)
-		c.Redirect(http.StatusSeeOther, "/admin/unassigned-tickets")
+		c.Redirect(http.StatusSeeOther, "/admin/unassigned-tickets?error="+url.QueryEscape(err.Error()))
 		return
 	}
 
-	utils.LogInfo(fmt.Sprintf("[TicketAdmin] Ticket %s assigned to tech %d", ticketID, techIDUint))
-	c.SetCookie("flash", "Technician assigned successfully", 3, "/", "", false, true)
-	c.Redirect(http.StatusSeeOther, "/admin/unassigned-tickets")
+	c.Redirect(http.StatusSeeOther, "/admin/unassigned-tickets?success="+url.QueryEscape(fmt.Sprintf("Ticket %d assigned successfully", id)))
 }
 
 // UnassignTechFromTicket handles POST /admin/tickets/:id/unassign
 // Removes the assigned technician from a ticket.
 func UnassignTechFromTicket(c *gin.Context) {
-	ticketID := c.Param("id")
-
-	var ticket models.Ticket
-	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
-		c.String(http.StatusNotFound, "Ticket not found")
-		return
+	svc, actor := apiTickets(c)
+	id, err := ParseTicketID(c.Param("id"))
+	if err == nil {
+		_, err = svc.Unassign(actor, id)
 	}
-
-	ticket.TechID = nil
-
-	if err := config.DB.Save(&ticket).Error; err != nil {
-		c.String(http.StatusInternalServerError, "Failed to unassign technician")
+	if err != nil {
+		c.Redirect(http.StatusFound, "/admin/assigned-tickets?error="+url.QueryEscape(err.Error()))
 		return
 	}
 
-	c.Redirect(http.StatusFound, "/admin/assigned-tickets?success=Ticket+"+ticketID+"+unassigned+successfully")
+	c.Redirect(http.StatusFound, "/admin/assigned-tickets?success="+url.QueryEscape(fmt.Sprintf("Ticket %d unassigned successfully", id)))
 }
@@ -0,0 +1,561 @@
+package controllers
+
+import (
+	"RyanForce/events"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TicketPriorities are the priorities a ticket may have, lowest first.
+var TicketPriorities = []string{"low", "medium", "high", "critical"}
+
+// TicketStatuses are the statuses a ticket moves through. New tickets start in the first one;
+// the last one closes the ticket.
+var TicketStatuses = []string{"initially reported", StatusAwaitingCustomer, "support to follow up", "working", StatusClosed}
+
+// StatusClosed is the status of a closed ticket. Reports and retention treat every other status
+// as open.
+const StatusClosed = "closed"
+
+// StatusAwaitingCustomer is the status of a ticket waiting on the customer. The auto-close job
+// closes tickets left in it too long.
+const StatusAwaitingCustomer = "customer to follow up"
+
+// statusAliases maps status names used by older forms and API clients to the current ones.
+var statusAliases = map[string]string{
+	"open":        "initially reported",
+	"pending":     StatusAwaitingCustomer,
+	"in progress": "working",
+	"resolved":    StatusClosed,
+}
+
+// defaultTicketPriority is used when a new ticket does not name a priority.
+const defaultTicketPriority = "medium"
+
+// maxTicketTitleLength and maxTicketSkills bound what a ticket may hold.
+const (
+	maxTicketTitleLength = 200
+	maxTicketSkills      = 5
+)
+
+// ErrTicketNotFound is returned for tickets that do not exist or are in the trash.
+var ErrTicketNotFound = errors.New("ticket not found")
+
+// ErrTicketForbidden is returned when the actor's role does not allow the action on the ticket.
+var ErrTicketForbidden = errors.New("you are not allowed to do that with this ticket")
+
+// ErrInvalidTechnician is returned when a ticket is assigned to a user who does not exist, is
+// deactivated, or does not have the tech role.
+var ErrInvalidTechnician = errors.New("no active technician has that ID")
+
+// TicketValidationError reports a ticket field that failed validation.
+type TicketValidationError struct {
+	Field   string
+	Message string
+}
+
+// Error implements error.
+func (e *TicketValidationError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Actor is the user a ticket operation is performed for, with the IP it came from for logging.
+type Actor struct {
+	UserID uint
+	Email  string
+	Role   string
+	IP     string
+}
+
+// ActorFromClaims returns the actor for a signed-in session.
+func ActorFromClaims(claims *utils.Claims, ip string) Actor {
+	return Actor{UserID: claims.UserID, Email: claims.Email, Role: claims.Role, IP: ip}
+}
+
+// CreateTicketInput holds the fields of a new ticket. Priority defaults to medium.
+type CreateTicketInput struct {
+	Title       string
+	Description string
+	Priority    string
+	Skills      []string
+}
+
+// UpdateTicketInput holds the ticket fields to change; nil fields are left as they are.
+type UpdateTicketInput struct {
+	Title       *string
+	Description *string
+	Priority    *string
+	Status      *string
+	Skills      *[]string
+	Version     uint   // Version the changes are based on; required unless AnyVersion is set
+	AnyVersion  bool   // Apply the changes to whatever version is saved, as If-Match: * asks
+	Comment     string // Posted along with the changes, which are only saved if it is; requires comments.create
+}
+
+// TicketFilter narrows a ticket listing. Empty fields do not filter.
+type TicketFilter struct {
+	Priority string
+	Status   string
+	Keyword  string // Matched against the title and description, ignoring case
+}
+
+// TicketService creates, changes, and lists tickets for the CLI, WebUI, and REST API alike, so
+// every interface applies the same validation and permission rules.
+type TicketService struct {
+	DB *gorm.DB
+}
+
+// NewTicketService returns a TicketService using db.
+func NewTicketService(db *gorm.DB) *TicketService {
+	return &TicketService{DB: db}
+}
+
+// ParseTicketID parses a ticket ID from user input. Anything that is not an ID is reported as
+// a ticket that does not exist.
+func ParseTicketID(s string) (uint, error) {
+	id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	if err != nil || id == 0 {
+		return 0, ErrTicketNotFound
+	}
+	return uint(id), nil
+}
+
+// TicketErrorStatus returns the HTTP status for an error from the TicketService.
+func TicketErrorStatus(err error) int {
+	var invalid *TicketValidationError
+	switch {
+	case errors.Is(err, ErrTicketNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrTicketForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrInvalidTechnician), errors.As(err, &invalid):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrEditConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrVersionRequired):
+		return http.StatusPreconditionRequired
+	}
+	return http.StatusInternalServerError
+}
+
+// Get returns a ticket the actor may view.
+func (s *TicketService) Get(actor Actor, id uint) (*models.Ticket, error) {
+	ticket, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if !rbac.CanViewTicket(actor.UserID, actor.Role, ticket) {
+		s.denied(actor, "view", id)
+		return nil, ErrTicketForbidden
+	}
+	return ticket, nil
+}
+
+// GetForUpdate returns a ticket the actor may update, for forms that edit it.
+func (s *TicketService) GetForUpdate(actor Actor, id uint) (*models.Ticket, error) {
+	ticket, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if !rbac.CanUpdateTicket(actor.UserID, actor.Role, ticket) {
+		s.denied(actor, "update", id)
+		return nil, ErrTicketForbidden
+	}
+	return ticket, nil
+}
+
+// List returns the tickets the actor may view that match the filter.
+func (s *TicketService) List(actor Actor, filter TicketFilter) ([]models.Ticket, error) {
+	query, ok := rbac.ScopeTickets(s.DB.Model(&models.Ticket{}), actor.UserID, actor.Role)
+	if !ok {
+		s.denied(actor, "list", 0)
+		return nil, ErrTicketForbidden
+	}
+	if filter.Priority != "" {
+		query = query.Where("priority = ?", strings.ToLower(strings.TrimSpace(filter.Priority)))
+	}
+	if filter.Status != "" {
+		status, err := normalizeStatus(filter.Status)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var tickets []models.Ticket
+	if err := query.Find(&tickets).Error; err != nil {
+		utils.LogErrorIP("[Tickets] Failed to list tickets", err, actor.IP)
+		return nil, fmt.Errorf("could not retrieve tickets")
+	}
+	return matchKeyword(tickets, filter.Keyword), nil
+}
+
+// Create raises a new ticket for the actor in the first status.
+func (s *TicketService) Create(actor Actor, in CreateTicketInput) (*models.Ticket, error) {
+	if !rbac.Can(actor.Role, rbac.TicketsCreate) {
+		s.denied(actor, "create", 0)
+		return nil, ErrTicketForbidden
+	}
+
+	title, err := validateTitle(in.Title)
+	if err != nil {
+		return nil, err
+	}
+	if in.Priority == "" {
+		in.Priority = defaultTicketPriority
+	}
+	priority, err := normalizePriority(in.Priority)
+	if err != nil {
+		return nil, err
+	}
+	skills, err := encodeSkills(in.Skills)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := models.Ticket{
+		Title:        title,
+		Description:  strings.TrimSpace(in.Description),
+		Priority:     priority,
+		Status:       TicketStatuses[0],
+		ClientID:     actor.UserID,
+		SkillsNeeded: skills,
+		Version:      1,
+	}
+	err = events.Transaction(s.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		if err := tx.Create(&ticket).Error; err != nil {
+			return err
+		}
+		emit(events.TicketCreated{TicketID: ticket.ID, ClientID: ticket.ClientID, Priority: ticket.Priority, ActorID: actor.UserID})
+		return nil
+	})
+	if err != nil {
+		utils.LogErrorIP("[Tickets] Failed to create ticket", err, actor.IP)
+		return nil, fmt.Errorf("could not save ticket")
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[Tickets] Ticket %d created by user %d", ticket.ID, actor.UserID), actor.IP)
+	return &ticket, nil
+}
+
+// Update changes the fields set in the input. Moving a ticket to the closed status records when
+// it closed; moving it out again clears that. The update only applies if the ticket is still at
+// the version it was based on; otherwise it returns a *ConflictError with the saved ticket.
+func (s *TicketService) Update(actor Actor, id uint, in UpdateTicketInput) (*models.Ticket, error) {
+	comment := strings.TrimSpace(in.Comment)
+	if comment != "" && !rbac.Can(actor.Role, rbac.CommentsCreate) {
+		s.denied(actor, "comment on", id)
+		return nil, ErrTicketForbidden
+	}
+	ticket, err := s.GetForUpdate(actor, id)
+	if err != nil {
+		return nil, err
+	}
+	saved := *ticket
+	if in.Version == 0 && !in.AnyVersion {
+		return nil, ErrVersionRequired
+	}
+
+	if in.Title != nil {
+		if ticket.Title, err = validateTitle(*in.Title); err != nil {
+			return nil, err
+		}
+	}
+	if in.Description != nil {
+		ticket.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.Priority != nil {
+		if ticket.Priority, err = normalizePriority(*in.Priority); err != nil {
+			return nil, err
+		}
+	}
+	if in.Skills != nil {
+		if ticket.SkillsNeeded, err = encodeSkills(*in.Skills); err != nil {
+			return nil, err
+		}
+	}
+	if in.Status != nil {
+		if ticket.Status, err = normalizeStatus(*in.Status); err != nil {
+			return nil, err
+		}
+		if ticket.Status == StatusClosed && ticket.ClosedAt == nil {
+			now := time.Now()
+			ticket.ClosedAt = &now
+		} else if ticket.Status != StatusClosed {
+			ticket.ClosedAt = nil
+		}
+	}
+
+	if !in.AnyVersion && in.Version != saved.Version {
+		return nil, s.conflict(actor, ticket, in, &saved)
+	}
+	ticket.Version = saved.Version + 1
+	var updated int64
+	err = events.Transaction(s.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		res := tx.Model(ticket).Where("version = ?", saved.Version).
+			Select("title", "description", "priority", "status", "skills_needed", "closed_at", "version", "updated_at").
+			Updates(ticket)
+		updated = res.RowsAffected
+		if res.Error != nil || updated == 0 {
+			return res.Error
+		}
+		emit(events.TicketUpdated{TicketID: id, Version: ticket.Version, PreviousStatus: saved.Status, Status: ticket.Status, ActorID: actor.UserID})
+		if comment == "" {
+			return nil
+		}
+		posted := models.Comment{TicketID: id, AuthorID: actor.UserID, AuthorEmail: actor.Email, Content: comment, CreatedAt: time.Now()}
+		if err := tx.Create(&posted).Error; err != nil {
+			return err
+		}
+		emit(events.CommentAdded{CommentID: posted.ID, TicketID: id, AuthorID: actor.UserID})
+		return nil
+	})
+	if err != nil {
+		utils.LogErrorIP(fmt.Sprintf("[Tickets] Failed to update ticket %d", id), err, actor.IP)
+		return nil, fmt.Errorf("could not update ticket")
+	}
+	if updated == 0 {
+		// Someone else saved the ticket between loading and updating it
+		current, err := s.load(id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.conflict(actor, ticket, in, current)
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[Tickets] Ticket %d updated to version %d by user %d", id, ticket.Version, actor.UserID), actor.IP)
+	if comment != "" {
+		utils.LogInfoIP(fmt.Sprintf("[Comment] User %d added a comment to Ticket #%d with the update", actor.UserID, id), actor.IP)
+	}
+	return ticket, nil
+}
+
+// conflict builds the error for an update to a ticket that has moved on to a newer version,
+// listing the fields the update sets that now hold other values.
+func (s *TicketService) conflict(actor Actor, yours *models.Ticket, in UpdateTicketInput, current *models.Ticket) error {
+	var changes []FieldChange
+	if in.Title != nil {
+		changes = addChange(changes, "title", yours.Title, current.Title)
+	}
+	if in.Description != nil {
+		changes = addChange(changes, "description", yours.Description, current.Description)
+	}
+	if in.Priority != nil {
+		changes = addChange(changes, "priority", yours.Priority, current.Priority)
+	}
+	if in.Status != nil {
+		changes = addChange(changes, "status", yours.Status, current.Status)
+	}
+	if in.Skills != nil {
+		changes = addChange(changes, "skills", yours.SkillsNeeded, current.SkillsNeeded)
+	}
+
+	utils.LogWarningIP(fmt.Sprintf("[Tickets] Update of ticket %d by user %d rejected: based on an older version than %d",
+		current.ID, actor.UserID, current.Version), actor.IP)
+	return &ConflictError{Resource: "ticket", ID: current.ID, CurrentVersion: current.Version, Current: current, Changes: changes}
+}
+
+// Assign makes an active user with the tech role the ticket's technician.
+func (s *TicketService) Assign(actor Actor, id, techID uint) (*models.Ticket, error) {
+	if !rbac.Can(actor.Role, rbac.TicketsAssign) {
+		s.denied(actor, "assign", id)
+		return nil, ErrTicketForbidden
+	}
+	ticket, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tech, err := findActiveTechnician(s.DB, techID)
+	if errors.Is(err, ErrInvalidTechnician) {
+		utils.LogWarningIP(fmt.Sprintf("[Tickets] User %d tried to assign ticket %d to user %d, who is not an active technician", actor.UserID, id, techID), actor.IP)
+		return nil, err
+	}
+	if err != nil {
+		utils.LogErrorIP(fmt.Sprintf("[Tickets] Failed to load technician %d", techID), err, actor.IP)
+		return nil, fmt.Errorf("could not assign technician")
+	}
+
+	previous := ticket.TechID
+	ticket.TechID = &tech.ID
+	ticket.Version++
+	err = events.Transaction(s.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		if err := tx.Model(ticket).Updates(map[string]interface{}{"tech_id": tech.ID, "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+		emit(events.TicketAssigned{TicketID: id, TechID: tech.ID, PreviousTechID: previous, ActorID: actor.UserID})
+		return nil
+	})
+	if err != nil {
+		utils.LogErrorIP(fmt.Sprintf("[Tickets] Failed to assign ticket %d", id), err, actor.IP)
+		return nil, fmt.Errorf("could not assign technician")
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[Tickets] Ticket %d assigned to technician %d by user %d", id, tech.ID, actor.UserID), actor.IP)
+	return ticket, nil
+}
+
+// Unassign removes the ticket's technician.
+func (s *TicketService) Unassign(actor Actor, id uint) (*models.Ticket, error) {
+	if !rbac.Can(actor.Role, rbac.TicketsAssign) {
+		s.denied(actor, "unassign", id)
+		return nil, ErrTicketForbidden
+	}
+	ticket, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := ticket.TechID
+	ticket.TechID = nil
+	ticket.Version++
+	err = events.Transaction(s.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		if err := tx.Model(ticket).Updates(map[string]interface{}{"tech_id": nil, "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+		emit(events.TicketUnassigned{TicketID: id, PreviousTechID: previous, ActorID: actor.UserID})
+		return nil
+	})
+	if err != nil {
+		utils.LogErrorIP(fmt.Sprintf("[Tickets] Failed to unassign ticket %d", id), err, actor.IP)
+		return nil, fmt.Errorf("could not unassign technician")
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[Tickets] Ticket %d unassigned by user %d", id, actor.UserID), actor.IP)
+	return ticket, nil
+}
+
+// Delete moves a ticket to the trash, from where an admin can restore it.
+func (s *TicketService) Delete(actor Actor, id uint) error {
+	if !rbac.Can(actor.Role, rbac.TicketsDelete) {
+		s.denied(actor, "delete", id)
+		return ErrTicketForbidden
+	}
+	ticket, err := s.load(id)
+	if err != nil {
+		return err
+	}
+
+	err = events.Transaction(s.DB, func(tx *gorm.DB, emit func(events.Event)) error {
+		if err := tx.Delete(ticket).Error; err != nil {
+			return err
+		}
+		emit(events.TicketDeleted{TicketID: id, ActorID: actor.UserID})
+		return nil
+	})
+	if err != nil {
+		utils.LogErrorIP(fmt.Sprintf("[Tickets] Failed to delete ticket %d", id), err, actor.IP)
+		return fmt.Errorf("could not delete ticket")
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[Tickets] Ticket %d moved to trash by user %d", id, actor.UserID), actor.IP)
+	return nil
+}
+
+// load fetches a ticket that is not in the trash.
+func (s *TicketService) load(id uint) (*models.Ticket, error) {
+	var ticket models.Ticket
+	if err := s.DB.Where("id = ?", id).Limit(1).Find(&ticket).Error; err != nil {
+		utils.LogError(fmt.Sprintf("[Tickets] Failed to load ticket %d", id), err)
+		return nil, fmt.Errorf("could not load ticket")
+	}
+	if ticket.ID == 0 {
+		return nil, ErrTicketNotFound
+	}
+	return &ticket, nil
+}
+
+// findActiveTechnician loads the user with the given ID, or returns ErrInvalidTechnician if they
+// do not exist, do not have the tech role, or are deactivated.
+func findActiveTechnician(db *gorm.DB, id uint) (*models.User, error) {
+	var tech models.User
+	if err := db.Where("id = ?", id).Limit(1).Find(&tech).Error; err != nil {
+		return nil, err
+	}
+	if tech.ID == 0 || tech.Role != rbac.RoleTech || tech.DeactivatedAt != nil {
+		return nil, ErrInvalidTechnician
+	}
+	return &tech, nil
+}
+
+// denied logs an action the actor's role does not allow.
+func (s *TicketService) denied(actor Actor, action string, id uint) {
+	target := "tickets"
+	if id != 0 {
+		target = fmt.Sprintf("ticket %d", id)
+	}
+	utils.LogWarningIP(fmt.Sprintf("[Tickets] User %d (role: %s) may not %s %s", actor.UserID, actor.Role, action, target), actor.IP)
+}
+
+// validateTitle trims a ticket title and checks that it is present and not too long.
+func validateTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", &TicketValidationError{Field: "title", Message: "is required"}
+	}
+	if len(title) > maxTicketTitleLength {
+		return "", &TicketValidationError{Field: "title", Message: fmt.Sprintf("must be at most %d characters", maxTicketTitleLength)}
+	}
+	return title, nil
+}
+
+// normalizePriority returns a priority in its canonical lower-case form.
+func normalizePriority(priority string) (string, error) {
+	priority = strings.ToLower(strings.TrimSpace(priority))
+	for _, p := range TicketPriorities {
+		if p == priority {
+			return p, nil
+		}
+	}
+	return "", &TicketValidationError{Field: "priority", Message: "must be one of " + strings.Join(TicketPriorities, ", ")}
+}
+
+// normalizeStatus returns a status in its canonical form, accepting the older aliases.
+func normalizeStatus(status string) (string, error) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if alias, ok := statusAliases[status]; ok {
+		return alias, nil
+	}
+	for _, s := range TicketStatuses {
+		if s == status {
+			return s, nil
+		}
+	}
+	return "", &TicketValidationError{Field: "status", Message: "must be one of " + strings.Join(TicketStatuses, ", ")}
+}
+
+// encodeSkills trims and de-duplicates the skills a ticket needs and returns them as the JSON
+// array stored on the ticket, or an empty string for none.
+func encodeSkills(skills []string) (string, error) {
+	var kept []string
+	seen := make(map[string]bool)
+	for _, skill := range skills {
+		skill = strings.TrimSpace(skill)
+		if skill == "" || seen[strings.ToLower(skill)] {
+			continue
+		}
+		seen[strings.ToLower(skill)] = true
+		kept = append(kept, skill)
+	}
+	if len(kept) == 0 {
+		return "", nil
+	}
+	if len(kept) > maxTicketSkills {
+		return "", &TicketValidationError{Field: "skills", Message: fmt.Sprintf("may list at most %d skills", maxTicketSkills)}
+	}
+	data, err := json.Marshal(kept)
+	if err != nil {
+		return "", fmt.Errorf("could not encode skills")
+	}
+	return string(data), nil
+}
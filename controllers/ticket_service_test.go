@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"errors"
+	"testing"
+)
+
+func TestTicketServiceCreateAndUpdate(t *testing.T) {
+	testutil.ResetDB(t)
+	svc := NewTicketService(config.DB)
+	client := models.User{Email: "client@service.test", Role: rbac.RoleClient}
+	other := models.User{Email: "other@service.test", Role: rbac.RoleClient}
+	config.DB.Create(&client)
+	config.DB.Create(&other)
+	actor := Actor{UserID: client.ID, Role: client.Role}
+
+	var invalid *TicketValidationError
+	if _, err := svc.Create(actor, CreateTicketInput{Title: "  ", Priority: "low"}); !errors.As(err, &invalid) || invalid.Field != "title" {
+		t.Fatalf("blank title: got %v", err)
+	}
+	if _, err := svc.Create(actor, CreateTicketInput{Title: "VPN", Priority: "urgent"}); !errors.As(err, &invalid) || invalid.Field != "priority" {
+		t.Fatalf("unknown priority: got %v", err)
+	}
+	if _, err := svc.Create(Actor{UserID: client.ID, Role: "nobody"}, CreateTicketInput{Title: "VPN"}); !errors.Is(err, ErrTicketForbidden) {
+		t.Fatalf("role without tickets.create: got %v", err)
+	}
+
+	ticket, err := svc.Create(actor, CreateTicketInput{Title: " VPN down ", Priority: "High", Skills: []string{"Networking", " networking", "", "VPN"}})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if ticket.Title != "VPN down" || ticket.Priority != "high" || ticket.Status != TicketStatuses[0] || ticket.ClientID != client.ID {
+		t.Fatalf("ticket not normalized: %+v", ticket)
+	}
+	if ticket.SkillsNeeded != `["Networking","VPN"]` {
+		t.Fatalf("skills = %s", ticket.SkillsNeeded)
+	}
+
+	if _, err := svc.Update(Actor{UserID: other.ID, Role: other.Role}, ticket.ID, UpdateTicketInput{}); !errors.Is(err, ErrTicketForbidden) {
+		t.Fatalf("another client's update: got %v", err)
+	}
+	if _, err := svc.Update(actor, ticket.ID+1000, UpdateTicketInput{}); !errors.Is(err, ErrTicketNotFound) {
+		t.Fatalf("missing ticket: got %v", err)
+	}
+
+	resolved := "Resolved"
+	if _, err := svc.Update(actor, ticket.ID, UpdateTicketInput{Status: &resolved}); !errors.Is(err, ErrVersionRequired) {
+		t.Fatalf("update without a version: %v", err)
+	}
+	updated, err := svc.Update(actor, ticket.ID, UpdateTicketInput{Status: &resolved, Version: ticket.Version})
+	if err != nil {
+		t.Fatalf("closing failed: %v", err)
+	}
+	if updated.Status != StatusClosed || updated.ClosedAt == nil {
+		t.Fatalf("legacy status not mapped to closed: %q, closed at %v", updated.Status, updated.ClosedAt)
+	}
+	working := "working"
+	if updated, err = svc.Update(actor, ticket.ID, UpdateTicketInput{Status: &working, Version: updated.Version}); err != nil || updated.ClosedAt != nil {
+		t.Fatalf("reopening did not clear closed_at: %v", err)
+	}
+}
+
+func TestTicketServiceAssign(t *testing.T) {
+	testutil.ResetDB(t)
+	svc := NewTicketService(config.DB)
+	admin := models.User{Email: "admin@assign.test", Role: rbac.RoleAdmin}
+	tech := models.User{Email: "tech@assign.test", Role: rbac.RoleTech}
+	client := models.User{Email: "client@assign.test", Role: rbac.RoleClient}
+	for _, u := range []*models.User{&admin, &tech, &client} {
+		config.DB.Create(u)
+	}
+	ticket := models.Ticket{Title: "Printer", Priority: "low", Status: TicketStatuses[0], ClientID: client.ID}
+	config.DB.Create(&ticket)
+	actor := Actor{UserID: admin.ID, Role: admin.Role}
+
+	if _, err := svc.Assign(Actor{UserID: client.ID, Role: client.Role}, ticket.ID, tech.ID); !errors.Is(err, ErrTicketForbidden) {
+		t.Fatalf("client assigning: got %v", err)
+	}
+	if _, err := svc.Assign(actor, ticket.ID, client.ID); !errors.Is(err, ErrInvalidTechnician) {
+		t.Fatalf("assigning to a client: got %v", err)
+	}
+	if _, err := svc.Assign(actor, ticket.ID, tech.ID+1000); !errors.Is(err, ErrInvalidTechnician) {
+		t.Fatalf("assigning to a missing user: got %v", err)
+	}
+
+	if _, err := svc.Assign(actor, ticket.ID, tech.ID); err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	config.DB.First(&ticket, ticket.ID)
+	if ticket.TechID == nil || *ticket.TechID != tech.ID {
+		t.Fatalf("ticket not assigned: %v", ticket.TechID)
+	}
+	if !rbac.CanUpdateTicket(tech.ID, tech.Role, &ticket) {
+		t.Fatal("assigned technician cannot update the ticket")
+	}
+
+	if _, err := svc.Unassign(actor, ticket.ID); err != nil {
+		t.Fatalf("Unassign failed: %v", err)
+	}
+	config.DB.First(&ticket, ticket.ID)
+	if ticket.TechID != nil {
+		t.Fatal("ticket still assigned")
+	}
+}
+
+func TestTicketServiceConflicts(t *testing.T) {
+	testutil.ResetDB(t)
+	svc := NewTicketService(config.DB)
+	tech := models.User{Email: "tech@conflict.test", Role: rbac.RoleTech}
+	admin := models.User{Email: "admin@conflict.test", Role: rbac.RoleAdmin}
+	config.DB.Create(&tech)
+	config.DB.Create(&admin)
+	ticket := models.Ticket{Title: "Email down", Priority: "high", Status: TicketStatuses[0], ClientID: admin.ID, TechID: &tech.ID}
+	config.DB.Create(&ticket)
+	config.DB.First(&ticket, ticket.ID)
+	if ticket.Version != 1 {
+		t.Fatalf("new ticket at version %d, want 1", ticket.Version)
+	}
+
+	// Both users load version 1; the admin saves first.
+	closed, working := StatusClosed, "working"
+	if _, err := svc.Update(Actor{UserID: admin.ID, Role: admin.Role}, ticket.ID, UpdateTicketInput{Status: &closed, Version: 1}); err != nil {
+		t.Fatalf("first update failed: %v", err)
+	}
+	_, err := svc.Update(Actor{UserID: tech.ID, Role: tech.Role}, ticket.ID, UpdateTicketInput{Status: &working, Version: 1, Comment: "Reopening"})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("stale update: got %v", err)
+	}
+	if conflict.CurrentVersion != 2 || len(conflict.Changes) != 1 || conflict.Changes[0].Current != StatusClosed {
+		t.Fatalf("conflict = %+v", conflict)
+	}
+	config.DB.First(&ticket, ticket.ID)
+	if ticket.Status != StatusClosed {
+		t.Fatalf("stale update overwrote the status: %q", ticket.Status)
+	}
+	var comments int64
+	config.DB.Model(&models.Comment{}).Where("ticket_id = ?", ticket.ID).Count(&comments)
+	if comments != 0 {
+		t.Fatal("comment saved with a rejected update")
+	}
+
+	if _, err := svc.Update(Actor{UserID: tech.ID, Role: tech.Role}, ticket.ID, UpdateTicketInput{Status: &working, Version: conflict.CurrentVersion, Comment: "Reopening"}); err != nil {
+		t.Fatalf("update on the current version failed: %v", err)
+	}
+	config.DB.Model(&models.Comment{}).Where("ticket_id = ?", ticket.ID).Count(&comments)
+	if comments != 1 {
+		t.Fatalf("%d comments saved with the update, want 1", comments)
+	}
+
+	comment := models.Comment{TicketID: ticket.ID, AuthorID: tech.ID, AuthorEmail: tech.Email, Content: "Rebooting"}
+	config.DB.Create(&comment)
+	if err := EditComment(comment.ID, "Rebooted", 0, "127.0.0.1"); !errors.Is(err, ErrVersionRequired) {
+		t.Fatalf("comment edit without a version: got %v", err)
+	}
+	if err := EditComment(comment.ID, "Rebooted", 1, "127.0.0.1"); err != nil {
+		t.Fatalf("EditComment failed: %v", err)
+	}
+	if err := EditComment(comment.ID, "Rebooting now", 1, "127.0.0.1"); !errors.As(err, &conflict) || conflict.Changes[0].Current != "Rebooted" {
+		t.Fatalf("stale comment edit: got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// TrashContents groups every soft-deleted record that can still be restored.
+type TrashContents struct {
+	Tickets  []models.Ticket
+	Comments []models.Comment
+	Accounts []models.Account
+}
+
+// PurgeResult reports how many records were permanently removed by a purge.
+type PurgeResult struct {
+	Tickets  int64
+	Comments int64
+	Accounts int64
+}
+
+// ErrNotInTrash is returned when a restore targets a record that is not soft-deleted.
+var ErrNotInTrash = errors.New("item not found in trash")
+
+// ListTrash returns all soft-deleted tickets, comments, and accounts, newest first.
+func ListTrash() (TrashContents, error) {
+	var trash TrashContents
+
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at desc").Find(&trash.Tickets).Error; err != nil {
+		return trash, fmt.Errorf("failed to load trashed tickets: %w", err)
+	}
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at desc").Find(&trash.Comments).Error; err != nil {
+		return trash, fmt.Errorf("failed to load trashed comments: %w", err)
+	}
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at desc").Find(&trash.Accounts).Error; err != nil {
+		return trash, fmt.Errorf("failed to load trashed accounts: %w", err)
+	}
+
+	return trash, nil
+}
+
+// RestoreTicket moves a trashed ticket back into normal use.
+func RestoreTicket(ticketID uint, ip string) error {
+	return restoreFromTrash(&models.Ticket{}, "Ticket", ticketID, ip)
+}
+
+// RestoreComment moves a trashed comment back onto its ticket.
+func RestoreComment(commentID uint, ip string) error {
+	return restoreFromTrash(&models.Comment{}, "Comment", commentID, ip)
+}
+
+// RestoreAccount moves a trashed account back into normal use.
+func RestoreAccount(accountID uint, ip string) error {
+	return restoreFromTrash(&models.Account{}, "Account", accountID, ip)
+}
+
+// restoreFromTrash clears deleted_at on a single soft-deleted row of the given model.
+func restoreFromTrash(model interface{}, label string, id uint, ip string) error {
+	result := config.DB.Unscoped().Model(model).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		utils.LogErrorIP(fmt.Sprintf("[Trash] Failed to restore %s %d", label, id), result.Error, ip)
+		return fmt.Errorf("failed to restore %s: %w", label, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		utils.LogWarningIP(fmt.Sprintf("[Trash] Restore failed — %s %d is not in the trash", label, id), ip)
+		return ErrNotInTrash
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[Trash] %s %d restored", label, id), ip)
+	return nil
+}
+
+// PurgeTrash permanently deletes trashed records that were deleted before the cutoff, in one
+// transaction. Comments belonging to a purged ticket are removed along with it.
+func PurgeTrash(olderThan time.Duration) (PurgeResult, error) {
+	var result PurgeResult
+	cutoff := time.Now().Add(-olderThan)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var ticketIDs []uint
+		if err := tx.Unscoped().Model(&models.Ticket{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Pluck("id", &ticketIDs).Error; err != nil {
+			return fmt.Errorf("failed to find expired tickets: %w", err)
+		}
+
+		if len(ticketIDs) > 0 {
+			res := tx.Unscoped().Where("ticket_id IN ?", ticketIDs).Delete(&models.Comment{})
+			if res.Error != nil {
+				return fmt.Errorf("failed to purge ticket comments: %w", res.Error)
+			}
+			result.Comments += res.RowsAffected
+
+			res = tx.Unscoped().Where("id IN ?", ticketIDs).Delete(&models.Ticket{})
+			if res.Error != nil {
+				return fmt.Errorf("failed to purge tickets: %w", res.Error)
+			}
+			result.Tickets = res.RowsAffected
+		}
+
+		res := tx.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(&models.Comment{})
+		if res.Error != nil {
+			return fmt.Errorf("failed to purge comments: %w", res.Error)
+		}
+		result.Comments += res.RowsAffected
+
+		// Users of a purged account are left without one (the foreign key sets account_id to NULL)
+		res = tx.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(&models.Account{})
+		if res.Error != nil {
+			return fmt.Errorf("failed to purge accounts: %w", res.Error)
+		}
+		result.Accounts = res.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return PurgeResult{}, err
+	}
+
+	utils.LogInfo(fmt.Sprintf("[Trash] Purged %d tickets, %d comments, %d accounts deleted before %s",
+		result.Tickets, result.Comments, result.Accounts, cutoff.Format("2006-01-02 15:04:05")))
+	return result, nil
+}
+
+// PurgeExpiredTrash purges everything that has been in the trash longer than the
+// configured retention period (RYANFORCE_TRASH_RETENTION_DAYS).
+func PurgeExpiredTrash() (PurgeResult, error) {
+	retention := time.Duration(config.TrashRetentionDays()) * 24 * time.Hour
+	return PurgeTrash(retention)
+}
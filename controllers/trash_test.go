@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"errors"
+	"testing"
+	"time"
+)
+
+// trashAt soft-deletes a record and backdates its deletion.
+func trashAt(t *testing.T, model interface{}, deletedAt time.Time) {
+	t.Helper()
+	if err := config.DB.Delete(model).Error; err != nil {
+		t.Fatalf("failed to trash %T: %v", model, err)
+	}
+	config.DB.Unscoped().Model(model).UpdateColumn("deleted_at", deletedAt)
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	testutil.ResetDB(t)
+	t.Setenv("RYANFORCE_TRASH_RETENTION_DAYS", "30")
+	expired := time.Now().AddDate(0, 0, -31)
+	recent := time.Now().AddDate(0, 0, -1)
+
+	client := models.User{Email: "client@trash.test", Role: rbac.RoleClient}
+	config.DB.Create(&client)
+	oldTicket := models.Ticket{Title: "Old", Status: "open", ClientID: client.ID}
+	newTicket := models.Ticket{Title: "New", Status: "open", ClientID: client.ID}
+	liveTicket := models.Ticket{Title: "Live", Status: "open", ClientID: client.ID}
+	for _, ticket := range []*models.Ticket{&oldTicket, &newTicket, &liveTicket} {
+		config.DB.Create(ticket)
+	}
+	// A comment still live on a purged ticket goes with it
+	onOldTicket := models.Comment{TicketID: oldTicket.ID, AuthorID: client.ID, Content: "on old"}
+	oldComment := models.Comment{TicketID: liveTicket.ID, AuthorID: client.ID, Content: "old"}
+	keptComment := models.Comment{TicketID: liveTicket.ID, AuthorID: client.ID, Content: "kept"}
+	for _, comment := range []*models.Comment{&onOldTicket, &oldComment, &keptComment} {
+		config.DB.Create(comment)
+	}
+	oldAccount := models.Account{Name: "Old Co", Domain: "old.trash.test"}
+	config.DB.Create(&oldAccount)
+
+	trashAt(t, &oldTicket, expired)
+	trashAt(t, &newTicket, recent)
+	trashAt(t, &oldComment, expired)
+	trashAt(t, &oldAccount, expired)
+
+	result, err := PurgeExpiredTrash()
+	if err != nil {
+		t.Fatalf("PurgeExpiredTrash failed: %v", err)
+	}
+	if result.Tickets != 1 || result.Comments != 2 || result.Accounts != 1 {
+		t.Fatalf("PurgeExpiredTrash = %+v, want 1 ticket, 2 comments, 1 account", result)
+	}
+
+	trash, err := ListTrash()
+	if err != nil || len(trash.Tickets) != 1 || trash.Tickets[0].ID != newTicket.ID ||
+		len(trash.Comments) != 0 || len(trash.Accounts) != 0 {
+		t.Fatalf("ListTrash after purge = %+v, %v", trash, err)
+	}
+	var comments int64
+	config.DB.Model(&models.Comment{}).Where("ticket_id = ?", liveTicket.ID).Count(&comments)
+	if comments != 1 {
+		t.Fatalf("live ticket has %d comments, want 1", comments)
+	}
+
+	if err := RestoreTicket(oldTicket.ID, ""); !errors.Is(err, ErrNotInTrash) {
+		t.Fatalf("restoring a purged ticket returned %v", err)
+	}
+	if err := RestoreTicket(newTicket.ID, ""); err != nil {
+		t.Fatalf("RestoreTicket failed: %v", err)
+	}
+	if err := config.DB.First(&models.Ticket{}, newTicket.ID).Error; err != nil {
+		t.Fatalf("restored ticket not visible: %v", err)
+	}
+	if err := RestoreTicket(newTicket.ID, ""); !errors.Is(err, ErrNotInTrash) {
+		t.Fatalf("restoring a live ticket returned %v", err)
+	}
+}
@@ -3,31 +3,71 @@ package controllers
 import (
 	"RyanForce/config"
 	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
 )
 
-// GetUsers returns a list of all users in the system.
+// GetUsers returns the users the caller may list: every user for staff with users.view, or
+// the members of their own client account for account contacts.
 func GetUsers(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+	query, ok := rbac.ScopeUsers(config.DB.Model(&models.User{}), claims.UserID, claims.Role)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+		return
+	}
+
 	var users []models.User
-	config.DB.Find(&users)
+	if err := query.Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
 	c.JSON(http.StatusOK, users)
 }
 
-// DeleteUserAPI allows an admin to delete a user by ID.
+// DeleteUserAPI allows an admin to delete a user by ID. The optional reassign_to query parameter
+// names the technician to hand the user's open tickets to; without it they are unassigned.
 func DeleteUserAPI(c *gin.Context) {
-	id := c.Param("id")
-	uid, err := strconv.Atoi(id)
+	claims := c.MustGet("user").(*utils.Claims)
+	uid, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
+	var reassignTo uint64
+	if raw := c.Query("reassign_to"); raw != "" {
+		if reassignTo, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reassign_to technician ID"})
+			return
+		}
+	}
 
-	if err := config.DB.Delete(&models.User{}, uid).Error; err != nil {
+	deletion, err := DeleteUser(uint(uid), uint(reassignTo), claims.UserID, c.ClientIP())
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrUserHasOpenTickets):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrInvalidTechnician):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reassign_to: " + err.Error()})
+		return
+	case errors.Is(err, ErrDeleteSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "User deleted successfully",
+		"tickets_reassigned": deletion.Reassigned,
+		"tickets_unassigned": deletion.Unassigned,
+	})
 }
@@ -0,0 +1,164 @@
+// Package directory talks to LDAP and Active Directory servers: it lists the users a
+// models.Directory selects and verifies their passwords with a bind.
+package directory
+
+import (
+	"RyanForce/models"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ErrInvalidCredentials is returned by Authenticate when the directory rejects the password or
+// the user is no longer selected by the directory's filters.
+var ErrInvalidCredentials = errors.New("invalid directory credentials")
+
+// timeout bounds connecting to the server and each request on the connection.
+const timeout = 10 * time.Second
+
+// pageSize is the number of entries requested per page when listing users.
+const pageSize = 500
+
+// Entry is one user found in a directory.
+type Entry struct {
+	DN    string
+	UID   string
+	Email string
+	Name  string
+}
+
+// Entries lists every user under the directory's base DN that matches its user and group
+// filters. Entries without an ID or email are skipped.
+func Entries(d *models.Directory) ([]Entry, error) {
+	conn, err := connect(d)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := searchRequest(d, "")
+	res, err := conn.SearchWithPaging(req, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("directory search failed: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		if entry := toEntry(d, e); entry.UID != "" && entry.Email != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Authenticate finds the user with the given email among the directory's users and binds as
+// them with password. It returns the matching entry, ErrInvalidCredentials, or an error when
+// the directory cannot be reached.
+func Authenticate(d *models.Directory, email, password string) (Entry, error) {
+	// An empty password would be an unauthenticated bind, which many servers accept.
+	if password == "" || email == "" {
+		return Entry{}, ErrInvalidCredentials
+	}
+
+	conn, err := connect(d)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer conn.Close()
+
+	filter := fmt.Sprintf("(%s=%s)", attribute(d.EmailAttribute, "mail"), ldap.EscapeFilter(email))
+	res, err := conn.Search(searchRequest(d, filter))
+	if err != nil {
+		return Entry{}, fmt.Errorf("directory search failed: %w", err)
+	}
+	if len(res.Entries) != 1 {
+		return Entry{}, ErrInvalidCredentials
+	}
+
+	entry := toEntry(d, res.Entries[0])
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return Entry{}, ErrInvalidCredentials
+		}
+		return Entry{}, fmt.Errorf("directory bind failed: %w", err)
+	}
+	return entry, nil
+}
+
+// connect dials the directory, upgrades to TLS if configured, and binds as the service account.
+func connect(d *models.Directory) (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: d.SkipTLSVerify}
+	conn, err := ldap.DialURL(d.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to directory: %w", err)
+	}
+	conn.SetTimeout(timeout)
+
+	if d.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("directory StartTLS failed: %w", err)
+		}
+	}
+	if d.BindDN != "" {
+		if err := conn.Bind(d.BindDN, d.BindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("directory service bind failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// searchRequest builds a subtree search of the base DN for the directory's users, narrowed by
+// an extra filter when one is given.
+func searchRequest(d *models.Directory, extra string) *ldap.SearchRequest {
+	filter := "(&" + filterOrDefault(d.UserFilter, "(objectClass=person)") + filterOrDefault(d.GroupFilter, "") + extra + ")"
+	attrs := []string{
+		attribute(d.IDAttribute, "entryUUID"),
+		attribute(d.EmailAttribute, "mail"),
+		attribute(d.NameAttribute, "cn"),
+	}
+	return ldap.NewSearchRequest(d.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, int(timeout.Seconds()), false, filter, attrs, nil)
+}
+
+// toEntry reads the configured attributes from a search result. Binary IDs such as
+// Active Directory's objectGUID are hex encoded.
+func toEntry(d *models.Directory, e *ldap.Entry) Entry {
+	idAttr := attribute(d.IDAttribute, "entryUUID")
+	uid := e.GetAttributeValue(idAttr)
+	if strings.EqualFold(idAttr, "objectGUID") || strings.EqualFold(idAttr, "objectSid") {
+		uid = hex.EncodeToString(e.GetRawAttributeValue(idAttr))
+	}
+	return Entry{
+		DN:    e.DN,
+		UID:   uid,
+		Email: strings.ToLower(strings.TrimSpace(e.GetAttributeValue(attribute(d.EmailAttribute, "mail")))),
+		Name:  strings.TrimSpace(e.GetAttributeValue(attribute(d.NameAttribute, "cn"))),
+	}
+}
+
+// attribute returns name, or fallback when no attribute is configured.
+func attribute(name, fallback string) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	return fallback
+}
+
+// filterOrDefault returns filter wrapped in parentheses, or fallback when it is empty.
+func filterOrDefault(filter, fallback string) string {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return fallback
+	}
+	if !strings.HasPrefix(filter, "(") {
+		filter = "(" + filter + ")"
+	}
+	return filter
+}
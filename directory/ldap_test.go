@@ -0,0 +1,112 @@
+package directory
+
+import (
+	"RyanForce/models"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// These tests run against a real server and are skipped unless RYANFORCE_TEST_LDAP_URL is set.
+// With the OpenLDAP container from the README:
+//
+//	RYANFORCE_TEST_LDAP_URL=ldap://localhost:389 go test ./directory/
+//
+// RYANFORCE_TEST_LDAP_BIND_DN, _BIND_PASSWORD, and _BASE_DN default to the container's admin
+// and dc=example,dc=org.
+func testDirectory(t *testing.T) *models.Directory {
+	url := os.Getenv("RYANFORCE_TEST_LDAP_URL")
+	if url == "" {
+		t.Skip("RYANFORCE_TEST_LDAP_URL not set")
+	}
+	env := func(key, fallback string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return fallback
+	}
+	base := env("RYANFORCE_TEST_LDAP_BASE_DN", "dc=example,dc=org")
+	return &models.Directory{
+		URL:          url,
+		BindDN:       env("RYANFORCE_TEST_LDAP_BIND_DN", "cn=admin,"+base),
+		BindPassword: env("RYANFORCE_TEST_LDAP_BIND_PASSWORD", "admin"),
+		BaseDN:       "ou=ryanforce-test," + base,
+		UserFilter:   "(objectClass=inetOrgPerson)",
+		GroupFilter:  "(businessCategory=support)",
+	}
+}
+
+// seed creates a throwaway OU with two users, only one of whom passes the group filter.
+func seed(t *testing.T, d *models.Directory) {
+	conn, err := connect(d)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close()
+
+	ou := ldap.NewAddRequest(d.BaseDN, nil)
+	ou.Attribute("objectClass", []string{"organizationalUnit"})
+	ou.Attribute("ou", []string{"ryanforce-test"})
+	if err := conn.Add(ou); err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultEntryAlreadyExists) {
+		t.Fatalf("add OU: %v", err)
+	}
+
+	users := []struct{ uid, name, category string }{
+		{"dana", "Dana Directory", "support"},
+		{"eve", "Eve Elsewhere", "sales"},
+	}
+	for _, u := range users {
+		add := ldap.NewAddRequest("uid="+u.uid+","+d.BaseDN, nil)
+		add.Attribute("objectClass", []string{"inetOrgPerson"})
+		add.Attribute("uid", []string{u.uid})
+		add.Attribute("cn", []string{u.name})
+		add.Attribute("sn", []string{u.uid})
+		add.Attribute("mail", []string{u.uid + "@example.org"})
+		add.Attribute("businessCategory", []string{u.category})
+		add.Attribute("userPassword", []string{"Secret123!"})
+		if err := conn.Add(add); err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultEntryAlreadyExists) {
+			t.Fatalf("add %s: %v", u.uid, err)
+		}
+	}
+
+	t.Cleanup(func() {
+		conn, err := connect(d)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, u := range users {
+			conn.Del(ldap.NewDelRequest("uid="+u.uid+","+d.BaseDN, nil))
+		}
+		conn.Del(ldap.NewDelRequest(d.BaseDN, nil))
+	})
+}
+
+func TestEntriesAndAuthenticate(t *testing.T) {
+	d := testDirectory(t)
+	seed(t, d)
+
+	entries, err := Entries(d)
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Email != "dana@example.org" || entries[0].Name != "Dana Directory" || entries[0].UID == "" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	entry, err := Authenticate(d, "dana@example.org", "Secret123!")
+	if err != nil || entry.UID != entries[0].UID {
+		t.Fatalf("Authenticate with the right password: %+v, %v", entry, err)
+	}
+	if _, err := Authenticate(d, "dana@example.org", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password: %v", err)
+	}
+	if _, err := Authenticate(d, "dana@example.org", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("empty password: %v", err)
+	}
+	if _, err := Authenticate(d, "eve@example.org", "Secret123!"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("user outside the group filter: %v", err)
+	}
+}
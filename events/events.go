@@ -0,0 +1,169 @@
+// Package events is an in-process bus for domain events such as "ticket assigned" or "comment
+// added". Code that changes tickets, comments, or users emits events inside its transaction; the
+// bus delivers them once the transaction commits, so subscribers never see a change that was
+// rolled back. Subscribers are registered at startup and choose how they are delivered to.
+package events
+
+import (
+	"RyanForce/config"
+	"RyanForce/utils"
+	"context"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Event is a domain event. Each event type is a plain struct that names itself.
+type Event interface {
+	// EventName is the dotted name of the event, e.g. "ticket.assigned".
+	EventName() string
+}
+
+// Mode controls how a subscriber receives events.
+type Mode int
+
+const (
+	// Sync handlers run in the publishing goroutine, in the order they subscribed, before the
+	// publishing call returns. They should be quick; errors and panics are logged.
+	Sync Mode = iota
+	// Async handlers run on a goroutine of their own, one event at a time in publish order. Events
+	// are dropped, with a warning, if the handler falls more than asyncQueueSize events behind.
+	Async
+	// Durable handlers receive events from the outbox table and are retried until they succeed,
+	// so they may see an event more than once. Without the outbox they are delivered like Async.
+	Durable
+)
+
+// asyncQueueSize is how many events an Async subscriber may fall behind before events are dropped.
+const asyncQueueSize = 1024
+
+// subscriber is one registered handler.
+type subscriber struct {
+	name   string // For logs
+	event  string // Event name to receive, or empty for every event
+	mode   Mode
+	handle func(context.Context, Event) error
+	queue  chan Event // Async only
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	inflight    sync.WaitGroup // Events queued for async subscribers and not yet handled
+)
+
+// Subscribe registers handler for every event of type T. name identifies the subscriber in logs.
+func Subscribe[T Event](name string, mode Mode, handler func(ctx context.Context, event T) error) {
+	var zero T
+	add(&subscriber{name: name, event: zero.EventName(), mode: mode, handle: func(ctx context.Context, e Event) error {
+		return handler(ctx, e.(T))
+	}})
+}
+
+// SubscribeAll registers handler for every event, whatever its type.
+func SubscribeAll(name string, mode Mode, handler func(ctx context.Context, event Event) error) {
+	add(&subscriber{name: name, mode: mode, handle: handler})
+}
+
+// add registers s, starting its goroutine if it is delivered to asynchronously.
+func add(s *subscriber) {
+	if s.mode == Async || s.mode == Durable {
+		s.queue = make(chan Event, asyncQueueSize)
+		go func() {
+			for e := range s.queue {
+				deliver(context.Background(), s, e)
+				inflight.Done()
+			}
+		}()
+	}
+	mu.Lock()
+	subscribers = append(subscribers, s)
+	mu.Unlock()
+}
+
+// Transaction runs fn in a database transaction. Events passed to emit are written to the outbox
+// in the same transaction when it is enabled, and published once the transaction commits; if fn
+// returns an error or the commit fails, they are discarded. db must not already be a transaction,
+// or the events would be published before the outer transaction commits.
+func Transaction(db *gorm.DB, fn func(tx *gorm.DB, emit func(Event)) error) error {
+	var pending []Event
+	err := db.Transaction(func(tx *gorm.DB) error {
+		pending = pending[:0]
+		if err := fn(tx, func(e Event) { pending = append(pending, e) }); err != nil {
+			return err
+		}
+		return saveOutbox(tx, pending)
+	})
+	if err != nil {
+		return err
+	}
+	dispatch(context.Background(), pending)
+	return nil
+}
+
+// Publish delivers events describing a change that has already been saved. Prefer Transaction,
+// which makes saving the change and recording the events atomic.
+func Publish(ctx context.Context, events ...Event) {
+	if err := saveOutbox(config.DB, events); err != nil {
+		utils.LogError("[Events] Failed to write events to the outbox", err)
+	}
+	dispatch(ctx, events)
+}
+
+// Flush waits until every event published so far has been handled by the Async subscribers, and
+// by the Durable ones when the outbox is off.
+func Flush() {
+	inflight.Wait()
+}
+
+// dispatch hands each event to its subscribers. Durable subscribers are left to the outbox relay
+// when the outbox is on.
+func dispatch(ctx context.Context, events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	outbox := config.EventOutboxEnabled()
+	mu.RLock()
+	subs := subscribers
+	mu.RUnlock()
+
+	for _, e := range events {
+		for _, s := range subs {
+			if s.event != "" && s.event != e.EventName() {
+				continue
+			}
+			switch {
+			case s.mode == Sync:
+				deliver(ctx, s, e)
+			case s.mode == Durable && outbox:
+				// Delivered by RelayOutbox
+			default:
+				inflight.Add(1)
+				select {
+				case s.queue <- e:
+				default:
+					inflight.Done()
+					utils.LogWarning(fmt.Sprintf("[Events] Subscriber %s is %d events behind; dropped %s", s.name, asyncQueueSize, e.EventName()))
+				}
+			}
+		}
+	}
+}
+
+// deliver calls one subscriber, logging failures so one bad handler cannot affect the publisher.
+func deliver(ctx context.Context, s *subscriber, e Event) {
+	if err := call(ctx, s, e); err != nil {
+		utils.LogError(fmt.Sprintf("[Events] Subscriber %s failed to handle %s", s.name, e.EventName()), err)
+	}
+}
+
+// call runs a handler, turning a panic into an error.
+func call(ctx context.Context, s *subscriber, e Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return s.handle(ctx, e)
+}
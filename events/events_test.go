@@ -0,0 +1,147 @@
+package events
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	testutil.OpenDB()
+	os.Exit(m.Run())
+}
+
+// recorder collects the ticket IDs of the events a subscriber received.
+type recorder struct {
+	mu  sync.Mutex
+	ids []uint
+}
+
+func (r *recorder) add(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, TicketID(e))
+}
+
+func (r *recorder) has(id uint) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, got := range r.ids {
+		if got == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTransactionPublishesAfterCommit(t *testing.T) {
+	var synced, async recorder
+	Subscribe("test-sync", Sync, func(ctx context.Context, e TicketCreated) error {
+		synced.add(e)
+		return nil
+	})
+	Subscribe("test-async", Async, func(ctx context.Context, e TicketCreated) error {
+		async.add(e)
+		return nil
+	})
+
+	err := Transaction(config.DB, func(tx *gorm.DB, emit func(Event)) error {
+		emit(TicketCreated{TicketID: 101})
+		return errors.New("rolled back")
+	})
+	if err == nil {
+		t.Fatal("expected the transaction's error")
+	}
+	if err := Transaction(config.DB, func(tx *gorm.DB, emit func(Event)) error {
+		emit(TicketCreated{TicketID: 102})
+		return nil
+	}); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	Flush()
+
+	if synced.has(101) || async.has(101) {
+		t.Fatal("event from a rolled-back transaction was published")
+	}
+	if !synced.has(102) || !async.has(102) {
+		t.Fatalf("committed event not delivered: sync %v, async %v", synced.ids, async.ids)
+	}
+}
+
+func TestFailingSubscriberDoesNotStopOthers(t *testing.T) {
+	var got recorder
+	Subscribe("test-panics", Sync, func(ctx context.Context, e TicketDeleted) error {
+		panic("boom")
+	})
+	Subscribe("test-after-panic", Sync, func(ctx context.Context, e TicketDeleted) error {
+		got.add(e)
+		return nil
+	})
+
+	Publish(context.Background(), TicketDeleted{TicketID: 201})
+	if !got.has(201) {
+		t.Fatal("a panicking subscriber kept the next one from being called")
+	}
+}
+
+func TestOutboxRetriesDurableSubscriber(t *testing.T) {
+	os.Setenv("RYANFORCE_EVENT_OUTBOX", "true")
+	defer os.Unsetenv("RYANFORCE_EVENT_OUTBOX")
+
+	var got recorder
+	calls := 0
+	Subscribe("test-durable", Durable, func(ctx context.Context, e TicketAssigned) error {
+		calls++
+		if calls == 1 {
+			return errors.New("downstream unavailable")
+		}
+		got.add(e)
+		return nil
+	})
+
+	if err := Transaction(config.DB, func(tx *gorm.DB, emit func(Event)) error {
+		emit(TicketAssigned{TicketID: 301, TechID: 7})
+		return nil
+	}); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	Flush()
+	if calls != 0 {
+		t.Fatal("durable subscriber was called before the outbox was relayed")
+	}
+
+	result, err := RelayOutbox(context.Background(), 10)
+	if err != nil || result.Failed != 1 {
+		t.Fatalf("expected one failed delivery, got %+v, %v", result, err)
+	}
+	var row models.OutboxEvent
+	config.DB.Where("event = ?", NameTicketAssigned).First(&row)
+	if row.ProcessedAt != nil || row.Attempts != 1 || row.LastError == "" {
+		t.Fatalf("failed delivery not recorded: %+v", row)
+	}
+
+	// Retry as if the backoff had passed
+	config.DB.Model(&row).Update("next_attempt_at", time.Now().Add(-time.Second))
+	if result, err := RelayOutbox(context.Background(), 10); err != nil || result.Delivered != 1 {
+		t.Fatalf("expected the retry to deliver, got %+v, %v", result, err)
+	}
+	if !got.has(301) {
+		t.Fatal("durable subscriber did not receive the decoded event")
+	}
+
+	config.DB.First(&row, row.ID)
+	if row.ProcessedAt == nil {
+		t.Fatal("delivered event not marked processed")
+	}
+	if pruned, err := PruneOutbox(time.Now().Add(time.Second)); err != nil || pruned != 1 {
+		t.Fatalf("expected one pruned event, got %d, %v", pruned, err)
+	}
+}
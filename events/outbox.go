@@ -0,0 +1,109 @@
+package events
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RelayResult counts what one RelayOutbox call did.
+type RelayResult struct {
+	Delivered int // Events every durable subscriber handled
+	Failed    int // Events left for a later retry
+}
+
+// saveOutbox writes events to the outbox table through db when the outbox is on.
+func saveOutbox(db *gorm.DB, events []Event) error {
+	if len(events) == 0 || !config.EventOutboxEnabled() {
+		return nil
+	}
+	now := time.Now()
+	rows := make([]models.OutboxEvent, 0, len(events))
+	for _, e := range events {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("could not encode %s: %w", e.EventName(), err)
+		}
+		rows = append(rows, models.OutboxEvent{Event: e.EventName(), Payload: string(payload), OccurredAt: now, NextAttemptAt: now})
+	}
+	return db.Create(&rows).Error
+}
+
+// RelayOutbox delivers up to limit undelivered outbox events, oldest first, to the Durable
+// subscribers. An event is marked processed once they have all handled it; if any fails, it is
+// retried later, after a delay that doubles with each attempt up to an hour. Run one relay at a
+// time, as the background job does.
+func RelayOutbox(ctx context.Context, limit int) (RelayResult, error) {
+	var result RelayResult
+	var rows []models.OutboxEvent
+	if err := config.DB.Where("processed_at IS NULL AND next_attempt_at <= ?", time.Now()).
+		Order("id").Limit(limit).Find(&rows).Error; err != nil {
+		return result, err
+	}
+
+	mu.RLock()
+	var durable []*subscriber
+	for _, s := range subscribers {
+		if s.mode == Durable {
+			durable = append(durable, s)
+		}
+	}
+	mu.RUnlock()
+
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		err := relay(ctx, durable, row)
+		now := time.Now()
+		if err == nil {
+			config.DB.Model(&row).Updates(map[string]interface{}{"processed_at": now, "attempts": row.Attempts + 1, "last_error": ""})
+			result.Delivered++
+			continue
+		}
+		delay := time.Minute << min(row.Attempts, 6)
+		if delay > time.Hour {
+			delay = time.Hour
+		}
+		config.DB.Model(&row).Updates(map[string]interface{}{"attempts": row.Attempts + 1, "last_error": err.Error(), "next_attempt_at": now.Add(delay)})
+		utils.LogError(fmt.Sprintf("[Events] Outbox event %d (%s) not delivered, retrying in %s", row.ID, row.Event, delay), err)
+		result.Failed++
+	}
+	return result, nil
+}
+
+// relay decodes one outbox row and hands it to each durable subscriber for its type.
+func relay(ctx context.Context, durable []*subscriber, row models.OutboxEvent) error {
+	t, ok := types[row.Event]
+	if !ok {
+		return fmt.Errorf("unknown event %q", row.Event)
+	}
+	value := reflect.New(t)
+	if err := json.Unmarshal([]byte(row.Payload), value.Interface()); err != nil {
+		return fmt.Errorf("could not decode: %w", err)
+	}
+	e := value.Elem().Interface().(Event)
+
+	for _, s := range durable {
+		if s.event != "" && s.event != row.Event {
+			continue
+		}
+		if err := call(ctx, s, e); err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+// PruneOutbox deletes outbox events that were delivered before cutoff.
+func PruneOutbox(cutoff time.Time) (int64, error) {
+	res := config.DB.Where("processed_at IS NOT NULL AND processed_at < ?", cutoff).Delete(&models.OutboxEvent{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,135 @@
+package events
+
+import "reflect"
+
+// Event names.
+const (
+	NameTicketCreated    = "ticket.created"
+	NameTicketUpdated    = "ticket.updated"
+	NameTicketAssigned   = "ticket.assigned"
+	NameTicketUnassigned = "ticket.unassigned"
+	NameTicketDeleted    = "ticket.deleted"
+	NameCommentAdded     = "comment.added"
+	NameCommentEdited    = "comment.edited"
+	NameCommentDeleted   = "comment.deleted"
+	NameUserCreated      = "user.created"
+	NameUserDeleted      = "user.deleted"
+)
+
+// TicketCreated is published when a ticket is raised.
+type TicketCreated struct {
+	TicketID uint
+	ClientID uint
+	Priority string
+	ActorID  uint
+}
+
+// TicketUpdated is published when a ticket's fields change.
+type TicketUpdated struct {
+	TicketID       uint
+	Version        uint
+	PreviousStatus string
+	Status         string
+	ActorID        uint
+}
+
+// TicketAssigned is published when a ticket is given to a technician, including on reassignment.
+type TicketAssigned struct {
+	TicketID       uint
+	TechID         uint
+	PreviousTechID *uint
+	ActorID        uint
+}
+
+// TicketUnassigned is published when a ticket loses its technician.
+type TicketUnassigned struct {
+	TicketID       uint
+	PreviousTechID *uint
+	ActorID        uint
+}
+
+// TicketDeleted is published when a ticket is moved to the trash.
+type TicketDeleted struct {
+	TicketID uint
+	ActorID  uint
+}
+
+// CommentAdded is published when a comment is posted on a ticket.
+type CommentAdded struct {
+	CommentID uint
+	TicketID  uint
+	AuthorID  uint
+}
+
+// CommentEdited is published when a comment's content changes.
+type CommentEdited struct {
+	CommentID uint
+	TicketID  uint
+	Version   uint
+}
+
+// CommentDeleted is published when a comment is moved to the trash.
+type CommentDeleted struct {
+	CommentID uint
+	TicketID  uint
+}
+
+// UserCreated is published when a user is added by any path other than demo seeding.
+type UserCreated struct {
+	UserID uint
+	Role   string
+	Source string // "register", "admin", "account", "scim", "directory", or "sso"
+}
+
+// UserDeleted is published when a user is moved to the trash. Their reassigned or unassigned
+// tickets are published as TicketAssigned and TicketUnassigned events too.
+type UserDeleted struct {
+	UserID  uint
+	ActorID uint
+}
+
+func (TicketCreated) EventName() string    { return NameTicketCreated }
+func (TicketUpdated) EventName() string    { return NameTicketUpdated }
+func (TicketAssigned) EventName() string   { return NameTicketAssigned }
+func (TicketUnassigned) EventName() string { return NameTicketUnassigned }
+func (TicketDeleted) EventName() string    { return NameTicketDeleted }
+func (CommentAdded) EventName() string     { return NameCommentAdded }
+func (CommentEdited) EventName() string    { return NameCommentEdited }
+func (CommentDeleted) EventName() string   { return NameCommentDeleted }
+func (UserCreated) EventName() string      { return NameUserCreated }
+func (UserDeleted) EventName() string      { return NameUserDeleted }
+
+// TicketID returns the ticket an event concerns, or zero for events that are not about a ticket.
+func TicketID(e Event) uint {
+	switch e := e.(type) {
+	case TicketCreated:
+		return e.TicketID
+	case TicketUpdated:
+		return e.TicketID
+	case TicketAssigned:
+		return e.TicketID
+	case TicketUnassigned:
+		return e.TicketID
+	case TicketDeleted:
+		return e.TicketID
+	case CommentAdded:
+		return e.TicketID
+	case CommentEdited:
+		return e.TicketID
+	case CommentDeleted:
+		return e.TicketID
+	}
+	return 0
+}
+
+// types maps event names to their types, for reading events back from the outbox.
+var types = map[string]reflect.Type{}
+
+func init() {
+	for _, e := range []Event{
+		TicketCreated{}, TicketUpdated{}, TicketAssigned{}, TicketUnassigned{}, TicketDeleted{},
+		CommentAdded{}, CommentEdited{}, CommentDeleted{}, UserCreated{}, UserDeleted{},
+	} {
+		types[e.EventName()] = reflect.TypeOf(e)
+	}
+}
@@ -0,0 +1,253 @@
+// Package fieldcrypt encrypts selected model fields at rest. Fields tagged
+// `gorm:"serializer:encrypted"` are stored as AES-256-GCM ciphertext made with a data key; data
+// keys are kept in the database wrapped by a master key from the environment, so neither the
+// database file nor its backups can be read without that key. Values written before encryption
+// was turned on are read back as they are until the re-encryption command rewrites them.
+package fieldcrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+// prefix marks an encrypted value; it is followed by the data key ID, a colon, and the base64
+// nonce and ciphertext.
+const prefix = "enc:v1:"
+
+// KeySize is the length in bytes of master and data keys.
+const KeySize = 32
+
+// ErrNoKey is returned when a value was encrypted with a data key that is not loaded.
+var ErrNoKey = errors.New("field is encrypted with an unknown data key; check RYANFORCE_MASTER_KEY")
+
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// keys is the active key set: the data key new values are encrypted with and every data key
+// that can decrypt. current is empty while encryption is off.
+var keys struct {
+	sync.RWMutex
+	current string
+	aeads   map[string]cipher.AEAD
+	reload  func() error
+}
+
+// Install makes the data keys available, encrypting new values with the one named current.
+// An empty current turns encryption of new values off.
+func Install(current string, dataKeys map[string][]byte) error {
+	aeads := make(map[string]cipher.AEAD, len(dataKeys))
+	for id, key := range dataKeys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return fmt.Errorf("data key %s: %w", id, err)
+		}
+		aeads[id] = aead
+	}
+	if _, ok := aeads[current]; current != "" && !ok {
+		return fmt.Errorf("current data key %s is not loaded", current)
+	}
+
+	keys.Lock()
+	keys.current, keys.aeads = current, aeads
+	keys.Unlock()
+	return nil
+}
+
+// OnUnknownKey sets a function that reloads the data keys, called once when a value names a
+// key that is not loaded, such as one created by a re-encryption in another process.
+func OnUnknownKey(reload func() error) {
+	keys.Lock()
+	keys.reload = reload
+	keys.Unlock()
+}
+
+// Enabled reports whether new values are being encrypted.
+func Enabled() bool {
+	keys.RLock()
+	defer keys.RUnlock()
+	return keys.current != ""
+}
+
+// NewKey returns a random key and an ID for it.
+func NewKey() (string, []byte, error) {
+	key := make([]byte, KeySize)
+	id := make([]byte, 8)
+	if _, err := rand.Read(key); err != nil {
+		return "", nil, err
+	}
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	return hex.EncodeToString(id), key, nil
+}
+
+// Wrap encrypts a data key with a master key for storage.
+func Wrap(master, dataKey []byte) (string, error) {
+	aead, err := newAEAD(master)
+	if err != nil {
+		return "", err
+	}
+	return seal(aead, dataKey, "data-key")
+}
+
+// Unwrap decrypts a data key stored by Wrap.
+func Unwrap(master []byte, wrapped string) ([]byte, error) {
+	aead, err := newAEAD(master)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, wrapped, "data-key")
+}
+
+// Encrypt encrypts a value for the named column, returning it unchanged while encryption is
+// off. Empty values stay empty so "is blank" checks keep working.
+func Encrypt(plaintext, column string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	keys.RLock()
+	id, aead := keys.current, keys.aeads[keys.current]
+	keys.RUnlock()
+	if id == "" {
+		return plaintext, nil
+	}
+	sealed, err := seal(aead, []byte(plaintext), column)
+	if err != nil {
+		return "", err
+	}
+	return prefix + id + ":" + sealed, nil
+}
+
+// Decrypt reverses Encrypt for the same column. Values that are not encrypted are returned as
+// they are.
+func Decrypt(value, column string) (string, error) {
+	id, sealed, ok := parse(value)
+	if !ok {
+		return value, nil
+	}
+	aead, err := lookup(id)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, sealed, column)
+	if err != nil {
+		return "", fmt.Errorf("decrypting %s: %w", column, err)
+	}
+	return string(plaintext), nil
+}
+
+// KeyID returns the ID of the data key a stored value was encrypted with, or "" if it is not
+// encrypted.
+func KeyID(value string) string {
+	id, _, _ := parse(value)
+	return id
+}
+
+// parse splits an encrypted value into its data key ID and sealed payload.
+func parse(value string) (string, string, bool) {
+	if !strings.HasPrefix(value, prefix) {
+		return "", "", false
+	}
+	id, sealed, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return id, sealed, ok
+}
+
+// lookup returns the cipher for a data key, reloading the key set once if it is unknown.
+func lookup(id string) (cipher.AEAD, error) {
+	keys.RLock()
+	aead, reload := keys.aeads[id], keys.reload
+	keys.RUnlock()
+	if aead != nil {
+		return aead, nil
+	}
+	if reload != nil {
+		if err := reload(); err != nil {
+			return nil, err
+		}
+		keys.RLock()
+		aead = keys.aeads[id]
+		keys.RUnlock()
+	}
+	if aead == nil {
+		return nil, ErrNoKey
+	}
+	return aead, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes", KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce, binding the ciphertext to context (the column name) so it
+// cannot be copied into another column.
+func seal(aead cipher.AEAD, plaintext []byte, context string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte(context))), nil
+}
+
+func open(aead cipher.AEAD, sealed, context string) ([]byte, error) {
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(context))
+}
+
+// Serializer is the GORM serializer for encrypted string fields, registered as "encrypted".
+type Serializer struct{}
+
+// Scan decrypts a value read from the database into the field.
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("encrypted field %s: unsupported database value %T", column(field), dbValue)
+	}
+	plaintext, err := Decrypt(stored, column(field))
+	if err != nil {
+		return err
+	}
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+// Value encrypts the field's value for writing to the database.
+func (Serializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted field %s must be a string", column(field))
+	}
+	return Encrypt(plaintext, column(field))
+}
+
+// column names a field as table.column, the context its ciphertext is bound to.
+func column(field *schema.Field) string {
+	return field.Schema.Table + "." + field.DBName
+}
@@ -0,0 +1,65 @@
+package fieldcrypt
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type note struct {
+	ID   uint
+	Body string `gorm:"serializer:encrypted"`
+}
+
+func TestSerializerRoundTrip(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&note{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values written while encryption is off stay readable once it is turned on.
+	if err := Install("", nil); err != nil {
+		t.Fatal(err)
+	}
+	legacy := note{Body: "router 10.0.0.1"}
+	db.Create(&legacy)
+
+	id, key, _ := NewKey()
+	if err := Install(id, map[string][]byte{id: key}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Install("", nil) })
+	secret := note{Body: "vpn password hunter2"}
+	db.Create(&secret)
+
+	var raw string
+	db.Raw("SELECT body FROM notes WHERE id = ?", secret.ID).Scan(&raw)
+	if strings.Contains(raw, "hunter2") || KeyID(raw) != id {
+		t.Fatalf("stored value is not encrypted with the data key: %q", raw)
+	}
+
+	var got []note
+	db.Order("id").Find(&got)
+	if len(got) != 2 || got[0].Body != legacy.Body || got[1].Body != secret.Body {
+		t.Fatalf("read back %+v", got)
+	}
+
+	// Ciphertext is bound to its column.
+	if _, err := Decrypt(raw, "tickets.description"); err == nil {
+		t.Fatal("ciphertext decrypted for another column")
+	}
+
+	_, master, _ := NewKey()
+	wrapped, _ := Wrap(master, key)
+	if unwrapped, err := Unwrap(master, wrapped); err != nil || string(unwrapped) != string(key) {
+		t.Fatal("data key did not survive wrapping")
+	}
+	if _, err := Unwrap(key, wrapped); err == nil {
+		t.Fatal("data key unwrapped with the wrong master key")
+	}
+}
@@ -2,16 +2,23 @@ package handlers
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"github.com/manifoldco/promptui"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"RyanForce/config"
 	"RyanForce/controllers"
+	"RyanForce/jobs"
+	"RyanForce/keyring"
 	"RyanForce/models"
+	"RyanForce/pwpolicy"
+	"RyanForce/rbac"
 	"RyanForce/utils"
 	"golang.org/x/term"
 )
@@ -19,9 +26,24 @@ import (
 // This file handles all CLI commands entered by the user. Each command is routed to a handler function
 // that performs the appropriate logic based on the user's role and input. It also supports session-based login.
 
+// capitalize upper-cases the first letter of a role or item name for display.
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
 // HandleCommand maps user input to application functionality.
-// Supports aliases for most commands and restricts access to certain features based on user roles.
+// Supports aliases for most commands and restricts access to certain features based on the
+// permissions of the user's role (see commandPermissions).
 func HandleCommand(cmd string) {
+	auditImpersonatedCommand(cmd)
+	if !authorizeCommand(cmd) {
+		return
+	}
+
 	switch cmd {
 	case "register", "r", "signup":
 		handleRegister() // New user registration
@@ -33,6 +55,8 @@ func HandleCommand(cmd string) {
 		handleListAccounts()
 	case "delete-account":
 		handleDeleteAccount()
+	case "set-account-networks":
+		handleSetAccountNetworks() // Restricts the networks an account's users may sign in from
 	case "login", "l", "auth":
 		handleLogin() // Log in to an existing account
 	case "logout", "lo", "exit":
@@ -85,13 +109,86 @@ func HandleCommand(cmd string) {
 		controllers.SeedDemoData() // Manually trigger seeding
 	case "clear-db":
 		handleClearDB() // DANGER - admin-only wipe of all users and tickets
+	case "trash", "list-trash":
+		handleListTrash() // Admin views deleted tickets, comments, and accounts
+	case "restore":
+		handleRestore() // Admin restores an item from the trash
+	case "purge-trash":
+		handlePurgeTrash() // Admin permanently removes expired trash
+	case "list-jobs":
+		handleListJobs() // Admin views job schedules and recent runs
+	case "run-job":
+		handleRunJob() // Admin queues a background job to run now
+	case "cancel-job":
+		handleCancelJob() // Admin cancels a queued or running job
+	case "retention-report":
+		handleRetentionRun(true) // Admin previews what the retention purge would delete
+	case "retention-purge":
+		handleRetentionRun(false) // Admin runs the retention purge now
+	case "set-retention":
+		handleSetRetention() // Admin configures global or per-account retention
+	case "legal-hold":
+		handleLegalHold() // Admin places or lifts a legal hold
+	case "list-directories":
+		handleListDirectories() // Admin views LDAP directories and their last sync
+	case "save-directory":
+		handleSaveDirectory() // Admin connects an account to an LDAP directory
+	case "sync-directory":
+		handleSyncDirectory() // Admin syncs an account's users from its directory now
+	case "delete-directory":
+		handleDeleteDirectory() // Admin disconnects an account from its directory
+	case "export-user":
+		handleExportUserData() // Admin exports a user's personal data to a ZIP file
+	case "anonymize-user", "erase-user":
+		handleAnonymizeUser() // Admin erases a user's personal data
+	case "list-roles":
+		handleListRoles() // Shows every role and its permissions
+	case "save-role":
+		handleSaveRole() // Creates or updates a custom role
+	case "delete-role":
+		handleDeleteRole() // Deletes an unused custom role
+	case "set-role":
+		handleSetRole() // Changes a user's role
+	case "set-password-login":
+		handleSetPasswordLogin() // Allows or blocks password login for a role
+	case "set-role-networks":
+		handleSetRoleNetworks() // Restricts the networks a role's members may sign in from
+	case "known-logins":
+		handleKnownLogins() // Shows the networks the current user has signed in from
+	case "failed-logins":
+		handleFailedLogins() // Shows locked accounts and recent failed logins by IP
+	case "enroll-mfa":
+		handleEnrollMFA() // Turns on two-step verification for the current user
+	case "disable-mfa":
+		handleDisableMFA() // Turns off two-step verification for the current user
+	case "reset-mfa":
+		handleResetMFA() // Admin clears a user's MFA so they can enroll again
+	case "require-mfa":
+		handleRequireMFA() // Admin makes MFA required or optional for a user
+	case "link-sso":
+		handleLinkSSO() // Admin links a user to an identity provider subject
+	case "rotate-keys":
+		handleRotateKeys() // Admin makes a new token signing key current
+	case "reencrypt-fields":
+		handleReencryptFields() // Admin rotates the field encryption key and rewrites encrypted fields
+	case "impersonate", "view-as":
+		handleImpersonate() // Admin starts a time-limited session as another user
+	case "stop-impersonating":
+		handleStopImpersonating() // Admin returns to their own session
+	case "list-colleagues":
+		handleListColleagues() // Account contact lists the users in their account
+	case "add-colleague":
+		handleAddColleague() // Account contact adds a client user to their account
+	case "account-report":
+		handleAccountReport() // Account contact views ticket counts and overdue tickets for their account
 	default:
 		fmt.Println("[Error] Unknown command. Try 'help' or 'whoami'")
 	}
 }
 
 // PromptLogin asks the user for email and password, attempts to authenticate them,
-// and returns a JWT token on success. Allows up to 3 attempts.
+// and returns a JWT token on success. Allows up to 3 attempts. Users with MFA are asked
+// for a TOTP or recovery code, and users required to enroll are walked through enrollment.
 func PromptLogin() (string, *utils.Claims, error) {
 	reader := bufio.NewReader(os.Stdin)
 
@@ -110,6 +207,15 @@ func PromptLogin() (string, *utils.Claims, error) {
 		password := string(bytePassword)
 
 		token, err := controllers.Authenticate(email, password)
+		if errors.Is(err, controllers.ErrMFARequired) {
+			token, err = promptMFACode(token)
+		}
+		if errors.Is(err, controllers.ErrMFAEnrollmentRequired) {
+			token, err = promptMFAEnrollment(token)
+		}
+		if errors.Is(err, controllers.ErrPasswordChangeRequired) {
+			token, err = promptExpiredPassword(token)
+		}
 		if err == nil {
 			claims, err := utils.ParseJWT(token)
 			if err != nil {
@@ -132,23 +238,85 @@ func PromptLogin() (string, *utils.Claims, error) {
 	return "", nil, fmt.Errorf("too many failed login attempts")
 }
 
+// promptMFACode asks for the second factor and exchanges the MFA challenge for a session token.
+func promptMFACode(challenge string) (string, error) {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Authentication code (or recovery code): ")
+	code, _ := reader.ReadString('\n')
+	return controllers.CompleteMFALogin(challenge, code, "CLI-Local", controllers.CLIUserAgent)
+}
+
+// promptMFAEnrollment enrolls a user whose admin requires MFA, then finishes their login.
+func promptMFAEnrollment(challenge string) (string, error) {
+	userID, err := controllers.MFAEnrollmentUserID(challenge)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Println("\nYour administrator requires two-step verification for this account.")
+	if !enrollMFA(userID) {
+		return "", fmt.Errorf("MFA enrollment was not completed")
+	}
+	return controllers.FinishEnrollmentLogin(challenge, "CLI-Local", controllers.CLIUserAgent)
+}
+
+// promptExpiredPassword asks a user whose password has expired for a new one and finishes
+// their login with it.
+func promptExpiredPassword(changeToken string) (string, error) {
+	fmt.Println("\nYour password has expired. " + pwpolicy.Requirements())
+	newPassword, err := promptPasswordTwice("New Password")
+	if err != nil {
+		return "", err
+	}
+	fmt.Println()
+	return controllers.ChangeExpiredPassword(changeToken, newPassword, "CLI-Local", controllers.CLIUserAgent)
+}
+
+// enrollMFA generates a TOTP secret, asks for a code to confirm it, and prints the recovery codes.
+func enrollMFA(userID uint) bool {
+	key, err := controllers.BeginMFAEnrollment(userID)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return false
+	}
+
+	fmt.Println("Add this account to your authenticator app using the key or URL below:")
+	fmt.Printf("  Key: %s\n  URL: %s\n", key.Secret(), key.URL())
+	fmt.Print("Enter the 6-digit code from the app: ")
+	reader := bufio.NewReader(os.Stdin)
+	code, _ := reader.ReadString('\n')
+
+	codes, err := controllers.ConfirmMFAEnrollment(userID, code)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return false
+	}
+
+	fmt.Println("\nTwo-step verification is on. Save these recovery codes; each works once and they will not be shown again:")
+	for _, c := range codes {
+		fmt.Println("  " + c)
+	}
+	fmt.Println()
+	return true
+}
+
 // DisplayDashboard prints a common welcome splash providing the User ID, session expiry, and role specific reports.
 func DisplayDashboard(claims *utils.Claims) {
-	fmt.Printf("\nWelcome, %s!\n", strings.Title(claims.Role))
+	fmt.Printf("\nWelcome, %s!\n", capitalize(claims.Role))
 	fmt.Printf("User ID       : %d\n", claims.UserID)
 	fmt.Printf("Session Expires: %s\n", claims.ExpiresAt.Time.Format("2006-01-02 15:04:05"))
 
-	switch claims.Role {
-	case "admin":
-		fmt.Println("\nAdmin Summary:")
+	switch {
+	case rbac.Can(claims.Role, rbac.ReportsView) || rbac.Can(claims.Role, rbac.TicketsViewAll):
+		fmt.Println("\nSummary:")
 		controllers.ReportStatus()
 		controllers.ReportUnassigned()
-	case "tech":
+	case rbac.Can(claims.Role, rbac.TicketsViewAssigned):
 		fmt.Println("\nAssigned Tickets:")
-		controllers.ListTickets(claims.UserID, claims.Role)
-	case "client":
+		controllers.ListTickets(controllers.ActorFromClaims(claims, "CLI-Local"))
+	case rbac.Can(claims.Role, rbac.TicketsViewOwn):
 		fmt.Println("\nYour Active Tickets:")
-		controllers.ListTickets(claims.UserID, claims.Role)
+		controllers.ListTickets(controllers.ActorFromClaims(claims, "CLI-Local"))
 	}
 
 	utils.LogInfo(fmt.Sprintf("[Dashboard] Displayed for user %d (%s)", claims.UserID, claims.Role))
@@ -186,7 +354,7 @@ func promptPasswordTwice(label string) (string, error) {
 }
 
 // handleRegister walks the user through creating a new account.
-// Prompts for email, password (with confirmation), and a role (client, tech, or admin).
+// Prompts for email, password (with confirmation), and a role (built-in or custom).
 func handleRegister() {
 	reader := bufio.NewReader(os.Stdin)
 
@@ -202,7 +370,7 @@ func handleRegister() {
 	}
 	fmt.Println()
 
-	roleOptions := []string{"client", "tech", "admin"}
+	roleOptions := rbac.RoleNames()
 	role, err := utils.PromptSelect("Select Role", roleOptions, 0)
 	if err != nil {
 		fmt.Println("Registration cancelled.")
@@ -210,8 +378,8 @@ func handleRegister() {
 		return
 	}
 
-	if !utils.IsValidPassword(password) {
-		fmt.Println("[Error] Password must be 8–32 characters with an uppercase letter, digit, and special character.")
+	if err := pwpolicy.Validate(password); err != nil {
+		fmt.Println("[Error]", err)
 		return
 	}
 
@@ -286,6 +454,9 @@ func handleListAccounts() {
 	fmt.Println("------------------------------")
 	for _, acct := range accounts {
 		fmt.Printf("Account ID %d: %s (Domain: %s)\n", acct.ID, acct.Name, acct.Domain)
+		if acct.AllowedNetworks != "" {
+			fmt.Printf("  Sign-in allowed from: %s\n", acct.AllowedNetworks)
+		}
 		if len(acct.Users) == 0 {
 			fmt.Println("  No users assigned.")
 		} else {
@@ -297,7 +468,12 @@ func handleListAccounts() {
 	}
 }
 
+// handleDeleteAccount moves an account to the trash once no users are assigned to it.
 func handleDeleteAccount() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Print("Account ID to delete: ")
@@ -310,21 +486,6 @@ func handleDeleteAccount() {
 	}
 	accountID := uint(id)
 
-	// Check for users assigned to this account
-	var count int64
-	err = config.DB.Model(&models.User{}).Where("account_id = ?", accountID).Count(&count).Error
-	if err != nil {
-		fmt.Println("[Error] Failed to check account associations:", err)
-		utils.LogError("[Admin] Failed account user check before deletion", err)
-		return
-	}
-
-	if count > 0 {
-		fmt.Printf("[Error] Cannot delete account ID %d — %d user(s) are still assigned.\n", accountID, count)
-		fmt.Println("Reassign or remove users before deleting this account.")
-		return
-	}
-
 	fmt.Printf("Are you sure you want to delete account %d? Type 'yes' to confirm: ", accountID)
 	confirm, _ := reader.ReadString('\n')
 	if strings.ToLower(strings.TrimSpace(confirm)) != "yes" {
@@ -332,14 +493,11 @@ func handleDeleteAccount() {
 		return
 	}
 
-	err = config.DB.Delete(&models.Account{}, accountID).Error
-	if err != nil {
+	if err := controllers.DeleteAccount(accountID, claims.UserID, "CLI-Local"); err != nil {
 		fmt.Println("[Error] Failed to delete account:", err)
-		utils.LogError("[Admin] Failed to delete account", err)
-	} else {
-		fmt.Printf("✅ Account ID %d deleted.\n", accountID)
-		utils.LogInfo(fmt.Sprintf("[Admin] Deleted account ID %d", accountID))
+		return
 	}
+	fmt.Printf("✅ Account ID %d deleted.\n", accountID)
 }
 
 // handleLogin authenticates a user and stores their session token.
@@ -357,7 +515,7 @@ func handleLogin() {
 		return
 	}
 
-	fmt.Printf("Login successful. Welcome %s.\n", strings.Title(claims.Role))
+	fmt.Printf("Login successful. Welcome %s.\n", capitalize(claims.Role))
 	fmt.Printf("Session expires at: %s\n", claims.ExpiresAt.Time.Format("2006-01-02 15:04:05"))
 	utils.LogInfo(fmt.Sprintf("[Login] Session saved for user %d (%s)", claims.UserID, claims.Role))
 
@@ -388,6 +546,9 @@ func handleWhoami() {
 	fmt.Println("User ID   :", claims.UserID)
 	fmt.Println("Role      :", claims.Role)
 	fmt.Println("Expires At:", claims.ExpiresAt.Time.Format("2006-01-02 15:04:05"))
+	if claims.Impersonating() {
+		fmt.Printf("Viewing as this user on behalf of %s (user %d)\n", claims.ImpersonatorEmail, claims.ImpersonatorID)
+	}
 	utils.LogInfo(fmt.Sprintf("[Whoami] Session viewed by user %d (%s)", claims.UserID, claims.Role))
 }
 
@@ -416,10 +577,6 @@ func handleResetPassword() {
 	}
 	fmt.Println()
 
-	if !utils.IsValidPassword(newPassword) {
-		fmt.Println("[Error] Password does not meet strength requirements.")
-		return
-	}
 	err = controllers.ResetPassword(email, oldPassword, newPassword)
 	if err != nil {
 		fmt.Println("[Error]", err)
@@ -449,10 +606,6 @@ func handleAdminResetPassword() {
 	}
 	fmt.Println()
 
-	if !utils.IsValidPassword(newPassword) {
-		fmt.Println("[Error] Password does not meet strength requirements.")
-		return
-	}
 	err = controllers.AdminResetPassword(claims.UserID, targetEmail, newPassword)
 	if err != nil {
 		fmt.Println("[Error]", err)
@@ -462,68 +615,64 @@ func handleAdminResetPassword() {
 }
 
 // handleCreateTicket prompts a logged-in client to submit a new support request.
-// It collects the ticket title, description, priority, and initial status.
+// It collects the ticket title, description, priority, and the skills it needs.
 func handleCreateTicket() {
 	claims, err := utils.LoadClaims()
 	if err != nil || claims == nil {
 		return
 	}
 
-	if claims.Role != "client" {
-		fmt.Println("[Error] Only clients can create tickets.")
-		utils.LogWarning(fmt.Sprintf("[CreateTicket] Unauthorized attempt by user %d (%s)", claims.UserID, claims.Role))
-		return
-	}
-
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Print("Title: ")
 	title, _ := reader.ReadString('\n')
-	title = strings.TrimSpace(title)
 
 	fmt.Print("Description: ")
 	desc, _ := reader.ReadString('\n')
-	desc = strings.TrimSpace(desc)
 
-	priorityOptions := []string{"low", "medium", "high", "critical"}
-	priority, err := utils.PromptSelect("Select Priority", priorityOptions, 1)
+	priority, err := utils.PromptSelect("Select Priority", controllers.TicketPriorities, 1)
 	if err != nil {
 		fmt.Println("Priority selection cancelled.")
 		utils.LogInfo("[CreateTicket] Priority selection cancelled")
 		return
 	}
 
-	status := "initially reported"
-	controllers.CreateTicket(title, desc, priority, status, claims.UserID)
-	utils.LogInfo(fmt.Sprintf("[CreateTicket] New ticket created by user %d", claims.UserID))
+	fmt.Print("Required skills (comma-separated, optional): ")
+	skills, _ := reader.ReadString('\n')
+
+	controllers.CreateTicket(controllers.ActorFromClaims(claims, "CLI-Local"), controllers.CreateTicketInput{
+		Title:       title,
+		Description: desc,
+		Priority:    priority,
+		Skills:      strings.Split(skills, ","),
+	})
 }
 
 // handleUpdateTicket allows a technician or admin the ability to modify an existing ticket.
+// Changes are collected from the menu and saved together on Return.
 func handleUpdateTicket() {
 	claims, err := utils.LoadClaims()
 	if err != nil || claims == nil {
 		return
 	}
+	actor := controllers.ActorFromClaims(claims, "CLI-Local")
 
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Print("Ticket ID: ")
 	idStr, _ := reader.ReadString('\n')
-	idStr = strings.TrimSpace(idStr)
-	idUint64, err := strconv.ParseUint(idStr, 10, 64)
+	ticketID, err := controllers.ParseTicketID(idStr)
 	if err != nil {
 		fmt.Println("[Error] Invalid ticket ID.")
 		return
 	}
-	ticketID := uint(idUint64)
 
-	var ticket models.Ticket
-	result := config.DB.First(&ticket, ticketID)
-	if result.Error != nil {
-		fmt.Println("[Error] Ticket not found.")
-		utils.LogWarning(fmt.Sprintf("[UpdateTicket] Ticket %d not found", ticketID))
+	ticket, err := controllers.NewTicketService(config.DB).GetForUpdate(actor, ticketID)
+	if err != nil {
+		fmt.Println("[Error]", err)
 		return
 	}
 
+	var changes controllers.UpdateTicketInput
 	for {
 		prompt := promptui.Select{
 			Label: "Select an action for this ticket",
@@ -542,53 +691,56 @@ func handleUpdateTicket() {
 			fmt.Printf("New Description [%s]: ", ticket.Description)
 			newDesc, _ := reader.ReadString('\n')
 			newDesc = strings.TrimSpace(newDesc)
-			if newDesc == "" {
-				newDesc = ticket.Description
+			if newDesc != "" {
+				ticket.Description = newDesc
+				changes.Description = &newDesc
 			}
-			ticket.Description = newDesc
 
 		case "Update Priority":
-			priorityOptions := []string{"low", "medium", "high", "critical"}
-			currentPriorityIndex := utils.IndexOf(ticket.Priority, priorityOptions)
-			newPriority, err := utils.PromptSelect("Select Priority", priorityOptions, currentPriorityIndex)
+			currentPriorityIndex := utils.IndexOf(ticket.Priority, controllers.TicketPriorities)
+			newPriority, err := utils.PromptSelect("Select Priority", controllers.TicketPriorities, currentPriorityIndex)
 			if err == nil {
 				ticket.Priority = newPriority
+				changes.Priority = &newPriority
 			}
 
 		case "Update Status":
-			statusOptions := []string{
-				"initially reported",
-				"customer to follow up",
-				"support to follow up",
-				"working",
-				"closed",
-			}
-			currentStatusIndex := utils.IndexOf(ticket.Status, statusOptions)
-			newStatus, err := utils.PromptSelect("Select Status", statusOptions, currentStatusIndex)
+			currentStatusIndex := utils.IndexOf(ticket.Status, controllers.TicketStatuses)
+			newStatus, err := utils.PromptSelect("Select Status", controllers.TicketStatuses, currentStatusIndex)
 			if err == nil {
 				ticket.Status = newStatus
+				changes.Status = &newStatus
 			}
 
 		case "Manage Comments":
-			handleManageComments(ticketID, claims.UserID, claims.Email)
+			handleManageComments(ticketID, claims)
 
 		case "Return":
-			// Save ticket updates before exiting
-			err := config.DB.Save(&ticket).Error
-			if err != nil {
-				fmt.Println("[Error] Failed to save ticket updates.")
-				utils.LogError(fmt.Sprintf("[UpdateTicket] Failed to save ticket %d", ticketID), err)
-			} else {
-				fmt.Println("[Success] Ticket updated successfully.")
-				utils.LogInfo(fmt.Sprintf("[UpdateTicket] User %d updated ticket %d", claims.UserID, ticketID))
+			if changes == (controllers.UpdateTicketInput{}) {
+				fmt.Println("No changes to save.")
+				return
 			}
+			changes.Version = ticket.Version
+			err := controllers.UpdateTicket(actor, ticketID, changes)
+			var conflict *controllers.ConflictError
+			if !errors.As(err, &conflict) {
+				return
+			}
+			fmt.Print("Apply your changes on top of the current version? Type 'yes' to confirm: ")
+			confirm, _ := reader.ReadString('\n')
+			if strings.TrimSpace(strings.ToLower(confirm)) != "yes" {
+				fmt.Println("Your changes were discarded.")
+				return
+			}
+			changes.Version = conflict.CurrentVersion
+			controllers.UpdateTicket(actor, ticketID, changes)
 			return
 		}
 	}
 }
 
 // handleManageComments launches a subprocess to add, edit, or delete comments for a given ticket
-func handleManageComments(ticketID uint, userID uint, userEmail string) {
+func handleManageComments(ticketID uint, claims *utils.Claims) {
 	for {
 		fmt.Println("\nComment Management for Ticket:", ticketID)
 
@@ -606,11 +758,15 @@ func handleManageComments(ticketID uint, userID uint, userEmail string) {
 
 		switch action {
 		case "Add Comment":
-			handleAddComment(ticketID, userID, userEmail)
+			if !rbac.Can(claims.Role, rbac.CommentsCreate) {
+				fmt.Println("[Error] Your role does not allow adding comments.")
+				continue
+			}
+			handleAddComment(ticketID, claims.UserID, claims.Email)
 		case "Edit Comment":
-			handleEditComment(ticketID)
+			handleEditComment(ticketID, claims)
 		case "Delete Comment":
-			handleDeleteComment(ticketID)
+			handleDeleteComment(ticketID, claims)
 		case "Return":
 			return
 		}
@@ -636,7 +792,7 @@ func handleAddComment(ticketID uint, userID uint, userEmail string) {
 	}
 }
 
-func handleEditComment(ticketID uint) {
+func handleEditComment(ticketID uint, claims *utils.Claims) {
 	comments, err := controllers.GetCommentsForTicket(ticketID)
 	if err != nil || len(comments) == 0 {
 		fmt.Println("[Info] No comments to edit.")
@@ -660,6 +816,10 @@ func handleEditComment(ticketID uint) {
 	}
 
 	selectedComment := comments[index]
+	if !rbac.CanModifyComment(claims.UserID, claims.Role, selectedComment.AuthorID) {
+		fmt.Println("[Error] You can only edit your own comments.")
+		return
+	}
 
 	newPrompt := promptui.Prompt{
 		Label:   "Enter new text",
@@ -672,15 +832,19 @@ func handleEditComment(ticketID uint) {
 		return
 	}
 
-	err = controllers.EditComment(selectedComment.ID, newText, "CLI-Local")
-	if err != nil {
+	err = controllers.EditComment(selectedComment.ID, newText, selectedComment.Version, "CLI-Local")
+	var conflict *controllers.ConflictError
+	switch {
+	case errors.As(err, &conflict):
+		controllers.PrintConflict(conflict)
+	case err != nil:
 		fmt.Println("[Error] Failed to edit comment.")
-	} else {
+	default:
 		fmt.Println("[Success] Comment updated.")
 	}
 }
 
-func handleDeleteComment(ticketID uint) {
+func handleDeleteComment(ticketID uint, claims *utils.Claims) {
 	comments, err := controllers.GetCommentsForTicket(ticketID)
 	if err != nil || len(comments) == 0 {
 		fmt.Println("[Info] No comments to delete.")
@@ -704,6 +868,10 @@ func handleDeleteComment(ticketID uint) {
 	}
 
 	selectedComment := comments[index]
+	if !rbac.CanModifyComment(claims.UserID, claims.Role, selectedComment.AuthorID) {
+		fmt.Println("[Error] You can only delete your own comments.")
+		return
+	}
 
 	confirm := promptui.Prompt{
 		Label: "Are you sure you want to delete this comment? (yes/no)",
@@ -745,7 +913,7 @@ func handleListTickets() {
 		return
 	}
 
-	controllers.ListTickets(claims.UserID, claims.Role)
+	controllers.ListTickets(controllers.ActorFromClaims(claims, "CLI-Local"))
 	utils.LogInfo(fmt.Sprintf("[ListTickets] Tickets listed for user %d (%s)", claims.UserID, claims.Role))
 }
 
@@ -756,23 +924,16 @@ func handleAssignTicket() {
 	if err != nil || claims == nil {
 		return
 	}
-	if claims.Role != "admin" {
-		fmt.Println("[Error] Only admins can assign tickets.")
-		utils.LogWarning(fmt.Sprintf("[AssignTicket] Unauthorized attempt by user %d (%s)", claims.UserID, claims.Role))
-		return
-	}
 
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Print("Ticket ID to assign: ")
 	tidStr, _ := reader.ReadString('\n')
-	tidStr = strings.TrimSpace(tidStr)
-	tid64, err := strconv.ParseUint(tidStr, 10, 64)
+	ticketID, err := controllers.ParseTicketID(tidStr)
 	if err != nil {
 		fmt.Println("[Error] Invalid ticket ID.")
 		return
 	}
-	ticketID := uint(tid64)
 
 	fmt.Print("Tech User ID: ")
 	techStr, _ := reader.ReadString('\n')
@@ -784,8 +945,7 @@ func handleAssignTicket() {
 	}
 	techID := uint(tech64)
 
-	controllers.AssignTicket(ticketID, techID)
-	utils.LogInfo(fmt.Sprintf("[AssignTicket] Admin %d assigned ticket %d to tech %d", claims.UserID, ticketID, techID))
+	controllers.AssignTicket(controllers.ActorFromClaims(claims, "CLI-Local"), ticketID, techID)
 }
 
 // handleViewTicket lets a user inspect a ticket by ID, respecting their role visibility.
@@ -810,7 +970,9 @@ func handleViewTicket() {
 	}
 	ticketID := uint(idUint64)
 
-	controllers.ViewTicket(ticketID, claims.UserID, claims.Role)
+	if !controllers.ViewTicket(controllers.ActorFromClaims(claims, "CLI-Local"), ticketID) {
+		return
+	}
 	utils.LogInfo(fmt.Sprintf("[ViewTicket] User %d (%s) viewed ticket %d", claims.UserID, claims.Role, ticketID))
 
 	// Fetch and display comments inline
@@ -835,15 +997,8 @@ func handleFilterTickets() {
 		return
 	}
 
-	priorityOptions := []string{"", "low", "medium", "high", "critical"}
-	statusOptions := []string{
-		"",
-		"initially reported",
-		"customer to follow up",
-		"support to follow up",
-		"working",
-		"closed",
-	}
+	priorityOptions := append([]string{""}, controllers.TicketPriorities...)
+	statusOptions := append([]string{""}, controllers.TicketStatuses...)
 
 	fmt.Println("Leave blank to skip a filter.")
 
@@ -859,7 +1014,14 @@ func handleFilterTickets() {
 		return
 	}
 
-	controllers.FilterTickets(claims.UserID, claims.Role, priority, status)
+	fmt.Print("Keyword in title or description: ")
+	keyword, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	controllers.FilterTickets(controllers.ActorFromClaims(claims, "CLI-Local"), controllers.TicketFilter{
+		Priority: priority,
+		Status:   status,
+		Keyword:  keyword,
+	})
 	utils.LogInfo(fmt.Sprintf("[FilterTickets] User %d (%s) filtered tickets", claims.UserID, claims.Role))
 }
 
@@ -892,22 +1054,23 @@ func handleCommentTicket() {
 		return
 	}
 
-	comment := models.Comment{
-		TicketID:    ticketID,
-		AuthorID:    claims.UserID,
-		AuthorEmail: claims.Email,
-		Content:     commentText,
-		CreatedAt:   time.Now(),
+	var ticket models.Ticket
+	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
+		fmt.Println("[Error] Ticket not found.")
+		return
+	}
+	if !rbac.CanViewTicket(claims.UserID, claims.Role, &ticket) {
+		fmt.Println("[Error] Unauthorized to comment on this ticket.")
+		utils.LogWarning(fmt.Sprintf("[CommentTicket] Unauthorized attempt by user %d (%s) on ticket %d", claims.UserID, claims.Role, ticketID))
+		return
 	}
 
-	if err := config.DB.Create(&comment).Error; err != nil {
+	if err := controllers.AddCommentToTicket(ticketID, commentText, claims.UserID, claims.Email, "CLI-Local"); err != nil {
 		fmt.Println("[Error] Failed to save comment.")
-		utils.LogError("[CommentTicket] Failed to save comment", err)
 		return
 	}
 
 	fmt.Println("Comment added.")
-	utils.LogInfo(fmt.Sprintf("[CommentTicket] User %d commented on ticket %d", claims.UserID, ticketID))
 }
 
 // handleDeleteTicket allows an admin to delete a ticket by its ID.
@@ -917,11 +1080,6 @@ func handleDeleteTicket() {
 	if err != nil || claims == nil {
 		return
 	}
-	if claims.Role != "admin" {
-		fmt.Println("[Error] Only admins can delete tickets.")
-		utils.LogWarning(fmt.Sprintf("[DeleteTicket] Unauthorized attempt by user %d (%s)", claims.UserID, claims.Role))
-		return
-	}
 
 	reader := bufio.NewReader(os.Stdin)
 
@@ -943,8 +1101,7 @@ func handleDeleteTicket() {
 		return
 	}
 
-	controllers.DeleteTicket(ticketID)
-	utils.LogInfo(fmt.Sprintf("[DeleteTicket] Admin %d deleted ticket %d", claims.UserID, ticketID))
+	controllers.DeleteTicket(controllers.ActorFromClaims(claims, "CLI-Local"), ticketID)
 }
 
 // handleViewLogs allows admins to view recent events from the log file.
@@ -954,11 +1111,6 @@ func handleViewLogs() {
 	if err != nil || claims == nil {
 		return
 	}
-	if claims.Role != "admin" {
-		fmt.Println("[Error] Only admins can view logs.")
-		utils.LogWarning(fmt.Sprintf("[ViewLogs] Unauthorized access attempt by user %d (%s)", claims.UserID, claims.Role))
-		return
-	}
 
 	data, err := os.ReadFile("logs/ryanforce.log")
 	if err != nil {
@@ -973,6 +1125,123 @@ func handleViewLogs() {
 	utils.LogInfo(fmt.Sprintf("[ViewLogs] Admin %d viewed logs", claims.UserID))
 }
 
+// handleFailedLogins prints failed logins from the last 24 hours by IP and the locked accounts.
+func handleFailedLogins() {
+	locked, err := controllers.LockedUsers()
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Println("\nLocked Accounts")
+	fmt.Println("------------------------")
+	if len(locked) == 0 {
+		fmt.Println("None")
+	}
+	for _, u := range locked {
+		until := "until unlocked by an admin"
+		if !u.IsLocked && u.LockedUntil != nil {
+			until = "until " + u.LockedUntil.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%s — locked %s (lockouts: %d)\n", u.Email, until, u.LockoutCount)
+	}
+
+	attempts, err := controllers.RecentFailedLogins(24 * time.Hour)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Println("\nFailed Logins by IP (last 24 hours)")
+	fmt.Println("------------------------")
+	if len(attempts) == 0 {
+		fmt.Println("None")
+	}
+	for _, a := range attempts {
+		throttled := ""
+		if a.ThrottledFor > 0 {
+			throttled = fmt.Sprintf(" | throttled for %s", a.ThrottledFor.Round(time.Second))
+		}
+		fmt.Printf("%-15s | %3d failures | last %s | %s%s\n", a.IP, a.Failures,
+			a.LastAttempt.Format("2006-01-02 15:04:05"), strings.Join(a.Emails, ", "), throttled)
+	}
+}
+
+// helpLine is one entry in the help output. It is shown when the user's role grants any of
+// perms, or always when perms is empty.
+type helpLine struct {
+	text  string
+	perms []rbac.Permission
+}
+
+var anyTicketView = []rbac.Permission{rbac.TicketsViewOwn, rbac.TicketsViewAssigned, rbac.TicketsViewAll}
+
+var helpLines = []helpLine{
+	{"login           (l, auth)          Log in to the system", nil},
+	{"logout          (lo, exit)         Log out of current session", nil},
+	{"whoami          (me, status)       Show info about current user", nil},
+	{"help            (h, ?)             Show this help message", nil},
+	{"enroll-mfa      -                  Turn on two-step verification", nil},
+	{"disable-mfa     -                  Turn off two-step verification", nil},
+	{"known-logins    -                  Show the networks you have signed in from", nil},
+	{"create-ticket   (ct, new)          Create a new support ticket", []rbac.Permission{rbac.TicketsCreate}},
+	{"view-ticket     (vt, show)         View a specific ticket", anyTicketView},
+	{"list-tickets    (lt, list)         List the tickets you can see", anyTicketView},
+	{"filter-tickets  (ft, search)       Filter tickets by status/priority", anyTicketView},
+	{"update-ticket   (ut, edit)         Update a ticket", []rbac.Permission{rbac.TicketsUpdateOwn, rbac.TicketsUpdateAssigned, rbac.TicketsUpdateAll}},
+	{"comment-ticket  (ctc)              Add a comment to a ticket", []rbac.Permission{rbac.CommentsCreate}},
+	{"assign-ticket   (at, assign)       Assign a ticket to a tech", []rbac.Permission{rbac.TicketsAssign}},
+	{"delete-ticket   (dt, remove)       Move a ticket to the trash by ID", []rbac.Permission{rbac.TicketsDelete}},
+	{"register        (r, signup)        Register a new user", []rbac.Permission{rbac.UsersManage}},
+	{"admin-reset-password (arp, admin-reset) Reset a user's password", []rbac.Permission{rbac.UsersManage}},
+	{"list-users      (lu)               Lists all users", []rbac.Permission{rbac.UsersView}},
+	{"delete-user     (du)               Delete a user, reassigning their open tickets", []rbac.Permission{rbac.UsersManage}},
+	{"list-roles      -                  List roles and their permissions", []rbac.Permission{rbac.RolesManage}},
+	{"save-role       -                  Create or update a custom role", []rbac.Permission{rbac.RolesManage}},
+	{"delete-role     -                  Delete an unused custom role", []rbac.Permission{rbac.RolesManage}},
+	{"set-role        -                  Change a user's role", []rbac.Permission{rbac.RolesManage}},
+	{"set-password-login -               Allow or block password login for a role", []rbac.Permission{rbac.RolesManage}},
+	{"set-role-networks -                Limit the networks a role may sign in from", []rbac.Permission{rbac.RolesManage}},
+	{"reset-mfa       -                  Reset a user's MFA after a lost authenticator", []rbac.Permission{rbac.UsersManage}},
+	{"require-mfa     -                  Require or stop requiring MFA for a user", []rbac.Permission{rbac.UsersManage}},
+	{"link-sso        -                  Link a user to their single sign-on identity", []rbac.Permission{rbac.UsersManage}},
+	{"impersonate     (view-as)          View the system as another user for a while", []rbac.Permission{rbac.UsersImpersonate}},
+	{"stop-impersonating -               Return to your own session", nil},
+	{"list-colleagues -                  List the users in your account", []rbac.Permission{rbac.AccountUsers}},
+	{"add-colleague   -                  Add a user to your account", []rbac.Permission{rbac.AccountUsers}},
+	{"account-report  -                  Show ticket counts and overdue tickets for your account", []rbac.Permission{rbac.AccountReports}},
+	{"create-account     -             Create a new customer account", []rbac.Permission{rbac.AccountsManage}},
+	{"assign-account     -             Assign user to an account by ID", []rbac.Permission{rbac.AccountsManage}},
+	{"list-accounts      -             List all accounts and their users", []rbac.Permission{rbac.AccountsView}},
+	{"delete-account     -             Delete an account by ID", []rbac.Permission{rbac.AccountsManage}},
+	{"set-account-networks -           Limit the networks an account's users may sign in from", []rbac.Permission{rbac.AccountsManage}},
+	{"trash           (list-trash)       List deleted tickets, comments, and accounts", []rbac.Permission{rbac.TrashManage}},
+	{"restore         -                  Restore an item from the trash", []rbac.Permission{rbac.TrashManage}},
+	{"purge-trash     -                  Permanently remove expired trash", []rbac.Permission{rbac.TrashManage}},
+	{"list-jobs       -                  List background job schedules and recent runs", []rbac.Permission{rbac.JobsManage}},
+	{"run-job         -                  Queue a background job to run now", []rbac.Permission{rbac.JobsManage}},
+	{"cancel-job      -                  Cancel a queued or running background job", []rbac.Permission{rbac.JobsManage}},
+	{"retention-report -                 Preview what the retention purge would delete", []rbac.Permission{rbac.RetentionManage}},
+	{"retention-purge -                  Run the retention purge now", []rbac.Permission{rbac.RetentionManage}},
+	{"set-retention   -                  Set global or per-account retention periods", []rbac.Permission{rbac.RetentionManage}},
+	{"legal-hold      -                  Place or lift a legal hold on an account or ticket", []rbac.Permission{rbac.RetentionManage}},
+	{"list-directories -                 List LDAP directories and their last sync", []rbac.Permission{rbac.DirectoryManage}},
+	{"save-directory  -                  Connect an account to an LDAP directory", []rbac.Permission{rbac.DirectoryManage}},
+	{"sync-directory  -                  Sync an account's users from its directory now", []rbac.Permission{rbac.DirectoryManage}},
+	{"delete-directory -                 Disconnect an account from its directory", []rbac.Permission{rbac.DirectoryManage}},
+	{"export-user     -                  Export a user's personal data to a ZIP file", []rbac.Permission{rbac.PrivacyManage}},
+	{"anonymize-user  (erase-user)       Erase a user's personal data, keeping tickets", []rbac.Permission{rbac.PrivacyManage}},
+	{"view-logs       (logs, tail)       View system event log", []rbac.Permission{rbac.LogsView}},
+	{"failed-logins   -                  Show locked accounts and failed logins by IP", []rbac.Permission{rbac.LogsView}},
+	{"report-status   -                  Show number of tickets by status", []rbac.Permission{rbac.ReportsView}},
+	{"report-priority  -                 Show number of tickets by priority", []rbac.Permission{rbac.ReportsView}},
+	{"report-unassigned -                List all tickets without an assigned tech", []rbac.Permission{rbac.ReportsView}},
+	{"report-overdue     -             Show open tickets that have passed SLA deadline", []rbac.Permission{rbac.ReportsView}},
+	{"report-all         -             Run full report summary (status, SLA, overdue)", []rbac.Permission{rbac.ReportsView}},
+	{"export-tickets     -             Export all tickets to CSV file", []rbac.Permission{rbac.ReportsView}},
+	{"rotate-keys     -                  Rotate the token signing key", []rbac.Permission{rbac.SystemManage}},
+	{"reencrypt-fields -                 Rotate the field encryption key and re-encrypt stored data", []rbac.Permission{rbac.SystemManage}},
+	{"clear-db        -                  Dangerously wipe all data", []rbac.Permission{rbac.SystemManage}},
+}
+
 // handleHelp shows available commands based on the current user's role.
 // Admins, techs, and clients will only see commands they are allowed to use.
 func handleHelp() {
@@ -983,50 +1252,12 @@ func handleHelp() {
 		return
 	}
 
-	role := claims.Role
-
 	fmt.Println("\nAvailable Commands:")
 	fmt.Println("------------------------")
-	fmt.Println("login           (l, auth)          Log in to the system")
-	fmt.Println("logout          (lo, exit)         Log out of current session")
-	fmt.Println("whoami          (me, status)       Show info about current user")
-	fmt.Println("help            (h, ?)             Show this help message")
-
-	if role == "client" {
-		fmt.Println("create-ticket   (ct, new)          Create a new support ticket")
-		fmt.Println("view-ticket     (vt, show)         View a specific ticket")
-		fmt.Println("list-tickets    (lt, list)         List your submitted tickets")
-		fmt.Println("filter-tickets  (ft, search)       Filter your tickets by status/priority")
-	}
-	if role == "tech" {
-		fmt.Println("update-ticket   (ut, edit)         Update a ticket assigned to you")
-		fmt.Println("view-ticket     (vt, show)         View a specific ticket")
-		fmt.Println("list-tickets    (lt, list)         List your assigned tickets")
-		fmt.Println("filter-tickets  (ft, search)       Filter assigned tickets")
-	}
-	if role == "admin" {
-		fmt.Println("register        (r, signup)        Register a new user")
-		fmt.Println("create-account     -             Create a new customer account")
-		fmt.Println("assign-account     -             Assign user to an account by ID")
-		fmt.Println("list-accounts      -             List all accounts and their users")
-		fmt.Println("delete-account     -             Delete an account by ID")
-		fmt.Println("admin-reset-password (arp, admin-reset) Reset a user's password")
-		fmt.Println("assign-ticket   (at, assign)       Assign a ticket to a tech")
-		fmt.Println("delete-ticket   (dt, remove)       Delete a ticket by ID")
-		fmt.Println("clear-db        -                  Dangerously wipe all data")
-		fmt.Println("view-logs       (logs, tail)       View system event log")
-		fmt.Println("update-ticket   (ut, edit)         Update any ticket")
-		fmt.Println("view-ticket     (vt, show)         View a specific ticket")
-		fmt.Println("list-tickets    (lt, list)         List all tickets")
-		fmt.Println("list-users      (lu)				Lists all users")
-		fmt.Println("delete-user 	 (du) 				Delete a user by ID")
-		fmt.Println("filter-tickets  (ft, search)       Filter all tickets")
-		fmt.Println("report-status   -                  Show number of tickets by status")
-		fmt.Println("report-priority  -                 Show number of tickets by priority")
-		fmt.Println("report-unassigned -                List all tickets without an assigned tech")
-		fmt.Println("report-overdue     -             Show open tickets that have passed SLA deadline")
-		fmt.Println("report-all         -             Run full report summary (status, SLA, overdue)")
-		fmt.Println("export-tickets     -             Export all tickets to CSV file")
+	for _, h := range helpLines {
+		if len(h.perms) == 0 || rbac.CanAny(claims.Role, h.perms...) {
+			fmt.Println(h.text)
+		}
 	}
 	utils.LogInfo(fmt.Sprintf("[Help] Help viewed by user %d (%s)", claims.UserID, claims.Role))
 }
@@ -1038,11 +1269,6 @@ func handleClearDB() {
 	if err != nil || claims == nil {
 		return
 	}
-	if claims.Role != "admin" {
-		fmt.Println("[Error] Only admins can clear the database.")
-		utils.LogWarning(fmt.Sprintf("[ClearDB] Unauthorized database wipe attempt by user %d (%s)", claims.UserID, claims.Role))
-		return
-	}
 
 	fmt.Print("Are you sure you want to delete all users and tickets? Type 'yes' to confirm: ")
 	reader := bufio.NewReader(os.Stdin)
@@ -1056,3 +1282,1056 @@ func handleClearDB() {
 	controllers.ClearDatabase(true)
 	utils.LogWarning(fmt.Sprintf("[ClearDB] Admin %d wiped database", claims.UserID))
 }
+
+// handleListTrash shows soft-deleted tickets, comments, and accounts (admin only).
+func handleListTrash() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	trash, err := controllers.ListTrash()
+	if err != nil {
+		fmt.Println("[Error] Could not load trash.")
+		utils.LogError("[Trash] Failed to load trash", err)
+		return
+	}
+
+	fmt.Printf("\nTrash (items are purged after %d days)\n", config.TrashRetentionDays())
+	fmt.Println("------------------------------------------")
+
+	fmt.Println("Tickets:")
+	if len(trash.Tickets) == 0 {
+		fmt.Println("  None")
+	}
+	for _, t := range trash.Tickets {
+		fmt.Printf("  ID: %d | Title: %s | Deleted: %s\n", t.ID, t.Title, t.DeletedAt.Time.Format("2006-01-02 15:04"))
+	}
+
+	fmt.Println("Comments:")
+	if len(trash.Comments) == 0 {
+		fmt.Println("  None")
+	}
+	for _, c := range trash.Comments {
+		fmt.Printf("  ID: %d | Ticket: %d | %s | Deleted: %s\n", c.ID, c.TicketID, truncate(c.Content, 30), c.DeletedAt.Time.Format("2006-01-02 15:04"))
+	}
+
+	fmt.Println("Accounts:")
+	if len(trash.Accounts) == 0 {
+		fmt.Println("  None")
+	}
+	for _, a := range trash.Accounts {
+		fmt.Printf("  ID: %d | Name: %s | Deleted: %s\n", a.ID, a.Name, a.DeletedAt.Time.Format("2006-01-02 15:04"))
+	}
+
+	utils.LogInfo(fmt.Sprintf("[Trash] Admin %d viewed trash", claims.UserID))
+}
+
+// handleRestore prompts for an item type and ID and restores it from the trash (admin only).
+func handleRestore() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	itemType, err := utils.PromptSelect("Restore which kind of item?", []string{"ticket", "comment", "account"}, 0)
+	if err != nil {
+		fmt.Println("Restore cancelled.")
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("%s ID to restore: ", capitalize(itemType))
+	idStr, _ := reader.ReadString('\n')
+	id64, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 64)
+	if err != nil {
+		fmt.Println("[Error] Invalid ID.")
+		return
+	}
+	id := uint(id64)
+
+	switch itemType {
+	case "ticket":
+		err = controllers.RestoreTicket(id, "CLI-Local")
+	case "comment":
+		err = controllers.RestoreComment(id, "CLI-Local")
+	case "account":
+		err = controllers.RestoreAccount(id, "CLI-Local")
+	}
+
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+
+	fmt.Printf("%s %d restored.\n", capitalize(itemType), id)
+	utils.LogInfo(fmt.Sprintf("[Trash] Admin %d restored %s %d", claims.UserID, itemType, id))
+}
+
+// handlePurgeTrash permanently removes items that have been in the trash longer
+// than the retention period (admin only).
+func handlePurgeTrash() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	fmt.Printf("Permanently delete items trashed more than %d days ago? Type 'yes' to confirm: ", config.TrashRetentionDays())
+	reader := bufio.NewReader(os.Stdin)
+	input, _ := reader.ReadString('\n')
+	if strings.TrimSpace(strings.ToLower(input)) != "yes" {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	result, err := controllers.PurgeExpiredTrash()
+	if err != nil {
+		fmt.Println("[Error] Purge failed:", err)
+		utils.LogError("[Trash] Purge failed", err)
+		return
+	}
+
+	fmt.Printf("Purged %d tickets, %d comments, %d accounts.\n", result.Tickets, result.Comments, result.Accounts)
+	utils.LogInfo(fmt.Sprintf("[Trash] Admin %d purged expired trash", claims.UserID))
+}
+
+// handleListJobs shows every registered job with its schedule, then the latest runs (admin only).
+func handleListJobs() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	recent, err := jobs.Recent(20)
+	if err != nil {
+		fmt.Println("[Error] Could not load jobs.")
+		utils.LogError("[Jobs] Failed to load jobs", err)
+		return
+	}
+
+	fmt.Println("\nJob Schedules")
+	fmt.Println("------------------------------------------")
+	for _, s := range jobs.Schedules() {
+		next := "-"
+		if s.NextRunAt != nil {
+			next = s.NextRunAt.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-16s | %-14s | Next: %s\n", s.Name, s.Spec, next)
+		if s.Error != "" {
+			fmt.Printf("  [Invalid schedule] %s\n", s.Error)
+		}
+	}
+
+	fmt.Println("\nRecent Runs")
+	fmt.Println("------------------------------------------")
+	if len(recent) == 0 {
+		fmt.Println("  None")
+	}
+	for _, j := range recent {
+		outcome := j.Result
+		if j.LastError != "" {
+			outcome = j.LastError
+		}
+		fmt.Printf("ID: %d | %s | %s | Attempts: %d/%d | By: %s | Run at: %s | %s\n",
+			j.ID, j.Name, j.Status, j.Attempts, j.MaxAttempts, j.TriggeredBy, j.RunAt.Format("2006-01-02 15:04:05"), truncate(outcome, 60))
+	}
+	fmt.Println("\nJobs run on instances started with 'web' or 'serve'.")
+}
+
+// handleRunJob queues a job, chosen from the registered ones, to run now (admin only).
+func handleRunJob() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	var names []string
+	for _, def := range jobs.Definitions() {
+		names = append(names, def.Name)
+	}
+	name, err := utils.PromptSelect("Run which job?", names, 0)
+	if err != nil {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	job, err := jobs.Trigger(name, fmt.Sprintf("user %d", claims.UserID))
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("Queued %s as job %d. A 'web' or 'serve' instance will run it shortly.\n", job.Name, job.ID)
+}
+
+// handleCancelJob cancels a queued job, or asks a running one to stop (admin only).
+func handleCancelJob() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Job ID to cancel: ")
+	idStr, _ := reader.ReadString('\n')
+	id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 64)
+	if err != nil {
+		fmt.Println("[Error] Invalid ID.")
+		return
+	}
+
+	job, err := jobs.Cancel(uint(id), fmt.Sprintf("user %d", claims.UserID))
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	if job.Status == jobs.StatusRunning {
+		fmt.Printf("Job %d (%s) will stop at its next check-in.\n", job.ID, job.Name)
+		return
+	}
+	fmt.Printf("Job %d (%s) cancelled.\n", job.ID, job.Name)
+}
+
+// handleRetentionRun previews (dryRun) or performs the retention purge (admin only).
+func handleRetentionRun(dryRun bool) {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	if !dryRun {
+		fmt.Print("Permanently delete records past their retention period? Type 'yes' to confirm: ")
+		reader := bufio.NewReader(os.Stdin)
+		input, _ := reader.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(input)) != "yes" {
+			fmt.Println("Cancelled.")
+			return
+		}
+	}
+
+	report, err := controllers.RunRetentionPurge(dryRun)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+
+	controllers.PrintRetentionReport(report)
+	utils.LogInfo(fmt.Sprintf("[Retention] Admin %d ran retention (dry run: %t)", claims.UserID, dryRun))
+}
+
+// handleSetRetention prompts for a scope and retention periods in days (admin only).
+func handleSetRetention() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	scope, err := utils.PromptSelect("Apply policy to", []string{"global", "account"}, 0)
+	if err != nil {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	var accountID *uint
+	if scope == "account" {
+		fmt.Print("Account ID: ")
+		idStr, _ := reader.ReadString('\n')
+		id64, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 64)
+		if err != nil {
+			fmt.Println("[Error] Invalid Account ID.")
+			return
+		}
+		id := uint(id64)
+		accountID = &id
+	}
+
+	fmt.Println("Enter retention periods in days (0 keeps records forever).")
+	days := make([]int, 0, 3)
+	for _, label := range []string{"Closed tickets", "Comments", "Logs"} {
+		fmt.Printf("%s: ", label)
+		input, _ := reader.ReadString('\n')
+		value, err := strconv.Atoi(strings.TrimSpace(input))
+		if err != nil {
+			fmt.Println("[Error] Please enter a whole number of days.")
+			return
+		}
+		days = append(days, value)
+	}
+
+	if err := controllers.SetRetentionPolicy(accountID, days[0], days[1], days[2]); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Println("Retention policy saved.")
+}
+
+// handleLegalHold places or lifts a legal hold on an account or a single ticket (admin only).
+func handleLegalHold() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	target, err := utils.PromptSelect("Legal hold target", []string{"account", "ticket"}, 0)
+	if err != nil {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("%s ID: ", capitalize(target))
+	idStr, _ := reader.ReadString('\n')
+	id64, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 64)
+	if err != nil {
+		fmt.Println("[Error] Invalid ID.")
+		return
+	}
+
+	action, err := utils.PromptSelect("Action", []string{"place hold", "lift hold"}, 0)
+	if err != nil {
+		fmt.Println("Cancelled.")
+		return
+	}
+	hold := action == "place hold"
+
+	if target == "account" {
+		err = controllers.SetAccountLegalHold(uint(id64), hold)
+	} else {
+		err = controllers.SetTicketLegalHold(uint(id64), hold)
+	}
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+
+	fmt.Printf("Legal hold on %s %d %s.\n", target, id64, map[bool]string{true: "placed", false: "lifted"}[hold])
+	utils.LogInfo(fmt.Sprintf("[Retention] Admin %d changed legal hold on %s %d", claims.UserID, target, id64))
+}
+
+// handleListDirectories shows every LDAP directory and the outcome of its last sync (admin only).
+func handleListDirectories() {
+	directories, err := controllers.ListDirectories()
+	if err != nil {
+		fmt.Println("[Error] Failed to load directories.")
+		return
+	}
+	if len(directories) == 0 {
+		fmt.Println("No directories configured.")
+		return
+	}
+
+	fmt.Println("\nLDAP Directories")
+	fmt.Println("------------------------------")
+	for _, d := range directories {
+		lastSync := "never"
+		if d.LastSyncAt != nil {
+			lastSync = d.LastSyncAt.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("Account %d (%s): %s\n", d.AccountID, d.Account.Name, d.URL)
+		fmt.Printf("  Base DN   : %s\n", d.BaseDN)
+		fmt.Printf("  Filter    : %s %s\n", d.UserFilter, d.GroupFilter)
+		fmt.Printf("  Attributes: id=%s email=%s name=%s\n", d.IDAttribute, d.EmailAttribute, d.NameAttribute)
+		fmt.Printf("  Last sync : %s\n", lastSync)
+		if d.LastSyncError != "" {
+			fmt.Printf("  Last error: %s\n", d.LastSyncError)
+		}
+	}
+}
+
+// handleSaveDirectory prompts for an account's LDAP connection and user selection (admin only).
+// Blank answers keep the defaults shown in brackets.
+func handleSaveDirectory() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	ask := func(label, fallback string) string {
+		if fallback != "" {
+			fmt.Printf("%s [%s]: ", label, fallback)
+		} else {
+			fmt.Printf("%s: ", label)
+		}
+		input, _ := reader.ReadString('\n')
+		if input = strings.TrimSpace(input); input != "" {
+			return input
+		}
+		return fallback
+	}
+
+	id64, err := strconv.ParseUint(ask("Account ID", ""), 10, 64)
+	if err != nil {
+		fmt.Println("[Error] Invalid Account ID.")
+		return
+	}
+
+	d := models.Directory{AccountID: uint(id64)}
+	d.URL = ask("Directory URL", "ldap://localhost:389")
+	if strings.HasPrefix(d.URL, "ldap://") {
+		d.StartTLS = strings.EqualFold(ask("Use StartTLS (y/n)", "n"), "y")
+	}
+	d.SkipTLSVerify = strings.EqualFold(ask("Skip TLS certificate verification (y/n)", "n"), "y")
+	d.BindDN = ask("Service bind DN", "")
+	fmt.Print("Service bind password (blank keeps the saved one): ")
+	if pw, err := term.ReadPassword(int(os.Stdin.Fd())); err == nil {
+		d.BindPassword = string(pw)
+	}
+	fmt.Println()
+	d.BaseDN = ask("User base DN (OU)", "")
+	d.UserFilter = ask("User filter", "(objectClass=person)")
+	d.GroupFilter = ask("Group filter, blank for all users", "")
+	d.IDAttribute = ask("ID attribute (objectGUID for Active Directory)", "entryUUID")
+	d.EmailAttribute = ask("Email attribute", "mail")
+	d.NameAttribute = ask("Name attribute", "cn")
+
+	if err := controllers.SaveDirectory(d); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Println("Directory saved. Run 'sync-directory' to import its users.")
+	utils.LogInfo(fmt.Sprintf("[Directory] Admin %d saved the directory for account %d", claims.UserID, d.AccountID))
+}
+
+// handleSyncDirectory syncs one account's users from its directory and prints the result (admin only).
+func handleSyncDirectory() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Account ID: ")
+	input, _ := reader.ReadString('\n')
+	id64, err := strconv.ParseUint(strings.TrimSpace(input), 10, 64)
+	if err != nil {
+		fmt.Println("[Error] Invalid Account ID.")
+		return
+	}
+
+	report, err := controllers.SyncDirectory(uint(id64))
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	controllers.PrintDirectorySyncReport(report)
+	utils.LogInfo(fmt.Sprintf("[Directory] Admin %d synced the directory for account %d", claims.UserID, id64))
+}
+
+// handleDeleteDirectory disconnects an account from its directory after confirmation (admin only).
+func handleDeleteDirectory() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Account ID: ")
+	input, _ := reader.ReadString('\n')
+	id64, err := strconv.ParseUint(strings.TrimSpace(input), 10, 64)
+	if err != nil {
+		fmt.Println("[Error] Invalid Account ID.")
+		return
+	}
+
+	fmt.Print("Directory users will keep their accounts but have no password until reset. Type 'yes' to confirm: ")
+	confirm, _ := reader.ReadString('\n')
+	if strings.TrimSpace(confirm) != "yes" {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	if err := controllers.DeleteDirectory(uint(id64)); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Println("Directory removed.")
+	utils.LogInfo(fmt.Sprintf("[Directory] Admin %d removed the directory for account %d", claims.UserID, id64))
+}
+
+// promptUserID reads a user ID from stdin, returning false if the input isn't a valid ID.
+func promptUserID(label string) (uint, bool) {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print(label)
+	input, _ := reader.ReadString('\n')
+	id64, err := strconv.ParseUint(strings.TrimSpace(input), 10, 64)
+	if err != nil {
+		fmt.Println("[Error] Invalid user ID.")
+		return 0, false
+	}
+	return uint(id64), true
+}
+
+// handleExportUserData writes everything stored about a user to user_<id>_export.zip (admin only).
+func handleExportUserData() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	userID, ok := promptUserID("User ID to export: ")
+	if !ok {
+		return
+	}
+
+	data, err := controllers.ExportUserData(userID)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+
+	filename := fmt.Sprintf("user_%d_export.zip", userID)
+	if err := os.WriteFile(filename, data, 0600); err != nil {
+		fmt.Println("[Error] Unable to write export file:", err)
+		utils.LogError("[Privacy] Failed to write export file", err)
+		return
+	}
+
+	fmt.Printf("Exported user %d to %s\n", userID, filename)
+	utils.LogInfo(fmt.Sprintf("[Privacy] Admin %d exported data for user %d", claims.UserID, userID))
+}
+
+// handleAnonymizeUser erases a user's personal data after confirmation (admin only).
+func handleAnonymizeUser() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	userID, ok := promptUserID("User ID to erase: ")
+	if !ok {
+		return
+	}
+
+	fmt.Printf("Permanently erase personal data for user %d? Tickets are kept. Type 'yes' to confirm: ", userID)
+	reader := bufio.NewReader(os.Stdin)
+	input, _ := reader.ReadString('\n')
+	if strings.TrimSpace(strings.ToLower(input)) != "yes" {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	if err := controllers.AnonymizeUser(userID, claims.UserID); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+
+	fmt.Printf("Personal data for user %d erased.\n", userID)
+}
+
+// handleListRoles prints every role with its permissions (requires roles.manage).
+func handleListRoles() {
+	for _, r := range rbac.ListRoles() {
+		kind := "custom"
+		if r.Builtin {
+			kind = "built-in"
+		}
+		names := make([]string, 0, len(r.Permissions))
+		for _, p := range r.Permissions {
+			names = append(names, string(p))
+		}
+		if r.PasswordLoginDisabled {
+			kind += ", SSO only"
+		}
+		fmt.Printf("\n%s (%s) - %s\n  %s\n", r.Name, kind, r.Description, strings.Join(names, ", "))
+		if r.AllowedNetworks != "" {
+			fmt.Printf("  Sign-in allowed from: %s\n", r.AllowedNetworks)
+		}
+	}
+}
+
+// handleSaveRole creates or updates a custom role from a comma-separated permission list.
+func handleSaveRole() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Role name: ")
+	name, _ := reader.ReadString('\n')
+	fmt.Print("Description: ")
+	description, _ := reader.ReadString('\n')
+
+	fmt.Println("Available permissions:")
+	for _, p := range rbac.AllPermissions {
+		fmt.Printf("  %-22s %s\n", p.Name, p.Description)
+	}
+	fmt.Print("Permissions (comma-separated): ")
+	list, _ := reader.ReadString('\n')
+
+	if err := rbac.SaveRole(name, description, rbac.ParsePermissions(list), claims.UserID); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Println("Role saved.")
+}
+
+// handleDeleteRole deletes a custom role that no user holds.
+func handleDeleteRole() {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Role name to delete: ")
+	name, _ := reader.ReadString('\n')
+
+	if err := rbac.DeleteRole(strings.TrimSpace(name)); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Println("Role deleted.")
+}
+
+// handleSetRole changes the role of a user by ID.
+func handleSetRole() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	userID, ok := promptUserID("User ID: ")
+	if !ok {
+		return
+	}
+	role, err := utils.PromptSelect("Select Role", rbac.RoleNames(), 0)
+	if err != nil {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	if err := rbac.SetUserRole(userID, role, claims.UserID); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("User %d is now %s.\n", userID, role)
+}
+
+// handleSetPasswordLogin allows or blocks password sign-in for a role (requires roles.manage).
+func handleSetPasswordLogin() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	role, err := utils.PromptSelect("Select Role", rbac.RoleNames(), 0)
+	if err != nil {
+		fmt.Println("Cancelled.")
+		return
+	}
+	choice, err := utils.PromptSelect("Password login", []string{"Allowed", "Disabled (SSO only)"}, 0)
+	if err != nil {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	allowed := choice == "Allowed"
+	if err := rbac.SetPasswordLogin(role, allowed, claims.UserID); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("Password login for %s set to %s.\n", role, strings.ToLower(choice))
+}
+
+// handleSetRoleNetworks limits the networks members of a role may sign in from (requires
+// roles.manage). An empty list removes the limit.
+func handleSetRoleNetworks() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	role, err := utils.PromptSelect("Select Role", rbac.RoleNames(), 0)
+	if err != nil {
+		fmt.Println("Cancelled.")
+		return
+	}
+	fmt.Printf("Current allowlist: %s\n", networksOrAny(rbac.AllowedNetworks(role)))
+	fmt.Print("Allowed IPs and CIDR ranges (comma-separated, blank for any): ")
+	list, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if err := rbac.SetAllowedNetworks(role, list, claims.UserID); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("Sign-in for %s is now allowed from: %s\n", role, networksOrAny(rbac.AllowedNetworks(role)))
+}
+
+// handleSetAccountNetworks limits the networks an account's users may sign in from (requires
+// accounts.manage). An empty list removes the limit.
+func handleSetAccountNetworks() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Account ID: ")
+	idStr, _ := reader.ReadString('\n')
+	id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 64)
+	if err != nil {
+		fmt.Println("[Error] Invalid Account ID.")
+		return
+	}
+	var account models.Account
+	if err := config.DB.First(&account, uint(id)).Error; err != nil {
+		fmt.Println("[Error] Account not found.")
+		return
+	}
+
+	fmt.Printf("Current allowlist for %s: %s\n", account.Name, networksOrAny(account.AllowedNetworks))
+	fmt.Print("Allowed IPs and CIDR ranges (comma-separated, blank for any): ")
+	list, _ := reader.ReadString('\n')
+
+	if err := controllers.SetAccountAllowedNetworks(&account, list, claims.UserID); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("Sign-in for %s users is now allowed from: %s\n", account.Name, networksOrAny(account.AllowedNetworks))
+}
+
+// networksOrAny describes an allowlist for display.
+func networksOrAny(list string) string {
+	if list == "" {
+		return "any network"
+	}
+	return list
+}
+
+// handleKnownLogins lists the networks the current user has signed in from.
+func handleKnownLogins() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		fmt.Println("[Error] Please log in first.")
+		return
+	}
+
+	known, err := controllers.KnownLogins(claims.UserID)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	if len(known) == 0 {
+		fmt.Println("No known logins yet. Logins from the CLI are not tracked.")
+		return
+	}
+	fmt.Printf("\n%-20s %-16s %-17s %-17s %s\n", "Network", "Last IP", "First Seen", "Last Seen", "Client")
+	for _, k := range known {
+		fmt.Printf("%-20s %-16s %-17s %-17s %s\n", k.Network, k.IP, k.CreatedAt.Format("2006-01-02 15:04"),
+			k.LastSeenAt.Format("2006-01-02 15:04"), k.UserAgent)
+	}
+}
+
+// handleEnrollMFA enrolls the current user in TOTP two-step verification.
+func handleEnrollMFA() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		fmt.Println("[Error] Please log in first.")
+		return
+	}
+	enrollMFA(claims.UserID)
+}
+
+// handleDisableMFA turns off two-step verification for the current user after checking a code.
+func handleDisableMFA() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		fmt.Println("[Error] Please log in first.")
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Authentication code (or recovery code): ")
+	code, _ := reader.ReadString('\n')
+
+	if err := controllers.DisableMFA(claims.UserID, code); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Println("Two-step verification turned off.")
+}
+
+// handleResetMFA clears a user's MFA secret and recovery codes (requires users.manage).
+func handleResetMFA() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	userID, ok := promptUserID("User ID to reset MFA for: ")
+	if !ok {
+		return
+	}
+	if err := controllers.ResetMFA(claims.UserID, userID); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("MFA reset for user %d. They can enroll again at their next login.\n", userID)
+}
+
+// handleRequireMFA makes MFA required or optional for a user (requires users.manage).
+func handleRequireMFA() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	userID, ok := promptUserID("User ID: ")
+	if !ok {
+		return
+	}
+	choice, err := utils.PromptSelect("MFA for this user", []string{"required", "optional"}, 0)
+	if err != nil {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	if err := controllers.SetMFARequired(claims.UserID, userID, choice == "required"); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("MFA is now %s for user %d.\n", choice, userID)
+}
+
+// handleLinkSSO links a user to an identity provider subject (requires users.manage). Users who
+// are not clients are never linked by email, so their subject, shown in the refused login's log
+// line, is linked here or by the user from their Known Sign-ins page.
+func handleLinkSSO() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	userID, ok := promptUserID("User ID: ")
+	if !ok {
+		return
+	}
+	fmt.Print("Identity provider subject (the \"sub\" claim): ")
+	subject, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	if err := controllers.LinkSSOIdentity(userID, subject, fmt.Sprintf("admin %d via CLI", claims.UserID)); err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("User %d is linked to single sign-on.\n", userID)
+}
+
+// handleImpersonate starts a time-limited session as another user (requires users.impersonate).
+// The admin's own session is set aside and restored by stop-impersonating.
+func handleImpersonate() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	userID, ok := promptUserID("User ID to view as: ")
+	if !ok {
+		return
+	}
+	token, target, err := controllers.StartImpersonation(claims, userID, "CLI")
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	if err := utils.StartImpersonationSession(token); err != nil {
+		fmt.Println("[Error] Failed to save session:", err)
+		return
+	}
+
+	fmt.Printf("Now viewing as %s (%s) until %s. Run 'stop-impersonating' to return.\n",
+		target.Email, target.Role, time.Now().Add(controllers.ImpersonationTTL()).Format("15:04"))
+}
+
+// handleStopImpersonating ends an impersonation session and restores the admin's own session.
+func handleStopImpersonating() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		if _, err := utils.EndImpersonationSession(); err == nil {
+			fmt.Println("Impersonation expired. Returned to your own session.")
+		}
+		return
+	}
+	if !claims.Impersonating() {
+		fmt.Println("You are not viewing as another user.")
+		return
+	}
+
+	controllers.StopImpersonation(claims, "CLI")
+	if _, err := utils.EndImpersonationSession(); err != nil {
+		fmt.Println("Your own session has expired. Please log in again.")
+		return
+	}
+	fmt.Printf("Stopped viewing as %s.\n", claims.Email)
+}
+
+// PrintImpersonationBanner reminds an admin before each prompt that they are acting as
+// another user.
+func PrintImpersonationBanner() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil || !claims.Impersonating() {
+		return
+	}
+	fmt.Printf("*** Viewing as %s (%s) until %s. Run 'stop-impersonating' to return. ***\n",
+		claims.Email, claims.Role, claims.ExpiresAt.Time.Format("15:04"))
+}
+
+// auditImpersonatedCommand records every command run while impersonating, with both the
+// real and effective user IDs.
+func auditImpersonatedCommand(cmd string) {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil || !claims.Impersonating() {
+		return
+	}
+	controllers.AuditImpersonatedAction(claims, "CLI command "+cmd, "CLI")
+}
+
+// handleRotateKeys makes a new token signing key current (requires system.manage) and lists
+// the keys that still verify tokens.
+func handleRotateKeys() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	fmt.Println("Signing keys:", keyring.Source())
+	choice, err := utils.PromptSelect("Rotate signing keys now?", []string{"No", "Yes"}, 0)
+	if err != nil || choice != "Yes" {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	next, removed, err := controllers.RotateSigningKeys(claims.UserID)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("New %s signing key %s is now current.\n", next.Algorithm, next.ID)
+	if len(removed) > 0 {
+		fmt.Println("Removed expired keys:", strings.Join(removed, ", "))
+	}
+
+	keys, err := keyring.Keys()
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("\n%-18s %-6s %-20s %s\n", "Key ID", "Alg", "Created", "Status")
+	for _, k := range keys {
+		status := "current"
+		if k.RetiredAt != nil {
+			status = "verifies until " + k.RetiredAt.Add(config.LoadKeySettings().Overlap).Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-18s %-6s %-20s %s\n", k.ID, k.Algorithm, k.CreatedAt.Local().Format("2006-01-02 15:04"), status)
+	}
+}
+
+// handleListColleagues prints the users in the current user's client account (requires account.users).
+func handleListColleagues() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	users, err := controllers.ListColleagues(claims.UserID)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Println("\nAccount Users")
+	fmt.Println("---------------")
+	for _, u := range users {
+		fmt.Printf("ID: %d | Email: %s | Name: %s | Role: %s\n", u.ID, u.Email, u.Name, u.Role)
+	}
+}
+
+// handleAddColleague creates a client user in the current user's client account (requires account.users).
+func handleAddColleague() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Email: ")
+	email, _ := reader.ReadString('\n')
+	fmt.Print("Full Name: ")
+	name, _ := reader.ReadString('\n')
+	fmt.Println(pwpolicy.Requirements())
+	password, err := promptPasswordTwice("Password")
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+
+	user, err := controllers.AddColleague(claims.UserID, email, name, password)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("Added %s to your account.\n", user.Email)
+}
+
+// handleAccountReport prints ticket counts and overdue tickets for the current user's client
+// account (requires account.reports).
+func handleAccountReport() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	report, err := controllers.BuildAccountReport(claims.UserID, claims.Role)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+
+	title := "All Accounts"
+	if report.AccountName != "" {
+		title = report.AccountName
+	}
+	fmt.Printf("\nTicket Report: %s\n", title)
+	fmt.Println("--------------------------")
+	for _, r := range report.Statuses {
+		fmt.Printf("Status   %-20s : %d\n", r.Value, r.Count)
+	}
+	for _, r := range report.Priorities {
+		fmt.Printf("Priority %-20s : %d\n", r.Value, r.Count)
+	}
+
+	fmt.Println("\nOverdue Tickets")
+	if len(report.Overdue) == 0 {
+		fmt.Println("All open tickets are within SLA.")
+	}
+	for _, t := range report.Overdue {
+		fmt.Printf("ID: %d | Priority: %-8s | Status: %-20s | Title: %s\n", t.ID, t.Priority, t.Status, t.Title)
+	}
+	utils.LogInfo(fmt.Sprintf("[Report] Account report generated for user %d", claims.UserID))
+}
+
+// handleReencryptFields makes a new field encryption data key current and rewrites every
+// encrypted field with it (requires system.manage).
+func handleReencryptFields() {
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		return
+	}
+
+	fmt.Println("This rewrites every ticket description, comment, and account note.")
+	choice, err := utils.PromptSelect("Re-encrypt fields now?", []string{"No", "Yes"}, 0)
+	if err != nil || choice != "Yes" {
+		fmt.Println("Cancelled.")
+		return
+	}
+
+	report, err := controllers.ReencryptFields(claims.UserID)
+	if err != nil {
+		fmt.Println("[Error]", err)
+		return
+	}
+	fmt.Printf("Data key %s is now current (%d older keys rewrapped under the current master key).\n", report.DataKeyID, report.Rewrapped)
+	fmt.Printf("Re-encrypted %d tickets, %d comments, %d accounts, and %d directories.\n",
+		report.Rows["tickets"], report.Rows["comments"], report.Rows["accounts"], report.Rows["directories"])
+	fmt.Println("Restart any running web server so it encrypts new data with the new key.")
+}
@@ -2,37 +2,38 @@ package handlers
 
 import (
 	"RyanForce/config"
+	"RyanForce/internal/testutil"
 	"RyanForce/models"
 	"RyanForce/utils"
 	"fmt"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestMain(m *testing.M) {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	if err != nil {
-		panic("failed to connect to in-memory test database")
-	}
-	config.DB = db
+	os.Setenv("RYANFORCE_JWT_SECRET", "test-secret-test-secret-test-secret")
+	testutil.OpenDB()
+	os.Exit(m.Run())
+}
 
-	err = config.DB.AutoMigrate(&models.User{}, &models.Ticket{}, &models.Comment{}, &models.Account{})
-	if err != nil {
-		panic("failed to migrate test database schema")
+// mockUser creates a user with the role, since sessions of users who do not exist are revoked.
+func mockUser(t *testing.T, role string) models.User {
+	t.Helper()
+	user := models.User{Email: role + "@mock.test", Role: role}
+	if err := config.DB.Where("email = ?", user.Email).FirstOrCreate(&user).Error; err != nil {
+		t.Fatalf("Failed to create mock user: %v", err)
 	}
-
-	code := m.Run()
-	os.Exit(code)
+	return user
 }
 
-func mockToken(role string) (string, error) {
-	return utils.GenerateJWT(1, "test@example.com", role)
+func mockToken(t *testing.T, role string) (string, error) {
+	user := mockUser(t, role)
+	return utils.GenerateJWT(user.ID, user.Email, role)
 }
 
 func setupMockSession(t *testing.T, role string) {
-	token, err := mockToken(role)
+	token, err := mockToken(t, role)
 	if err != nil {
 		t.Fatalf("Failed to generate mock token: %v", err)
 	}
@@ -114,15 +115,20 @@ func TestSessionExpiredBehavior(t *testing.T) {
 }
 
 func TestHandleViewTicket(t *testing.T) {
+	testutil.ResetDB(t)
 	setupMockSession(t, "admin")
 	defer utils.ClearSession()
 
+	client := models.User{Email: "client@viewticket.test", Role: "client"}
+	if err := config.DB.Create(&client).Error; err != nil {
+		t.Fatalf("Failed to create test client: %v", err)
+	}
 	ticket := models.Ticket{
 		Title:       "Test Ticket",
 		Description: "Test ticket description.",
 		Priority:    "medium",
 		Status:      "open",
-		ClientID:    1,
+		ClientID:    client.ID,
 	}
 	if err := config.DB.Create(&ticket).Error; err != nil {
 		t.Fatalf("Failed to create test ticket: %v", err)
@@ -150,3 +156,24 @@ func TestHandleViewTicket(t *testing.T) {
 		handleViewTicket()
 	})
 }
+
+func TestImpersonationBlocksCommands(t *testing.T) {
+	admin, client := mockUser(t, "admin"), mockUser(t, "client")
+	token, err := utils.GenerateImpersonationJWT(client.ID, client.Email, client.Role, admin.ID, admin.Email, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate impersonation token: %v", err)
+	}
+	if err := utils.SaveSession(token); err != nil {
+		t.Fatalf("Failed to save impersonation session: %v", err)
+	}
+	defer utils.ClearSession()
+
+	for _, cmd := range []string{"enroll-mfa", "disable-mfa", "reset-password"} {
+		if authorizeCommand(cmd) {
+			t.Errorf("%s was allowed while impersonating", cmd)
+		}
+	}
+	if !authorizeCommand("list-tickets") {
+		t.Error("list-tickets was refused while impersonating")
+	}
+}
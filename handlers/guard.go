@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"fmt"
+)
+
+// commandPermissions maps each CLI command (and its aliases) to the permission it requires.
+// Commands not listed here (login, logout, whoami, help, reset-password, and the ticket commands
+// that are scoped per ticket) are open to every logged-in user.
+var commandPermissions = map[string]rbac.Permission{
+	"register": rbac.UsersManage, "r": rbac.UsersManage, "signup": rbac.UsersManage,
+	"admin-reset-password": rbac.UsersManage, "arp": rbac.UsersManage, "admin-reset": rbac.UsersManage,
+	"list-users": rbac.UsersView, "lu": rbac.UsersView,
+	"delete-user": rbac.UsersManage, "du": rbac.UsersManage,
+	"set-role":           rbac.RolesManage,
+	"set-password-login": rbac.RolesManage,
+	"set-role-networks":  rbac.RolesManage,
+	"list-roles":         rbac.RolesManage,
+	"save-role":          rbac.RolesManage,
+	"delete-role":        rbac.RolesManage,
+	"reset-mfa":          rbac.UsersManage,
+	"require-mfa":        rbac.UsersManage,
+	"link-sso":           rbac.UsersManage,
+
+	"list-colleagues": rbac.AccountUsers,
+	"add-colleague":   rbac.AccountUsers,
+	"account-report":  rbac.AccountReports,
+
+	"create-account":       rbac.AccountsManage,
+	"assign-account":       rbac.AccountsManage,
+	"list-accounts":        rbac.AccountsView,
+	"delete-account":       rbac.AccountsManage,
+	"set-account-networks": rbac.AccountsManage,
+
+	"create-ticket": rbac.TicketsCreate, "ct": rbac.TicketsCreate, "new": rbac.TicketsCreate,
+	"assign-ticket": rbac.TicketsAssign, "at": rbac.TicketsAssign, "assign": rbac.TicketsAssign,
+	"delete-ticket": rbac.TicketsDelete, "dt": rbac.TicketsDelete, "remove": rbac.TicketsDelete,
+	"comment-ticket": rbac.CommentsCreate, "ctc": rbac.CommentsCreate,
+
+	"view-logs": rbac.LogsView, "logs": rbac.LogsView, "tail": rbac.LogsView,
+	"failed-logins":       rbac.LogsView,
+	"report-status":       rbac.ReportsView,
+	"report-priority":     rbac.ReportsView,
+	"report-unassigned":   rbac.ReportsView,
+	"report-resolve-time": rbac.ReportsView,
+	"report-overdue":      rbac.ReportsView,
+	"report-all":          rbac.ReportsView,
+	"export-tickets":      rbac.ReportsView,
+
+	"seed-demo":   rbac.SystemManage,
+	"clear-db":    rbac.SystemManage,
+	"rotate-keys": rbac.SystemManage,
+
+	"reencrypt-fields": rbac.SystemManage,
+
+	"trash": rbac.TrashManage, "list-trash": rbac.TrashManage,
+	"restore":     rbac.TrashManage,
+	"purge-trash": rbac.TrashManage,
+
+	"list-jobs":  rbac.JobsManage,
+	"run-job":    rbac.JobsManage,
+	"cancel-job": rbac.JobsManage,
+
+	"retention-report": rbac.RetentionManage,
+	"retention-purge":  rbac.RetentionManage,
+	"set-retention":    rbac.RetentionManage,
+	"legal-hold":       rbac.RetentionManage,
+	"list-directories": rbac.DirectoryManage,
+	"save-directory":   rbac.DirectoryManage,
+	"sync-directory":   rbac.DirectoryManage,
+	"delete-directory": rbac.DirectoryManage,
+
+	"impersonate": rbac.UsersImpersonate, "view-as": rbac.UsersImpersonate,
+
+	"export-user":    rbac.PrivacyManage,
+	"anonymize-user": rbac.PrivacyManage, "erase-user": rbac.PrivacyManage,
+}
+
+// impersonationBlocked lists the commands an admin may not run while viewing as another user,
+// the CLI counterpart of middleware.BlockWhileImpersonating.
+var impersonationBlocked = map[string]bool{
+	"reset-password": true, "rp": true, "reset": true,
+	"admin-reset-password": true, "arp": true, "admin-reset": true,
+	"enroll-mfa": true, "disable-mfa": true, "reset-mfa": true, "require-mfa": true,
+}
+
+// authorizeCommand is the CLI counterpart of middleware.RequirePermission. It reports whether
+// the current session may run cmd, printing and logging the reason when it may not.
+func authorizeCommand(cmd string) bool {
+	perm, guarded := commandPermissions[cmd]
+	if !guarded && !impersonationBlocked[cmd] {
+		return true
+	}
+
+	claims, err := utils.LoadClaims()
+	if err != nil || claims == nil {
+		if !guarded {
+			return true
+		}
+		fmt.Println("[Error] Please log in first.")
+		return false
+	}
+
+	if impersonationBlocked[cmd] && claims.Impersonating() {
+		fmt.Printf("[Error] '%s' is not available while viewing as another user. Run 'stop-impersonating' first.\n", cmd)
+		utils.LogWarning(fmt.Sprintf("[Impersonation] Admin %d blocked from CLI command %s while viewing as user %d", claims.ImpersonatorID, cmd, claims.UserID))
+		return false
+	}
+	if !guarded {
+		return true
+	}
+
+	if !rbac.Can(claims.Role, perm) {
+		fmt.Printf("[Error] Your role (%s) does not allow '%s'.\n", claims.Role, cmd)
+		utils.LogWarning(fmt.Sprintf("[RBAC] User %d (%s) denied CLI command %s", claims.UserID, claims.Role, cmd))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+// Package testutil holds the in-memory database the package tests share, so every package sets
+// it up and empties it the same way.
+package testutil
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// testModels lists every table the tests use. Add new models here rather than in a package's TestMain.
+var testModels = []interface{}{
+	&models.User{}, &models.Account{}, &models.Directory{}, &models.LoginEvent{},
+	&models.RoleLoginPolicy{}, &models.Role{}, &models.PasswordHistory{}, &models.Ticket{},
+	&models.Comment{}, &models.DataKey{}, &models.KnownLogin{}, &models.MFARecoveryCode{},
+	&models.Job{}, &models.JobSchedule{}, &models.OutboxEvent{}, &models.RetentionPolicy{},
+}
+
+// OpenDB makes config.DB an in-memory SQLite database with every test table. Call it from
+// TestMain; it panics if the database cannot be set up.
+func OpenDB() {
+	db, err := config.OpenSQLite(":memory:")
+	if err != nil {
+		panic("failed to connect to in-memory test database")
+	}
+	// Every connection to :memory: is a separate database, and async subscribers and the job
+	// runner query from their own goroutines, so all queries must share one
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	config.DB = db
+
+	if err := config.DB.AutoMigrate(testModels...); err != nil {
+		panic("failed to migrate test database schema")
+	}
+}
+
+// ResetDB empties every table, so a test starts from the same state however many times the tests
+// run (go test -count=N).
+func ResetDB(t testing.TB) {
+	t.Helper()
+	// Rows reference each other in cycles, so empty the tables with the checks off; the one
+	// connection makes the pragma apply to every statement below
+	config.DB.Exec("PRAGMA foreign_keys = OFF")
+	defer config.DB.Exec("PRAGMA foreign_keys = ON")
+	for _, model := range testModels {
+		if err := config.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(model).Error; err != nil {
+			t.Fatalf("failed to empty %T: %v", model, err)
+		}
+	}
+}
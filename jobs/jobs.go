@@ -0,0 +1,212 @@
+// Package jobs runs background work from a queue kept in the database. Jobs are registered by
+// name, queued on demand or by a schedule, and claimed by worker pools in any server instance
+// sharing the database. Failed runs are retried with exponential backoff, and a job never runs
+// twice at once unless its definition allows it.
+package jobs
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// TriggeredBySchedule marks jobs queued by their schedule rather than by a person.
+const TriggeredBySchedule = "schedule"
+
+var (
+	ErrUnknownJob  = errors.New("no job is registered with that name")
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job has already finished")
+)
+
+// Definition describes a kind of job.
+type Definition struct {
+	Name        string
+	Description string
+	Schedule    string        // Cron expression or @every interval; empty or "off" for on-demand only
+	MaxAttempts int           // Runs before the job is marked failed; defaults to 3
+	Timeout     time.Duration // Per-attempt limit; defaults to 30 minutes
+	Concurrent  bool          // Allow more than one run of this job at a time
+
+	// Run does the work and returns a short summary for the job list. It should stop when ctx
+	// is cancelled, which happens on timeout, cancellation, or shutdown.
+	Run func(ctx context.Context, job *models.Job) (string, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Definition{}
+	wake       = make(chan struct{}, 1) // Nudges local workers when a job is queued
+)
+
+// Register adds a job definition, replacing any earlier one with the same name.
+func Register(def Definition) {
+	if def.MaxAttempts <= 0 {
+		def.MaxAttempts = 3
+	}
+	if def.Timeout <= 0 {
+		def.Timeout = 30 * time.Minute
+	}
+	registryMu.Lock()
+	registry[def.Name] = def
+	registryMu.Unlock()
+}
+
+// Lookup returns the definition registered under name.
+func Lookup(name string) (Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok := registry[name]
+	return def, ok
+}
+
+// Definitions returns every registered definition, sorted by name.
+func Definitions() []Definition {
+	registryMu.RLock()
+	defs := make([]Definition, 0, len(registry))
+	for _, def := range registry {
+		defs = append(defs, def)
+	}
+	registryMu.RUnlock()
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// Enqueue queues a run of the named job to start at runAt, or as soon as a worker is free if
+// runAt is zero. by records who asked for it.
+func Enqueue(name, payload, by string, runAt time.Time) (*models.Job, error) {
+	def, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	job := newJob(def, payload, by, runAt)
+	if err := config.DB.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	if by != TriggeredBySchedule {
+		utils.LogAudit(fmt.Sprintf("[Jobs] %s queued job %d (%s)", by, job.ID, job.Name))
+	}
+	notify()
+	return &job, nil
+}
+
+// Trigger queues the named job to run now.
+func Trigger(name, by string) (*models.Job, error) {
+	return Enqueue(name, "", by, time.Time{})
+}
+
+// Cancel stops a job. A queued job is cancelled straight away; a running job is asked to stop
+// and is marked cancelled by its worker at the next heartbeat.
+func Cancel(id uint, by string) (*models.Job, error) {
+	var job models.Job
+	if err := config.DB.First(&job, id).Error; err != nil {
+		return nil, ErrJobNotFound
+	}
+	query := config.DB.Model(&models.Job{}).Where("id = ? AND status = ?", id, job.Status)
+	switch job.Status {
+	case StatusQueued:
+		query = query.Updates(map[string]interface{}{
+			"status": StatusCancelled, "finished_at": time.Now(), "last_error": "cancelled by " + by,
+		})
+	case StatusRunning:
+		query = query.Update("cancel_requested", true)
+	default:
+		return &job, ErrJobFinished
+	}
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return &job, ErrJobFinished // Finished or started between the read and the update
+	}
+	utils.LogAudit(fmt.Sprintf("[Jobs] %s cancelled job %d (%s, was %s)", by, job.ID, job.Name, job.Status))
+	config.DB.First(&job, id)
+	return &job, nil
+}
+
+// Recent returns the latest jobs, newest first.
+func Recent(limit int) ([]models.Job, error) {
+	var list []models.Job
+	err := config.DB.Order("id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// ScheduleInfo describes a registered job and its schedule for the admin views.
+type ScheduleInfo struct {
+	Name        string
+	Description string
+	Spec        string
+	Error       string // Why the schedule cannot be used, if it is invalid
+	NextRunAt   *time.Time
+	LastRunAt   *time.Time
+	LastJob     *models.Job
+}
+
+// Schedules lists every registered job with its schedule state, sorted by name.
+func Schedules() []ScheduleInfo {
+	var rows []models.JobSchedule
+	config.DB.Find(&rows)
+	byName := make(map[string]models.JobSchedule, len(rows))
+	for _, row := range rows {
+		byName[row.Name] = row
+	}
+
+	var infos []ScheduleInfo
+	for _, def := range Definitions() {
+		info := ScheduleInfo{Name: def.Name, Description: def.Description, Spec: effectiveSpec(def)}
+		if info.Spec != "off" {
+			if _, err := ParseSchedule(info.Spec); err != nil {
+				info.Error = err.Error()
+			}
+		}
+		if row, ok := byName[def.Name]; ok {
+			if row.Spec == info.Spec {
+				info.NextRunAt = row.NextRunAt
+			}
+			info.LastRunAt = row.LastRunAt
+			if row.LastJobID != 0 {
+				var last models.Job
+				if config.DB.First(&last, row.LastJobID).Error == nil {
+					info.LastJob = &last
+				}
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// effectiveSpec applies the RYANFORCE_JOB_SCHEDULE_<NAME> override, treating an empty schedule as "off".
+func effectiveSpec(def Definition) string {
+	spec := config.JobSchedule(def.Name, def.Schedule)
+	if spec == "" {
+		return "off"
+	}
+	return spec
+}
+
+// notify wakes a local worker without blocking if one is already due to wake.
+func notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
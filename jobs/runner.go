@@ -0,0 +1,387 @@
+package jobs
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Runner queues scheduled jobs and works through the queue. Every instance sharing the database
+// can run one: schedules are advanced with a compare-and-swap so each run is queued once, and a
+// job is claimed with a conditional update so only one runner gets it.
+type Runner struct {
+	ID       string // Identifies this instance in the jobs it holds
+	settings config.JobSettings
+	slots    chan struct{} // One token per worker
+	wg       sync.WaitGroup
+	invalid  map[string]string // Schedules already reported as invalid, by job name
+}
+
+// NewRunner creates a runner with a unique ID made of the host name, process ID, and a random suffix.
+func NewRunner(settings config.JobSettings) *Runner {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	workers := settings.Workers
+	if workers < 0 {
+		workers = 0
+	}
+	return &Runner{
+		ID:       fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix)),
+		settings: settings,
+		slots:    make(chan struct{}, workers),
+		invalid:  map[string]string{},
+	}
+}
+
+// Start polls in the background until ctx is cancelled. Jobs still running then are stopped and
+// queued again for another instance, without counting the interrupted attempt.
+func (r *Runner) Start(ctx context.Context) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.settings.PollInterval)
+		defer ticker.Stop()
+		for {
+			r.tick(ctx, time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+	utils.LogInfo(fmt.Sprintf("[Jobs] Runner %s started with %d worker(s)", r.ID, cap(r.slots)))
+}
+
+// Wait blocks until the polling loop and every running job have stopped.
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// tick does one round of scheduling and claims as many queued jobs as there are free workers.
+func (r *Runner) tick(ctx context.Context, now time.Time) {
+	if ctx.Err() != nil {
+		return
+	}
+	r.syncSchedules(now)
+	r.enqueueDue(now)
+	r.reclaimExpired(now)
+
+	for {
+		select {
+		case r.slots <- struct{}{}:
+		default:
+			return // Every worker is busy
+		}
+		job, def := r.claim(now)
+		if job == nil {
+			<-r.slots
+			return
+		}
+		r.wg.Add(1)
+		go func() {
+			defer func() { <-r.slots; r.wg.Done() }()
+			r.run(ctx, job, def)
+		}()
+	}
+}
+
+// syncSchedules records each registered schedule, restarting it from now when its spec changes.
+func (r *Runner) syncSchedules(now time.Time) {
+	var rows []models.JobSchedule
+	if err := config.DB.Find(&rows).Error; err != nil {
+		utils.LogError("[Jobs] Failed to load schedules", err)
+		return
+	}
+	byName := make(map[string]models.JobSchedule, len(rows))
+	for _, row := range rows {
+		byName[row.Name] = row
+	}
+
+	for _, def := range Definitions() {
+		spec := effectiveSpec(def)
+		row, exists := byName[def.Name]
+		if exists && row.Spec == spec {
+			continue
+		}
+		next := r.firstRun(def.Name, spec, now)
+		if !exists {
+			row = models.JobSchedule{Name: def.Name, Spec: spec, NextRunAt: next, Version: 1}
+			// Another instance may have recorded it first, which is just as good
+			config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+			continue
+		}
+		result := config.DB.Model(&models.JobSchedule{}).
+			Where("name = ? AND version = ?", row.Name, row.Version).
+			Updates(map[string]interface{}{"spec": spec, "next_run_at": next, "version": row.Version + 1})
+		if result.Error == nil && result.RowsAffected == 1 {
+			utils.LogInfo(fmt.Sprintf("[Jobs] Schedule for %s changed from %q to %q", def.Name, row.Spec, spec))
+		}
+	}
+}
+
+// firstRun returns when a schedule first runs after now, or nil if it is off or invalid.
+func (r *Runner) firstRun(name, spec string, now time.Time) *time.Time {
+	if spec == "off" {
+		return nil
+	}
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		if r.invalid[name] != spec {
+			r.invalid[name] = spec
+			utils.LogError(fmt.Sprintf("[Jobs] Schedule for %s is invalid and will not run", name), err)
+		}
+		return nil
+	}
+	next := schedule.Next(now)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// enqueueDue queues a run of every schedule that has come due. The schedule is advanced first, and
+// only the instance whose update lands queues the run. A run is skipped, not queued, while the
+// previous one is still waiting or running.
+func (r *Runner) enqueueDue(now time.Time) {
+	var due []models.JobSchedule
+	if err := config.DB.Where("next_run_at <= ?", now).Find(&due).Error; err != nil {
+		utils.LogError("[Jobs] Failed to load due schedules", err)
+		return
+	}
+
+	for _, row := range due {
+		def, ok := Lookup(row.Name)
+		if !ok || effectiveSpec(def) != row.Spec {
+			continue // Registered by a different version of the app, or about to be resynced
+		}
+		next := r.firstRun(row.Name, row.Spec, now)
+
+		var queued *models.Job
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.JobSchedule{}).
+				Where("name = ? AND version = ?", row.Name, row.Version).
+				Updates(map[string]interface{}{"next_run_at": next, "last_run_at": now, "version": row.Version + 1})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error // Another instance got there first
+			}
+			if !def.Concurrent {
+				var pending int64
+				tx.Model(&models.Job{}).Where("name = ? AND status IN ?", def.Name, []string{StatusQueued, StatusRunning}).Count(&pending)
+				if pending > 0 {
+					utils.LogInfo(fmt.Sprintf("[Jobs] Skipped scheduled %s: the previous run has not finished", def.Name))
+					return nil
+				}
+			}
+			job := newJob(def, "", TriggeredBySchedule, now)
+			if err := tx.Create(&job).Error; err != nil {
+				return err
+			}
+			queued = &job
+			return tx.Model(&models.JobSchedule{}).Where("name = ?", row.Name).Update("last_job_id", job.ID).Error
+		})
+		if err != nil {
+			utils.LogError(fmt.Sprintf("[Jobs] Failed to queue scheduled %s", row.Name), err)
+		} else if queued != nil {
+			utils.LogInfo(fmt.Sprintf("[Jobs] Queued scheduled job %d (%s)", queued.ID, queued.Name))
+		}
+	}
+}
+
+// reclaimExpired retries jobs whose runner stopped renewing the lease, most likely because its
+// instance crashed, or fails them if they are out of attempts.
+func (r *Runner) reclaimExpired(now time.Time) {
+	var expired []models.Job
+	if err := config.DB.Where("status = ? AND locked_until < ?", StatusRunning, now).Find(&expired).Error; err != nil {
+		utils.LogError("[Jobs] Failed to look for abandoned jobs", err)
+		return
+	}
+	for _, job := range expired {
+		reason := fmt.Sprintf("lease expired: runner %s stopped responding", job.LockedBy)
+		updates := r.afterFailure(&job, reason, now)
+		updates["locked_by"] = ""
+		updates["locked_until"] = nil
+		result := config.DB.Model(&models.Job{}).
+			Where("id = ? AND status = ? AND locked_by = ? AND locked_until < ?", job.ID, StatusRunning, job.LockedBy, now).
+			Updates(updates)
+		if result.Error == nil && result.RowsAffected == 1 {
+			utils.LogWarning(fmt.Sprintf("[Jobs] Job %d (%s) abandoned by %s is now %s", job.ID, job.Name, job.LockedBy, updates["status"]))
+		}
+	}
+}
+
+// claim takes the oldest due job this instance knows how to run. Unless the job allows concurrent
+// runs, the claim only succeeds while no other run of the same job holds the lock.
+func (r *Runner) claim(now time.Time) (*models.Job, Definition) {
+	var candidates []models.Job
+	if err := config.DB.Where("status = ? AND run_at <= ?", StatusQueued, now).
+		Order("run_at, id").Limit(20).Find(&candidates).Error; err != nil {
+		utils.LogError("[Jobs] Failed to look for queued jobs", err)
+		return nil, Definition{}
+	}
+
+	for _, job := range candidates {
+		def, ok := Lookup(job.Name)
+		if !ok {
+			continue
+		}
+		until := now.Add(r.settings.Lease)
+		query := config.DB.Model(&models.Job{}).Where("id = ? AND status = ?", job.ID, StatusQueued)
+		if !def.Concurrent {
+			query = query.Where("NOT EXISTS (SELECT 1 FROM jobs AS other WHERE other.name = jobs.name AND other.status = ?)", StatusRunning)
+		}
+		result := query.Updates(map[string]interface{}{
+			"status":       StatusRunning,
+			"locked_by":    r.ID,
+			"locked_until": until,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"started_at":   now,
+		})
+		if result.Error != nil {
+			utils.LogError(fmt.Sprintf("[Jobs] Failed to claim job %d", job.ID), result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue // Claimed elsewhere, cancelled, or another run of the job holds the lock
+		}
+		config.DB.First(&job, job.ID)
+		return &job, def
+	}
+	return nil, Definition{}
+}
+
+// run executes a claimed job, renewing its lease until it returns, and records the outcome.
+func (r *Runner) run(ctx context.Context, job *models.Job, def Definition) {
+	utils.LogInfo(fmt.Sprintf("[Jobs] Running job %d (%s), attempt %d of %d", job.ID, job.Name, job.Attempts, job.MaxAttempts))
+	jobCtx, cancel := context.WithTimeout(ctx, def.Timeout)
+	defer cancel()
+
+	var cancelled atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		interval := r.settings.Lease / 3
+		if interval <= 0 {
+			interval = time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			result := config.DB.Model(&models.Job{}).Where("id = ? AND locked_by = ? AND status = ?", job.ID, r.ID, StatusRunning).
+				Update("locked_until", time.Now().Add(r.settings.Lease))
+			if result.Error == nil && result.RowsAffected == 0 {
+				cancel() // The lease was lost, so another runner may retry the job
+				return
+			}
+			var current models.Job
+			if config.DB.Select("cancel_requested").First(&current, job.ID).Error == nil && current.CancelRequested {
+				cancelled.Store(true)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	result, err := runSafely(jobCtx, def, job)
+	close(done)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		err = fmt.Errorf("timed out after %s", def.Timeout)
+	}
+
+	now := time.Now()
+	var updates map[string]interface{}
+	switch {
+	case err == nil:
+		updates = map[string]interface{}{"status": StatusSucceeded, "result": result, "last_error": "", "finished_at": now}
+	case cancelled.Load():
+		updates = map[string]interface{}{"status": StatusCancelled, "last_error": "cancelled while running", "finished_at": now}
+	case ctx.Err() != nil:
+		// Shutting down: put the job back for another instance without using up an attempt
+		updates = map[string]interface{}{"status": StatusQueued, "attempts": gorm.Expr("attempts - 1"), "run_at": now}
+	default:
+		updates = r.afterFailure(job, err.Error(), now)
+	}
+	updates["locked_by"] = ""
+	updates["locked_until"] = nil
+
+	saved := config.DB.Model(&models.Job{}).Where("id = ? AND locked_by = ? AND status = ?", job.ID, r.ID, StatusRunning).Updates(updates)
+	if saved.Error != nil {
+		utils.LogError(fmt.Sprintf("[Jobs] Failed to record the outcome of job %d", job.ID), saved.Error)
+		return
+	}
+	if saved.RowsAffected == 0 {
+		utils.LogWarning(fmt.Sprintf("[Jobs] Job %d (%s) lost its lease before finishing; outcome discarded", job.ID, job.Name))
+		return
+	}
+	switch updates["status"] {
+	case StatusSucceeded:
+		utils.LogInfo(fmt.Sprintf("[Jobs] Job %d (%s) succeeded: %s", job.ID, job.Name, result))
+	case StatusFailed:
+		utils.LogError(fmt.Sprintf("[Jobs] Job %d (%s) failed after %d attempt(s)", job.ID, job.Name, job.Attempts), err)
+	case StatusCancelled:
+		utils.LogInfo(fmt.Sprintf("[Jobs] Job %d (%s) cancelled while running", job.ID, job.Name))
+	case StatusQueued:
+		if ctx.Err() != nil {
+			utils.LogInfo(fmt.Sprintf("[Jobs] Job %d (%s) interrupted by shutdown and queued again", job.ID, job.Name))
+		} else {
+			utils.LogError(fmt.Sprintf("[Jobs] Job %d (%s) attempt %d failed; retrying at %s",
+				job.ID, job.Name, job.Attempts, updates["run_at"].(time.Time).Format(time.RFC3339)), err)
+		}
+	}
+}
+
+// runSafely calls the job, turning a panic into an error so one bad job cannot stop the runner.
+func runSafely(ctx context.Context, def Definition, job *models.Job) (result string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return def.Run(ctx, job)
+}
+
+// afterFailure returns the updates for a failed attempt: queued again after a backoff that doubles
+// with each attempt, or failed once the attempts are used up.
+func (r *Runner) afterFailure(job *models.Job, reason string, now time.Time) map[string]interface{} {
+	if job.Attempts >= job.MaxAttempts {
+		return map[string]interface{}{"status": StatusFailed, "last_error": reason, "finished_at": now}
+	}
+	delay := r.settings.RetryBase
+	for i := 1; i < job.Attempts && delay < r.settings.RetryMax; i++ {
+		delay *= 2
+	}
+	if delay > r.settings.RetryMax {
+		delay = r.settings.RetryMax
+	}
+	return map[string]interface{}{"status": StatusQueued, "last_error": reason, "run_at": now.Add(delay)}
+}
+
+// newJob builds a queued job for def.
+func newJob(def Definition, payload, by string, runAt time.Time) models.Job {
+	return models.Job{
+		Name:        def.Name,
+		Payload:     payload,
+		Status:      StatusQueued,
+		MaxAttempts: def.MaxAttempts,
+		RunAt:       runAt,
+		TriggeredBy: by,
+	}
+}
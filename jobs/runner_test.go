@@ -0,0 +1,189 @@
+package jobs
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	testutil.OpenDB()
+	os.Exit(m.Run())
+}
+
+// testRunner returns a runner with fast leases and retries.
+func testRunner() *Runner {
+	return NewRunner(config.JobSettings{
+		Workers:      2,
+		PollInterval: time.Hour,
+		Lease:        60 * time.Millisecond,
+		RetryBase:    time.Minute,
+		RetryMax:     4 * time.Minute,
+	})
+}
+
+// waitForStatus polls until the job reaches status.
+func waitForStatus(t *testing.T, id uint, status string) models.Job {
+	t.Helper()
+	var job models.Job
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		config.DB.First(&job, id)
+		if job.Status == status {
+			return job
+		}
+	}
+	t.Fatalf("job %d is %s, want %s (last error %q)", id, job.Status, status, job.LastError)
+	return job
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	testutil.ResetDB(t)
+	var calls atomic.Int32
+	Register(Definition{Name: "test-flaky", MaxAttempts: 2, Run: func(ctx context.Context, job *models.Job) (string, error) {
+		if calls.Add(1) == 1 {
+			return "", errors.New("upstream unavailable")
+		}
+		return "done", nil
+	}})
+	Register(Definition{Name: "test-panics", MaxAttempts: 1, Run: func(ctx context.Context, job *models.Job) (string, error) {
+		panic("boom")
+	}})
+	r := testRunner()
+	ctx := context.Background()
+
+	flaky, _ := Trigger("test-flaky", "user 1")
+	now := time.Now()
+	r.tick(ctx, now)
+	job := waitForStatus(t, flaky.ID, StatusQueued)
+	if job.Attempts != 1 || job.LastError != "upstream unavailable" || job.RunAt.Sub(now) < time.Minute {
+		t.Fatalf("failed attempt not rescheduled with backoff: %+v", job)
+	}
+
+	r.tick(ctx, now.Add(30*time.Second))
+	r.Wait()
+	if calls.Load() != 1 {
+		t.Fatal("retry ran before its backoff")
+	}
+	r.tick(ctx, now.Add(2*time.Minute))
+	if job = waitForStatus(t, flaky.ID, StatusSucceeded); job.Attempts != 2 || job.Result != "done" {
+		t.Fatalf("retry: %+v", job)
+	}
+
+	panics, _ := Trigger("test-panics", "user 1")
+	r.tick(ctx, time.Now())
+	if job = waitForStatus(t, panics.ID, StatusFailed); job.LastError != "panic: boom" {
+		t.Fatalf("panicking job: %+v", job)
+	}
+	r.Wait()
+}
+
+func TestSingletonAndCancel(t *testing.T) {
+	testutil.ResetDB(t)
+	release := make(chan struct{})
+	var running atomic.Int32
+	Register(Definition{Name: "test-slow", Run: func(ctx context.Context, job *models.Job) (string, error) {
+		running.Add(1)
+		defer running.Add(-1)
+		select {
+		case <-release:
+			return "released", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}})
+	first, second := testRunner(), testRunner()
+	ctx := context.Background()
+
+	a, _ := Trigger("test-slow", "user 1")
+	b, _ := Trigger("test-slow", "user 1")
+	first.tick(ctx, time.Now())
+	second.tick(ctx, time.Now())
+	waitForStatus(t, a.ID, StatusRunning)
+	time.Sleep(50 * time.Millisecond)
+	if got := running.Load(); got != 1 {
+		t.Fatalf("%d runs of a singleton job at once", got)
+	}
+	waitForStatus(t, b.ID, StatusQueued)
+
+	// A queued job is cancelled at once; a running one at its next heartbeat
+	if _, err := Cancel(b.ID, "user 1"); err != nil {
+		t.Fatalf("cancelling a queued job: %v", err)
+	}
+	waitForStatus(t, b.ID, StatusCancelled)
+	if _, err := Cancel(a.ID, "user 1"); err != nil {
+		t.Fatalf("cancelling a running job: %v", err)
+	}
+	waitForStatus(t, a.ID, StatusCancelled)
+	if _, err := Cancel(a.ID, "user 1"); !errors.Is(err, ErrJobFinished) {
+		t.Fatalf("cancelling a finished job: got %v", err)
+	}
+
+	c, _ := Trigger("test-slow", "user 1")
+	second.tick(ctx, time.Now())
+	waitForStatus(t, c.ID, StatusRunning)
+	close(release)
+	waitForStatus(t, c.ID, StatusSucceeded)
+	first.Wait()
+	second.Wait()
+}
+
+func TestScheduledRunQueuedOnce(t *testing.T) {
+	testutil.ResetDB(t)
+	Register(Definition{Name: "test-scheduled", Schedule: "@every 1h", Run: func(ctx context.Context, job *models.Job) (string, error) {
+		return "", nil
+	}})
+	first, second := testRunner(), testRunner()
+	now := time.Now()
+	first.syncSchedules(now)
+	second.syncSchedules(now)
+
+	var schedule models.JobSchedule
+	config.DB.First(&schedule, "name = ?", "test-scheduled")
+	if schedule.NextRunAt == nil || schedule.NextRunAt.Sub(now.Add(time.Hour)).Abs() > time.Second {
+		t.Fatalf("schedule not recorded: %+v", schedule)
+	}
+
+	later := now.Add(61 * time.Minute)
+	first.enqueueDue(later)
+	second.enqueueDue(later)
+	var queued []models.Job
+	config.DB.Where("name = ?", "test-scheduled").Find(&queued)
+	if len(queued) != 1 || queued[0].TriggeredBy != TriggeredBySchedule {
+		t.Fatalf("due schedule queued %d jobs", len(queued))
+	}
+	config.DB.First(&schedule, "name = ?", "test-scheduled")
+	if schedule.LastJobID != queued[0].ID || !schedule.NextRunAt.After(later) {
+		t.Fatalf("schedule not advanced: %+v", schedule)
+	}
+
+	// Still queued when the schedule comes round again, so the run is skipped
+	first.enqueueDue(later.Add(2 * time.Hour))
+	var count int64
+	config.DB.Model(&models.Job{}).Where("name = ?", "test-scheduled").Count(&count)
+	if count != 1 {
+		t.Fatalf("overlapping scheduled run queued: %d jobs", count)
+	}
+}
+
+func TestAbandonedJobReclaimed(t *testing.T) {
+	testutil.ResetDB(t)
+	Register(Definition{Name: "test-abandoned", MaxAttempts: 2, Run: func(ctx context.Context, job *models.Job) (string, error) {
+		return "", nil
+	}})
+	past := time.Now().Add(-time.Minute)
+	crashed := models.Job{Name: "test-abandoned", Status: StatusRunning, Attempts: 1, MaxAttempts: 2, RunAt: past, LockedBy: "gone", LockedUntil: &past}
+	config.DB.Create(&crashed)
+
+	r := testRunner()
+	r.reclaimExpired(time.Now())
+	job := waitForStatus(t, crashed.ID, StatusQueued)
+	if job.LockedBy != "" || job.LastError == "" {
+		t.Fatalf("abandoned job not released: %+v", job)
+	}
+}
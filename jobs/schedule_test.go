@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	// Monday 2026-03-02 10:07
+	from := time.Date(2026, 3, 2, 10, 7, 30, 0, time.Local)
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 2, 10, 15, 0, 0, time.Local)},
+		{"0 7 * * 1", time.Date(2026, 3, 9, 7, 0, 0, 0, time.Local)},
+		{"30 9-17/4 * * *", time.Date(2026, 3, 2, 13, 30, 0, 0, time.Local)},
+		{"0 0 1,15 * *", time.Date(2026, 3, 15, 0, 0, 0, 0, time.Local)},
+		{"0 12 * * 7", time.Date(2026, 3, 8, 12, 0, 0, 0, time.Local)}, // 7 is Sunday too
+		{"0 0 13 * 5", time.Date(2026, 3, 6, 0, 0, 0, 0, time.Local)},  // Either day field matches
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.Local)}, // Next leap day
+		{"@hourly", time.Date(2026, 3, 2, 11, 0, 0, 0, time.Local)},
+		{"@monthly", time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local)},
+		{"@every 90m", time.Date(2026, 3, 2, 11, 37, 30, 0, time.Local)},
+		{"0 0 30 2 *", time.Time{}}, // Never
+	}
+	for _, tc := range cases {
+		schedule, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", tc.spec, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tc.want) {
+			t.Errorf("%q: next = %s, want %s", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "@every soon", "@every 10ms", "@yearly"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) accepted an invalid schedule", spec)
+		}
+	}
+}
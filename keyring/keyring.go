@@ -0,0 +1,377 @@
+// Package keyring manages the keys that sign and verify RyanForce tokens. Keys come from a JSON
+// key file, created on first use and rewritten by Rotate, or from RYANFORCE_JWT_SECRET. Every
+// token names its key in the kid header, so a key that has been rotated out keeps verifying the
+// tokens it signed until its overlap period ends. EdDSA and RS256 public keys are published as a
+// JWKS so other services can verify RyanForce session tokens.
+package keyring
+
+import (
+	"RyanForce/config"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Supported signing algorithms.
+const (
+	HS256 = "HS256"
+	EdDSA = "EdDSA"
+	RS256 = "RS256"
+)
+
+// ErrUnknownKey is returned for tokens whose kid is missing, unknown, or past its overlap period.
+var ErrUnknownKey = errors.New("unknown or expired signing key")
+
+// ErrEnvironmentKeys is returned by Rotate when the keys come from the environment.
+var ErrEnvironmentKeys = errors.New("signing keys come from RYANFORCE_JWT_SECRET; rotate them by setting a new secret " +
+	"and moving the old one to RYANFORCE_JWT_PREVIOUS_SECRETS")
+
+// Key is one signing key as stored in the key file. HS256 keys hold a base64 secret; EdDSA and
+// RS256 keys hold a PEM-encoded PKCS#8 private key. A key is current until it is retired.
+type Key struct {
+	ID         string     `json:"kid"`
+	Algorithm  string     `json:"alg"`
+	Secret     string     `json:"secret,omitempty"`
+	PrivateKey string     `json:"private_key,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+
+	signing   interface{} // []byte, ed25519.PrivateKey, or *rsa.PrivateKey
+	verifying interface{} // []byte, ed25519.PublicKey, or *rsa.PublicKey
+	material  []byte      // Secret bytes that purpose keys are derived from
+}
+
+// keyFile is the layout of the key file.
+type keyFile struct {
+	Keys []*Key `json:"keys"`
+}
+
+// Method returns the JWT signing method of the key.
+func (k *Key) Method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case EdDSA:
+		return jwt.SigningMethodEdDSA
+	case RS256:
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodHS256
+}
+
+// usable reports whether the key may still verify tokens: it is current, or was retired less
+// than overlap ago.
+func (k *Key) usable(now time.Time, overlap time.Duration) bool {
+	return k.RetiredAt == nil || now.Before(k.RetiredAt.Add(overlap))
+}
+
+// Derive returns a 32-byte key for one purpose, such as MFA challenge tokens or CLI session
+// encryption, derived from the key's secret material. Different purposes get unrelated keys, so
+// a token made for one can never be accepted as another.
+func (k *Key) Derive(purpose string) []byte {
+	mac := hmac.New(sha256.New, k.material)
+	mac.Write([]byte("ryanforce:" + purpose))
+	return mac.Sum(nil)
+}
+
+// parse decodes the key's secret or private key.
+func (k *Key) parse() error {
+	switch k.Algorithm {
+	case HS256:
+		secret, err := base64.StdEncoding.DecodeString(k.Secret)
+		if err != nil || len(secret) < 32 {
+			return fmt.Errorf("key %s: secret must be at least 32 base64-encoded bytes", k.ID)
+		}
+		k.signing, k.verifying, k.material = secret, secret, secret
+		return nil
+	case EdDSA, RS256:
+	default:
+		return fmt.Errorf("key %s: unsupported algorithm %q", k.ID, k.Algorithm)
+	}
+
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil {
+		return fmt.Errorf("key %s: private key is not PEM encoded", k.ID)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("key %s: %w", k.ID, err)
+	}
+	switch private := parsed.(type) {
+	case ed25519.PrivateKey:
+		if k.Algorithm != EdDSA {
+			break
+		}
+		k.signing, k.verifying, k.material = private, private.Public(), private.Seed()
+		return nil
+	case *rsa.PrivateKey:
+		if k.Algorithm != RS256 {
+			break
+		}
+		k.signing, k.verifying, k.material = private, &private.PublicKey, private.D.Bytes()
+		return nil
+	}
+	return fmt.Errorf("key %s: private key does not match algorithm %s", k.ID, k.Algorithm)
+}
+
+// Generate creates a new current key for the algorithm.
+func Generate(algorithm string) (*Key, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	k := &Key{ID: hex.EncodeToString(id), Algorithm: algorithm, CreatedAt: time.Now().UTC()}
+
+	var private interface{}
+	switch algorithm {
+	case HS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		k.Secret = base64.StdEncoding.EncodeToString(secret)
+		return k, k.parse()
+	case EdDSA:
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = edKey
+	case RS256:
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		private = rsaKey
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q (use HS256, EdDSA, or RS256)", algorithm)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	k.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	return k, k.parse()
+}
+
+// cache holds the keys last loaded, newest first, and what they were loaded from so changes
+// to the environment or the key file (e.g. rotate-keys run from another process) are picked up.
+var cache struct {
+	sync.Mutex
+	keys    []*Key
+	from    string
+	modTime time.Time
+}
+
+// load returns the configured keys, newest first, reloading them when their source changed.
+func load(settings config.KeySettings) ([]*Key, error) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if settings.Secret != "" {
+		from := "env:" + settings.Secret + "," + strings.Join(settings.PreviousSecrets, ",")
+		if cache.from != from {
+			keys, err := environmentKeys(settings)
+			if err != nil {
+				return nil, err
+			}
+			cache.keys, cache.from, cache.modTime = keys, from, time.Time{}
+		}
+		return cache.keys, nil
+	}
+
+	info, err := os.Stat(settings.File)
+	if errors.Is(err, os.ErrNotExist) {
+		k, err := Generate(settings.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeKeys(settings.File, []*Key{k}); err != nil {
+			return nil, err
+		}
+		if info, err = os.Stat(settings.File); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	from := "file:" + settings.File
+	if cache.from != from || !cache.modTime.Equal(info.ModTime()) {
+		keys, err := readKeys(settings.File)
+		if err != nil {
+			return nil, err
+		}
+		cache.keys, cache.from, cache.modTime = keys, from, info.ModTime()
+	}
+	return cache.keys, nil
+}
+
+// environmentKeys builds HS256 keys from RYANFORCE_JWT_SECRET and RYANFORCE_JWT_PREVIOUS_SECRETS.
+// Their kids are derived from the secrets so every instance sharing them agrees.
+func environmentKeys(settings config.KeySettings) ([]*Key, error) {
+	if len(settings.Secret) < 32 {
+		return nil, errors.New("RYANFORCE_JWT_SECRET must be at least 32 characters")
+	}
+	var keys []*Key
+	for _, secret := range append([]string{settings.Secret}, settings.PreviousSecrets...) {
+		sum := sha256.Sum256([]byte(secret))
+		k := &Key{ID: "env-" + hex.EncodeToString(sum[:6]), Algorithm: HS256, signing: []byte(secret),
+			verifying: []byte(secret), material: []byte(secret)}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// readKeys loads and parses the key file, sorting keys newest first.
+func readKeys(path string) ([]*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	if len(f.Keys) == 0 {
+		return nil, fmt.Errorf("key file %s has no keys", path)
+	}
+	for _, k := range f.Keys {
+		if err := k.parse(); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(f.Keys, func(i, j int) bool { return f.Keys[i].CreatedAt.After(f.Keys[j].CreatedAt) })
+	return f.Keys, nil
+}
+
+// writeKeys saves the key set readable only by the owner, replacing the file atomically.
+func writeKeys(path string, keys []*Key) error {
+	data, err := json.MarshalIndent(keyFile{Keys: keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Current returns the key new tokens are signed with.
+func Current() (*Key, error) {
+	keys, err := load(config.LoadKeySettings())
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.RetiredAt == nil {
+			return k, nil
+		}
+	}
+	return nil, errors.New("no current signing key; run rotate-keys")
+}
+
+// Keys returns every key that may still verify tokens, newest first.
+func Keys() ([]*Key, error) {
+	settings := config.LoadKeySettings()
+	keys, err := load(settings)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var usable []*Key
+	for _, k := range keys {
+		if k.usable(now, settings.Overlap) {
+			usable = append(usable, k)
+		}
+	}
+	return usable, nil
+}
+
+// Lookup returns the key with the kid if it may still verify tokens.
+func Lookup(kid string) (*Key, error) {
+	keys, err := Keys()
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.ID == kid {
+			return k, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// Rotate retires the current key and makes a new one with RYANFORCE_JWT_ALG current. Retired
+// keys keep verifying for RYANFORCE_JWT_KEY_OVERLAP_HOURS so existing sessions stay valid, and
+// keys past that are removed. It returns the new key and the kids of the removed keys.
+func Rotate() (*Key, []string, error) {
+	settings := config.LoadKeySettings()
+	if settings.Secret != "" {
+		return nil, nil, ErrEnvironmentKeys
+	}
+	// Read the file afresh rather than changing the cached keys other goroutines are using
+	if _, err := load(settings); err != nil {
+		return nil, nil, err
+	}
+	keys, err := readKeys(settings.File)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	next, err := Generate(settings.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now().UTC()
+	kept := []*Key{next}
+	var removed []string
+	for _, k := range keys {
+		if k.RetiredAt == nil {
+			retired := now
+			k.RetiredAt = &retired
+		}
+		if k.usable(now, settings.Overlap) {
+			kept = append(kept, k)
+		} else {
+			removed = append(removed, k.ID)
+		}
+	}
+
+	if err := writeKeys(settings.File, kept); err != nil {
+		return nil, nil, err
+	}
+	cache.Lock()
+	cache.from = "" // Reload from the rewritten file
+	cache.Unlock()
+	return next, removed, nil
+}
+
+// Source describes where the keys come from, for display.
+func Source() string {
+	settings := config.LoadKeySettings()
+	if settings.Secret != "" {
+		return "environment (RYANFORCE_JWT_SECRET)"
+	}
+	return settings.File
+}
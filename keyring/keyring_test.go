@@ -0,0 +1,130 @@
+package keyring
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func useKeyFile(t *testing.T, alg string) string {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	t.Setenv("RYANFORCE_JWT_SECRET", "")
+	t.Setenv("RYANFORCE_JWT_KEYS_FILE", path)
+	t.Setenv("RYANFORCE_JWT_ALG", alg)
+	return path
+}
+
+func TestRotationKeepsOldTokensValid(t *testing.T) {
+	for _, alg := range []string{HS256, EdDSA, RS256} {
+		t.Run(alg, func(t *testing.T) {
+			path := useKeyFile(t, alg)
+
+			old, err := Sign(&jwt.RegisteredClaims{Subject: "1"})
+			if err != nil {
+				t.Fatalf("sign failed: %v", err)
+			}
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+				t.Fatalf("key file not created owner-only: %v", err)
+			}
+			first, _ := Current()
+
+			next, removed, err := Rotate()
+			if err != nil || len(removed) != 0 {
+				t.Fatalf("rotate: %v, removed %v", err, removed)
+			}
+			if next.ID == first.ID {
+				t.Fatal("rotation kept the same key current")
+			}
+			if err := Parse(old, &jwt.RegisteredClaims{}); err != nil {
+				t.Fatalf("token from the retired key rejected: %v", err)
+			}
+
+			token, _ := Sign(&jwt.RegisteredClaims{Subject: "2"})
+			parsed, _ := jwt.Parse(token, nil)
+			if parsed == nil || parsed.Header["kid"] != next.ID || parsed.Header["alg"] != alg {
+				t.Fatalf("new token header %v", parsed.Header)
+			}
+
+			// Once the overlap has passed, the next rotation drops the retired key.
+			t.Setenv("RYANFORCE_JWT_KEY_OVERLAP_HOURS", "0")
+			if err := Parse(old, &jwt.RegisteredClaims{}); err == nil {
+				t.Fatal("token from an expired key accepted")
+			}
+			if _, removed, _ = Rotate(); len(removed) != 2 {
+				t.Fatalf("removed %v, want both older keys", removed)
+			}
+		})
+	}
+}
+
+func TestPurposeKeysAreSeparate(t *testing.T) {
+	useKeyFile(t, HS256)
+
+	session, _ := Sign(&jwt.RegisteredClaims{Subject: "1"})
+	challenge, _ := SignPurpose("mfa-challenge", &jwt.RegisteredClaims{Subject: "1"})
+	if err := ParsePurpose("mfa-challenge", challenge, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("challenge rejected: %v", err)
+	}
+	if err := Parse(challenge, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("challenge accepted as a session token")
+	}
+	if err := ParsePurpose("password-change", challenge, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("challenge accepted for another purpose")
+	}
+	if err := ParsePurpose("mfa-challenge", session, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("session token accepted as a challenge")
+	}
+}
+
+func TestJWKSVerifiesTokens(t *testing.T) {
+	useKeyFile(t, RS256)
+
+	token, _ := Sign(&jwt.RegisteredClaims{Subject: "1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))})
+	set, err := JWKS()
+	if err != nil || len(set.Keys) != 1 {
+		t.Fatalf("JWKS: %v, %+v", err, set)
+	}
+
+	// Verify the way another service would, from the published key only.
+	jwk := set.Keys[0]
+	n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+	e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+	public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	_, err = jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if t.Header["kid"] != jwk.Kid {
+			return nil, ErrUnknownKey
+		}
+		return public, nil
+	})
+	if err != nil {
+		t.Fatalf("token did not verify with the published key: %v", err)
+	}
+
+	useKeyFile(t, HS256)
+	if set, _ := JWKS(); len(set.Keys) != 0 {
+		t.Fatal("HS256 secret published")
+	}
+}
+
+func TestEnvironmentKeys(t *testing.T) {
+	t.Setenv("RYANFORCE_JWT_SECRET", "first-secret-first-secret-first-secret")
+	old, _ := Sign(&jwt.RegisteredClaims{Subject: "1"})
+
+	t.Setenv("RYANFORCE_JWT_SECRET", "second-secret-second-secret-second-secret")
+	if err := Parse(old, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("token from a replaced secret accepted")
+	}
+	t.Setenv("RYANFORCE_JWT_PREVIOUS_SECRETS", "first-secret-first-secret-first-secret")
+	if err := Parse(old, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("token from a previous secret rejected: %v", err)
+	}
+	if _, _, err := Rotate(); err != ErrEnvironmentKeys {
+		t.Fatalf("rotate with environment keys: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Sign signs claims with the current key, naming it in the kid header.
+func Sign(claims jwt.Claims) (string, error) {
+	k, err := Current()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(k.Method(), claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.signing)
+}
+
+// Parse verifies a token signed by Sign and decodes it into claims. The kid must name a key
+// that may still verify tokens, and the token's algorithm must be that key's.
+func Parse(tokenStr string, claims jwt.Claims) error {
+	return parse(tokenStr, claims, func(k *Key) interface{} { return k.verifying }, func(k *Key) string { return k.Algorithm })
+}
+
+// SignPurpose signs claims for internal use, such as MFA challenges or the SSO state cookie,
+// with an HS256 key derived from the current key for that purpose.
+func SignPurpose(purpose string, claims jwt.Claims) (string, error) {
+	k, err := Current()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.Derive(purpose))
+}
+
+// ParsePurpose verifies a token made by SignPurpose for the same purpose.
+func ParsePurpose(purpose, tokenStr string, claims jwt.Claims) error {
+	return parse(tokenStr, claims, func(k *Key) interface{} { return k.Derive(purpose) }, func(*Key) string { return HS256 })
+}
+
+// parse verifies tokenStr using the key its kid names.
+func parse(tokenStr string, claims jwt.Claims, verifying func(*Key) interface{}, algorithm func(*Key) string) error {
+	token, err := jwt.ParseWithClaims(strings.TrimSpace(tokenStr), claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		k, err := Lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != algorithm(k) {
+			return nil, errors.New("unexpected signing method")
+		}
+		return verifying(k), nil
+	})
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKSet is a JWKS document.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public halves of every EdDSA and RS256 key that may still verify tokens.
+// HS256 secrets are never published, so the set is empty while HS256 is used.
+func JWKS() (JWKSet, error) {
+	set := JWKSet{Keys: []JWK{}}
+	keys, err := Keys()
+	if err != nil {
+		return set, err
+	}
+	for _, k := range keys {
+		switch public := k.verifying.(type) {
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{Kty: "OKP", Kid: k.ID, Use: "sig", Alg: EdDSA, Crv: "Ed25519",
+				X: base64.RawURLEncoding.EncodeToString(public)})
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{Kty: "RSA", Kid: k.ID, Use: "sig", Alg: RS256,
+				N: base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())})
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"RyanForce/config"
+	"RyanForce/utils"
+	"fmt"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Send delivers a plain-text email through the SMTP server in config.LoadMailSettings.
+// When no server is configured the message is not sent and only its recipient and subject
+// are logged, so development setups never leak reset links into the log.
+func Send(to, subject, body string) error {
+	settings := config.LoadMailSettings()
+	if settings.Host == "" {
+		utils.LogWarning(fmt.Sprintf("[Mail] SMTP not configured; not sending %q to %s", subject, to))
+		return nil
+	}
+
+	from, err := mail.ParseAddress(settings.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	var auth smtp.Auth
+	if settings.Username != "" {
+		auth = smtp.PlainAuth("", settings.Username, settings.Password, settings.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", settings.Host, settings.Port)
+	if err := smtp.SendMail(addr, auth, from.Address, []string{to}, buildMessage(settings.From, to, subject, body)); err != nil {
+		utils.LogError(fmt.Sprintf("[Mail] Failed to send %q to %s", subject, to), err)
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	utils.LogInfo(fmt.Sprintf("[Mail] Sent %q to %s", subject, to))
+	return nil
+}
+
+// buildMessage formats an RFC 5322 message with CRLF line endings.
+func buildMessage(from, to, subject, body string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + sanitizeHeader(from) + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + sanitizeHeader(subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// sanitizeHeader strips line breaks so a value cannot inject extra headers.
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
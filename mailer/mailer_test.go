@@ -0,0 +1,124 @@
+package mailer
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSink is a minimal in-process SMTP server that records the first message it receives.
+type smtpSink struct {
+	listener net.Listener
+	rcpt     chan string
+	data     chan string
+}
+
+func startSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start SMTP sink: %v", err)
+	}
+	s := &smtpSink{listener: l, rcpt: make(chan string, 1), data: make(chan string, 1)}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *smtpSink) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 sink ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 sink")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			s.rcpt <- strings.TrimSpace(line[len("RCPT TO:"):])
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end with .")
+			var body strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				body.WriteString(l)
+			}
+			s.data <- body.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSendDeliversToSMTPServer(t *testing.T) {
+	sink := startSMTPSink(t)
+	host, port, _ := net.SplitHostPort(sink.listener.Addr().String())
+	t.Setenv("RYANFORCE_SMTP_HOST", host)
+	t.Setenv("RYANFORCE_SMTP_PORT", port)
+	t.Setenv("RYANFORCE_SMTP_FROM", "RyanForce <no-reply@example.com>")
+
+	if err := Send("user@example.com", "Reset\r\nBcc: evil@example.com", "line one\nline two"); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	select {
+	case rcpt := <-sink.rcpt:
+		if rcpt != "<user@example.com>" {
+			t.Errorf("Unexpected recipient %q", rcpt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP sink did not receive a recipient")
+	}
+
+	var msg string
+	select {
+	case msg = <-sink.data:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP sink did not receive a message")
+	}
+	if !strings.Contains(msg, "Subject: ResetBcc: evil@example.com\r\n") {
+		t.Errorf("Subject header was not sanitized:\n%s", msg)
+	}
+	if strings.Contains(msg, "\r\nBcc:") {
+		t.Errorf("Header injection reached the message:\n%s", msg)
+	}
+	if !strings.Contains(msg, "line one\r\nline two") {
+		t.Errorf("Body was not delivered with CRLF line endings:\n%s", msg)
+	}
+}
+
+func TestSendWithoutHostDoesNothing(t *testing.T) {
+	t.Setenv("RYANFORCE_SMTP_HOST", "")
+	t.Setenv("RYANFORCE_SMTP_PORT", strconv.Itoa(1))
+
+	if err := Send("user@example.com", "Subject", "Body"); err != nil {
+		t.Fatalf("Send without SMTP host should not fail: %v", err)
+	}
+}
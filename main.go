@@ -4,15 +4,26 @@ import (
 	"RyanForce/config"
 	"RyanForce/controllers"
 	"RyanForce/handlers"
+	"RyanForce/metrics"
+	"RyanForce/pwpolicy"
+	"RyanForce/rbac"
 	"RyanForce/routes"
 	"RyanForce/utils"
+	"RyanForce/web"
 	"html/template"
 	"strconv"
 
 	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,6 +34,22 @@ func main() {
 	utils.InitLogger(false) // Log setup
 
 	config.Connect()
+	if err := metrics.RegisterDBCallbacks(config.DB); err != nil {
+		utils.LogError("[Startup] Failed to register database metrics", err)
+	}
+	if err := controllers.InitFieldEncryption(); err != nil {
+		utils.LogError("[Startup] Failed to load field encryption keys", err)
+		fmt.Println("[Startup] Field encryption:", err)
+		os.Exit(1)
+	}
+	if err := rbac.SeedDefaultRoles(); err != nil {
+		utils.LogError("[Startup] Failed to seed default roles", err)
+	}
+	if err := controllers.MigrateLegacyLocks(); err != nil {
+		utils.LogError("[Startup] Failed to migrate legacy account locks", err)
+	}
+	controllers.RegisterJobs()
+	controllers.RegisterEventSubscribers()
 
 	// Check command-line arguments
 	args := os.Args[1:]
@@ -33,6 +60,8 @@ func main() {
 		switch strings.ToLower(arg) {
 		case "web":
 			mode = "web"
+		case "serve":
+			mode = "serve"
 		case "cli":
 			mode = "cli"
 		case "seed":
@@ -49,17 +78,121 @@ func main() {
 	}
 
 	// If we didn't seed-only, start the selected mode
-	if mode == "web" {
+	switch mode {
+	case "web":
 		startWeb()
-	} else {
+	case "serve":
+		startServer()
+	default:
 		startCLIWithSession()
 	}
 }
 
-// startWeb initializes and runs the Gin-based WebUI server
+// startWeb initializes and runs the Gin-based WebUI server for local development
 func startWeb() {
 	fmt.Println("[Startup] Launching WebUI on http://localhost:8080")
 
+	r := newWebEngine()
+	if err := r.SetTrustedProxies(config.TrustedProxies()); err != nil {
+		utils.LogError("[WebUI] Invalid trusted proxy configuration", err)
+	}
+
+	purgeExpiredTrash()
+	controllers.StartJobRunner(context.Background())
+
+	if err := r.Run(":8080"); err != nil {
+		utils.LogError("[WebUI] Failed to start server", err)
+	}
+}
+
+// purgeExpiredTrash permanently removes anything that has outlived the trash retention period
+// when a server starts; the trash-purge job repeats it while the server runs. One-shot CLI
+// sessions leave it alone.
+func purgeExpiredTrash() {
+	if _, err := controllers.PurgeExpiredTrash(); err != nil {
+		utils.LogError("[Startup] Failed to purge expired trash", err)
+	}
+}
+
+// startServer runs the WebUI and API in production mode: server timeouts, optional TLS,
+// trusted proxies, and a graceful shutdown that drains in-flight requests on SIGTERM/SIGINT.
+func startServer() {
+	settings := config.LoadServerSettings()
+
+	gin.SetMode(gin.ReleaseMode)
+	r := newWebEngine()
+	if err := r.SetTrustedProxies(settings.TrustedProxies); err != nil {
+		fmt.Println("[Error] Invalid RYANFORCE_TRUSTED_PROXIES:", err)
+		utils.LogError("[Server] Invalid trusted proxy configuration", err)
+		os.Exit(1)
+	}
+
+	srv := &http.Server{
+		Addr:              settings.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: settings.ReadHeaderTimeout,
+		ReadTimeout:       settings.ReadTimeout,
+		WriteTimeout:      settings.WriteTimeout,
+		IdleTimeout:       settings.IdleTimeout,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	srv.RegisterOnShutdown(web.CloseLiveStreams) // Event streams never go idle, so end them for the drain
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	purgeExpiredTrash()
+	runner := controllers.StartJobRunner(ctx)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		var err error
+		if settings.TLSEnabled() {
+			fmt.Printf("[Startup] Serving HTTPS on %s\n", settings.Addr)
+			err = srv.ListenAndServeTLS(settings.TLSCertFile, settings.TLSKeyFile)
+		} else {
+			fmt.Printf("[Startup] Serving HTTP on %s\n", settings.Addr)
+			err = srv.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+	utils.LogInfo(fmt.Sprintf("[Server] Listening on %s (TLS: %t)", settings.Addr, settings.TLSEnabled()))
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			fmt.Println("[Error] Server failed:", err)
+			utils.LogError("[Server] Failed to start server", err)
+			os.Exit(1)
+		}
+		return
+	case <-ctx.Done():
+	}
+
+	fmt.Printf("[Shutdown] Signal received, draining requests for up to %s...\n", settings.DrainDelay+settings.ShutdownTimeout)
+	utils.LogInfo("[Server] Shutdown signal received, draining in-flight requests")
+	controllers.SetDraining()
+	// Keep serving while load balancers see /readyz fail and stop sending new requests
+	time.Sleep(settings.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), settings.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		utils.LogError("[Server] Graceful shutdown did not finish in time", err)
+	}
+	runner.Wait() // Running jobs were stopped with ctx and are queued again for the next start
+
+	if sqlDB, err := config.DB.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+	fmt.Println("[Shutdown] Server stopped.")
+	utils.LogInfo("[Server] Server stopped")
+}
+
+// newWebEngine creates the Gin engine with template helpers and all routes registered.
+func newWebEngine() *gin.Engine {
 	// Register template helpers
 	r := gin.Default()
 	r.SetFuncMap(template.FuncMap{
@@ -68,14 +201,15 @@ func startWeb() {
 		"dec":      func(i int) int { return i - 1 },
 		"multiply": func(a, b int) int { return a * b },
 		"itoa":     strconv.Itoa,
+
+		"passwordRules": pwpolicy.Requirements,
+		"ssoProvider":   controllers.SSOProviderName,
 	})
 
 	r.LoadHTMLGlob("web/templates/*.html")
 	routes.SetupRouterWithEngine(r)
-
-	if err := r.Run(":8080"); err != nil {
-		utils.LogError("[WebUI] Failed to start server", err)
-	}
+	web.StartLiveUpdates()
+	return r
 }
 
 // startCLIWithSession handles session checks and displays dashboard in CLI mode
@@ -115,6 +249,7 @@ func startCLI() {
 
 	for {
 		utils.LogInfo("[CLI] Waiting for user input")
+		handlers.PrintImpersonationBanner()
 		fmt.Print("RyanForce > ")
 		input, err := reader.ReadString('\n')
 		if err != nil {
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace prefixes every metric RyanForce exposes on /metrics.
+const namespace = "ryanforce"
+
+var (
+	// HTTPRequests counts handled HTTP requests by method, route template, and status code.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route, and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration records request latency by method and route template.
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency in seconds, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// DBQueryDuration records database statement timings by GORM operation and table.
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database statement latency in seconds, by operation and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "table"})
+
+	// DBErrors counts database statements that returned an error (not-found lookups excluded).
+	DBErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_errors_total",
+		Help:      "Database statements that failed, by operation and table.",
+	}, []string{"operation", "table"})
+
+	// LoginAttempts counts login attempts by outcome and reason.
+	LoginAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_attempts_total",
+		Help:      "Login attempts, by result (success or failure) and failure reason.",
+	}, []string{"result", "reason"})
+
+	// AccountLockouts counts accounts locked after too many failed logins.
+	AccountLockouts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "account_lockouts_total",
+		Help:      "User accounts locked after repeated failed logins.",
+	})
+
+	// DomainEvents counts domain events published after their change committed, by event name.
+	DomainEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "domain_events_total",
+		Help:      "Domain events published, by event name.",
+	}, []string{"event"})
+)
+
+// RecordLogin counts a single login attempt. reason is empty for successful logins.
+func RecordLogin(success bool, reason string) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	LoginAttempts.WithLabelValues(result, reason).Inc()
+}
+
+// MustRegister adds extra collectors (such as the ticket gauges) to the default registry.
+func MustRegister(collectors ...prometheus.Collector) {
+	prometheus.MustRegister(collectors...)
+}
@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"RyanForce/utils"
-	"fmt"
 	"net/http"
 	"strings"
 
@@ -10,7 +9,8 @@ import (
 )
 
 // JWTAuthMiddleware ensures the incoming request has a valid token.
-// If not, it halts the request and returns a 401 error.
+// If not, it halts the request and returns a 401 error. Requests made with an
+// impersonation token are audited.
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenStr := c.GetHeader("Authorization")
@@ -21,7 +21,6 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenStr = strings.TrimPrefix(tokenStr, "Bearer ")
-		utils.LogInfo(fmt.Sprintf("[JWTAuth] Token received: %s", tokenStr))
 
 		claims, err := utils.ParseJWT(tokenStr)
 		if err != nil {
@@ -31,6 +30,10 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 		}
 
 		c.Set("user", claims)
+		if claims.Impersonating() {
+			impersonated(c, claims)
+			return
+		}
 		c.Next()
 	}
 }
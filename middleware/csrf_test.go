@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"RyanForce/utils"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.SetHTMLTemplate(template.Must(template.New("403.html").Parse("forbidden")))
+	r.Use(CSRFMiddleware())
+	r.GET("/form", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8",
+			[]byte(`<form method="POST" action="/submit"></form><form method="get" action="/search"></form>`))
+	})
+	r.GET("/data", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"form": `<form method="post">`}) })
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/submit", ok)
+	r.POST("/api/tickets", ok)
+	r.POST("/scim/v2/Users", ok)
+
+	do := func(req *http.Request, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	post := func(path, token string, cookies ...*http.Cookie) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(url.Values{"csrf_token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return do(req, cookies...).Code
+	}
+
+	// The first page sets the session cookie and puts the token in POST forms only
+	w := do(httptest.NewRequest(http.MethodGet, "/form", nil))
+	var session *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == utils.CSRFCookie {
+			session = cookie
+		}
+	}
+	if session == nil {
+		t.Fatal("no CSRF session cookie set")
+	}
+	match := regexp.MustCompile(`<form method="POST" action="/submit"><input type="hidden" name="csrf_token" value="([^"]+)">`).
+		FindStringSubmatch(w.Body.String())
+	if match == nil || strings.Count(w.Body.String(), "csrf_token") != 1 {
+		t.Fatalf("token not injected into just the POST form:\n%s", w.Body.String())
+	}
+	token := match[1]
+	if body := do(httptest.NewRequest(http.MethodGet, "/data", nil), session).Body.String(); strings.Contains(body, "csrf_token") {
+		t.Fatalf("token injected into a JSON response: %s", body)
+	}
+
+	if code := post("/submit", token, session); code != http.StatusOK {
+		t.Fatalf("POST with the form token = %d", code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.Header.Set("X-CSRF-Token", token)
+	if code := do(req, session).Code; code != http.StatusOK {
+		t.Fatalf("POST with the header token = %d", code)
+	}
+	if code := post("/submit", "", session); code != http.StatusForbidden {
+		t.Fatalf("POST without a token = %d", code)
+	}
+	other := &http.Cookie{Name: utils.CSRFCookie, Value: "another-browser"}
+	if code := post("/submit", token, other); code != http.StatusForbidden {
+		t.Fatalf("POST with another session's token = %d", code)
+	}
+
+	// Bearer-authenticated APIs carry no cookies to forge
+	for _, path := range []string{"/api/tickets", "/scim/v2/Users"} {
+		if code := post(path, ""); code != http.StatusOK {
+			t.Fatalf("POST %s without a token = %d", path, code)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission allows the request through only if the authenticated user's role grants
+// at least one of the given permissions. It must run after WebAuthMiddleware or JWTAuthMiddleware.
+func RequirePermission(perms ...rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("user")
+		claims, ok := value.(*utils.Claims)
+		if !exists || !ok {
+			redirectOrJSON(c, "Authentication required")
+			return
+		}
+
+		if !rbac.CanAny(claims.Role, perms...) {
+			utils.LogWarningIP(fmt.Sprintf("[RBAC] User %d (%s) denied %s %s", claims.UserID, claims.Role, c.Request.Method, c.FullPath()), c.ClientIP())
+			forbidden(c)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// forbidden responds with a 403 page for browsers and a JSON error for API clients.
+func forbidden(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") || !strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+		return
+	}
+	c.HTML(http.StatusForbidden, "403.html", nil)
+	c.Abort()
+}
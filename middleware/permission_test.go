@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionFor creates a user with the role and returns a session token for them.
+func sessionFor(t *testing.T, role string) string {
+	t.Helper()
+	user := models.User{Email: role + "@middleware.test", Role: role}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create %s user: %v", role, err)
+	}
+	token, err := utils.GenerateJWT(user.ID, user.Email, role)
+	if err != nil {
+		t.Fatalf("GenerateJWT failed: %v", err)
+	}
+	return token
+}
+
+func TestAdminRoutesRequirePermissions(t *testing.T) {
+	testutil.ResetDB(t)
+	gin.SetMode(gin.TestMode)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r := gin.New()
+	admin := r.Group("/admin", WebAuthMiddleware())
+	admin.GET("/clients", RequirePermission(rbac.UsersView), ok)
+	admin.POST("/roles/assign", RequirePermission(rbac.RolesManage), ok)
+	api := r.Group("/api", JWTAuthMiddleware())
+	api.DELETE("/users/:id", RequirePermission(rbac.UsersManage), ok)
+
+	web := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	bearer := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	client := sessionFor(t, rbac.RoleClient)
+	tech := sessionFor(t, rbac.RoleTech)
+	adminToken := sessionFor(t, rbac.RoleAdmin)
+
+	if code := web(http.MethodGet, "/admin/clients", client); code != http.StatusForbidden {
+		t.Fatalf("client GET /admin/clients = %d", code)
+	}
+	if code := bearer(http.MethodDelete, "/api/users/1", client); code != http.StatusForbidden {
+		t.Fatalf("client DELETE /api/users/1 = %d", code)
+	}
+	// Technicians can't reach the role editor, so they can't make anyone an admin
+	if code := web(http.MethodPost, "/admin/roles/assign", tech); code != http.StatusForbidden {
+		t.Fatalf("tech POST /admin/roles/assign = %d", code)
+	}
+	if code := web(http.MethodGet, "/admin/clients", ""); code != http.StatusFound {
+		t.Fatalf("anonymous GET /admin/clients = %d", code)
+	}
+
+	for _, check := range []struct {
+		name string
+		code int
+	}{
+		{"GET /admin/clients", web(http.MethodGet, "/admin/clients", adminToken)},
+		{"POST /admin/roles/assign", web(http.MethodPost, "/admin/roles/assign", adminToken)},
+		{"DELETE /api/users/1", bearer(http.MethodDelete, "/api/users/1", adminToken)},
+	} {
+		if check.code != http.StatusOK {
+			t.Fatalf("admin %s = %d", check.name, check.code)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"RyanForce/config"
+	"RyanForce/scim"
+	"RyanForce/utils"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMAuthMiddleware requires the RYANFORCE_SCIM_TOKEN bearer token on /scim/v2 requests.
+// While no token is configured every request gets 404, so SCIM is off by default.
+func SCIMAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := config.SCIMToken()
+		if token == "" {
+			abortSCIM(c, http.StatusNotFound, "SCIM provisioning is not enabled")
+			return
+		}
+
+		given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			utils.LogWarningIP("[SCIM] Rejected request with missing or invalid token", c.ClientIP())
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			abortSCIM(c, http.StatusUnauthorized, "a valid bearer token is required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// abortSCIM ends the request with a SCIM error body.
+func abortSCIM(c *gin.Context, status int, detail string) {
+	body, _ := json.Marshal(scim.NewError(status, "", detail))
+	c.Data(status, scim.ContentType, body)
+	c.Abort()
+}
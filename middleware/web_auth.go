@@ -7,7 +7,8 @@ import (
 )
 
 // WebAuthMiddleware authenticates WebUI users by validating the token cookie.
-// Injects the user's claims into the context for easy access by handlers.
+// Injects the user's claims into the context for easy access by handlers. Requests made
+// while impersonating show a banner and are audited.
 func WebAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token, err := c.Cookie("token")
@@ -26,6 +27,10 @@ func WebAuthMiddleware() gin.HandlerFunc {
 
 		// Set user claims into context
 		c.Set("user", claims)
+		if claims.Impersonating() {
+			impersonated(c, claims)
+			return
+		}
 		c.Next()
 	}
 }
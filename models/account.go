@@ -9,7 +9,11 @@ type Account struct {
 	Name    string `gorm:"uniqueIndex"`
 	Domain  string
 	Address string
-	Notes   string
+	Notes   string `gorm:"serializer:encrypted"` // Encrypted at rest
 
-	Users []User `gorm:"foreignKey:AccountID"` // One-to-many
+	LegalHold bool // Blocks retention purges for every ticket raised by this account
+
+	AllowedNetworks string // Comma-separated IPs and CIDR ranges the account's users may sign in from; empty allows any
+
+	Users []User `gorm:"foreignKey:AccountID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"` // One-to-many
 }
@@ -1,12 +1,18 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Comment struct {
-	ID          uint      `gorm:"primaryKey"`
-	TicketID    uint      `gorm:"not null"`           // Foreign key to the related ticket
-	AuthorID    uint      `gorm:"not null"`           // ID of the user who authored the comment
-	AuthorEmail string    `gorm:"not null"`           // Email of the user who authored the comment
-	Content     string    `gorm:"type:text;not null"` // Body of the comment
-	CreatedAt   time.Time // Timestamp of when the comment was posted
+	ID          uint           `gorm:"primaryKey"`
+	TicketID    uint           `gorm:"not null"`                                // Foreign key to the related ticket
+	AuthorID    uint           `gorm:"not null"`                                // ID of the user who authored the comment
+	AuthorEmail string         `gorm:"not null"`                                // Email of the user who authored the comment
+	Content     string         `gorm:"type:text;not null;serializer:encrypted"` // Body of the comment, encrypted at rest
+	Version     uint           `gorm:"not null;default:1"`                      // Incremented by every edit; edits based on an older version are rejected
+	CreatedAt   time.Time      // Timestamp of when the comment was posted
+	DeletedAt   gorm.DeletedAt `gorm:"index"` // Set when the comment is moved to the trash
 }
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	_ "RyanForce/fieldcrypt" // Registers the "encrypted" serializer used by sensitive fields
+)
+
+// DataKey is a key that encrypts sensitive model fields. It is stored wrapped (encrypted) by a
+// master key from the environment and is never written to the database in the clear.
+type DataKey struct {
+	ID          string `gorm:"primaryKey"` // Named in every value encrypted with the key
+	MasterKeyID string // Master key that wraps it
+	WrappedKey  string // Data key encrypted with the master key
+	CreatedAt   time.Time
+	RetiredAt   *time.Time // Set by re-encryption; retired keys only decrypt
+}
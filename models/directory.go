@@ -0,0 +1,31 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Directory is an LDAP or Active Directory server that authenticates and supplies the client
+// users of one account. Users found under BaseDN that match UserFilter and GroupFilter are
+// synced as clients of the account.
+type Directory struct {
+	gorm.Model
+
+	AccountID     uint    `gorm:"uniqueIndex"`
+	Account       Account `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"` // Purging the account removes its directory
+	URL           string  // ldap://host:389 or ldaps://host:636
+	StartTLS      bool
+	SkipTLSVerify bool
+	BindDN        string // Service account used for searches
+	BindPassword  string `json:"-" gorm:"serializer:encrypted"` // Encrypted at rest; left out of ListDirectories
+
+	BaseDN         string // OU searched for users
+	UserFilter     string // e.g. (objectClass=inetOrgPerson)
+	GroupFilter    string // e.g. (memberOf=cn=ryanforce,ou=groups,dc=acme,dc=com); empty allows all users
+	IDAttribute    string // Stable identifier: entryUUID (OpenLDAP) or objectGUID (AD)
+	EmailAttribute string
+	NameAttribute  string
+
+	LastSyncAt    *time.Time
+	LastSyncError string
+}
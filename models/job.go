@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Job is one run of a background job. Workers on any instance claim a queued job once RunAt has
+// passed, and renew LockedUntil while it runs; a running job whose lease has expired is retried.
+type Job struct {
+	ID              uint   `gorm:"primaryKey"`
+	Name            string `gorm:"index;not null"` // The registered job to run
+	Payload         string `gorm:"type:text"`      // JSON arguments, for jobs that take any
+	Status          string `gorm:"index;not null"` // queued, running, succeeded, failed, or cancelled
+	Attempts        int    // Runs started so far
+	MaxAttempts     int
+	RunAt           time.Time  `gorm:"index"` // Not claimed before this time; pushed back after a failed attempt
+	TriggeredBy     string     // "schedule", or who queued it
+	LockedBy        string     // Instance running the job
+	LockedUntil     *time.Time // Lease expiry, renewed by the running instance
+	CancelRequested bool       // Set to stop a running job at its next heartbeat
+	Result          string     // Summary reported by a successful run
+	LastError       string     `gorm:"type:text"`
+	StartedAt       *time.Time // Start of the latest attempt
+	FinishedAt      *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// JobSchedule records when a scheduled job next runs. Instances queue a due run by advancing
+// NextRunAt and Version together, only if Version is unchanged, so each run is queued once however
+// many instances share the database.
+type JobSchedule struct {
+	Name      string `gorm:"primaryKey"`
+	Spec      string // Cron expression, @every interval, or "off"
+	NextRunAt *time.Time
+	LastRunAt *time.Time
+	LastJobID uint
+	Version   uint `gorm:"not null;default:1"`
+}
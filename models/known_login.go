@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// KnownLogin is a network a user has signed in from successfully. Networks are IPv4 /24 and
+// IPv6 /64 blocks; IP and UserAgent are from the most recent login in the block. A login from a
+// network with no row raises a new-network alert.
+type KnownLogin struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"uniqueIndex:idx_known_login_network"`
+	Network    string `gorm:"uniqueIndex:idx_known_login_network"`
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time // First login from the network
+	LastSeenAt time.Time
+}
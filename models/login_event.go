@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// LoginEvent records a single login attempt from the CLI, WebUI, or API.
+// It backs the login history included in personal data exports.
+type LoginEvent struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    *uint  `gorm:"index"` // Nil when the email didn't match any user
+	Email     string `gorm:"index"` // Email exactly as it was submitted
+	IP        string `gorm:"index"`
+	Success   bool
+	Reason    string // Why the attempt failed, empty on success
+	CreatedAt time.Time
+}
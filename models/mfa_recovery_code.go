@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// MFARecoveryCode is a single-use code that can stand in for a TOTP code when a user
+// has lost their authenticator. Only the bcrypt hash of the code is stored.
+type MFARecoveryCode struct {
+	ID        uint `gorm:"primaryKey"`
+	UserID    uint `gorm:"index"`
+	CodeHash  string
+	UsedAt    *time.Time // Nil until the code is redeemed
+	CreatedAt time.Time
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// OutboxEvent is a domain event saved in the same transaction as the change it describes, so it
+// is delivered to durable subscribers even if the process stops straight after the commit.
+type OutboxEvent struct {
+	ID            uint       `gorm:"primaryKey"`
+	Event         string     `gorm:"index;not null"` // Event name, e.g. "ticket.assigned"
+	Payload       string     `gorm:"type:text"`      // The event as JSON
+	OccurredAt    time.Time  // When the change was committed
+	ProcessedAt   *time.Time `gorm:"index"` // Set once every durable subscriber has handled it
+	Attempts      int
+	NextAttemptAt time.Time `gorm:"index"` // Not delivered again before this time after a failure
+	LastError     string    `gorm:"type:text"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// PasswordHistory stores the bcrypt hash of a password a user has had, so the password policy
+// can stop them from reusing it. Only the most recent entries allowed by the policy are kept.
+type PasswordHistory struct {
+	ID           uint `gorm:"primaryKey"`
+	UserID       uint `gorm:"index"`
+	PasswordHash string
+	CreatedAt    time.Time
+}
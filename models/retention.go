@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// RetentionPolicy defines how many days old records are kept before the retention job
+// permanently deletes them. A zero value means "keep forever".
+// The policy with a nil AccountID is the global default; a policy tied to an account
+// replaces the global one for tickets raised by that account's users.
+type RetentionPolicy struct {
+	gorm.Model
+
+	AccountID        *uint `gorm:"uniqueIndex"`
+	ClosedTicketDays int   // Days after ClosedAt before a closed ticket is deleted
+	CommentDays      int   // Days after CreatedAt before a comment is deleted
+	LogDays          int   // Days before application log lines are pruned (global policy only)
+}
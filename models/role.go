@@ -0,0 +1,23 @@
+package models
+
+import "gorm.io/gorm"
+
+// Role is a custom, admin-defined set of permissions (e.g. "dispatcher" or "auditor").
+// The built-in admin, tech, and client roles are defined in code and never stored here.
+type Role struct {
+	gorm.Model
+
+	Name        string `gorm:"uniqueIndex"`
+	Description string
+	Permissions string `gorm:"type:text"` // Comma-separated permission names
+}
+
+// RoleLoginPolicy records login restrictions for a built-in or custom role. Roles without a row
+// have no restrictions.
+type RoleLoginPolicy struct {
+	gorm.Model
+
+	Role                  string `gorm:"uniqueIndex"`
+	PasswordLoginDisabled bool   // Members must sign in with single sign-on
+	AllowedNetworks       string // Comma-separated IPs and CIDR ranges members may sign in from; empty allows any
+}
@@ -2,22 +2,27 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Ticket represents a support ticket in the system.
 type Ticket struct {
 	ID           uint `gorm:"primaryKey"`
 	Title        string
-	Description  string
+	Description  string `gorm:"serializer:encrypted"` // Encrypted at rest
 	Priority     string
 	Status       string
 	ClientID     uint
-	Client       User `gorm:"foreignKey:ClientID"`
+	Client       User `gorm:"foreignKey:ClientID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
 	TechID       *uint
-	AssignedTech *User `gorm:"foreignKey:TechID"`
+	AssignedTech *User `gorm:"foreignKey:TechID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
 	ClosedAt     *time.Time
-	Comments     []Comment `gorm:"foreignKey:TicketID"`
+	Comments     []Comment `gorm:"foreignKey:TicketID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 	SkillsNeeded string    `gorm:"type:text"`
+	LegalHold    bool      // Blocks retention purges of this ticket and its comments
+	Version      uint      `gorm:"not null;default:1"` // Incremented by every change; edits based on an older version are rejected
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"` // Set when the ticket is moved to the trash
 }
@@ -16,10 +16,31 @@ type User struct {
 	Name           string
 	Skills         string
 	Role           string
-	FailedAttempts int
-	IsLocked       bool
+	FailedAttempts int        // Consecutive failures since the last lockout or successful login
+	IsLocked       bool       // Set for deactivated and erased users; failed logins set LockedUntil instead
+	LockedUntil    *time.Time // Temporary lockout; the account unlocks itself after this time
+	LockoutCount   int        // Lockouts since the last successful login, used for backoff
 	LastLogin      *time.Time
 
+	SessionsRevokedAt *time.Time // Session tokens issued before this time are rejected
+	PasswordChangedAt *time.Time // Used for password expiry; nil falls back to CreatedAt
+
+	OIDCSubject string `gorm:"column:oidc_subject;index"` // "sub" claim from the identity provider once linked via SSO
+
+	DirectoryID   *uint      `gorm:"index"` // Set for users synced from an LDAP directory, who sign in with their directory password
+	DirectoryUID  string     `gorm:"index"` // The user's stable ID in that directory
+	DeactivatedAt *time.Time // Set when the user leaves their directory or is deactivated over SCIM; deactivated users cannot sign in
+
+	ExternalID string `gorm:"index"` // SCIM externalId set by the identity provider that provisions the user
+
+	MFAEnabled  bool   // TOTP confirmed and checked at login
+	MFARequired bool   // Set by an admin; the user must enroll before their next login completes
+	MFASecret   string `json:"-"` // Base32 TOTP secret; set during enrollment, cleared on reset
+	MFALastStep int64  `json:"-"` // TOTP time step of the last code accepted; codes for it or an earlier step are refused
+
+	StepUpCodeHash  string     `json:"-"` // Hash of the emailed code a login from a new network must confirm
+	StepUpExpiresAt *time.Time `json:"-"`
+
 	AccountID *uint   // Foreign key
 	Account   Account `gorm:"foreignKey:AccountID"`
 }
@@ -0,0 +1,96 @@
+// Package netpolicy parses the IP/CIDR allowlists that can be set on roles and accounts, and
+// groups client IPs into networks for known-login tracking. Allowlists are stored as a
+// comma-separated string; an empty list allows every address.
+package netpolicy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Parse reads a comma-separated list of IP addresses and CIDR ranges. Bare addresses become
+// single-host ranges.
+func Parse(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address or CIDR range: %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or CIDR range: %q", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Normalize validates an allowlist and returns it in canonical form ("10.0.0.0/8, 192.0.2.7/32"),
+// or an empty string for an empty list.
+func Normalize(list string) (string, error) {
+	networks, err := Parse(list)
+	if err != nil {
+		return "", err
+	}
+	entries := make([]string, 0, len(networks))
+	for _, n := range networks {
+		entries = append(entries, n.String())
+	}
+	return strings.Join(entries, ", "), nil
+}
+
+// Allows reports whether ip is in the allowlist. An empty list allows every address, and a list
+// that fails to parse allows none, so a corrupted setting fails closed.
+func Allows(list, ip string) bool {
+	if strings.TrimSpace(list) == "" {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	networks, err := Parse(list)
+	if err != nil {
+		return false
+	}
+	for _, n := range networks {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIP reports whether s is an IP address. Logins from the local CLI use "CLI-Local" instead,
+// and network rules do not apply to them.
+func IsIP(s string) bool {
+	return net.ParseIP(s) != nil
+}
+
+// NetworkOf returns the network an address belongs to for known-login tracking: its /24 for
+// IPv4 and its /64 for IPv6, so a client whose address changes within its ISP's block is not
+// treated as new. It returns an empty string for anything that is not an IP address.
+func NetworkOf(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+	if v4 := addr.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: addr.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
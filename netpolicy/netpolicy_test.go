@@ -0,0 +1,54 @@
+package netpolicy
+
+import "testing"
+
+func TestAllows(t *testing.T) {
+	cases := []struct {
+		list string
+		ip   string
+		ok   bool
+	}{
+		{"", "203.0.113.9", true},
+		{"", "CLI-Local", true},
+		{"10.0.0.0/8", "10.20.30.40", true},
+		{"10.0.0.0/8", "11.0.0.1", false},
+		{"192.0.2.7, 10.0.0.0/8", "192.0.2.7", true},
+		{"192.0.2.7", "192.0.2.8", false},
+		{"2001:db8::/32", "2001:db8:1::5", true},
+		{"2001:db8::/32", "10.0.0.1", false},
+		{"10.0.0.0/8", "CLI-Local", false},
+		{"not-a-network", "10.0.0.1", false}, // Unparseable lists fail closed
+	}
+	for _, tc := range cases {
+		if got := Allows(tc.list, tc.ip); got != tc.ok {
+			t.Errorf("Allows(%q, %q) = %t, want %t", tc.list, tc.ip, got, tc.ok)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	got, err := Normalize(" 10.1.2.3/8 ,192.0.2.7,, 2001:db8::1 ")
+	if err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if want := "10.0.0.0/8, 192.0.2.7/32, 2001:db8::1/128"; got != want {
+		t.Errorf("Normalize = %q, want %q", got, want)
+	}
+	if _, err := Normalize("10.0.0.0/8, office"); err == nil {
+		t.Error("Normalize accepted an invalid entry")
+	}
+}
+
+func TestNetworkOf(t *testing.T) {
+	cases := map[string]string{
+		"203.0.113.9":        "203.0.113.0/24",
+		"2001:db8:1:2:3::4":  "2001:db8:1:2::/64",
+		"::ffff:203.0.113.9": "203.0.113.0/24",
+		"CLI-Local":          "",
+	}
+	for ip, want := range cases {
+		if got := NetworkOf(ip); got != want {
+			t.Errorf("NetworkOf(%q) = %q, want %q", ip, got, want)
+		}
+	}
+}
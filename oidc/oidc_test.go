@@ -0,0 +1,86 @@
+package oidc
+
+import (
+	"RyanForce/oidc/oidctest"
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// authorize follows the provider's authorization endpoint and returns the callback query.
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("Authorization request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected a redirect from the authorization endpoint, got %s", resp.Status)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Invalid redirect: %v", err)
+	}
+	return loc.Query()
+}
+
+func TestAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	mock := oidctest.NewProvider("ryanforce")
+	defer mock.Close()
+	mock.SetClaims(map[string]interface{}{"sub": "u-1", "email": "tech@example.com", "groups": []string{"it-staff"}})
+
+	cfg := Config{ClientID: "ryanforce", RedirectURL: "http://localhost:8080/login/oidc/callback", Scopes: []string{"openid", "email"}}
+	ctx := context.Background()
+
+	provider, err := Discover(ctx, mock.Issuer())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	verifier, _ := RandomString()
+	callback := authorize(t, provider.AuthCodeURL(cfg, "state-1", "nonce-1", verifier))
+	if callback.Get("state") != "state-1" {
+		t.Fatalf("State was not echoed back: %q", callback.Get("state"))
+	}
+
+	claims, err := provider.Exchange(ctx, cfg, callback.Get("code"), verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if claims["email"] != "tech@example.com" || claims["sub"] != "u-1" {
+		t.Errorf("Unexpected claims: %v", claims)
+	}
+}
+
+func TestExchangeRejectsWrongVerifierAndNonce(t *testing.T) {
+	mock := oidctest.NewProvider("ryanforce")
+	defer mock.Close()
+	mock.SetClaims(map[string]interface{}{"sub": "u-2", "email": "user@example.com"})
+
+	cfg := Config{ClientID: "ryanforce", RedirectURL: "http://localhost:8080/login/oidc/callback", Scopes: []string{"openid"}}
+	ctx := context.Background()
+	provider, err := Discover(ctx, mock.Issuer())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	verifier, _ := RandomString()
+	callback := authorize(t, provider.AuthCodeURL(cfg, "s", "n", verifier))
+	if _, err := provider.Exchange(ctx, cfg, callback.Get("code"), "wrong-verifier", "n"); err == nil {
+		t.Error("Expected exchange with the wrong PKCE verifier to fail")
+	}
+
+	callback = authorize(t, provider.AuthCodeURL(cfg, "s", "n", verifier))
+	if _, err := provider.Exchange(ctx, cfg, callback.Get("code"), verifier, "other-nonce"); err == nil {
+		t.Error("Expected exchange with a mismatched nonce to fail")
+	}
+
+	other := Config{ClientID: "someone-else", RedirectURL: cfg.RedirectURL}
+	callback = authorize(t, provider.AuthCodeURL(cfg, "s", "n", verifier))
+	if _, err := provider.Exchange(ctx, other, callback.Get("code"), verifier, "n"); err == nil {
+		t.Error("Expected an ID token for another client to be rejected")
+	}
+}
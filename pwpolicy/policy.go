@@ -0,0 +1,196 @@
+// Package pwpolicy enforces the password policy from config.LoadPasswordPolicy: length,
+// character classes, the bundled common-password list, reuse of recent passwords, and expiry.
+// Every path that creates, changes, or resets a password goes through Assign.
+package pwpolicy
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/utils"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var (
+	commonOnce      sync.Once
+	commonPasswords map[string]struct{}
+)
+
+// ErrCommonPassword is returned for passwords found in the common-password list.
+var ErrCommonPassword = errors.New("password is too common, choose something harder to guess")
+
+// ErrReusedPassword is returned when a user picks one of their recent passwords.
+var ErrReusedPassword = errors.New("password was used recently, choose a different one")
+
+// ErrDirectoryManaged is returned when setting a password for a user who signs in against an
+// LDAP directory; their password can only be changed in the directory.
+var ErrDirectoryManaged = errors.New("this user's password is managed by their directory")
+
+// Requirements describes the length and character rules in a sentence for forms and prompts.
+func Requirements() string {
+	p := config.LoadPasswordPolicy()
+
+	var classes []string
+	if p.RequireUpper {
+		classes = append(classes, "a capital letter")
+	}
+	if p.RequireLower {
+		classes = append(classes, "a lowercase letter")
+	}
+	if p.RequireDigit {
+		classes = append(classes, "a number")
+	}
+	if p.RequireSpecial {
+		classes = append(classes, "a special character")
+	}
+
+	msg := fmt.Sprintf("Password must be %d–%d characters", p.MinLength, p.MaxLength)
+	switch len(classes) {
+	case 0:
+	case 1:
+		msg += " and include " + classes[0]
+	default:
+		msg += " and include " + strings.Join(classes[:len(classes)-1], ", ") + ", and " + classes[len(classes)-1]
+	}
+	return msg + "."
+}
+
+// Validate checks a password against the length, character-class, and common-password rules.
+// It does not know the user, so password reuse is checked separately by Assign.
+func Validate(pw string) error {
+	p := config.LoadPasswordPolicy()
+
+	length := len([]rune(pw))
+	if length < p.MinLength || length > p.MaxLength {
+		return errors.New(Requirements())
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range pw {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSpecial = true
+		}
+	}
+	if (p.RequireUpper && !hasUpper) || (p.RequireLower && !hasLower) ||
+		(p.RequireDigit && !hasDigit) || (p.RequireSpecial && !hasSpecial) {
+		return errors.New(Requirements())
+	}
+
+	if p.CheckCommon && isCommon(pw) {
+		return ErrCommonPassword
+	}
+	return nil
+}
+
+// Assign validates pw for the user, checks it against their current and recent passwords, and
+// sets the new hash and change time on user. The caller saves the user and then calls Remember.
+// For a user that has not been created yet (ID 0) the reuse check is skipped. Directory users
+// are refused with ErrDirectoryManaged.
+func Assign(user *models.User, pw string) error {
+	if user.DirectoryID != nil {
+		return ErrDirectoryManaged
+	}
+	if err := Validate(pw); err != nil {
+		return err
+	}
+	if user.ID != 0 && reused(user, pw) {
+		return ErrReusedPassword
+	}
+
+	hash, err := utils.HashPassword(pw)
+	if err != nil {
+		utils.LogError("[Password] Failed to hash password", err)
+		return fmt.Errorf("failed to hash password")
+	}
+
+	now := time.Now()
+	user.PasswordHash = hash
+	user.PasswordChangedAt = &now
+	return nil
+}
+
+// Remember adds the user's current password hash to their history and drops entries beyond
+// the policy's history size.
+func Remember(db *gorm.DB, user *models.User) error {
+	size := config.LoadPasswordPolicy().HistorySize
+	if size <= 0 || user.PasswordHash == "" {
+		return nil
+	}
+
+	if err := db.Create(&models.PasswordHistory{UserID: user.ID, PasswordHash: user.PasswordHash}).Error; err != nil {
+		return err
+	}
+
+	var keep []uint
+	db.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).
+		Order("created_at desc, id desc").Limit(size).Pluck("id", &keep)
+	return db.Where("user_id = ? AND id NOT IN ?", user.ID, keep).Delete(&models.PasswordHistory{}).Error
+}
+
+// Expired reports whether the user's password is older than the policy's maximum age and must
+// be changed before the next login completes. Directory passwords are left to the directory.
+func Expired(user *models.User) bool {
+	maxAge := config.LoadPasswordPolicy().MaxAge
+	if maxAge <= 0 || user.DirectoryID != nil {
+		return false
+	}
+
+	changed := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changed = *user.PasswordChangedAt
+	}
+	return time.Since(changed) > maxAge
+}
+
+// reused reports whether pw matches the user's current password or one in their history.
+func reused(user *models.User, pw string) bool {
+	size := config.LoadPasswordPolicy().HistorySize
+	if size <= 0 {
+		return false
+	}
+	if user.PasswordHash != "" && utils.CheckPasswordHash(pw, user.PasswordHash) {
+		return true
+	}
+
+	var history []models.PasswordHistory
+	config.DB.Where("user_id = ?", user.ID).Order("created_at desc, id desc").Limit(size).Find(&history)
+	for _, h := range history {
+		if utils.CheckPasswordHash(pw, h.PasswordHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCommon reports whether pw appears in the bundled common-password list, ignoring case.
+func isCommon(pw string) bool {
+	commonOnce.Do(func() {
+		commonPasswords = make(map[string]struct{})
+		for _, line := range strings.Split(commonPasswordList, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			commonPasswords[strings.ToLower(line)] = struct{}{}
+		}
+	})
+	_, found := commonPasswords[strings.ToLower(pw)]
+	return found
+}
@@ -0,0 +1,100 @@
+package pwpolicy
+
+import (
+	"RyanForce/config"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	testutil.OpenDB()
+	os.Exit(m.Run())
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		pw string
+		ok bool
+	}{
+		{"Str0ng!Pass", true},
+		{"Sh0rt!", false},
+		{"nouppercase1!", false},
+		{"NoDigits!!", false},
+		{"NoSpecial123", false},
+		{"Password123!", false}, // In the common-password list
+		{"p@SSW0RD", false},     // Common-password check ignores case
+	}
+	for _, tc := range cases {
+		if err := Validate(tc.pw); (err == nil) != tc.ok {
+			t.Errorf("Validate(%q) = %v, want ok=%t", tc.pw, err, tc.ok)
+		}
+	}
+}
+
+func TestValidateUsesConfiguredRules(t *testing.T) {
+	t.Setenv("RYANFORCE_PASSWORD_MIN_LENGTH", "12")
+	t.Setenv("RYANFORCE_PASSWORD_REQUIRE_LOWER", "true")
+
+	if err := Validate("STR0NG!PASS"); err == nil {
+		t.Error("Expected an 11-character password without lowercase letters to be rejected")
+	}
+	if err := Validate("Str0ng!Passphrase"); err != nil {
+		t.Errorf("Expected a long mixed-case password to pass: %v", err)
+	}
+}
+
+func TestAssignRejectsRecentPasswords(t *testing.T) {
+	testutil.ResetDB(t)
+	t.Setenv("RYANFORCE_PASSWORD_HISTORY", "2")
+
+	user := models.User{Email: "history@example.com"}
+	if err := Assign(&user, "First!Pass1"); err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	config.DB.Create(&user)
+	Remember(config.DB, &user)
+
+	for _, pw := range []string{"Second!Pass2", "Third!Pass3"} {
+		if err := Assign(&user, pw); err != nil {
+			t.Fatalf("Assign(%q) failed: %v", pw, err)
+		}
+		config.DB.Save(&user)
+		Remember(config.DB, &user)
+	}
+
+	if err := Assign(&user, "Second!Pass2"); !errors.Is(err, ErrReusedPassword) {
+		t.Errorf("Expected a recent password to be rejected, got %v", err)
+	}
+	if err := Assign(&user, "First!Pass1"); err != nil {
+		t.Errorf("Expected a password older than the history size to be allowed, got %v", err)
+	}
+
+	var kept int64
+	config.DB.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).Count(&kept)
+	if kept != 2 {
+		t.Errorf("Expected history to be trimmed to 2 entries, found %d", kept)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	old := time.Now().Add(-100 * 24 * time.Hour)
+	user := models.User{PasswordChangedAt: &old}
+
+	if Expired(&user) {
+		t.Error("Passwords must not expire when no maximum age is configured")
+	}
+
+	t.Setenv("RYANFORCE_PASSWORD_MAX_AGE_DAYS", "90")
+	if !Expired(&user) {
+		t.Error("Expected a 100-day-old password to be expired with a 90-day maximum age")
+	}
+	recent := time.Now().Add(-24 * time.Hour)
+	user.PasswordChangedAt = &recent
+	if Expired(&user) {
+		t.Error("Expected a 1-day-old password to be valid")
+	}
+}
@@ -0,0 +1,103 @@
+package rbac
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/netpolicy"
+	"RyanForce/utils"
+	"fmt"
+)
+
+// PasswordLoginAllowed reports whether members of the role may sign in with a password.
+// Roles default to allowing it until an admin turns it off.
+func PasswordLoginAllowed(role string) bool {
+	var policy models.RoleLoginPolicy
+	if err := config.DB.Where("role = ?", role).Limit(1).Find(&policy).Error; err != nil {
+		utils.LogError("[RBAC] Failed to load login policy for role "+role, err)
+		return true
+	}
+	return !policy.PasswordLoginDisabled
+}
+
+// PasswordLoginDisabledRoles returns the roles whose members must use single sign-on.
+func PasswordLoginDisabledRoles() map[string]bool {
+	var policies []models.RoleLoginPolicy
+	config.DB.Where("password_login_disabled = ?", true).Find(&policies)
+
+	disabled := map[string]bool{}
+	for _, p := range policies {
+		disabled[p.Role] = true
+	}
+	return disabled
+}
+
+// SetPasswordLogin allows or blocks password sign-in for a role. Password login can only be
+// turned off while single sign-on is configured, so nobody is locked out of every login method.
+func SetPasswordLogin(role string, allowed bool, actorID uint) error {
+	if !RoleExists(role) {
+		return fmt.Errorf("unknown role: %s", role)
+	}
+	if !allowed && !config.LoadOIDCSettings().Enabled() {
+		return fmt.Errorf("single sign-on is not configured; password login cannot be disabled")
+	}
+
+	var policy models.RoleLoginPolicy
+	config.DB.Where("role = ?", role).Limit(1).Find(&policy)
+	policy.Role = role
+	policy.PasswordLoginDisabled = !allowed
+	if err := config.DB.Save(&policy).Error; err != nil {
+		return fmt.Errorf("failed to save login policy: %w", err)
+	}
+
+	utils.LogAudit(fmt.Sprintf("[RBAC] User %d set password login for role %s to allowed=%t", actorID, role, allowed))
+	return nil
+}
+
+// AllowedNetworks returns the IPs and CIDR ranges members of the role may sign in from, or an
+// empty string when the role has no allowlist.
+func AllowedNetworks(role string) string {
+	var policy models.RoleLoginPolicy
+	if err := config.DB.Where("role = ?", role).Limit(1).Find(&policy).Error; err != nil {
+		utils.LogError("[RBAC] Failed to load login policy for role "+role, err)
+		return ""
+	}
+	return policy.AllowedNetworks
+}
+
+// allowedNetworksByRole returns every role's allowlist, for listing roles.
+func allowedNetworksByRole() map[string]string {
+	var policies []models.RoleLoginPolicy
+	config.DB.Where("allowed_networks <> ?", "").Find(&policies)
+
+	networks := map[string]string{}
+	for _, p := range policies {
+		networks[p.Role] = p.AllowedNetworks
+	}
+	return networks
+}
+
+// SetAllowedNetworks restricts the networks members of a role may sign in from to a
+// comma-separated list of IPs and CIDR ranges. An empty list removes the restriction.
+func SetAllowedNetworks(role, list string, actorID uint) error {
+	if !RoleExists(role) {
+		return fmt.Errorf("unknown role: %s", role)
+	}
+	normalized, err := netpolicy.Normalize(list)
+	if err != nil {
+		return err
+	}
+
+	var policy models.RoleLoginPolicy
+	config.DB.Where("role = ?", role).Limit(1).Find(&policy)
+	policy.Role = role
+	policy.AllowedNetworks = normalized
+	if err := config.DB.Save(&policy).Error; err != nil {
+		return fmt.Errorf("failed to save login policy: %w", err)
+	}
+
+	if normalized == "" {
+		normalized = "any"
+	}
+	utils.LogAudit(fmt.Sprintf("[RBAC] User %d set allowed networks for role %s to %s", actorID, role, normalized))
+	return nil
+}
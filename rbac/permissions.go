@@ -0,0 +1,148 @@
+package rbac
+
+// Permission names a single action a role may perform.
+type Permission string
+
+// Ticket permissions. The view/update sets are scopes: "own" covers tickets the user raised,
+// "account" covers tickets raised by anyone in the user's client account, "assigned" covers
+// tickets assigned to them, and "all" covers every ticket.
+const (
+	TicketsCreate         Permission = "tickets.create"
+	TicketsViewOwn        Permission = "tickets.view.own"
+	TicketsViewAccount    Permission = "tickets.view.account"
+	TicketsViewAssigned   Permission = "tickets.view.assigned"
+	TicketsViewAll        Permission = "tickets.view.all"
+	TicketsUpdateOwn      Permission = "tickets.update.own"
+	TicketsUpdateAccount  Permission = "tickets.update.account"
+	TicketsUpdateAssigned Permission = "tickets.update.assigned"
+	TicketsUpdateAll      Permission = "tickets.update.all"
+	TicketsAssign         Permission = "tickets.assign"
+	TicketsDelete         Permission = "tickets.delete"
+)
+
+// Comment, administration, and maintenance permissions.
+const (
+	CommentsCreate   Permission = "comments.create"
+	CommentsModerate Permission = "comments.moderate" // Edit or delete other users' comments
+	UsersView        Permission = "users.view"
+	UsersManage      Permission = "users.manage"      // Create, edit, delete, unlock, and reset passwords
+	UsersImpersonate Permission = "users.impersonate" // View the app as another user
+	AccountUsers     Permission = "account.users"     // List and add colleagues in their own client account
+	AccountReports   Permission = "account.reports"   // Reports limited to their own client account
+	AccountsView     Permission = "accounts.view"
+	AccountsManage   Permission = "accounts.manage"
+	ReportsView      Permission = "reports.view" // Reports, CSV exports, and the audit trail
+	LogsView         Permission = "logs.view"
+	TrashManage      Permission = "trash.manage"
+	RetentionManage  Permission = "retention.manage"
+	PrivacyManage    Permission = "privacy.manage"   // Personal data export and erasure
+	DirectoryManage  Permission = "directory.manage" // LDAP connections and user sync
+	RolesManage      Permission = "roles.manage"
+	JobsManage       Permission = "jobs.manage"   // View, run, and cancel background jobs
+	SystemManage     Permission = "system.manage" // Seeding and wiping the database, rotating signing keys
+)
+
+// PermissionInfo describes a permission for the role editor.
+type PermissionInfo struct {
+	Name        Permission
+	Description string
+}
+
+// AllPermissions lists every permission in display order.
+var AllPermissions = []PermissionInfo{
+	{TicketsCreate, "Create tickets"},
+	{TicketsViewOwn, "View tickets they raised"},
+	{TicketsViewAccount, "View tickets raised by anyone in their account"},
+	{TicketsViewAssigned, "View tickets assigned to them"},
+	{TicketsViewAll, "View every ticket"},
+	{TicketsUpdateOwn, "Update tickets they raised"},
+	{TicketsUpdateAccount, "Update tickets raised by anyone in their account"},
+	{TicketsUpdateAssigned, "Update tickets assigned to them"},
+	{TicketsUpdateAll, "Update every ticket"},
+	{TicketsAssign, "Assign and unassign technicians"},
+	{TicketsDelete, "Move tickets to the trash"},
+	{CommentsCreate, "Comment on tickets they can view"},
+	{CommentsModerate, "Edit or delete anyone's comments"},
+	{UsersView, "View the user list"},
+	{UsersManage, "Create, edit, delete, unlock, and reset users"},
+	{UsersImpersonate, "View the app as another user, for support"},
+	{AccountUsers, "List and add colleagues in their own account"},
+	{AccountReports, "View reports for their own account"},
+	{AccountsView, "View client accounts"},
+	{AccountsManage, "Create, edit, and delete client accounts"},
+	{ReportsView, "View reports, exports, and the audit trail"},
+	{LogsView, "View the system log"},
+	{TrashManage, "Restore and purge trashed items"},
+	{RetentionManage, "Configure retention policies and legal holds"},
+	{PrivacyManage, "Export and erase personal data"},
+	{DirectoryManage, "Configure LDAP directories and run user syncs"},
+	{RolesManage, "Create and edit roles"},
+	{JobsManage, "View, run, and cancel background jobs"},
+	{SystemManage, "Seed or wipe the database and rotate signing keys"},
+}
+
+// Built-in role names. Their permissions are fixed in code.
+const (
+	RoleAdmin        = "admin"
+	RoleTech         = "tech"
+	RoleClient       = "client"
+	RoleAccountAdmin = "account_admin" // A client account's designated contact
+)
+
+// builtinRoles maps each built-in role to its permission set.
+var builtinRoles = map[string][]Permission{
+	RoleAdmin: allPermissionNames(),
+	RoleTech: {
+		TicketsViewAssigned, TicketsUpdateAssigned, CommentsCreate,
+	},
+	RoleClient: {
+		TicketsCreate, TicketsViewOwn, TicketsUpdateOwn, CommentsCreate,
+	},
+	RoleAccountAdmin: {
+		TicketsCreate, TicketsViewOwn, TicketsViewAccount, TicketsUpdateOwn, TicketsUpdateAccount,
+		CommentsCreate, AccountUsers, AccountReports,
+	},
+}
+
+// defaultCustomRoles are created on first start as editable examples of custom roles.
+var defaultCustomRoles = []struct {
+	name        string
+	description string
+	permissions []Permission
+}{
+	{
+		name:        "dispatcher",
+		description: "Triages the queue: sees every ticket and assigns technicians",
+		permissions: []Permission{TicketsViewAll, TicketsUpdateAll, TicketsAssign, CommentsCreate, UsersView, AccountsView},
+	},
+	{
+		name:        "auditor",
+		description: "Read-only access to tickets, reports, and logs",
+		permissions: []Permission{TicketsViewAll, UsersView, AccountsView, ReportsView, LogsView},
+	},
+}
+
+// allPermissionNames returns the name of every known permission.
+func allPermissionNames() []Permission {
+	names := make([]Permission, 0, len(AllPermissions))
+	for _, p := range AllPermissions {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// IsKnownPermission reports whether p is a defined permission.
+func IsKnownPermission(p Permission) bool {
+	for _, info := range AllPermissions {
+		if info.Name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBuiltinRole reports whether name is one of the fixed admin, tech, client, or account_admin roles.
+func IsBuiltinRole(name string) bool {
+	_, ok := builtinRoles[name]
+	return ok
+}
@@ -0,0 +1,333 @@
+package rbac
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RoleInfo describes a role and the permissions it grants.
+type RoleInfo struct {
+	Name        string
+	Description string
+	Permissions []Permission
+	Builtin     bool
+
+	PasswordLoginDisabled bool   // Members must sign in with single sign-on
+	AllowedNetworks       string // IPs and CIDR ranges members may sign in from; empty allows any
+}
+
+// Has reports whether the role grants p. Used by the role editor template.
+func (r RoleInfo) Has(p Permission) bool {
+	for _, granted := range r.Permissions {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// roleNamePattern limits custom role names to short lowercase identifiers.
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// privilegedPermissions let a user manage other users, roles, or the system itself.
+var privilegedPermissions = []Permission{UsersManage, RolesManage, SystemManage, UsersImpersonate}
+
+// ErrPrivilegedRole refuses a SCIM change that would grant or take away a privileged role.
+var ErrPrivilegedRole = errors.New("roles that manage users, roles, or the system are assigned in RyanForce, not over SCIM")
+
+var (
+	cacheMu     sync.RWMutex
+	customRoles map[string]RoleInfo // nil until first loaded from the database
+)
+
+// Can reports whether the named role grants the permission.
+func Can(role string, p Permission) bool {
+	for _, granted := range PermissionsFor(role) {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionMap returns the role's permissions keyed by name, for use in templates
+// (e.g. {{ if index .can "users.manage" }}).
+func PermissionMap(role string) map[string]bool {
+	perms := map[string]bool{}
+	for _, p := range PermissionsFor(role) {
+		perms[string(p)] = true
+	}
+	return perms
+}
+
+// CanAny reports whether the named role grants at least one of the permissions.
+func CanAny(role string, perms ...Permission) bool {
+	for _, p := range perms {
+		if Can(role, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPrivilegedRole reports whether the role can manage users, roles, or the system, or view the
+// app as another user. Only people in RyanForce can grant these roles, never SCIM provisioning.
+func IsPrivilegedRole(role string) bool {
+	return CanAny(role, privilegedPermissions...)
+}
+
+// PermissionsFor returns the permissions granted to a role. Unknown roles get none.
+func PermissionsFor(role string) []Permission {
+	if perms, ok := builtinRoles[role]; ok {
+		return perms
+	}
+	roles := loadCustomRoles()
+	return roles[role].Permissions
+}
+
+// RoleExists reports whether name is a built-in or custom role.
+func RoleExists(name string) bool {
+	if IsBuiltinRole(name) {
+		return true
+	}
+	_, ok := loadCustomRoles()[name]
+	return ok
+}
+
+// RoleNames returns every role name, built-in roles first.
+func RoleNames() []string {
+	names := []string{RoleClient, RoleAccountAdmin, RoleTech, RoleAdmin}
+	custom := make([]string, 0)
+	for name := range loadCustomRoles() {
+		custom = append(custom, name)
+	}
+	sort.Strings(custom)
+	return append(names, custom...)
+}
+
+// ListRoles returns every role with its permissions, built-in roles first.
+func ListRoles() []RoleInfo {
+	roles := []RoleInfo{
+		{Name: RoleAdmin, Description: "Full access to every feature", Permissions: builtinRoles[RoleAdmin], Builtin: true},
+		{Name: RoleTech, Description: "Works tickets assigned to them", Permissions: builtinRoles[RoleTech], Builtin: true},
+		{Name: RoleClient, Description: "Raises and follows their own tickets", Permissions: builtinRoles[RoleClient], Builtin: true},
+		{Name: RoleAccountAdmin, Description: "Client account contact: manages the account's tickets and colleagues", Permissions: builtinRoles[RoleAccountAdmin], Builtin: true},
+	}
+	custom := loadCustomRoles()
+	names := make([]string, 0, len(custom))
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		roles = append(roles, custom[name])
+	}
+
+	disabled := PasswordLoginDisabledRoles()
+	networks := allowedNetworksByRole()
+	for i := range roles {
+		roles[i].PasswordLoginDisabled = disabled[roles[i].Name]
+		roles[i].AllowedNetworks = networks[roles[i].Name]
+	}
+	return roles
+}
+
+// SaveRole creates or updates a custom role. Built-in roles cannot be changed. actorID is the user
+// making the change: nobody may edit the role they hold, or grant a permission their own role
+// lacks, so roles.manage cannot be used to raise anyone above the actor.
+func SaveRole(name, description string, perms []Permission, actorID uint) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !roleNamePattern.MatchString(name) {
+		return fmt.Errorf("role name must be 2–32 lowercase letters, digits, '-' or '_'")
+	}
+	if IsBuiltinRole(name) {
+		return fmt.Errorf("the built-in %s role cannot be changed", name)
+	}
+
+	var actor models.User
+	if err := config.DB.Select("id", "role").Where("id = ?", actorID).Limit(1).Find(&actor).Error; err != nil || actor.ID == 0 {
+		return fmt.Errorf("user not found")
+	}
+	if actor.Role == name {
+		utils.LogWarning(fmt.Sprintf("[RBAC] User %d tried to edit their own role %s", actorID, name))
+		return fmt.Errorf("you cannot change the role you hold")
+	}
+
+	names := make([]string, 0, len(perms))
+	for _, p := range perms {
+		if !IsKnownPermission(p) {
+			return fmt.Errorf("unknown permission: %s", p)
+		}
+		if !Can(actor.Role, p) {
+			utils.LogWarning(fmt.Sprintf("[RBAC] User %d (%s) tried to grant %s to role %s", actorID, actor.Role, p, name))
+			return fmt.Errorf("you cannot grant %s, which your own role lacks", p)
+		}
+		names = append(names, string(p))
+	}
+
+	var role models.Role
+	err := config.DB.Unscoped().Where("name = ?", name).First(&role).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to load role: %w", err)
+	}
+	role.Name = name
+	role.Description = strings.TrimSpace(description)
+	role.Permissions = strings.Join(names, ",")
+	role.DeletedAt = gorm.DeletedAt{}
+	if err := config.DB.Unscoped().Save(&role).Error; err != nil {
+		return fmt.Errorf("failed to save role: %w", err)
+	}
+
+	invalidateCache()
+	utils.LogAudit(fmt.Sprintf("[RBAC] User %d saved role %q with permissions: %s", actorID, name, role.Permissions))
+	return nil
+}
+
+// DeleteRole removes a custom role. Roles still assigned to users cannot be deleted.
+func DeleteRole(name string) error {
+	if IsBuiltinRole(name) {
+		return fmt.Errorf("the built-in %s role cannot be deleted", name)
+	}
+
+	var inUse int64
+	if err := config.DB.Model(&models.User{}).Where("role = ?", name).Count(&inUse).Error; err != nil {
+		return fmt.Errorf("failed to check role usage: %w", err)
+	}
+	if inUse > 0 {
+		return fmt.Errorf("role %s is still assigned to %d user(s)", name, inUse)
+	}
+
+	result := config.DB.Where("name = ?", name).Delete(&models.Role{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete role: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("role %s not found", name)
+	}
+
+	invalidateCache()
+	utils.LogAudit(fmt.Sprintf("[RBAC] Role %q deleted", name))
+	return nil
+}
+
+// SetUserRole changes a user's role. actorID is the user making the change, or zero for SCIM
+// provisioning applying the group mappings an admin configured. Nobody may change their own role,
+// only an admin may grant the admin role or take it away, and SCIM may not grant or take away a
+// privileged role (see IsPrivilegedRole). The user's sessions are revoked.
+func SetUserRole(userID uint, role string, actorID uint) error {
+	if !RoleExists(role) {
+		return fmt.Errorf("unknown role: %s", role)
+	}
+	if userID == actorID {
+		return fmt.Errorf("you cannot change your own role")
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if actorID == 0 && (IsPrivilegedRole(role) || IsPrivilegedRole(user.Role)) {
+		utils.LogWarning(fmt.Sprintf("[RBAC] Refused SCIM change of user %d from %s to %s: privileged role", userID, user.Role, role))
+		return ErrPrivilegedRole
+	}
+	if actorID != 0 && (role == RoleAdmin || user.Role == RoleAdmin) {
+		var actor models.User
+		if err := config.DB.Where("id = ?", actorID).Limit(1).Find(&actor).Error; err != nil || actor.Role != RoleAdmin {
+			utils.LogWarning(fmt.Sprintf("[RBAC] User %d (not an admin) tried to change the role of user %d from %s to %s", actorID, userID, user.Role, role))
+			return fmt.Errorf("only an admin can grant or remove the admin role")
+		}
+	}
+	// Sessions carry the role they were issued with, so end them for the new role to apply at once
+	oldRole := user.Role
+	if err := config.DB.Model(&user).Updates(map[string]interface{}{"role": role, "sessions_revoked_at": time.Now()}).Error; err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	utils.LogAudit(fmt.Sprintf("[RBAC] User %d changed role of user %d from %s to %s", actorID, userID, oldRole, role))
+	return nil
+}
+
+// SeedDefaultRoles creates the example custom roles (dispatcher and auditor) the first time
+// the app starts. Roles an admin has since deleted are not recreated.
+func SeedDefaultRoles() error {
+	for _, def := range defaultCustomRoles {
+		var count int64
+		if err := config.DB.Unscoped().Model(&models.Role{}).Where("name = ?", def.name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		names := make([]string, 0, len(def.permissions))
+		for _, p := range def.permissions {
+			names = append(names, string(p))
+		}
+		role := models.Role{Name: def.name, Description: def.description, Permissions: strings.Join(names, ",")}
+		if err := config.DB.Create(&role).Error; err != nil {
+			return err
+		}
+		utils.LogInfo(fmt.Sprintf("[RBAC] Created default role %q", def.name))
+	}
+	invalidateCache()
+	return nil
+}
+
+// ParsePermissions splits a comma-separated permission list, ignoring blanks.
+func ParsePermissions(list string) []Permission {
+	var perms []Permission
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			perms = append(perms, Permission(part))
+		}
+	}
+	return perms
+}
+
+// loadCustomRoles returns the cached custom roles, reading them from the database if needed.
+func loadCustomRoles() map[string]RoleInfo {
+	cacheMu.RLock()
+	roles := customRoles
+	cacheMu.RUnlock()
+	if roles != nil {
+		return roles
+	}
+
+	roles = map[string]RoleInfo{}
+	if config.DB != nil {
+		var rows []models.Role
+		if err := config.DB.Find(&rows).Error; err != nil {
+			utils.LogError("[RBAC] Failed to load custom roles", err)
+			return roles
+		}
+		for _, r := range rows {
+			roles[r.Name] = RoleInfo{
+				Name:        r.Name,
+				Description: r.Description,
+				Permissions: ParsePermissions(r.Permissions),
+			}
+		}
+	}
+
+	cacheMu.Lock()
+	customRoles = roles
+	cacheMu.Unlock()
+	return roles
+}
+
+// invalidateCache forces the next lookup to re-read custom roles from the database.
+func invalidateCache() {
+	cacheMu.Lock()
+	customRoles = nil
+	cacheMu.Unlock()
+}
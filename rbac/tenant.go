@@ -0,0 +1,57 @@
+package rbac
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+
+	"gorm.io/gorm"
+)
+
+// AccountOf returns the ID of the client account the user belongs to, or 0 if they have none.
+func AccountOf(userID uint) uint {
+	var user models.User
+	if err := config.DB.Unscoped().Select("id", "account_id").First(&user, userID).Error; err != nil || user.AccountID == nil {
+		return 0
+	}
+	return *user.AccountID
+}
+
+// SameAccount reports whether both users belong to the same client account.
+func SameAccount(userID, otherID uint) bool {
+	accountID := AccountOf(userID)
+	return accountID != 0 && AccountOf(otherID) == accountID
+}
+
+// accountMembers is a subquery selecting the IDs of every user in the account. Deleted users
+// are included so the tickets they raised stay visible to the account.
+func accountMembers(accountID uint) *gorm.DB {
+	return config.DB.Unscoped().Model(&models.User{}).Select("id").Where("account_id = ?", accountID)
+}
+
+// ScopeUsers narrows a user query to the users the actor may list: everyone with users.view,
+// or the members of their own account with account.users. Returns false otherwise.
+func ScopeUsers(query *gorm.DB, userID uint, role string) (*gorm.DB, bool) {
+	if Can(role, UsersView) {
+		return query, true
+	}
+	if Can(role, AccountUsers) {
+		if accountID := AccountOf(userID); accountID != 0 {
+			return query.Where("users.account_id = ?", accountID), true
+		}
+	}
+	return query, false
+}
+
+// ScopeReportTickets narrows a ticket query for reporting: every ticket with reports.view, or
+// the tickets raised in the actor's own account with account.reports. Returns false otherwise.
+func ScopeReportTickets(query *gorm.DB, userID uint, role string) (*gorm.DB, bool) {
+	if Can(role, ReportsView) {
+		return query, true
+	}
+	if Can(role, AccountReports) {
+		if accountID := AccountOf(userID); accountID != 0 {
+			return query.Where("tickets.client_id IN (?)", accountMembers(accountID)), true
+		}
+	}
+	return query, false
+}
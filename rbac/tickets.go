@@ -0,0 +1,67 @@
+package rbac
+
+import (
+	"RyanForce/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ScopeTickets narrows a ticket query to the tickets the user may view. Every ticket list goes
+// through it, so a user only ever sees their own account's tickets unless they can view all.
+// Returns false when the role cannot view any tickets.
+func ScopeTickets(query *gorm.DB, userID uint, role string) (*gorm.DB, bool) {
+	if Can(role, TicketsViewAll) {
+		return query, true
+	}
+
+	var conditions []string
+	var args []interface{}
+	if Can(role, TicketsViewOwn) {
+		conditions = append(conditions, "tickets.client_id = ?")
+		args = append(args, userID)
+	}
+	if Can(role, TicketsViewAccount) {
+		if accountID := AccountOf(userID); accountID != 0 {
+			conditions = append(conditions, "tickets.client_id IN (?)")
+			args = append(args, accountMembers(accountID))
+		}
+	}
+	if Can(role, TicketsViewAssigned) {
+		conditions = append(conditions, "tickets.tech_id = ?")
+		args = append(args, userID)
+	}
+	if len(conditions) == 0 {
+		return query, false
+	}
+	return query.Where("("+strings.Join(conditions, " OR ")+")", args...), true
+}
+
+// CanViewTicket reports whether the user may view the ticket.
+func CanViewTicket(userID uint, role string, ticket *models.Ticket) bool {
+	return inScope(userID, role, ticket, TicketsViewAll, TicketsViewOwn, TicketsViewAccount, TicketsViewAssigned)
+}
+
+// CanUpdateTicket reports whether the user may change the ticket's fields or status.
+func CanUpdateTicket(userID uint, role string, ticket *models.Ticket) bool {
+	return inScope(userID, role, ticket, TicketsUpdateAll, TicketsUpdateOwn, TicketsUpdateAccount, TicketsUpdateAssigned)
+}
+
+// CanModifyComment reports whether the user may edit or delete a comment written by authorID.
+func CanModifyComment(userID uint, role string, authorID uint) bool {
+	return authorID == userID || Can(role, CommentsModerate)
+}
+
+// inScope checks a ticket against an all/own/account/assigned permission set.
+func inScope(userID uint, role string, ticket *models.Ticket, all, own, account, assigned Permission) bool {
+	if Can(role, all) {
+		return true
+	}
+	if Can(role, own) && ticket.ClientID == userID {
+		return true
+	}
+	if Can(role, account) && SameAccount(userID, ticket.ClientID) {
+		return true
+	}
+	return Can(role, assigned) && ticket.TechID != nil && *ticket.TechID == userID
+}
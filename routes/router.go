@@ -3,6 +3,7 @@ package routes
 import (
 	"RyanForce/controllers"
 	"RyanForce/middleware"
+	"RyanForce/rbac"
 	"RyanForce/utils"
 	"RyanForce/web"
 	"net/http"
@@ -14,6 +15,9 @@ import (
 func SetupRouterWithEngine(r *gin.Engine) *gin.Engine {
 
 	r.LoadHTMLGlob("web/templates/*.html")
+	r.Use(middleware.MetricsMiddleware())
+	r.Use(middleware.SecurityHeaders())
+	r.Use(middleware.CSRFMiddleware())
 	r.Static("/static", "./web/static")
 
 	// Root route
@@ -28,31 +32,66 @@ func SetupRouterWithEngine(r *gin.Engine) *gin.Engine {
 		c.Redirect(http.StatusFound, "/login")
 	})
 
+	// Health checks for load balancers and orchestrators
+	r.GET("/healthz", controllers.Healthz)
+	r.GET("/readyz", controllers.Readyz)
+
+	// Public keys for verifying session tokens
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
+
+	// Prometheus metrics
+	r.GET("/metrics", controllers.MetricsHandler())
+
 	// Authentication and Session
 	r.GET("/login", web.ShowLoginPage)
 	r.POST("/login", web.HandleWebLogin)
-	r.GET("/dashboard", web.ShowDashboard)
+	r.GET("/login/mfa", web.ShowMFAPrompt)
+	r.POST("/login/mfa", web.HandleMFAPrompt)
+	r.GET("/login/mfa/enroll", web.ShowLoginEnrollment)
+	r.POST("/login/mfa/enroll", web.HandleLoginEnrollment)
+	r.GET("/login/password", web.ShowPasswordChange)
+	r.GET("/login/oidc", web.StartSSOLogin)
+	r.GET("/login/oidc/callback", web.HandleSSOCallback)
+	r.POST("/login/password", web.HandlePasswordChange)
+	r.GET("/dashboard", middleware.WebAuthMiddleware(), web.ShowDashboard)
+	r.POST("/impersonation/stop", middleware.WebAuthMiddleware(), web.StopImpersonation)
 	r.GET("/logout", web.HandleLogout)
 
 	r.GET("/reset-password", web.ShowResetForm)
 	r.POST("/reset-password", web.HandleResetPassword)
-
-	r.GET("/admin/reset-password", web.ShowAdminResetForm)
-	r.POST("/admin/reset-password", web.HandleAdminResetPassword)
-
-	r.GET("/admin/unlock", web.ShowUnlockForm)
-	r.POST("/admin/unlock", web.HandleUnlockUser)
-
-	r.GET("/admin/unassigned-tickets", web.ShowUnassignedTickets)
+	r.GET("/forgot-password", web.ShowForgotPassword)
+	r.POST("/forgot-password", web.HandleForgotPassword)
+	r.GET("/password-reset", web.ShowPasswordResetLink)
+	r.POST("/password-reset", web.HandlePasswordResetLink)
+
+	// Group: Account self-service - Protected
+	accountGroup := r.Group("/account")
+	accountGroup.Use(middleware.WebAuthMiddleware())
+	{
+		noImpersonation := middleware.BlockWhileImpersonating()
+		accountGroup.GET("/mfa", noImpersonation, web.ShowAccountMFA)
+		accountGroup.POST("/mfa/enroll", noImpersonation, web.HandleAccountEnrollment)
+		accountGroup.POST("/mfa/disable", noImpersonation, web.HandleAccountDisableMFA)
+		accountGroup.GET("/logins", web.ShowKnownLogins)
+		accountGroup.POST("/sso/link", noImpersonation, web.StartSSOLink)
+
+		// Client account contacts: colleagues and reports limited to their own account
+		canManageColleagues := middleware.RequirePermission(rbac.AccountUsers)
+		accountGroup.GET("/users", canManageColleagues, web.ShowColleagues)
+		accountGroup.POST("/users", canManageColleagues, web.AddColleague)
+		accountGroup.GET("/reports", middleware.RequirePermission(rbac.AccountReports), web.ShowAccountReports)
+	}
 
 	// Group: Ticket WebUI - Protected
+	// Per-ticket access (own, account, assigned, or all) is checked in the handlers.
 	ticketGroup := r.Group("/tickets")
 	ticketGroup.Use(middleware.WebAuthMiddleware())
 	{
-		ticketGroup.GET("/create", web.ShowCreateTicketForm)
-		ticketGroup.POST("/create", web.HandleCreateTicket)
-		ticketGroup.GET("/mine", web.ListClientTickets)
-		ticketGroup.GET("/tech", web.ListTechTickets) // << ADD BACK
+		ticketGroup.GET("/create", middleware.RequirePermission(rbac.TicketsCreate), web.ShowCreateTicketForm)
+		ticketGroup.POST("/create", middleware.RequirePermission(rbac.TicketsCreate), web.HandleCreateTicket)
+		ticketGroup.GET("/mine", middleware.RequirePermission(rbac.TicketsViewOwn, rbac.TicketsViewAccount), web.ListClientTickets)
+		ticketGroup.GET("/tech", middleware.RequirePermission(rbac.TicketsViewAssigned), web.ListTechTickets)
+		ticketGroup.GET("/events", web.StreamTicketEvents)
 		ticketGroup.GET("/:id", web.ViewTicketPage)
 		ticketGroup.POST("/:id/comments", web.AddComment)
 		ticketGroup.POST("/:id/update-status", web.UpdateTicketStatus)
@@ -69,67 +108,134 @@ func SetupRouterWithEngine(r *gin.Engine) *gin.Engine {
 		commentGroup.POST("/:commentID/delete", web.DeleteComment)
 	}
 
-	// Admin
+	// Admin - Protected, every route requires a named permission
 	adminGroup := r.Group("/admin")
+	adminGroup.Use(middleware.WebAuthMiddleware())
 	{
-		adminGroup.GET("/tickets/:id/assign", controllers.FindMatchingTechs)
-		adminGroup.POST("/tickets/:id/assign/:tech_id", controllers.AssignTechToTicket)
-		adminGroup.GET("/assigned-tickets", web.ShowAssignedTickets)
-		adminGroup.POST("/tickets/:id/unassign", controllers.UnassignTechFromTicket)
-
-		adminGroup.GET("/clients", web.ListClients)
-		adminGroup.GET("/clients/new", web.NewClientForm)
-		adminGroup.POST("/clients", web.CreateClient)
-		adminGroup.GET("/clients/:id", web.ShowClient)
-		adminGroup.GET("/clients/:id/edit", web.ShowEditClientForm)
-		adminGroup.POST("/clients/:id", web.UpdateClient)
-		adminGroup.POST("/clients/:id/delete", web.DeleteClient)
-
-		adminGroup.GET("/techs", web.ListTechs)
-		adminGroup.GET("/techs/new", web.NewTechForm)
-		adminGroup.POST("/techs", web.CreateTech)
-		adminGroup.GET("/techs/:id", web.ShowTech)
-		adminGroup.GET("/techs/:id/edit", web.EditTechForm)
-		adminGroup.POST("/techs/:id", web.UpdateTech)
-		adminGroup.POST("/techs/:id/delete", web.DeleteTech)
-
-		adminGroup.GET("/accounts", web.ListAccounts)
-		adminGroup.POST("/accounts", web.CreateAccount)
-		adminGroup.GET("/accounts/:id/edit", web.EditAccountForm)
-		adminGroup.POST("/accounts/:id", web.UpdateAccount)
-		adminGroup.POST("/accounts/:id/delete", web.DeleteAccount)
-
-		adminGroup.GET("/reports", web.AdminReports)
-		adminGroup.GET("/reports/export", web.ExportReportCSV)
-		adminGroup.GET("/clients/export", web.ExportClientsCSV)
-		adminGroup.GET("/reports/audit/export", web.ExportAuditCSV)
+		canAssign := middleware.RequirePermission(rbac.TicketsAssign)
+		adminGroup.GET("/unassigned-tickets", canAssign, web.ShowUnassignedTickets)
+		adminGroup.GET("/tickets/:id/assign", canAssign, controllers.FindMatchingTechs)
+		adminGroup.POST("/tickets/:id/assign/:tech_id", canAssign, controllers.AssignTechToTicket)
+		adminGroup.GET("/assigned-tickets", canAssign, web.ShowAssignedTickets)
+		adminGroup.POST("/tickets/:id/unassign", canAssign, controllers.UnassignTechFromTicket)
+
+		canViewUsers := middleware.RequirePermission(rbac.UsersView)
+		canManageUsers := middleware.RequirePermission(rbac.UsersManage)
+		adminGroup.GET("/reset-password", canManageUsers, web.ShowAdminResetForm)
+		adminGroup.POST("/reset-password", canManageUsers, middleware.BlockWhileImpersonating(), web.HandleAdminResetPassword)
+		adminGroup.GET("/unlock", canManageUsers, web.ShowUnlockForm)
+		adminGroup.POST("/unlock", canManageUsers, web.HandleUnlockUser)
+		adminGroup.GET("/mfa", canManageUsers, web.ShowAdminMFA)
+		adminGroup.GET("/login-attempts", middleware.RequirePermission(rbac.LogsView), web.ShowFailedLogins)
+		adminGroup.POST("/mfa", canManageUsers, middleware.BlockWhileImpersonating(), web.HandleAdminMFA)
+
+		adminGroup.GET("/clients", canViewUsers, web.ListClients)
+		adminGroup.GET("/clients/new", canManageUsers, web.NewClientForm)
+		adminGroup.POST("/clients", canManageUsers, web.CreateClient)
+		adminGroup.GET("/clients/:id", canViewUsers, web.ShowClient)
+		adminGroup.GET("/clients/:id/edit", canManageUsers, web.ShowEditClientForm)
+		adminGroup.POST("/clients/:id", canManageUsers, web.UpdateClient)
+		adminGroup.POST("/clients/:id/delete", canManageUsers, web.DeleteClient)
+
+		adminGroup.GET("/techs", canViewUsers, web.ListTechs)
+		adminGroup.GET("/techs/new", canManageUsers, web.NewTechForm)
+		adminGroup.POST("/techs", canManageUsers, web.CreateTech)
+		adminGroup.GET("/techs/:id", canViewUsers, web.ShowTech)
+		adminGroup.GET("/techs/:id/edit", canManageUsers, web.EditTechForm)
+		adminGroup.POST("/techs/:id", canManageUsers, web.UpdateTech)
+		adminGroup.GET("/techs/:id/delete", canManageUsers, web.ConfirmDeleteTech)
+		adminGroup.POST("/techs/:id/delete", canManageUsers, web.DeleteTech)
+
+		canManageAccounts := middleware.RequirePermission(rbac.AccountsManage)
+		adminGroup.GET("/accounts", middleware.RequirePermission(rbac.AccountsView), web.ListAccounts)
+		adminGroup.POST("/accounts", canManageAccounts, web.CreateAccount)
+		adminGroup.GET("/accounts/:id/edit", canManageAccounts, web.EditAccountForm)
+		adminGroup.POST("/accounts/:id", canManageAccounts, web.UpdateAccount)
+		adminGroup.POST("/accounts/:id/delete", canManageAccounts, web.DeleteAccount)
+
+		canViewReports := middleware.RequirePermission(rbac.ReportsView)
+		adminGroup.GET("/reports", canViewReports, web.AdminReports)
+		adminGroup.GET("/reports/export", canViewReports, web.ExportReportCSV)
+		adminGroup.GET("/clients/export", canViewReports, web.ExportClientsCSV)
+		adminGroup.GET("/reports/audit/export", canViewReports, web.ExportAuditCSV)
+
+		canManageTrash := middleware.RequirePermission(rbac.TrashManage)
+		adminGroup.GET("/trash", canManageTrash, web.ShowTrash)
+		adminGroup.POST("/trash/purge", canManageTrash, web.PurgeTrash)
+		adminGroup.POST("/trash/:type/:id/restore", canManageTrash, web.RestoreTrashItem)
+
+		canManageJobs := middleware.RequirePermission(rbac.JobsManage)
+		adminGroup.GET("/jobs", canManageJobs, web.ShowJobs)
+		adminGroup.POST("/jobs", canManageJobs, web.TriggerJob)
+		adminGroup.POST("/jobs/:id/cancel", canManageJobs, web.CancelJob)
+
+		canManagePrivacy := middleware.RequirePermission(rbac.PrivacyManage)
+		adminGroup.GET("/users/:id/export", canManagePrivacy, web.ExportUserData)
+		adminGroup.POST("/users/:id/anonymize", canManagePrivacy, web.AnonymizeUser)
+		adminGroup.POST("/users/:id/impersonate", middleware.RequirePermission(rbac.UsersImpersonate), web.StartImpersonation)
+
+		canManageRoles := middleware.RequirePermission(rbac.RolesManage)
+		adminGroup.GET("/roles", canManageRoles, web.ShowRoles)
+		adminGroup.POST("/roles", canManageRoles, web.SaveRole)
+		adminGroup.POST("/roles/assign", canManageRoles, web.AssignUserRole)
+		adminGroup.POST("/roles/:name/delete", canManageRoles, web.DeleteRole)
+		adminGroup.POST("/roles/:name/password-login", canManageRoles, web.SetRolePasswordLogin)
+		adminGroup.POST("/roles/:name/networks", canManageRoles, web.SetRoleNetworks)
 	}
 
 	// REST API
 	r.POST("/api/login", controllers.LoginAPI)
+	r.POST("/api/login/mfa", controllers.LoginMFAAPI)
+	r.POST("/api/login/password", controllers.ChangeExpiredPasswordAPI)
 	r.POST("/api/register", controllers.RegisterAPI)
+	r.POST("/api/password/forgot", controllers.ForgotPasswordAPI)
+	r.POST("/api/password/reset", controllers.ResetPasswordAPI)
 
 	protected := r.Group("/api")
 	protected.Use(middleware.JWTAuthMiddleware())
 	{
 		protected.GET("/tickets", controllers.ListTicketsAPI)
 		protected.GET("/tickets/filter", controllers.FilterTicketsAPI)
-		protected.POST("/tickets", controllers.CreateTicketAPI)
+		protected.POST("/tickets", middleware.RequirePermission(rbac.TicketsCreate), controllers.CreateTicketAPI)
 		protected.GET("/tickets/:id", controllers.ViewTicketAPI)
 		protected.PATCH("/tickets/:id", controllers.UpdateTicketAPI)
-		protected.DELETE("/tickets/:id", controllers.DeleteTicketAPI)
-		protected.POST("/tickets/:id/assign", controllers.AssignTicketAPI)
+		protected.DELETE("/tickets/:id", middleware.RequirePermission(rbac.TicketsDelete), controllers.DeleteTicketAPI)
+		protected.POST("/tickets/:id/assign", middleware.RequirePermission(rbac.TicketsAssign), controllers.AssignTicketAPI)
 
-		protected.GET("/users", controllers.GetUsers)
-		protected.DELETE("/users/:id", controllers.DeleteUserAPI)
+		protected.GET("/users", middleware.RequirePermission(rbac.UsersView, rbac.AccountUsers), controllers.GetUsers)
+		protected.DELETE("/users/:id", middleware.RequirePermission(rbac.UsersManage), controllers.DeleteUserAPI)
+		protected.GET("/users/:id/export", middleware.RequirePermission(rbac.PrivacyManage), controllers.ExportUserDataAPI)
+		protected.POST("/users/:id/anonymize", middleware.RequirePermission(rbac.PrivacyManage), controllers.AnonymizeUserAPI)
 
 		// New Comment API Routes
-		protected.POST("/tickets/:id/comments", web.PostComment)
+		protected.POST("/tickets/:id/comments", middleware.RequirePermission(rbac.CommentsCreate), web.PostComment)
 		protected.GET("/tickets/:id/comments", web.GetCommentsByTicket)
 		protected.PUT("/comments/:id", web.PutComment)
 		protected.DELETE("/comments/:id", web.DeleteCommentAPI)
 	}
 
+	// SCIM 2.0 provisioning for identity providers, authenticated with RYANFORCE_SCIM_TOKEN
+	scimGroup := r.Group("/scim/v2")
+	scimGroup.Use(middleware.SCIMAuthMiddleware())
+	{
+		scimGroup.GET("/ServiceProviderConfig", controllers.SCIMServiceProviderConfig)
+		scimGroup.GET("/ResourceTypes", controllers.SCIMResourceTypes)
+
+		scimGroup.GET("/Users", controllers.SCIMListUsers)
+		scimGroup.POST("/Users", controllers.SCIMCreateUser)
+		scimGroup.GET("/Users/:id", controllers.SCIMGetUser)
+		scimGroup.PUT("/Users/:id", controllers.SCIMReplaceUser)
+		scimGroup.PATCH("/Users/:id", controllers.SCIMPatchUser)
+		scimGroup.DELETE("/Users/:id", controllers.SCIMDeleteUser)
+
+		scimGroup.GET("/Groups", controllers.SCIMListGroups)
+		scimGroup.POST("/Groups", controllers.SCIMCreateGroup)
+		scimGroup.GET("/Groups/:id", controllers.SCIMGetGroup)
+		scimGroup.PUT("/Groups/:id", controllers.SCIMReplaceGroup)
+		scimGroup.PATCH("/Groups/:id", controllers.SCIMPatchGroup)
+		scimGroup.DELETE("/Groups/:id", controllers.SCIMDeleteGroup)
+	}
+
 	// 404 fallback
 	r.NoRoute(func(c *gin.Context) {
 		c.HTML(http.StatusNotFound, "404.html", nil)
@@ -0,0 +1,213 @@
+package scim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Comparison is one "attribute operator value" expression of a filter. Attr is lower-cased
+// and stripped of any schema URN prefix. Value is empty for "pr".
+type Comparison struct {
+	Attr  string
+	Op    string // eq, ne, co, sw, ew, pr
+	Value string
+}
+
+// Filter is a list of comparisons that must all match. Only "and" is supported between them,
+// which covers what identity providers send (e.g. userName eq "x" or externalId eq "y").
+type Filter []Comparison
+
+// ParseFilter parses a filter such as `userName eq "ann@acme.com" and active eq true`.
+// An empty string is an empty filter that matches everything.
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter Filter
+	for len(tokens) > 0 {
+		if strings.Contains(tokens[0].text, "[") && !tokens[0].quoted {
+			// A value filter such as members[value eq "12"] is one comparison on a sub-attribute.
+			path, err := ParsePath(tokens[0].text)
+			if err != nil || path.Sub != "" {
+				return nil, fmt.Errorf("unsupported value filter %q", tokens[0].text)
+			}
+			filter = append(filter, Comparison{Attr: path.Attr + "." + path.Filter.Attr, Op: path.Filter.Op, Value: path.Filter.Value})
+			tokens = tokens[1:]
+		} else {
+			c, rest, err := parseComparison(tokens)
+			if err != nil {
+				return nil, err
+			}
+			filter = append(filter, c)
+			tokens = rest
+		}
+
+		if len(tokens) > 0 {
+			if strings.ToLower(tokens[0].text) != "and" || tokens[0].quoted {
+				return nil, fmt.Errorf("only 'and' is supported between filter expressions")
+			}
+			tokens = tokens[1:]
+			if len(tokens) == 0 {
+				return nil, fmt.Errorf("filter ends with 'and'")
+			}
+		}
+	}
+	return filter, nil
+}
+
+// parseComparison reads one "attribute operator [value]" expression from the front of tokens.
+func parseComparison(tokens []token) (Comparison, []token, error) {
+	if len(tokens) < 2 {
+		return Comparison{}, nil, fmt.Errorf("incomplete filter expression")
+	}
+	c := Comparison{Attr: NormalizeAttr(tokens[0].text), Op: strings.ToLower(tokens[1].text)}
+	tokens = tokens[2:]
+	if strings.ContainsAny(c.Attr, "[]()") {
+		return Comparison{}, nil, fmt.Errorf("complex filter on %s is not supported", c.Attr)
+	}
+
+	switch c.Op {
+	case "pr":
+	case "eq", "ne", "co", "sw", "ew":
+		if len(tokens) == 0 {
+			return Comparison{}, nil, fmt.Errorf("missing value for %s", c.Attr)
+		}
+		c.Value = tokens[0].text
+		if !tokens[0].quoted && tokens[0].text == "null" {
+			c.Value = ""
+		}
+		tokens = tokens[1:]
+	default:
+		return Comparison{}, nil, fmt.Errorf("unsupported filter operator %q", c.Op)
+	}
+	return c, tokens, nil
+}
+
+// Matches reports whether value satisfies the comparison. String comparisons ignore case,
+// as every attribute this API filters on is case-insensitive.
+func (c Comparison) Matches(value string, present bool) bool {
+	v, want := strings.ToLower(value), strings.ToLower(c.Value)
+	switch c.Op {
+	case "pr":
+		return present && value != ""
+	case "eq":
+		return v == want
+	case "ne":
+		return v != want
+	case "co":
+		return strings.Contains(v, want)
+	case "sw":
+		return strings.HasPrefix(v, want)
+	case "ew":
+		return strings.HasSuffix(v, want)
+	}
+	return false
+}
+
+// Path is a parsed PATCH path such as `members[value eq "12"]` or `name.givenName`.
+type Path struct {
+	Attr   string      // Lower-cased top-level attribute
+	Filter *Comparison // Value filter inside brackets, if any
+	Sub    string      // Lower-cased sub-attribute, if any
+}
+
+// ParsePath parses a PATCH operation path.
+func ParsePath(s string) (Path, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Path{}, nil
+	}
+
+	var p Path
+	if open := strings.Index(s, "["); open >= 0 {
+		end := strings.LastIndex(s, "]")
+		if end < open {
+			return Path{}, fmt.Errorf("unbalanced brackets in path %q", s)
+		}
+		filter, err := ParseFilter(s[open+1 : end])
+		if err != nil || len(filter) != 1 {
+			return Path{}, fmt.Errorf("unsupported value filter in path %q", s)
+		}
+		p.Filter = &filter[0]
+		if rest := s[end+1:]; strings.HasPrefix(rest, ".") {
+			p.Sub = strings.ToLower(rest[1:])
+		} else if rest != "" {
+			return Path{}, fmt.Errorf("invalid path %q", s)
+		}
+		p.Attr = NormalizeAttr(s[:open])
+		return p, nil
+	}
+
+	attr := NormalizeAttr(s)
+	if dot := strings.Index(attr, "."); dot >= 0 {
+		p.Attr, p.Sub = attr[:dot], attr[dot+1:]
+	} else {
+		p.Attr = attr
+	}
+	return p, nil
+}
+
+// NormalizeAttr lower-cases an attribute name and removes a core schema URN prefix, so
+// "urn:ietf:params:scim:schemas:core:2.0:User:userName" becomes "username".
+func NormalizeAttr(attr string) string {
+	for _, schema := range []string{UserSchema, GroupSchema} {
+		if len(attr) > len(schema) && strings.EqualFold(attr[:len(schema)+1], schema+":") {
+			attr = attr[len(schema)+1:]
+			break
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(attr))
+}
+
+// token is a word or quoted string from a filter.
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits a filter into words and JSON-quoted strings. Brackets stay attached to the
+// word they appear in so unsupported complex filters can be reported.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == ' ' || s[i] == '\t':
+			i++
+		case s[i] == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			text, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string in filter: %w", err)
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			depth := 0
+			for end < len(s) && (depth > 0 || (s[end] != ' ' && s[end] != '\t')) {
+				switch s[end] {
+				case '[':
+					depth++
+				case ']':
+					depth--
+				}
+				end++
+			}
+			tokens = append(tokens, token{text: s[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,51 @@
+package scim
+
+import "testing"
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter(`userName eq "Ann@Acme.com" and urn:ietf:params:scim:schemas:core:2.0:User:active eq true`)
+	if err != nil {
+		t.Fatalf("ParseFilter: %v", err)
+	}
+	if len(filter) != 2 || filter[0] != (Comparison{"username", "eq", "Ann@Acme.com"}) || filter[1] != (Comparison{"active", "eq", "true"}) {
+		t.Fatalf("unexpected filter: %+v", filter)
+	}
+	if !filter[0].Matches("ann@acme.com", true) {
+		t.Error("eq should ignore case")
+	}
+
+	filter, err = ParseFilter(`id eq "role-tech" and members[value eq "12"]`)
+	if err != nil || len(filter) != 2 || filter[1] != (Comparison{"members.value", "eq", "12"}) {
+		t.Fatalf("member filter: %+v, %v", filter, err)
+	}
+
+	for _, bad := range []string{`userName`, `userName eq`, `userName gt "a"`, `a eq "b" or c eq "d"`, `userName eq "open`, `a eq "b" and`} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Errorf("ParseFilter(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		in   string
+		attr string
+		sub  string
+		flt  *Comparison
+	}{
+		{"active", "active", "", nil},
+		{"name.givenName", "name", "givenname", nil},
+		{"urn:ietf:params:scim:schemas:core:2.0:User:userName", "username", "", nil},
+		{`members[value eq "7"]`, "members", "", &Comparison{"value", "eq", "7"}},
+		{`emails[type eq "work"].value`, "emails", "value", &Comparison{"type", "eq", "work"}},
+	}
+	for _, tc := range cases {
+		p, err := ParsePath(tc.in)
+		if err != nil {
+			t.Fatalf("ParsePath(%q): %v", tc.in, err)
+		}
+		if p.Attr != tc.attr || p.Sub != tc.sub || (p.Filter == nil) != (tc.flt == nil) || (p.Filter != nil && *p.Filter != *tc.flt) {
+			t.Errorf("ParsePath(%q) = %+v", tc.in, p)
+		}
+	}
+}
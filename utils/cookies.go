@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"RyanForce/config"
+	"github.com/gin-gonic/gin"
+)
+
+// SetCookie sets an HttpOnly cookie on the whole site using the configured Secure, SameSite,
+// and Domain attributes. A negative maxAge deletes the cookie; zero makes it a browser-session cookie.
+func SetCookie(c *gin.Context, name, value string, maxAge int) {
+	settings := config.LoadCookieSettings()
+	c.SetSameSite(settings.SameSite)
+	c.SetCookie(name, value, maxAge, "/", settings.Domain, settings.Secure, true)
+}
+
+// ClearCookie deletes a cookie set by SetCookie.
+func ClearCookie(c *gin.Context, name string) {
+	SetCookie(c, name, "", -1)
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"RyanForce/config"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"sync"
+)
+
+// CSRFCookie holds the random per-browser-session value that CSRF tokens are derived from.
+const CSRFCookie = "csrf_session"
+
+var (
+	csrfKeyOnce sync.Once
+	csrfKey     []byte
+)
+
+// NewCSRFSession returns a fresh random value for the CSRF session cookie.
+func NewCSRFSession() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CSRFToken derives the form token for a CSRF session. Because the token is an HMAC of the
+// cookie, a cookie planted by another site or subdomain cannot be paired with a forged token.
+func CSRFToken(session string) string {
+	mac := hmac.New(sha256.New, csrfSigningKey())
+	mac.Write([]byte(session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// csrfSigningKey returns RYANFORCE_CSRF_KEY, or a random key generated at startup. Set the
+// variable when running several instances so tokens are accepted by all of them.
+func csrfSigningKey() []byte {
+	csrfKeyOnce.Do(func() {
+		if key := config.GetEnv("RYANFORCE_CSRF_KEY", ""); key != "" {
+			csrfKey = []byte(key)
+			return
+		}
+		csrfKey = make([]byte, 32)
+		if _, err := rand.Read(csrfKey); err != nil {
+			LogError("[CSRF] Failed to generate signing key", err)
+		}
+	})
+	return csrfKey
+}
@@ -4,7 +4,6 @@ import (
 	"github.com/manifoldco/promptui"
 	"golang.org/x/crypto/bcrypt"
 	"strings"
-	"unicode"
 )
 
 // PromptSelect displays a CLI selection menu with a label and options list.
@@ -31,31 +30,6 @@ func IndexOf(target string, options []string) int {
 	return 0
 }
 
-// IsValidPassword checks if a password meets strength requirements:
-// 8–32 characters, 1 uppercase, 1 digit, 1 special character
-func IsValidPassword(pw string) bool {
-	if len(pw) < 8 || len(pw) > 32 {
-		return false
-	}
-
-	hasUpper := false
-	hasDigit := false
-	hasSpecial := false
-
-	for _, r := range pw {
-		switch {
-		case unicode.IsUpper(r):
-			hasUpper = true
-		case unicode.IsDigit(r):
-			hasDigit = true
-		case unicode.IsPunct(r) || unicode.IsSymbol(r):
-			hasSpecial = true
-		}
-	}
-
-	return hasUpper && hasDigit && hasSpecial
-}
-
 // HashPassword generates a secure bcrypt hash of the provided plaintext password.
 // This hash is what's stored in the database, never the raw password.
 func HashPassword(password string) (string, error) {
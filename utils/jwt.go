@@ -1,25 +1,34 @@
 package utils
 
 import (
+	"RyanForce/config"
+	"RyanForce/keyring"
+	"RyanForce/models"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
-	"strings"
+	"strconv"
 	"time"
 )
 
-// jwtKey is used to sign and verify JWT tokens.
-var jwtKey = []byte("your-secret-key")
-
 // Claims defines the structure stored inside the JWT.
-// Includes the user's ID, email, role, and standard JWT expiration metadata.
+// Includes the user's ID, email, role, and standard JWT expiration metadata. While an admin
+// impersonates a user, UserID, Email, and Role are the effective user's and the Impersonator
+// fields identify the admin really making the requests.
 type Claims struct {
-	UserID uint
-	Email  string
-	Role   string
+	UserID            uint
+	Email             string
+	Role              string
+	ImpersonatorID    uint   `json:",omitempty"`
+	ImpersonatorEmail string `json:",omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Impersonating reports whether the token was issued to an admin acting as another user.
+func (c *Claims) Impersonating() bool {
+	return c.ImpersonatorID != 0
+}
+
 // GenerateJWT creates a signed JWT token using the user's ID, email, and role.
 // Tokens are valid for 24 hours from the time of creation.
 func GenerateJWT(userID uint, email, role string) (string, error) {
@@ -28,33 +37,211 @@ func GenerateJWT(userID uint, email, role string) (string, error) {
 		Email:  email,
 		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return keyring.Sign(claims)
+}
+
+// GenerateImpersonationJWT creates a session token that acts as the given user on behalf of
+// an impersonating admin. It expires after ttl rather than the usual 24 hours.
+func GenerateImpersonationJWT(userID uint, email, role string, impersonatorID uint, impersonatorEmail string, ttl time.Duration) (string, error) {
+	claims := &Claims{
+		UserID:            userID,
+		Email:             email,
+		Role:              role,
+		ImpersonatorID:    impersonatorID,
+		ImpersonatorEmail: impersonatorEmail,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+	}
+
+	return keyring.Sign(claims)
 }
 
 // ParseJWT validates the given JWT token string and extracts the custom Claims.
 // Returns an error if the token is invalid, expired, or improperly signed.
 func ParseJWT(tokenStr string) (*Claims, error) {
 	claims := &Claims{}
-	tokenStr = strings.TrimSpace(tokenStr)
+	if err := keyring.Parse(tokenStr, claims); err != nil {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	if sessionRevoked(claims, claims.UserID) {
+		return nil, errors.New("session has been revoked")
+	}
+	if claims.Impersonating() && sessionRevoked(claims, claims.ImpersonatorID) {
+		return nil, errors.New("session has been revoked")
+	}
 
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtKey, nil
-	})
+	return claims, nil
+}
 
-	if err != nil || !token.Valid {
-		if err != nil {
-			fmt.Println("ParseJWT error:", err)
-			return nil, errors.New("invalid or expired token")
-		}
+// sessionRevoked reports whether the sessions of userID (the token's user, or the admin
+// impersonating them) were revoked (e.g. by a password reset or role change) after this token was
+// issued. The sessions of a user who no longer exists, or was deleted, are revoked too.
+func sessionRevoked(claims *Claims, userID uint) bool {
+	if config.DB == nil {
+		return false
 	}
 
+	var user models.User
+	if err := config.DB.Select("id", "sessions_revoked_at").Where("id = ?", userID).Limit(1).Find(&user).Error; err != nil {
+		return false
+	}
+	if user.ID == 0 {
+		return true
+	}
+	if user.SessionsRevokedAt == nil {
+		return false
+	}
+	return claims.IssuedAt == nil || claims.IssuedAt.Time.Before(user.SessionsRevokedAt.Truncate(time.Second))
+}
+
+// Login challenge purposes name the keys the tokens between the password step and the second
+// step are signed with. They are derived from, but differ from, the session signing key so a
+// challenge can never be accepted as a session token, and from each other so a challenge only
+// completes the step it was issued for: an MFA challenge needs an authenticator or recovery code,
+// a step-up challenge needs the emailed code, and only an enrollment challenge lets a user who
+// has no session yet enroll an authenticator.
+const (
+	mfaChallengePurpose    = "mfa-challenge"
+	stepUpChallengePurpose = "step-up-challenge"
+	mfaEnrollmentPurpose   = "mfa-enrollment"
+)
+
+// mfaChallengeTTL is how long a user has to enter their TOTP code after their password is accepted.
+const mfaChallengeTTL = 5 * time.Minute
+
+// GenerateMFAChallenge creates a short-lived token proving the user passed the password step.
+// It is exchanged for a session token once a valid TOTP or recovery code is supplied.
+func GenerateMFAChallenge(userID uint) (string, error) {
+	return generateChallenge(mfaChallengePurpose, userID)
+}
+
+// ParseMFAChallenge validates a challenge token and returns the user ID it was issued for.
+func ParseMFAChallenge(tokenStr string) (uint, error) {
+	return parseChallenge(mfaChallengePurpose, tokenStr)
+}
+
+// GenerateStepUpChallenge creates a short-lived token proving the user passed the password step
+// from a network that needs step-up verification. It is completed by the emailed code.
+func GenerateStepUpChallenge(userID uint) (string, error) {
+	return generateChallenge(stepUpChallengePurpose, userID)
+}
+
+// ParseStepUpChallenge validates a step-up challenge and returns the user ID it was issued for.
+func ParseStepUpChallenge(tokenStr string) (uint, error) {
+	return parseChallenge(stepUpChallengePurpose, tokenStr)
+}
+
+// GenerateMFAEnrollmentChallenge creates a short-lived token letting a user whose admin requires
+// MFA, and who passed every other login step, enroll an authenticator before a session is issued.
+func GenerateMFAEnrollmentChallenge(userID uint) (string, error) {
+	return generateChallenge(mfaEnrollmentPurpose, userID)
+}
+
+// ParseMFAEnrollmentChallenge validates an enrollment challenge and returns its user ID.
+func ParseMFAEnrollmentChallenge(tokenStr string) (uint, error) {
+	return parseChallenge(mfaEnrollmentPurpose, tokenStr)
+}
+
+// generateChallenge signs a login challenge for the user with the purpose's key.
+func generateChallenge(purpose string, userID uint) (string, error) {
+	claims := &jwt.RegisteredClaims{
+		Subject:   fmt.Sprint(userID),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaChallengeTTL)),
+	}
+	return keyring.SignPurpose(purpose, claims)
+}
+
+// parseChallenge verifies a login challenge signed for purpose and returns its user ID.
+func parseChallenge(purpose, tokenStr string) (uint, error) {
+	claims := &jwt.RegisteredClaims{}
+	if err := keyring.ParsePurpose(purpose, tokenStr, claims); err != nil {
+		return 0, errors.New("invalid or expired MFA challenge")
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid MFA challenge subject")
+	}
+	return uint(userID), nil
+}
+
+// passwordChangePurpose names the key password change tokens are signed with. It differs from
+// mfaChallengePurpose so an MFA challenge, issued before the second factor is checked, cannot be
+// used to change a password.
+const passwordChangePurpose = "password-change"
+
+// passwordChangeTTL is how long a user whose password expired has to choose a new one.
+const passwordChangeTTL = 10 * time.Minute
+
+// GeneratePasswordChangeToken creates a short-lived token proving the user completed every
+// login step but must change their expired password before a session is issued.
+func GeneratePasswordChangeToken(userID uint) (string, error) {
+	claims := &jwt.RegisteredClaims{
+		Subject:   fmt.Sprint(userID),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(passwordChangeTTL)),
+	}
+	return keyring.SignPurpose(passwordChangePurpose, claims)
+}
+
+// ParsePasswordChangeToken validates a password change token and returns its user ID.
+func ParsePasswordChangeToken(tokenStr string) (uint, error) {
+	claims := &jwt.RegisteredClaims{}
+	if err := keyring.ParsePurpose(passwordChangePurpose, tokenStr, claims); err != nil {
+		return 0, errors.New("invalid or expired password change token")
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid password change token subject")
+	}
+	return uint(userID), nil
+}
+
+// oidcStatePurpose names the key that signs the SSO state cookie kept between the redirect to
+// the identity provider and the callback.
+const oidcStatePurpose = "oidc-state"
+
+// oidcStateTTL is how long a user has to finish signing in at the identity provider.
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCState holds the values an SSO callback is checked against: the state parameter, the ID
+// token nonce, and the PKCE code verifier.
+type OIDCState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	LinkUser uint   `json:"link_user,omitempty"` // Signed-in user linking their account, or zero for a login
+	jwt.RegisteredClaims
+}
+
+// GenerateOIDCState signs the SSO state for the login cookie. linkUser is the signed-in user
+// linking their account to the identity provider, or zero for an ordinary login.
+func GenerateOIDCState(state, nonce, verifier string, linkUser uint) (string, error) {
+	claims := &OIDCState{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		LinkUser: linkUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcStateTTL)),
+		},
+	}
+	return keyring.SignPurpose(oidcStatePurpose, claims)
+}
+
+// ParseOIDCState validates the SSO state cookie.
+func ParseOIDCState(tokenStr string) (*OIDCState, error) {
+	claims := &OIDCState{}
+	if err := keyring.ParsePurpose(oidcStatePurpose, tokenStr, claims); err != nil {
+		return nil, errors.New("invalid or expired sign-in state")
+	}
 	return claims, nil
 }
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// logTimestampLayout matches the date and time written by log.Ldate|log.Ltime.
+const logTimestampLayout = "2006/01/02 15:04:05"
+
+// PruneLogFile removes lines older than cutoff from a log file written by this package.
+// Lines whose timestamp can't be read (stack traces, wrapped output) are kept.
+// With dryRun set the file is left untouched and only the count is returned.
+func PruneLogFile(path string, cutoff time.Time, dryRun bool) (int, error) {
+	return rewriteLogFile(path, dryRun, func(line string) (string, bool) {
+		ts, ok := parseLogTimestamp(line)
+		return line, !ok || !ts.Before(cutoff)
+	})
+}
+
+// ScrubLogFile replaces every whole occurrence of each key in replacements (an email or IP
+// address, say) with its value, and returns how many lines changed. A key inside a longer
+// address doesn't match: scrubbing 10.0.0.1 leaves 10.0.0.12 alone.
+func ScrubLogFile(path string, replacements map[string]string) (int, error) {
+	terms := make([]string, 0, len(replacements))
+	for term := range replacements {
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	// Longest first, so a term that contains another is replaced whole
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+
+	return rewriteLogFile(path, false, func(line string) (string, bool) {
+		for _, term := range terms {
+			line = replaceWhole(line, term, replacements[term])
+		}
+		return line, true
+	})
+}
+
+// rewriteLogFile passes every line of a log file written by this package through edit, which
+// returns the line to write and whether to keep it, and returns how many lines were changed
+// or dropped. With dryRun set the file is left untouched.
+//
+// The file is rewritten in place while this process's loggers wait, so no line they write is
+// lost, and other processes appending to it keep writing to the same file.
+func rewriteLogFile(path string, dryRun bool, edit func(line string) (string, bool)) (int, error) {
+	unlock := lockLogFile(path)
+	defer unlock()
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var kept []string
+	changed := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		edited, keep := edit(line)
+		if !keep || edited != line {
+			changed++
+		}
+		if keep {
+			kept = append(kept, edited)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if dryRun || changed == 0 {
+		return changed, nil
+	}
+
+	var content bytes.Buffer
+	for _, line := range kept {
+		content.WriteString(line + "\n")
+	}
+	if err := file.Truncate(0); err != nil {
+		return 0, err
+	}
+	if _, err := file.WriteAt(content.Bytes(), 0); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// replaceWhole replaces the occurrences of term in line that aren't part of a longer word or
+// address.
+func replaceWhole(line, term, with string) string {
+	var b strings.Builder
+	start := 0
+	for from := 0; ; {
+		i := strings.Index(line[from:], term)
+		if i < 0 {
+			break
+		}
+		i += from
+		end := i + len(term)
+		if continuesToken(line, i-1, -1) || continuesToken(line, end, 1) {
+			from = i + 1
+			continue
+		}
+		b.WriteString(line[start:i])
+		b.WriteString(with)
+		start, from = end, end
+	}
+	b.WriteString(line[start:])
+	return b.String()
+}
+
+// continuesToken reports whether the byte at i, next to a match, carries on the word, email or
+// IP address the match is in. step is the direction away from the match. A '.' or ':' only
+// does when another letter or digit follows it, so a sentence's full stop still ends a match.
+func continuesToken(line string, i, step int) bool {
+	if i < 0 || i >= len(line) {
+		return false
+	}
+	switch c := line[i]; {
+	case isAlnum(c) || c == '@' || c == '_' || c == '-' || c == '+':
+		return true
+	case c == '.' || c == ':':
+		next := i + step
+		return next >= 0 && next < len(line) && isAlnum(line[next])
+	}
+	return false
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseLogTimestamp reads the timestamp that follows the "LEVEL: " prefix of a log line.
+func parseLogTimestamp(line string) (time.Time, bool) {
+	idx := strings.Index(line, ": ")
+	if idx < 0 {
+		return time.Time{}, false
+	}
+	rest := line[idx+2:]
+	if len(rest) < len(logTimestampLayout) {
+		return time.Time{}, false
+	}
+	ts, err := time.ParseInLocation(logTimestampLayout, rest[:len(logTimestampLayout)], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
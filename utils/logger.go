@@ -5,15 +5,72 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sync"
 )
 
 var (
 	infoLogger  *log.Logger
 	warnLogger  *log.Logger
 	errorLogger *log.Logger
+	auditLogger *log.Logger
 )
 
-// InitLogger initializes three separate log files for info, warning, and error logs.
+// AuditLogPath is the audit trail file shown on the admin reports page.
+const AuditLogPath = "logs/audit.log"
+
+// logFile is a log file this process appends to. Writes take its lock, so PruneLogFile can
+// rewrite the file without a line being written in between and lost.
+type logFile struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (f *logFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Write(p)
+}
+
+// openLogs holds the open log files by absolute path.
+var openLogs = struct {
+	sync.Mutex
+	files map[string]*logFile
+}{files: map[string]*logFile{}}
+
+// openLogFile opens a log file for appending and records it for lockLogFile.
+func openLogFile(path string) (*logFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	f := &logFile{file: file}
+	if abs, err := filepath.Abs(path); err == nil {
+		openLogs.Lock()
+		openLogs.files[abs] = f
+		openLogs.Unlock()
+	}
+	return f, nil
+}
+
+// lockLogFile holds back this process's writes to the log file at path until the returned
+// function is called. Files this process has not opened need no lock.
+func lockLogFile(path string) func() {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return func() {}
+	}
+	openLogs.Lock()
+	f := openLogs.files[abs]
+	openLogs.Unlock()
+	if f == nil {
+		return func() {}
+	}
+	f.mu.Lock()
+	return f.mu.Unlock
+}
+
+// InitLogger initializes separate log files for info, warning, error, and audit logs.
 func InitLogger(toConsole bool) {
 	err := os.MkdirAll("logs", os.ModePerm)
 	if err != nil {
@@ -21,27 +78,34 @@ func InitLogger(toConsole bool) {
 		return
 	}
 
-	infoFile, err := os.OpenFile("logs/info.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	infoFile, err := openLogFile("logs/info.log")
 	if err != nil {
 		fmt.Println("[Logger] Failed to open info.log:", err)
 		return
 	}
 
-	warnFile, err := os.OpenFile("logs/warn.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	warnFile, err := openLogFile("logs/warn.log")
 	if err != nil {
 		fmt.Println("[Logger] Failed to open warn.log:", err)
 		return
 	}
 
-	errorFile, err := os.OpenFile("logs/error.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	errorFile, err := openLogFile("logs/error.log")
 	if err != nil {
 		fmt.Println("[Logger] Failed to open error.log:", err)
 		return
 	}
 
+	auditFile, err := openLogFile(AuditLogPath)
+	if err != nil {
+		fmt.Println("[Logger] Failed to open audit.log:", err)
+		return
+	}
+
 	infoLogger = log.New(infoFile, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
 	warnLogger = log.New(warnFile, "WARN: ", log.Ldate|log.Ltime|log.Lshortfile)
 	errorLogger = log.New(errorFile, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+	auditLogger = log.New(auditFile, "AUDIT: ", log.Ldate|log.Ltime)
 
 	// Optional: send logs to stdout for debugging purposes
 	if toConsole {
@@ -69,6 +133,15 @@ func LogError(message string, err error) {
 	}
 }
 
+// LogAudit records a security- or compliance-relevant event in the audit trail.
+// Audit entries are also copied to the info log so they show up alongside normal activity.
+func LogAudit(message string) {
+	if auditLogger != nil {
+		_ = auditLogger.Output(2, message)
+	}
+	LogInfo("[Audit] " + message)
+}
+
 // New — Log Info with IP Address
 func LogInfoIP(message string, ip string) {
 	formatted := formatIPMessage(message, ip)
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"RyanForce/keyring"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -11,14 +12,18 @@ import (
 	"path/filepath"
 )
 
-// secretKey is used for AES encryption/decryption of session tokens.
-// Must be exactly 32 bytes to support AES-256 encryption.
-var secretKey = []byte("32-byte-supersecret-key!!!!!!!!!")
+// sessionPurpose names the AES-256 key, derived from the current signing key (see keyring),
+// that CLI session files are encrypted with.
+const sessionPurpose = "cli-session"
 
 // sessionFile is the local file where the encrypted session token is saved.
 // Stored in the system's temporary directory.
 var sessionFile = filepath.Join(os.TempDir(), ".ryanforce_session")
 
+// impersonatorSessionFile keeps the admin's own encrypted session while they impersonate
+// another user, so it can be restored when they stop.
+var impersonatorSessionFile = filepath.Join(os.TempDir(), ".ryanforce_session_impersonator")
+
 // ErrSessionExpired is returned when a session file is missing, corrupted, or expired.
 var ErrSessionExpired = errors.New("session expired")
 
@@ -65,18 +70,40 @@ func LoadSession() (string, error) {
 	return decrypted, nil
 }
 
-// ClearSession deletes the stored session file from disk.
-// Used to log out the current user.
+// ClearSession deletes the stored session file from disk, along with any admin session set
+// aside for impersonation. Used to log out the current user.
 func ClearSession() error {
-	err := os.Remove(sessionFile)
-	if err != nil && !os.IsNotExist(err) {
-		LogError("[Session] Failed to delete session file", err)
-		return err
+	for _, path := range []string{sessionFile, impersonatorSessionFile} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			LogError("[Session] Failed to delete session file", err)
+			return err
+		}
 	}
 	LogInfo("[Session] Session cleared.")
 	return nil
 }
 
+// StartImpersonationSession sets the current session aside and saves the impersonation token
+// in its place.
+func StartImpersonationSession(token string) error {
+	if err := os.Rename(sessionFile, impersonatorSessionFile); err != nil {
+		LogError("[Session] Failed to set aside session for impersonation", err)
+		return err
+	}
+	return SaveSession(token)
+}
+
+// EndImpersonationSession restores the session set aside by StartImpersonationSession and
+// returns its token. If that session has expired meanwhile, both are cleared.
+func EndImpersonationSession() (string, error) {
+	if err := os.Rename(impersonatorSessionFile, sessionFile); err != nil {
+		LogWarning("[Session] No session set aside for impersonation, clearing session.")
+		ClearSession()
+		return "", ErrSessionExpired
+	}
+	return LoadSession()
+}
+
 // LoadClaims loads and validates the session token and returns JWT claims.
 // If session is missing or expired, it prints a friendly message and returns nil.
 func LoadClaims() (*Claims, error) {
@@ -100,7 +127,11 @@ func LoadClaims() (*Claims, error) {
 
 // encrypt takes a plaintext string and returns an AES-encrypted base64-encoded string.
 func encrypt(plaintext string) (string, error) {
-	block, err := aes.NewCipher(secretKey)
+	key, err := keyring.Current()
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key.Derive(sessionPurpose))
 	if err != nil {
 		return "", err
 	}
@@ -117,12 +148,27 @@ func encrypt(plaintext string) (string, error) {
 }
 
 // decrypt takes a base64-encoded AES-encrypted string and returns the original plaintext.
+// Sessions saved before a key rotation are decrypted with the key they were saved under.
 func decrypt(encoded string) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
 		return "", err
 	}
-	block, err := aes.NewCipher(secretKey)
+	keys, err := keyring.Keys()
+	if err != nil {
+		return "", err
+	}
+	for _, key := range keys {
+		if plaintext, err := decryptWith(key.Derive(sessionPurpose), data); err == nil {
+			return plaintext, nil
+		}
+	}
+	return "", errors.New("session was not encrypted with a current key")
+}
+
+// decryptWith opens AES-GCM ciphertext with one key.
+func decryptWith(secret, data []byte) (string, error) {
+	block, err := aes.NewCipher(secret)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"RyanForce/controllers"
+	"RyanForce/utils"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShowColleagues handles GET /account/users
+// Lists the users in the account contact's own client account with a form to add another.
+func ShowColleagues(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+	users, err := controllers.ListColleagues(claims.UserID)
+	if err != nil {
+		c.HTML(http.StatusForbidden, "403.html", gin.H{"message": err.Error()})
+		return
+	}
+
+	c.HTML(http.StatusOK, "account_users.html", gin.H{
+		"users":   users,
+		"self":    claims.UserID,
+		"success": c.Query("success"),
+		"error":   c.Query("error"),
+	})
+}
+
+// AddColleague handles POST /account/users
+// Creates a client user in the account contact's own client account.
+func AddColleague(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+	user, err := controllers.AddColleague(claims.UserID, c.PostForm("Email"), c.PostForm("Name"), c.PostForm("Password"))
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/account/users?error="+url.QueryEscape(err.Error()))
+		return
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[WebUI] User %d added colleague %s", claims.UserID, user.Email), c.ClientIP())
+	c.Redirect(http.StatusSeeOther, "/account/users?success="+url.QueryEscape("Added "+user.Email))
+}
+
+// ShowKnownLogins handles GET /account/logins
+// Lists the networks the signed-in user has signed in from.
+func ShowKnownLogins(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+	logins, err := controllers.KnownLogins(claims.UserID)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "account_logins.html", gin.H{"error": err.Error()})
+		return
+	}
+	c.HTML(http.StatusOK, "account_logins.html", gin.H{
+		"logins":    logins,
+		"ssoLinked": controllers.SSOLinked(claims.UserID),
+		"error":     c.Query("error"),
+	})
+}
+
+// ShowAccountReports handles GET /account/reports
+// Shows ticket counts and overdue tickets for the account contact's own client account.
+func ShowAccountReports(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+	report, err := controllers.BuildAccountReport(claims.UserID, claims.Role)
+	if err != nil {
+		c.HTML(http.StatusForbidden, "403.html", gin.H{"message": err.Error()})
+		return
+	}
+
+	c.HTML(http.StatusOK, "account_reports.html", gin.H{"report": report})
+}
@@ -2,10 +2,14 @@ package web
 
 import (
 	"RyanForce/config"
+	"RyanForce/controllers"
 	"RyanForce/models"
+	"RyanForce/netpolicy"
 	"RyanForce/utils"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
 func ShowUnassignedTickets(c *gin.Context) {
@@ -15,10 +19,10 @@ func ShowUnassignedTickets(c *gin.Context) {
 		return
 	}
 
-	success := c.Query("success")
 	c.HTML(http.StatusOK, "admin_unassigned.html", gin.H{
 		"tickets": tickets,
-		"success": success,
+		"success": c.Query("success"),
+		"error":   c.Query("error"),
 	})
 }
 
@@ -93,12 +97,23 @@ func UpdateAccount(c *gin.Context) {
 	account.Domain = c.PostForm("Domain")
 	account.Address = c.PostForm("Address")
 	account.Notes = c.PostForm("Notes")
+	networks := c.PostForm("AllowedNetworks")
+	if _, err := netpolicy.Normalize(networks); err != nil {
+		account.AllowedNetworks = networks
+		c.HTML(http.StatusBadRequest, "admin_account_edit.html", gin.H{"account": account, "error": err.Error()})
+		return
+	}
 
 	if err := config.DB.Save(&account).Error; err != nil {
 		utils.LogError("[Admin] Failed to update account", err)
 		c.String(http.StatusInternalServerError, "Failed to update account")
 		return
 	}
+	claims := c.MustGet("user").(*utils.Claims)
+	if err := controllers.SetAccountAllowedNetworks(&account, networks, claims.UserID); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	c.Redirect(http.StatusFound, "/admin/accounts")
 }
@@ -116,28 +131,22 @@ func ListAccounts(c *gin.Context) {
 
 	c.HTML(http.StatusOK, "admin_accounts.html", gin.H{
 		"accounts": accounts,
+		"error":    c.Query("error"),
 	})
 }
 
+// DeleteAccount handles POST /admin/accounts/:id/delete
+// Moves an account to the trash if no users are assigned to it.
 func DeleteAccount(c *gin.Context) {
-	accountID := c.Param("id")
-
-	// Check if any users are still assigned
-	var count int64
-	if err := config.DB.Model(&models.User{}).Where("account_id = ?", accountID).Count(&count).Error; err != nil {
-		utils.LogError("[Admin] Failed account-user check", err)
-		c.String(http.StatusInternalServerError, "Failed to check account")
-		return
-	}
-
-	if count > 0 {
-		c.String(http.StatusForbidden, "Cannot delete account: %d user(s) are still assigned.", count)
+	accountID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusNotFound, "Account not found")
 		return
 	}
 
-	if err := config.DB.Delete(&models.Account{}, accountID).Error; err != nil {
-		utils.LogError("[Admin] Failed to delete account", err)
-		c.String(http.StatusInternalServerError, "Failed to delete account")
+	claims := c.MustGet("user").(*utils.Claims)
+	if err := controllers.DeleteAccount(uint(accountID), claims.UserID, c.ClientIP()); err != nil {
+		c.Redirect(http.StatusFound, "/admin/accounts?error="+url.QueryEscape(err.Error()))
 		return
 	}
 
@@ -152,9 +161,9 @@ func ShowAssignedTickets(c *gin.Context) {
 		return
 	}
 
-	success := c.Query("success")
 	c.HTML(http.StatusOK, "admin_assigned.html", gin.H{
 		"tickets": tickets,
-		"success": success,
+		"success": c.Query("success"),
+		"error":   c.Query("error"),
 	})
 }
@@ -2,22 +2,28 @@ package web
 
 import (
 	"RyanForce/config"
+	"RyanForce/controllers"
 	"RyanForce/models"
+	"RyanForce/rbac"
 	"RyanForce/utils"
 	"encoding/csv"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
 	"strconv"
 )
 
+// clientRoles are the roles listed as clients: ordinary client users and account contacts.
+var clientRoles = []string{rbac.RoleClient, rbac.RoleAccountAdmin}
+
 // ListClients handles GET /admin/clients
 // Displays all clients to the admin.
 func ListClients(c *gin.Context) {
 	accountFilter := c.Query("account")
 
 	var clients []models.User
-	db := config.DB.Preload("Account").Where("role = ?", "client")
+	db := config.DB.Preload("Account").Where("role IN ?", clientRoles)
 
 	var selectedAccountID uint
 	if accountFilter != "" {
@@ -39,6 +45,8 @@ func ListClients(c *gin.Context) {
 		"clients":           clients,
 		"accounts":          accounts,
 		"selectedAccountID": selectedAccountID,
+		"success":           c.Query("success"),
+		"error":             c.Query("error"),
 	})
 }
 
@@ -74,20 +82,8 @@ func CreateClient(c *gin.Context) {
 		}
 	}
 
-	password := c.PostForm("Password")
-	if !utils.IsValidPassword(password) {
-		c.String(http.StatusBadRequest, "Password does not meet security requirements")
-		return
-	}
-	hash, err := utils.HashPassword(password)
-	if err != nil {
-		c.String(http.StatusInternalServerError, "Password hashing failed")
-		return
-	}
-	user.PasswordHash = hash
-
-	if err := config.DB.Create(&user).Error; err != nil {
-		c.String(http.StatusInternalServerError, "Error creating client")
+	if err := controllers.CreateUser(&user, c.PostForm("Password"), "admin"); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -140,33 +136,40 @@ func UpdateClient(c *gin.Context) {
 
 	if err := config.DB.Save(&client).Error; err != nil {
 		utils.LogError("[AdminClient] Failed to update client", err)
-		c.SetCookie("flash", "Failed to update client", 3, "/", "", false, true)
+		utils.SetCookie(c, "flash", "Failed to update client", 3)
 		c.Redirect(http.StatusSeeOther, "/admin/clients")
 		return
 	}
 
 	utils.LogInfo(fmt.Sprintf("[AdminClient] Client %d updated successfully", client.ID))
-	c.SetCookie("flash", "Client updated successfully", 3, "/", "", false, true)
+	utils.SetCookie(c, "flash", "Client updated successfully", 3)
 	c.Redirect(http.StatusSeeOther, "/admin/clients")
 }
 
 // DeleteClient handles POST /admin/clients/:id/delete
-// Deletes the specified client from the system.
+// Deletes the specified client from the system. Clients with open tickets are kept until those
+// tickets are closed.
 func DeleteClient(c *gin.Context) {
-	id := c.Param("id")
-	var client models.User
-	if err := config.DB.Delete(&client, id).Error; err != nil {
-		c.String(http.StatusInternalServerError, "Error deleting client")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusNotFound, "Client not found")
 		return
 	}
-	c.Redirect(http.StatusFound, "/admin/clients")
+
+	claims := c.MustGet("user").(*utils.Claims)
+	deletion, err := controllers.DeleteUser(uint(id), 0, claims.UserID, c.ClientIP())
+	if err != nil {
+		c.Redirect(http.StatusFound, "/admin/clients?error="+url.QueryEscape(err.Error()))
+		return
+	}
+	c.Redirect(http.StatusFound, "/admin/clients?success="+url.QueryEscape("Client "+deletion.User.Email+" deleted"))
 }
 
 func ExportClientsCSV(c *gin.Context) {
 	accountFilter := c.Query("account")
 
 	var clients []models.User
-	db := config.DB.Preload("Account").Where("role = ?", "client")
+	db := config.DB.Preload("Account").Where("role IN ?", clientRoles)
 
 	if accountFilter != "" {
 		if id, err := strconv.ParseUint(accountFilter, 10, 64); err == nil {
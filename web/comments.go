@@ -1,8 +1,12 @@
 package web
 
 import (
+	"RyanForce/config"
 	"RyanForce/controllers"
+	"RyanForce/models"
+	"RyanForce/rbac"
 	"RyanForce/utils"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
@@ -32,6 +36,10 @@ func PostComment(c *gin.Context) {
 	}
 	userClaims := claims.(*utils.Claims)
 
+	if !canSeeTicket(c, userClaims, uint(ticketID)) {
+		return
+	}
+
 	err = controllers.AddCommentToTicket(uint(ticketID), input.Content, userClaims.UserID, userClaims.Email, c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add comment"})
@@ -50,6 +58,10 @@ func GetCommentsByTicket(c *gin.Context) {
 		return
 	}
 
+	if !canSeeTicket(c, c.MustGet("user").(*utils.Claims), uint(ticketID)) {
+		return
+	}
+
 	comments, err := controllers.GetCommentsForTicket(uint(ticketID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
@@ -70,13 +82,27 @@ func PutComment(c *gin.Context) {
 
 	var input struct {
 		Content string `json:"content" binding:"required"`
+		Version uint   `json:"version"` // Required; the edit is rejected if the comment has moved on
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	err = controllers.EditComment(uint(commentID), input.Content, c.ClientIP())
+	if !canModifyComment(c, uint(commentID)) {
+		return
+	}
+
+	err = controllers.EditComment(uint(commentID), input.Content, input.Version, c.ClientIP())
+	var conflict *controllers.ConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": conflict.Error(), "current": conflict.Current, "conflicts": conflict.Changes})
+		return
+	}
+	if errors.Is(err, controllers.ErrVersionRequired) {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
 		return
@@ -94,6 +120,10 @@ func DeleteCommentAPI(c *gin.Context) {
 		return
 	}
 
+	if !canModifyComment(c, uint(commentID)) {
+		return
+	}
+
 	err = controllers.DeleteComment(uint(commentID), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
@@ -102,3 +132,35 @@ func DeleteCommentAPI(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted"})
 }
+
+// canSeeTicket checks that the ticket exists and the user may view it,
+// writing a 404 or 403 response when they can't.
+func canSeeTicket(c *gin.Context, claims *utils.Claims, ticketID uint) bool {
+	var ticket models.Ticket
+	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+		return false
+	}
+	if !rbac.CanViewTicket(claims.UserID, claims.Role, &ticket) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized to view this ticket"})
+		return false
+	}
+	return true
+}
+
+// canModifyComment checks that the comment exists and the user wrote it or may moderate comments,
+// writing a 404 or 403 response when they can't.
+func canModifyComment(c *gin.Context, commentID uint) bool {
+	claims := c.MustGet("user").(*utils.Claims)
+
+	var comment models.Comment
+	if err := config.DB.First(&comment, commentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return false
+	}
+	if !rbac.CanModifyComment(claims.UserID, claims.Role, comment.AuthorID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only change your own comments"})
+		return false
+	}
+	return true
+}
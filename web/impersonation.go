@@ -0,0 +1,67 @@
+package web
+
+import (
+	"RyanForce/controllers"
+	"RyanForce/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// impersonatorCookie keeps the admin's own session token while they view the app as another
+// user, so it can be restored when they stop.
+const impersonatorCookie = "impersonator_token"
+
+// StartImpersonation handles POST /admin/users/:id/impersonate
+// Swaps the admin's session for a short-lived one acting as the user and opens their dashboard.
+func StartImpersonation(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	original, err := c.Cookie("token")
+	if err != nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	claims := c.MustGet("user").(*utils.Claims)
+	token, target, err := controllers.StartImpersonation(claims, uint(userID), c.ClientIP())
+	if err != nil {
+		utils.SetCookie(c, "flash", err.Error(), 3)
+		c.Redirect(http.StatusSeeOther, backToUserList(c))
+		return
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[WebUI] Admin %d viewing as user %d (%s)", claims.UserID, target.ID, target.Email), c.ClientIP())
+	utils.SetCookie(c, impersonatorCookie, original, 3600)
+	utils.SetCookie(c, "token", token, int(controllers.ImpersonationTTL().Seconds()))
+	c.Redirect(http.StatusSeeOther, "/dashboard")
+}
+
+// StopImpersonation handles POST /impersonation/stop
+// Restores the admin's own session. If it has expired meanwhile, the admin signs in again.
+func StopImpersonation(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+	if !claims.Impersonating() {
+		c.Redirect(http.StatusSeeOther, "/dashboard")
+		return
+	}
+	controllers.StopImpersonation(claims, c.ClientIP())
+
+	original, _ := c.Cookie(impersonatorCookie)
+	utils.ClearCookie(c, impersonatorCookie)
+	admin, err := utils.ParseJWT(original)
+	if err != nil || admin.UserID != claims.ImpersonatorID || admin.Impersonating() {
+		utils.ClearCookie(c, "token")
+		utils.SetCookie(c, "flash", "Your session expired. Please log in again.", 3)
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+
+	utils.SetCookie(c, "token", original, 3600)
+	c.Redirect(http.StatusSeeOther, "/dashboard")
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"RyanForce/jobs"
+	"RyanForce/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ShowJobs handles GET /admin/jobs
+// Lists the registered jobs with their schedules, and the most recent runs.
+func ShowJobs(c *gin.Context) {
+	recent, err := jobs.Recent(50)
+	if err != nil {
+		utils.LogError("[AdminJobs] Failed to load jobs", err)
+		c.String(http.StatusInternalServerError, "Failed to load jobs")
+		return
+	}
+
+	c.HTML(http.StatusOK, "admin_jobs.html", gin.H{
+		"schedules": jobs.Schedules(),
+		"jobs":      recent,
+		"success":   c.Query("success"),
+		"error":     c.Query("error"),
+	})
+}
+
+// TriggerJob handles POST /admin/jobs
+// Queues the job named in the form to run now.
+func TriggerJob(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+	job, err := jobs.Trigger(c.PostForm("name"), fmt.Sprintf("user %d", claims.UserID))
+	if errors.Is(err, jobs.ErrUnknownJob) {
+		c.Redirect(http.StatusSeeOther, "/admin/jobs?error=Unknown+job")
+		return
+	}
+	if err != nil {
+		utils.LogErrorIP("[AdminJobs] Failed to queue job", err, c.ClientIP())
+		c.Redirect(http.StatusSeeOther, "/admin/jobs?error=Failed+to+queue+job")
+		return
+	}
+
+	msg := fmt.Sprintf("Queued %s as job %d", job.Name, job.ID)
+	c.Redirect(http.StatusSeeOther, "/admin/jobs?success="+url.QueryEscape(msg))
+}
+
+// CancelJob handles POST /admin/jobs/:id/cancel
+// Cancels a queued job, or asks a running one to stop.
+func CancelJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/admin/jobs?error=Invalid+ID")
+		return
+	}
+
+	claims := c.MustGet("user").(*utils.Claims)
+	job, err := jobs.Cancel(uint(id), fmt.Sprintf("user %d", claims.UserID))
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		c.Redirect(http.StatusSeeOther, "/admin/jobs?error=Job+not+found")
+		return
+	case errors.Is(err, jobs.ErrJobFinished):
+		c.Redirect(http.StatusSeeOther, "/admin/jobs?error=Job+has+already+finished")
+		return
+	case err != nil:
+		utils.LogErrorIP(fmt.Sprintf("[AdminJobs] Failed to cancel job %d", id), err, c.ClientIP())
+		c.Redirect(http.StatusSeeOther, "/admin/jobs?error=Failed+to+cancel+job")
+		return
+	}
+
+	msg := fmt.Sprintf("Job %d cancelled", job.ID)
+	if job.Status == jobs.StatusRunning {
+		msg = fmt.Sprintf("Job %d will stop at its next check-in", job.ID)
+	}
+	c.Redirect(http.StatusSeeOther, "/admin/jobs?success="+url.QueryEscape(msg))
+}
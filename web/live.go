@@ -0,0 +1,247 @@
+package web
+
+import (
+	"RyanForce/config"
+	"RyanForce/events"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// liveBuffer is how many updates a stream may fall behind before it is closed; the browser
+	// reconnects and reloads what it shows.
+	liveBuffer = 64
+	// liveHeartbeat is how often an idle stream sends a comment, so proxies keep it open.
+	liveHeartbeat = 25 * time.Second
+	// liveRecheck is how often an idle stream checks that its session is still good.
+	liveRecheck = time.Minute
+	// liveSessionEnded is the event sent just before a stream is closed because its session was
+	// revoked or its user deactivated.
+	liveSessionEnded = "session.ended"
+)
+
+// liveUpdate is the message sent on a stream for one ticket or comment event.
+type liveUpdate struct {
+	Event     string `json:"event"`
+	TicketID  uint   `json:"ticket_id,omitempty"`
+	CommentID uint   `json:"comment_id,omitempty"`
+}
+
+// liveStream is one browser's open event stream.
+type liveStream struct {
+	claims *utils.Claims
+	token  string // Session token the stream was opened with
+	send   chan liveUpdate
+}
+
+// currentRole checks the stream's session again, as a new request would be: the token must not
+// have expired or been revoked, and the user must not have been deactivated. It returns the
+// user's role now, which may differ from the one in the token.
+func (s *liveStream) currentRole() (string, bool) {
+	if _, err := utils.ParseJWT(s.token); err != nil {
+		return "", false
+	}
+	var user models.User
+	if err := config.DB.Select("id", "role", "deactivated_at").Where("id = ?", s.claims.UserID).
+		Limit(1).Find(&user).Error; err != nil || user.ID == 0 || user.DeactivatedAt != nil {
+		return "", false
+	}
+	return user.Role, true
+}
+
+// live holds the open streams. Once closed, at shutdown, no new streams are accepted.
+var live = struct {
+	sync.Mutex
+	streams map[*liveStream]struct{}
+	closed  bool
+}{streams: map[*liveStream]struct{}{}}
+
+// StartLiveUpdates subscribes the event streams to the domain event bus. Call it once when the
+// WebUI starts.
+func StartLiveUpdates() {
+	events.SubscribeAll("live-updates", events.Async, broadcastLive)
+}
+
+// CloseLiveStreams ends every open stream and refuses new ones, so a graceful shutdown is not held
+// up waiting for them.
+func CloseLiveStreams() {
+	live.Lock()
+	defer live.Unlock()
+	live.closed = true
+	for s := range live.streams {
+		delete(live.streams, s)
+		close(s.send)
+	}
+}
+
+// StreamTicketEvents serves a Server-Sent Events stream of changes to the tickets, and their
+// comments, that the user may view. Each message is a small JSON object naming the event and the
+// ticket; the page then reloads the parts it shows. The stream ends when the session expires, is
+// revoked, or the user is deactivated.
+func StreamTicketEvents(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+	token, _ := c.Cookie("token")
+	stream := &liveStream{claims: claims, token: token, send: make(chan liveUpdate, liveBuffer)}
+	live.Lock()
+	if live.closed {
+		live.Unlock()
+		c.String(http.StatusServiceUnavailable, "Server is shutting down")
+		return
+	}
+	live.streams[stream] = struct{}{}
+	live.Unlock()
+	defer removeLiveStream(stream)
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		utils.LogWarningIP(fmt.Sprintf("[Live] Could not lift the write deadline for %s: %v", claims.Email, err), c.ClientIP())
+	}
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if !writeLive(c, "retry: 5000\n\n") {
+		return
+	}
+
+	var expired <-chan time.Time
+	if claims.ExpiresAt != nil {
+		timer := time.NewTimer(time.Until(claims.ExpiresAt.Time))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	recheck := time.NewTicker(liveRecheck)
+	defer recheck.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-expired:
+			return
+		case <-heartbeat.C:
+			if !writeLive(c, ": ping\n\n") {
+				return
+			}
+		case <-recheck.C:
+			if _, ok := stream.currentRole(); !ok {
+				writeLive(c, `data: {"event":"`+liveSessionEnded+`"}`+"\n\n")
+				return
+			}
+		case update, ok := <-stream.send:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(update)
+			if !writeLive(c, "data: "+string(data)+"\n\n") {
+				return
+			}
+		}
+	}
+}
+
+// writeLive writes and flushes part of a stream, reporting whether the browser is still there.
+func writeLive(c *gin.Context, s string) bool {
+	if _, err := c.Writer.WriteString(s); err != nil {
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
+
+// removeLiveStream forgets a stream whose handler has returned.
+func removeLiveStream(s *liveStream) {
+	live.Lock()
+	defer live.Unlock()
+	if _, ok := live.streams[s]; ok {
+		delete(live.streams, s)
+		close(s.send)
+	}
+}
+
+// broadcastLive sends a ticket or comment event to each stream whose user may view the ticket,
+// under the same rules as the ticket page and with the user's current role. A technician a ticket
+// was taken from is told too, so their list drops it. Streams whose session is no longer good are
+// told so and closed.
+func broadcastLive(ctx context.Context, e events.Event) error {
+	id := events.TicketID(e)
+	if id == 0 {
+		return nil
+	}
+	var ticket models.Ticket
+	if err := config.DB.Unscoped().Where("id = ?", id).Limit(1).Find(&ticket).Error; err != nil || ticket.ID == 0 {
+		return err // Already purged if not found; nobody can have it open
+	}
+	update := liveUpdate{Event: e.EventName(), TicketID: id}
+	var previousTech *uint
+	switch e := e.(type) {
+	case events.CommentAdded:
+		update.CommentID = e.CommentID
+	case events.CommentEdited:
+		update.CommentID = e.CommentID
+	case events.CommentDeleted:
+		update.CommentID = e.CommentID
+	case events.TicketAssigned:
+		previousTech = e.PreviousTechID
+	case events.TicketUnassigned:
+		previousTech = e.PreviousTechID
+	}
+
+	// Visibility can take a query, so check it without holding up streams opening and closing
+	live.Lock()
+	streams := make([]*liveStream, 0, len(live.streams))
+	for s := range live.streams {
+		streams = append(streams, s)
+	}
+	live.Unlock()
+	var visible, ended []*liveStream
+	for _, s := range streams {
+		role, ok := s.currentRole()
+		if !ok {
+			ended = append(ended, s)
+			continue
+		}
+		wasAssigned := previousTech != nil && *previousTech == s.claims.UserID
+		if wasAssigned || rbac.CanViewTicket(s.claims.UserID, role, &ticket) {
+			visible = append(visible, s)
+		}
+	}
+
+	live.Lock()
+	defer live.Unlock()
+	for _, s := range ended {
+		if _, open := live.streams[s]; open {
+			select {
+			case s.send <- liveUpdate{Event: liveSessionEnded}:
+			default:
+			}
+			delete(live.streams, s)
+			close(s.send)
+		}
+	}
+	for _, s := range visible {
+		if _, open := live.streams[s]; !open {
+			continue
+		}
+		select {
+		case s.send <- update:
+		default:
+			// Too far behind; closing makes the browser reconnect and catch up
+			delete(live.streams, s)
+			close(s.send)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"RyanForce/config"
+	"RyanForce/events"
+	"RyanForce/internal/testutil"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"context"
+	"testing"
+	"time"
+)
+
+// openStream registers a live stream for a new user with the role, as StreamTicketEvents would.
+func openStream(t *testing.T, email, role string) (*liveStream, models.User) {
+	t.Helper()
+	user := models.User{Email: email, Role: role}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create %s: %v", email, err)
+	}
+	token, err := utils.GenerateJWT(user.ID, user.Email, role)
+	if err != nil {
+		t.Fatalf("GenerateJWT failed: %v", err)
+	}
+	claims, err := utils.ParseJWT(token)
+	if err != nil {
+		t.Fatalf("ParseJWT failed: %v", err)
+	}
+	stream := &liveStream{claims: claims, token: token, send: make(chan liveUpdate, liveBuffer)}
+	live.Lock()
+	live.streams[stream] = struct{}{}
+	live.Unlock()
+	t.Cleanup(func() { removeLiveStream(stream) })
+	return stream, user
+}
+
+// received returns the updates waiting on a stream and whether it is still open.
+func received(s *liveStream) ([]liveUpdate, bool) {
+	var updates []liveUpdate
+	for {
+		select {
+		case update, ok := <-s.send:
+			if !ok {
+				return updates, false
+			}
+			updates = append(updates, update)
+		default:
+			return updates, true
+		}
+	}
+}
+
+func TestBroadcastLive(t *testing.T) {
+	testutil.ResetDB(t)
+	ownerStream, owner := openStream(t, "owner@live.test", rbac.RoleClient)
+	otherStream, _ := openStream(t, "other@live.test", rbac.RoleClient)
+	formerStream, former := openStream(t, "former@live.test", rbac.RoleTech)
+	revokedStream, revoked := openStream(t, "revoked@live.test", rbac.RoleAdmin)
+	adminStream, _ := openStream(t, "admin@live.test", rbac.RoleAdmin)
+
+	ticket := models.Ticket{Title: "Live", Status: "open", ClientID: owner.ID}
+	config.DB.Create(&ticket)
+	config.DB.Model(&revoked).Update("sessions_revoked_at", time.Now().Add(time.Minute))
+
+	// The ticket was just taken from the former technician, who is told so their list drops it
+	event := events.TicketUnassigned{TicketID: ticket.ID, PreviousTechID: &former.ID}
+	if err := broadcastLive(context.Background(), event); err != nil {
+		t.Fatalf("broadcastLive failed: %v", err)
+	}
+
+	want := liveUpdate{Event: events.NameTicketUnassigned, TicketID: ticket.ID}
+	for name, s := range map[string]*liveStream{"owner": ownerStream, "former tech": formerStream, "admin": adminStream} {
+		if updates, open := received(s); len(updates) != 1 || updates[0] != want || !open {
+			t.Fatalf("%s received %+v (open %t), want %+v", name, updates, open, want)
+		}
+	}
+	if updates, open := received(otherStream); len(updates) != 0 || !open {
+		t.Fatalf("another client received %+v (open %t)", updates, open)
+	}
+	updates, open := received(revokedStream)
+	if len(updates) != 1 || updates[0].Event != liveSessionEnded || open {
+		t.Fatalf("revoked session received %+v (open %t), want %s and the stream closed", updates, open, liveSessionEnded)
+	}
+
+	// A role change applies to the next update without reopening the stream
+	config.DB.Model(&models.User{}).Where("email = ?", "admin@live.test").Update("role", rbac.RoleClient)
+	broadcastLive(context.Background(), events.TicketUpdated{TicketID: ticket.ID})
+	if updates, _ := received(adminStream); len(updates) != 0 {
+		t.Fatalf("demoted admin received %+v", updates)
+	}
+}
+
+func TestCloseLiveStreams(t *testing.T) {
+	testutil.ResetDB(t)
+	stream, _ := openStream(t, "closing@live.test", rbac.RoleClient)
+	defer func() {
+		live.Lock()
+		live.closed = false
+		live.Unlock()
+	}()
+
+	CloseLiveStreams()
+	if _, open := received(stream); open {
+		t.Fatal("stream left open at shutdown")
+	}
+	live.Lock()
+	defer live.Unlock()
+	if !live.closed || len(live.streams) != 0 {
+		t.Fatalf("live streams after close: closed %t, %d open", live.closed, len(live.streams))
+	}
+}
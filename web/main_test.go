@@ -0,0 +1,13 @@
+package web
+
+import (
+	"RyanForce/internal/testutil"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("RYANFORCE_JWT_SECRET", "test-secret-test-secret-test-secret")
+	testutil.OpenDB()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,245 @@
+package web
+
+import (
+	"RyanForce/config"
+	"RyanForce/controllers"
+	"RyanForce/models"
+	"RyanForce/utils"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+	"html/template"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mfaChallengeCookie holds the MFA challenge between the password step and the code step.
+const mfaChallengeCookie = "mfa_challenge"
+
+// mfaChallengeMaxAge matches the lifetime of the challenge token itself (five minutes).
+const mfaChallengeMaxAge = 300
+
+// ShowMFAPrompt handles GET /login/mfa
+// Asks a user who passed the password step for their TOTP or recovery code, or for the code
+// emailed to them when a login from a new network needs step-up verification.
+func ShowMFAPrompt(c *gin.Context) {
+	challenge, err := c.Cookie(mfaChallengeCookie)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	c.HTML(http.StatusOK, "login_mfa.html", gin.H{"error": "", "emailed": controllers.StepUpPending(challenge)})
+}
+
+// HandleMFAPrompt handles POST /login/mfa
+func HandleMFAPrompt(c *gin.Context) {
+	challenge, err := c.Cookie(mfaChallengeCookie)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	ip := c.ClientIP()
+	token, err := controllers.CompleteMFALogin(challenge, c.PostForm("code"), ip, c.Request.UserAgent())
+	if errors.Is(err, controllers.ErrMFAEnrollmentRequired) {
+		utils.SetCookie(c, mfaChallengeCookie, token, mfaChallengeMaxAge)
+		c.Redirect(http.StatusSeeOther, "/login/mfa/enroll")
+		return
+	}
+	if startPasswordChange(c, token, err) {
+		return
+	}
+	if err != nil {
+		utils.LogWarning("[WebUI] MFA step failed from IP: " + ip)
+		c.HTML(http.StatusUnauthorized, "login_mfa.html", gin.H{"error": err.Error(), "emailed": controllers.StepUpPending(challenge)})
+		return
+	}
+
+	utils.ClearCookie(c, mfaChallengeCookie)
+	utils.ClearCookie(c, utils.CSRFCookie)
+	utils.SetCookie(c, "token", token, 3600)
+	c.Redirect(http.StatusFound, "/dashboard")
+}
+
+// ShowLoginEnrollment handles GET /login/mfa/enroll
+// Shown when an admin requires MFA for a user who has not enrolled yet.
+func ShowLoginEnrollment(c *gin.Context) {
+	challenge, err := c.Cookie(mfaChallengeCookie)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	userID, err := controllers.MFAEnrollmentUserID(challenge)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	key, err := controllers.BeginMFAEnrollment(userID)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "mfa_enroll.html", gin.H{"error": err.Error()})
+		return
+	}
+	c.HTML(http.StatusOK, "mfa_enroll.html", enrollmentData(key, "/login/mfa/enroll"))
+}
+
+// HandleLoginEnrollment handles POST /login/mfa/enroll
+// Confirms the new authenticator, shows the recovery codes, and signs the user in.
+func HandleLoginEnrollment(c *gin.Context) {
+	challenge, err := c.Cookie(mfaChallengeCookie)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	userID, err := controllers.MFAEnrollmentUserID(challenge)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	codes, err := controllers.ConfirmMFAEnrollment(userID, c.PostForm("code"))
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "mfa_enroll.html", gin.H{"error": err.Error() + ". Reload the page to start again."})
+		return
+	}
+
+	token, err := controllers.FinishEnrollmentLogin(challenge, c.ClientIP(), c.Request.UserAgent())
+	if errors.Is(err, controllers.ErrPasswordChangeRequired) {
+		utils.ClearCookie(c, mfaChallengeCookie)
+		utils.SetCookie(c, passwordChangeCookie, token, passwordChangeMaxAge)
+		c.HTML(http.StatusOK, "mfa_enroll.html", gin.H{"codes": codes, "next": "/login/password"})
+		return
+	}
+	if err != nil {
+		c.HTML(http.StatusUnauthorized, "login.html", gin.H{"error": err.Error()})
+		return
+	}
+
+	utils.ClearCookie(c, mfaChallengeCookie)
+	utils.ClearCookie(c, utils.CSRFCookie)
+	utils.SetCookie(c, "token", token, 3600)
+	c.HTML(http.StatusOK, "mfa_enroll.html", gin.H{"codes": codes, "next": "/dashboard"})
+}
+
+// ShowAccountMFA handles GET /account/mfa
+// Shows the signed-in user's MFA status and lets them enroll or disable it.
+func ShowAccountMFA(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+
+	var user models.User
+	if err := config.DB.First(&user, claims.UserID).Error; err != nil {
+		c.HTML(http.StatusNotFound, "404.html", nil)
+		return
+	}
+	if user.MFAEnabled {
+		c.HTML(http.StatusOK, "mfa_enroll.html", gin.H{
+			"enabled":  true,
+			"required": user.MFARequired,
+			"success":  c.Query("success"),
+			"error":    c.Query("error"),
+		})
+		return
+	}
+
+	key, err := controllers.BeginMFAEnrollment(user.ID)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "mfa_enroll.html", gin.H{"error": err.Error()})
+		return
+	}
+	data := enrollmentData(key, "/account/mfa/enroll")
+	data["success"] = c.Query("success")
+	c.HTML(http.StatusOK, "mfa_enroll.html", data)
+}
+
+// HandleAccountEnrollment handles POST /account/mfa/enroll
+func HandleAccountEnrollment(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+
+	codes, err := controllers.ConfirmMFAEnrollment(claims.UserID, c.PostForm("code"))
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "mfa_enroll.html", gin.H{"error": err.Error() + ". Reload the page to start again."})
+		return
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[WebUI] User %d enrolled in MFA", claims.UserID), c.ClientIP())
+	c.HTML(http.StatusOK, "mfa_enroll.html", gin.H{"codes": codes, "next": "/account/mfa"})
+}
+
+// HandleAccountDisableMFA handles POST /account/mfa/disable
+func HandleAccountDisableMFA(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+
+	if err := controllers.DisableMFA(claims.UserID, c.PostForm("code")); err != nil {
+		c.Redirect(http.StatusSeeOther, "/account/mfa?error="+url.QueryEscape(err.Error()))
+		return
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[WebUI] User %d disabled MFA", claims.UserID), c.ClientIP())
+	c.Redirect(http.StatusSeeOther, "/account/mfa?success=MFA+disabled")
+}
+
+// ShowAdminMFA handles GET /admin/mfa
+func ShowAdminMFA(c *gin.Context) {
+	c.HTML(http.StatusOK, "admin_mfa.html", gin.H{"error": "", "success": ""})
+}
+
+// HandleAdminMFA handles POST /admin/mfa
+// Resets a user's MFA enrollment or changes whether MFA is required for them.
+func HandleAdminMFA(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+
+	email := strings.TrimSpace(c.PostForm("email"))
+	var user models.User
+	if err := config.DB.First(&user, "email = ?", email).Error; err != nil {
+		c.HTML(http.StatusBadRequest, "admin_mfa.html", gin.H{"error": "User not found"})
+		return
+	}
+
+	var err error
+	var msg string
+	switch c.PostForm("action") {
+	case "reset":
+		err = controllers.ResetMFA(claims.UserID, user.ID)
+		msg = "MFA reset for " + email + ". They can enroll again at their next login."
+	case "require":
+		err = controllers.SetMFARequired(claims.UserID, user.ID, true)
+		msg = "MFA is now required for " + email + "."
+	case "optional":
+		err = controllers.SetMFARequired(claims.UserID, user.ID, false)
+		msg = "MFA is now optional for " + email + "."
+	default:
+		c.HTML(http.StatusBadRequest, "admin_mfa.html", gin.H{"error": "Unknown action"})
+		return
+	}
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "admin_mfa.html", gin.H{"error": err.Error()})
+		return
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[AdminMFA] Admin %d ran %s for %s", claims.UserID, c.PostForm("action"), email), c.ClientIP())
+	c.HTML(http.StatusOK, "admin_mfa.html", gin.H{"success": msg})
+}
+
+// enrollmentData builds the template data for the enrollment form: a QR code as a data URI,
+// the secret for manual entry, and where to post the confirmation code.
+func enrollmentData(key *otp.Key, action string) gin.H {
+	data := gin.H{"secret": key.Secret(), "action": action}
+
+	img, err := key.Image(200, 200)
+	if err != nil {
+		utils.LogError("[MFA] Failed to render QR code", err)
+		return data
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		utils.LogError("[MFA] Failed to encode QR code", err)
+		return data
+	}
+	data["qr"] = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return data
+}
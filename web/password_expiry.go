@@ -0,0 +1,64 @@
+package web
+
+import (
+	"RyanForce/controllers"
+	"RyanForce/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// passwordChangeCookie holds the password change token while a user with an expired password
+// chooses a new one.
+const passwordChangeCookie = "password_change"
+
+// passwordChangeMaxAge matches the lifetime of the password change token (ten minutes).
+const passwordChangeMaxAge = 600
+
+// startPasswordChange sends a user whose password expired to the change form when err is
+// controllers.ErrPasswordChangeRequired, and reports whether it did.
+func startPasswordChange(c *gin.Context, token string, err error) bool {
+	if !errors.Is(err, controllers.ErrPasswordChangeRequired) {
+		return false
+	}
+	utils.ClearCookie(c, mfaChallengeCookie)
+	utils.SetCookie(c, passwordChangeCookie, token, passwordChangeMaxAge)
+	c.Redirect(http.StatusSeeOther, "/login/password")
+	return true
+}
+
+// ShowPasswordChange handles GET /login/password
+// Asks a user whose password has expired to choose a new one before their login completes.
+func ShowPasswordChange(c *gin.Context) {
+	if _, err := c.Cookie(passwordChangeCookie); err != nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	c.HTML(http.StatusOK, "login_password.html", gin.H{"error": ""})
+}
+
+// HandlePasswordChange handles POST /login/password
+func HandlePasswordChange(c *gin.Context) {
+	changeToken, err := c.Cookie(passwordChangeCookie)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	newPassword := c.PostForm("new_password")
+	if newPassword != c.PostForm("confirm_password") {
+		c.HTML(http.StatusBadRequest, "login_password.html", gin.H{"error": "Passwords do not match."})
+		return
+	}
+
+	token, err := controllers.ChangeExpiredPassword(changeToken, newPassword, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "login_password.html", gin.H{"error": err.Error()})
+		return
+	}
+
+	utils.ClearCookie(c, passwordChangeCookie)
+	utils.ClearCookie(c, utils.CSRFCookie)
+	utils.SetCookie(c, "token", token, 3600)
+	c.Redirect(http.StatusFound, "/dashboard")
+}
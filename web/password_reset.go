@@ -0,0 +1,63 @@
+package web
+
+import (
+	"RyanForce/controllers"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ShowForgotPassword renders the form for requesting a password reset link.
+func ShowForgotPassword(c *gin.Context) {
+	c.HTML(http.StatusOK, "forgot_password.html", gin.H{"error": ""})
+}
+
+// HandleForgotPassword emails a reset link. The same message is shown whether or not the
+// email belongs to an account.
+func HandleForgotPassword(c *gin.Context) {
+	email := c.PostForm("email")
+	if email == "" {
+		c.HTML(http.StatusBadRequest, "forgot_password.html", gin.H{"error": "Email is required."})
+		return
+	}
+
+	controllers.RequestPasswordReset(email, c.ClientIP())
+	c.HTML(http.StatusOK, "forgot_password.html", gin.H{"success": controllers.ForgotPasswordMessage})
+}
+
+// ShowPasswordResetLink renders the new-password form for an emailed reset link.
+func ShowPasswordResetLink(c *gin.Context) {
+	token := c.Query("token")
+	if !controllers.ValidateResetToken(token) {
+		c.HTML(http.StatusBadRequest, "password_reset_link.html", gin.H{
+			"invalid": true,
+			"error":   "This reset link is invalid or has expired.",
+		})
+		return
+	}
+	c.HTML(http.StatusOK, "password_reset_link.html", gin.H{"token": token})
+}
+
+// HandlePasswordResetLink sets the new password for an emailed reset link.
+func HandlePasswordResetLink(c *gin.Context) {
+	token := c.PostForm("token")
+	newPassword := c.PostForm("new_password")
+
+	if newPassword != c.PostForm("confirm_password") {
+		c.HTML(http.StatusBadRequest, "password_reset_link.html", gin.H{
+			"token": token,
+			"error": "Passwords do not match.",
+		})
+		return
+	}
+
+	if err := controllers.CompletePasswordReset(token, newPassword, c.ClientIP()); err != nil {
+		c.HTML(http.StatusBadRequest, "password_reset_link.html", gin.H{
+			"token":   token,
+			"invalid": !controllers.ValidateResetToken(token),
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "password_reset_link.html", gin.H{"done": true})
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"RyanForce/controllers"
+	"RyanForce/utils"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// ExportUserData handles GET /admin/users/:id/export
+// Downloads everything stored about a user as a ZIP of JSON files.
+func ExportUserData(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	data, err := controllers.ExportUserData(uint(userID))
+	if err != nil {
+		c.String(http.StatusNotFound, err.Error())
+		return
+	}
+
+	claims := c.MustGet("user").(*utils.Claims)
+	utils.LogInfoIP(fmt.Sprintf("[AdminPrivacy] Admin %d exported data for user %d", claims.UserID, userID), c.ClientIP())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment;filename=user_%d_export.zip", userID))
+	c.Data(http.StatusOK, "application/zip", data)
+}
+
+// AnonymizeUser handles POST /admin/users/:id/anonymize
+// Erases a user's personal data while keeping their ticket history.
+func AnonymizeUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	claims := c.MustGet("user").(*utils.Claims)
+	if err := controllers.AnonymizeUser(uint(userID), claims.UserID); err != nil {
+		utils.SetCookie(c, "flash", err.Error(), 3)
+		c.Redirect(http.StatusSeeOther, backToUserList(c))
+		return
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[AdminPrivacy] Admin %d erased user %d", claims.UserID, userID), c.ClientIP())
+	utils.SetCookie(c, "flash", "User data erased", 3)
+	c.Redirect(http.StatusSeeOther, backToUserList(c))
+}
+
+// backToUserList returns the admin list page the request came from, defaulting to clients.
+func backToUserList(c *gin.Context) string {
+	if c.PostForm("return") == "techs" {
+		return "/admin/techs"
+	}
+	return "/admin/clients"
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"RyanForce/config"
+	"RyanForce/models"
+	"RyanForce/rbac"
+	"RyanForce/utils"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ShowRoles handles GET /admin/roles
+// Lists built-in and custom roles with their permissions and a form for editing custom roles.
+func ShowRoles(c *gin.Context) {
+	c.HTML(http.StatusOK, "admin_roles.html", gin.H{
+		"roles":       rbac.ListRoles(),
+		"permissions": rbac.AllPermissions,
+		"roleNames":   rbac.RoleNames(),
+		"success":     c.Query("success"),
+		"error":       c.Query("error"),
+	})
+}
+
+// SaveRole handles POST /admin/roles
+// Creates a custom role or replaces the permissions of an existing one.
+func SaveRole(c *gin.Context) {
+	name := c.PostForm("name")
+	perms := make([]rbac.Permission, 0)
+	for _, p := range c.PostFormArray("permissions") {
+		perms = append(perms, rbac.Permission(p))
+	}
+
+	claims := c.MustGet("user").(*utils.Claims)
+	if err := rbac.SaveRole(name, c.PostForm("description"), perms, claims.UserID); err != nil {
+		c.Redirect(http.StatusSeeOther, "/admin/roles?error="+url.QueryEscape(err.Error()))
+		return
+	}
+
+	utils.LogInfoIP(fmt.Sprintf("[AdminRoles] User %d saved role %s", claims.UserID, name), c.ClientIP())
+	c.Redirect(http.StatusSeeOther, "/admin/roles?success=Role+saved")
+}
+
+// DeleteRole handles POST /admin/roles/:name/delete
+func DeleteRole(c *gin.Context) {
+	name := c.Param("name")
+	if err := rbac.DeleteRole(name); err != nil {
+		c.Redirect(http.StatusSeeOther, "/admin/roles?error="+url.QueryEscape(err.Error()))
+		return
+	}
+
+	claims := c.MustGet("user").(*utils.Claims)
+	utils.LogInfoIP(fmt.Sprintf("[AdminRoles] User %d deleted role %s", claims.UserID, name), c.ClientIP())
+	c.Redirect(http.StatusSeeOther, "/admin/roles?success=Role+deleted")
+}
+
+// AssignUserRole handles POST /admin/roles/assign
+// Gives the user with the submitted email the selected role.
+func AssignUserRole(c *gin.Context) {
+	email := strings.TrimSpace(c.PostForm("email"))
+	role := c.PostForm("role")
+
+	var user models.User
+	if err := config.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/admin/roles?error=User+not+found")
+		return
+	}
+
+	claims := c.MustGet("user").(*utils.Claims)
+	if err := rbac.SetUserRole(user.ID, role, claims.UserID); err != nil {
+		c.Redirect(http.StatusSeeOther, "/admin/roles?error="+url.QueryEscape(err.Error()))
+		return
+	}
+
+	msg := fmt.Sprintf("%s is now %s", email, role)
+	c.Redirect(http.StatusSeeOther, "/admin/roles?success="+url.QueryEscape(msg))
+}
+
+// SetRoleNetworks handles POST /admin/roles/:name/networks
+// Limits the IPs and CIDR ranges members of a role may sign in from; blank allows any.
+func SetRoleNetworks(c *gin.Context) {
+	name := c.Param("name")
+
+	claims := c.MustGet("user").(*utils.Claims)
+	if err := rbac.SetAllowedNetworks(name, c.PostForm("networks"), claims.UserID); err != nil {
+		c.Redirect(http.StatusSeeOther, "/admin/roles?error="+url.QueryEscape(err.Error()))
+		return
+	}
+
+	msg := fmt.Sprintf("Members of %s may now sign in from any network", name)
+	if networks := rbac.AllowedNetworks(name); networks != "" {
+		msg = fmt.Sprintf("Members of %s may now sign in only from %s", name, networks)
+	}
+	c.Redirect(http.StatusSeeOther, "/admin/roles?success="+url.QueryEscape(msg))
+}
+
+// SetRolePasswordLogin handles POST /admin/roles/:name/password-login
+// Allows or blocks password sign-in for members of a role.
+func SetRolePasswordLogin(c *gin.Context) {
+	name := c.Param("name")
+	allowed := c.PostForm("allowed") == "true"
+
+	claims := c.MustGet("user").(*utils.Claims)
+	if err := rbac.SetPasswordLogin(name, allowed, claims.UserID); err != nil {
+		c.Redirect(http.StatusSeeOther, "/admin/roles?error="+url.QueryEscape(err.Error()))
+		return
+	}
+
+	msg := fmt.Sprintf("Password login for %s is now off; members must use single sign-on", name)
+	if allowed {
+		msg = fmt.Sprintf("Password login for %s is now on", name)
+	}
+	c.Redirect(http.StatusSeeOther, "/admin/roles?success="+url.QueryEscape(msg))
+}
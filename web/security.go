@@ -0,0 +1,28 @@
+package web
+
+import (
+	"RyanForce/controllers"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// ShowFailedLogins handles GET /admin/login-attempts
+// Lists failed logins from the last 24 hours grouped by IP, and the accounts currently locked.
+func ShowFailedLogins(c *gin.Context) {
+	attempts, err := controllers.RecentFailedLogins(24 * time.Hour)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "admin_login_attempts.html", gin.H{"error": err.Error()})
+		return
+	}
+	locked, err := controllers.LockedUsers()
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "admin_login_attempts.html", gin.H{"error": err.Error()})
+		return
+	}
+
+	c.HTML(http.StatusOK, "admin_login_attempts.html", gin.H{
+		"attempts": attempts,
+		"locked":   locked,
+	})
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"RyanForce/controllers"
+	"RyanForce/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// oidcStateCookie holds the signed SSO state between the redirect to the identity provider and
+// the callback.
+const oidcStateCookie = "oidc_state"
+
+// oidcStateMaxAge matches the lifetime of the signed state (ten minutes).
+const oidcStateMaxAge = 600
+
+// StartSSOLogin handles GET /login/oidc
+// Redirects the browser to the identity provider.
+func StartSSOLogin(c *gin.Context) {
+	authURL, state, err := controllers.BeginSSOLogin(c.Request.Context())
+	if err != nil {
+		c.HTML(http.StatusServiceUnavailable, "login.html", gin.H{"error": err.Error()})
+		return
+	}
+
+	utils.SetCookie(c, oidcStateCookie, state, oidcStateMaxAge)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// StartSSOLink handles POST /account/sso/link
+// Sends the signed-in user to the identity provider to link their account to it.
+func StartSSOLink(c *gin.Context) {
+	claims := c.MustGet("user").(*utils.Claims)
+	authURL, state, err := controllers.BeginSSOLink(c.Request.Context(), claims.UserID)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/account/logins?error="+url.QueryEscape(err.Error()))
+		return
+	}
+
+	utils.SetCookie(c, oidcStateCookie, state, oidcStateMaxAge)
+	c.Redirect(http.StatusSeeOther, authURL)
+}
+
+// HandleSSOCallback handles GET /login/oidc/callback
+// Finishes the SSO login and signs the user in.
+func HandleSSOCallback(c *gin.Context) {
+	ip := c.ClientIP()
+	if errParam := c.Query("error"); errParam != "" {
+		utils.LogWarningIP("[WebUI] Identity provider returned error: "+errParam, ip)
+		utils.ClearCookie(c, oidcStateCookie)
+		c.HTML(http.StatusUnauthorized, "login.html", gin.H{"error": "Single sign-on was cancelled or refused."})
+		return
+	}
+
+	stateToken, _ := c.Cookie(oidcStateCookie)
+	utils.ClearCookie(c, oidcStateCookie)
+
+	token, err := controllers.CompleteSSOLogin(c.Request.Context(), stateToken, c.Query("state"), c.Query("code"), ip, c.Request.UserAgent())
+	var lockout *controllers.LockoutError
+	if errors.As(err, &lockout) {
+		c.HTML(http.StatusTooManyRequests, "login.html", gin.H{"error": "Too many failed attempts. Try again in " + lockout.RetryAfter.Round(time.Second).String() + "."})
+		return
+	}
+	if err != nil {
+		c.HTML(http.StatusUnauthorized, "login.html", gin.H{"error": err.Error()})
+		return
+	}
+
+	utils.ClearCookie(c, utils.CSRFCookie)
+	utils.SetCookie(c, "token", token, 3600)
+	c.Redirect(http.StatusFound, "/dashboard")
+}
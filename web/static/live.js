@@ -0,0 +1,86 @@
+// Live updates: keeps the parts of a page marked data-live current. The server streams an event
+// whenever a ticket the user can see changes; the page is then fetched again and each marked
+// region, matched by id, is swapped for its new version. A page for one ticket sets
+// data-live-ticket on its body and ignores events about other tickets.
+(function () {
+  if (!window.EventSource || !document.querySelector("[data-live]")) {
+    return;
+  }
+  const ticket = document.body.dataset.liveTicket;
+  const source = new EventSource("/tickets/events");
+  let timer = null;
+
+  source.onmessage = function (message) {
+    const update = JSON.parse(message.data);
+    if (update.event === "session.ended") {
+      source.close();
+      notice("Your session has ended. Sign in again to see the latest changes.");
+      return;
+    }
+    if (ticket && String(update.ticket_id) !== ticket) {
+      return;
+    }
+    // Changes often arrive together, e.g. a status change with a comment
+    clearTimeout(timer);
+    timer = setTimeout(refresh, 300);
+  };
+
+  // busy reports whether the user is working in a region, so swapping it would lose their input.
+  function busy(region) {
+    const active = document.activeElement;
+    if (active && active !== document.body && region.contains(active)) {
+      return true;
+    }
+    return Array.from(region.querySelectorAll(".edit-form")).some(function (form) {
+      return form.style.display !== "none";
+    });
+  }
+
+  // notice shows a message that stays until the page is reloaded.
+  function notice(text) {
+    let box = document.getElementById("live-notice");
+    if (!box) {
+      box = document.createElement("div");
+      box.id = "live-notice";
+      box.className = "live-notice";
+      const container = document.querySelector(".container") || document.body;
+      container.insertBefore(box, container.firstChild);
+    }
+    box.textContent = text;
+  }
+
+  async function refresh() {
+    let response;
+    try {
+      response = await fetch(window.location.href, { credentials: "same-origin", headers: { "Accept": "text/html" } });
+    } catch (err) {
+      return; // Offline for now; the next event tries again
+    }
+    if (response.redirected && new URL(response.url).pathname === "/login") {
+      source.close();
+      notice("Your session has ended. Sign in again to see the latest changes.");
+      return;
+    }
+    if (response.status === 403 || response.status === 404) {
+      source.close();
+      notice("This ticket has been deleted or you no longer have access to it.");
+      return;
+    }
+    if (!response.ok) {
+      return;
+    }
+
+    const fresh = new DOMParser().parseFromString(await response.text(), "text/html");
+    document.querySelectorAll("[data-live]").forEach(function (region) {
+      const replacement = fresh.getElementById(region.id);
+      if (!replacement) {
+        return;
+      }
+      if (busy(region)) {
+        notice("This page has changed. Reload it to see the latest version.");
+        return;
+      }
+      region.replaceWith(document.adoptNode(replacement));
+    });
+  }
+})();
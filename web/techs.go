@@ -2,12 +2,17 @@ package web
 
 import (
 	"RyanForce/config"
+	"RyanForce/controllers"
 	"RyanForce/models"
+	"RyanForce/rbac"
 	"RyanForce/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -24,8 +29,9 @@ func ListTechs(c *gin.Context) {
 		}
 	}
 
-	c.HTML(http.StatusOK, "tech_list.html", gin.H{
-		"techs": techs,
+	c.HTML(http.StatusOK, "admin_techs_list.html", gin.H{
+		"techs":   techs,
+		"success": c.Query("success"),
 	})
 }
 
@@ -57,26 +63,13 @@ func CreateTech(c *gin.Context) {
 	}
 	user.Skills = string(skillsJSON)
 
-	// Validate and hash password
-	password := c.PostForm("Password")
-	if !utils.IsValidPassword(password) {
-		c.String(http.StatusBadRequest, "Password does not meet security requirements")
-		return
-	}
-	hash, err := utils.HashPassword(password)
-	if err != nil {
-		c.String(http.StatusInternalServerError, "Failed to hash password")
-		return
-	}
-	user.PasswordHash = hash
-
-	if err := config.DB.Create(&user).Error; err != nil {
-		c.String(http.StatusInternalServerError, "Failed to create technician")
+	if err := controllers.CreateUser(&user, c.PostForm("Password"), "admin"); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
 	utils.LogInfo(fmt.Sprintf("[AdminTech] Technician %s created successfully", user.Email))
-	c.SetCookie("flash", "Technician created successfully", 3, "/", "", false, true)
+	utils.SetCookie(c, "flash", "Technician created successfully", 3)
 	c.Redirect(http.StatusSeeOther, "/admin/techs")
 }
 
@@ -137,18 +130,74 @@ func UpdateTech(c *gin.Context) {
 	}
 
 	utils.LogInfo(fmt.Sprintf("[AdminTech] Technician %s updated successfully", tech.Email))
-	c.SetCookie("flash", "Technician updated successfully", 3, "/", "", false, true)
+	utils.SetCookie(c, "flash", "Technician updated successfully", 3)
 	c.Redirect(http.StatusSeeOther, "/admin/techs")
 }
 
+// ConfirmDeleteTech handles GET /admin/techs/:id/delete
+// Lists the technician's open tickets and offers to reassign them before deleting the technician.
+func ConfirmDeleteTech(c *gin.Context) {
+	renderDeleteTech(c, http.StatusOK, "")
+}
+
 // DeleteTech handles POST /admin/techs/:id/delete
-// Deletes a technician record.
+// Deletes a technician, handing their open tickets to the technician chosen in reassign_to or
+// leaving them unassigned.
 func DeleteTech(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusNotFound, "Technician not found")
+		return
+	}
+	var reassignTo uint64
+	if raw := c.PostForm("reassign_to"); raw != "" {
+		if reassignTo, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			renderDeleteTech(c, http.StatusBadRequest, "Invalid technician ID")
+			return
+		}
+	}
+
+	claims := c.MustGet("user").(*utils.Claims)
+	deletion, err := controllers.DeleteUser(uint(id), uint(reassignTo), claims.UserID, c.ClientIP())
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, controllers.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		renderDeleteTech(c, status, err.Error())
+		return
+	}
+
+	msg := fmt.Sprintf("Technician %s deleted", deletion.User.Email)
+	if deletion.Reassigned > 0 {
+		msg += fmt.Sprintf("; %d open ticket(s) reassigned", deletion.Reassigned)
+	} else if deletion.Unassigned > 0 {
+		msg += fmt.Sprintf("; %d open ticket(s) are now unassigned", deletion.Unassigned)
+	}
+	c.Redirect(http.StatusFound, "/admin/techs?success="+url.QueryEscape(msg))
+}
+
+// renderDeleteTech shows the delete confirmation for the technician in the URL, with the other
+// active technicians their open tickets can be reassigned to.
+func renderDeleteTech(c *gin.Context, status int, errMsg string) {
 	var tech models.User
-	if err := config.DB.Delete(&tech, id).Error; err != nil {
-		c.String(http.StatusInternalServerError, "Error deleting technician")
+	if err := config.DB.Where("id = ? AND role = ?", c.Param("id"), rbac.RoleTech).Limit(1).Find(&tech).Error; err != nil || tech.ID == 0 {
+		c.String(http.StatusNotFound, "Technician not found")
+		return
+	}
+	tickets, err := controllers.OpenTicketsAssignedTo(tech.ID)
+	if err != nil {
+		utils.LogError("[AdminTech] Failed to load open tickets", err)
+		c.String(http.StatusInternalServerError, "Failed to load the technician's tickets")
 		return
 	}
-	c.Redirect(http.StatusFound, "/admin/techs")
+	var techs []models.User
+	config.DB.Where("role = ? AND id <> ? AND deactivated_at IS NULL", rbac.RoleTech, tech.ID).Order("email").Find(&techs)
+
+	c.HTML(status, "admin_tech_delete.html", gin.H{
+		"tech":    tech,
+		"tickets": tickets,
+		"techs":   techs,
+		"error":   errMsg,
+	})
 }
@@ -2,15 +2,16 @@ package web
 
 import (
 	"RyanForce/config"
+	"RyanForce/controllers"
 	"RyanForce/models"
+	"RyanForce/rbac"
 	"RyanForce/utils"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
 	"strconv"
-	"strings"
-	"time"
 )
 
 // DisplayComment defines a simplified comment for rendering.
@@ -25,18 +26,28 @@ func ShowCreateTicketForm(c *gin.Context) {
 	c.HTML(http.StatusOK, "create_ticket.html", nil)
 }
 
-// ListClientTickets shows tickets submitted by the currently logged-in client.
+// ListClientTickets shows the tickets the logged-in client may view: the ones they raised, or
+// every ticket in their account for account contacts.
 func ListClientTickets(c *gin.Context) {
 	claims := c.MustGet("user").(*utils.Claims)
 
+	query, ok := rbac.ScopeTickets(config.DB.Model(&models.Ticket{}), claims.UserID, claims.Role)
+	if !ok {
+		c.HTML(http.StatusForbidden, "403.html", nil)
+		return
+	}
+
 	var tickets []models.Ticket
-	if err := config.DB.Where("client_id = ?", claims.UserID).Find(&tickets).Error; err != nil {
+	if err := query.Preload("Client").Order("updated_at DESC").Find(&tickets).Error; err != nil {
 		utils.LogError("[WebUI] Ticket listing failed", err)
 		c.String(http.StatusInternalServerError, "Could not retrieve tickets")
 		return
 	}
 
-	c.HTML(http.StatusOK, "client_tickets.html", gin.H{"tickets": tickets})
+	c.HTML(http.StatusOK, "client_tickets.html", gin.H{
+		"tickets":     tickets,
+		"accountView": rbac.Can(claims.Role, rbac.TicketsViewAccount),
+	})
 }
 
 // ListTechTickets shows tickets assigned to the currently logged-in technician.
@@ -68,24 +79,11 @@ func ViewTicketPage(c *gin.Context) {
 
 	claims := c.MustGet("user").(*utils.Claims)
 
-	if claims.Role == "client" && ticket.ClientID != claims.UserID {
-		c.HTML(http.StatusForbidden, "403.html", nil)
-		return
-	}
-	if claims.Role == "tech" && (ticket.TechID == nil || *ticket.TechID != claims.UserID) {
+	if !rbac.CanViewTicket(claims.UserID, claims.Role, &ticket) {
 		c.HTML(http.StatusForbidden, "403.html", nil)
 		return
 	}
 
-	// NEW: Parse SkillsNeeded JSON into []string
-	var skills []string
-	if ticket.SkillsNeeded != "" {
-		if err := json.Unmarshal([]byte(ticket.SkillsNeeded), &skills); err != nil {
-			utils.LogWarning(fmt.Sprintf("[TicketWebUI] Failed to parse skillsNeeded for ticket %d", ticket.ID))
-			skills = []string{} // fallback safely
-		}
-	}
-
 	type DisplayCommentFull struct {
 		models.Comment
 		CanEdit  bool
@@ -94,7 +92,7 @@ func ViewTicketPage(c *gin.Context) {
 
 	var displayComments []DisplayCommentFull
 	for _, com := range comments {
-		canEdit := com.AuthorID == claims.UserID || claims.Role == "admin"
+		canEdit := rbac.CanModifyComment(claims.UserID, claims.Role, com.AuthorID)
 		displayComments = append(displayComments, DisplayCommentFull{
 			Comment:  com,
 			CanEdit:  canEdit,
@@ -103,16 +101,18 @@ func ViewTicketPage(c *gin.Context) {
 	}
 
 	flashMsg, _ := c.Cookie("flash")
-	c.SetCookie("flash", "", -1, "/", "", false, true)
+	utils.ClearCookie(c, "flash")
 
-	// UPDATED: Pass parsed skills slice into template
 	c.HTML(http.StatusOK, "ticket_view.html", gin.H{
-		"Ticket":   ticket,
-		"Skills":   skills, // <-- HERE
-		"Comments": displayComments,
-		"Flash":    flashMsg,
-		"UserID":   claims.UserID,
-		"UserRole": claims.Role,
+		"Ticket":    ticket,
+		"Skills":    parseTicketSkills(&ticket),
+		"Comments":  displayComments,
+		"Flash":     flashMsg,
+		"Error":     c.Query("error"),
+		"UserID":    claims.UserID,
+		"UserRole":  claims.Role,
+		"CanUpdate": rbac.CanUpdateTicket(claims.UserID, claims.Role, &ticket),
+		"Statuses":  controllers.TicketStatuses,
 	})
 }
 
@@ -134,19 +134,25 @@ func AddComment(c *gin.Context) {
 	}
 	claims := c.MustGet("user").(*utils.Claims)
 
-	comment := models.Comment{
-		TicketID:    ticketID,
-		AuthorID:    claims.UserID,
-		AuthorEmail: claims.Email,
-		Content:     content,
-		CreatedAt:   time.Now(),
+	var ticket models.Ticket
+	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
+		c.HTML(http.StatusNotFound, "404.html", nil)
+		return
+	}
+	if !rbac.Can(claims.Role, rbac.CommentsCreate) || !rbac.CanViewTicket(claims.UserID, claims.Role, &ticket) {
+		c.HTML(http.StatusForbidden, "403.html", nil)
+		return
 	}
 
-	_ = config.DB.Create(&comment)
+	if err := controllers.AddCommentToTicket(ticketID, content, claims.UserID, claims.Email, c.ClientIP()); err != nil {
+		c.Redirect(http.StatusSeeOther, "/tickets/"+idStr+"?error="+url.QueryEscape("Failed to add comment"))
+		return
+	}
 	c.Redirect(http.StatusSeeOther, "/tickets/"+idStr)
 }
 
-// UpdateComment edits an existing comment.
+// UpdateComment edits an existing comment. The form carries the version it was loaded at, so an
+// edit made after someone else changed the comment is shown as a conflict instead of saved.
 func UpdateComment(c *gin.Context) {
 	commentID := c.Param("commentID")
 	newContent := c.PostForm("content")
@@ -156,20 +162,30 @@ func UpdateComment(c *gin.Context) {
 		c.HTML(http.StatusNotFound, "404.html", nil)
 		return
 	}
+	ticketURL := "/tickets/" + strconv.Itoa(int(comment.TicketID))
 	if newContent == "" {
-		c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
+		c.Redirect(http.StatusSeeOther, ticketURL)
 		return
 	}
 	claims := c.MustGet("user").(*utils.Claims)
 
-	if comment.AuthorID != claims.UserID && claims.Role != "admin" {
-		c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
+	if !rbac.CanModifyComment(claims.UserID, claims.Role, comment.AuthorID) {
+		c.Redirect(http.StatusSeeOther, ticketURL)
 		return
 	}
 
-	comment.Content = newContent
-	_ = config.DB.Save(&comment)
-	c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
+	version, _ := strconv.ParseUint(c.PostForm("version"), 10, 64)
+	err := controllers.EditComment(comment.ID, newContent, uint(version), c.ClientIP())
+	var conflict *controllers.ConflictError
+	if errors.As(err, &conflict) {
+		c.Redirect(http.StatusSeeOther, ticketURL+"?error="+url.QueryEscape(conflict.Summary()))
+		return
+	}
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, ticketURL+"?error="+url.QueryEscape(err.Error()))
+		return
+	}
+	c.Redirect(http.StatusSeeOther, ticketURL)
 }
 
 // DeleteComment removes a comment.
@@ -183,13 +199,17 @@ func DeleteComment(c *gin.Context) {
 	}
 	claims := c.MustGet("user").(*utils.Claims)
 
-	if comment.AuthorID != claims.UserID && claims.Role != "admin" {
+	if !rbac.CanModifyComment(claims.UserID, claims.Role, comment.AuthorID) {
 		c.String(http.StatusForbidden, "Forbidden")
 		return
 	}
 
-	_ = config.DB.Delete(&comment)
-	c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
+	ticketURL := "/tickets/" + strconv.Itoa(int(comment.TicketID))
+	if err := controllers.DeleteComment(comment.ID, c.ClientIP()); err != nil {
+		c.Redirect(http.StatusSeeOther, ticketURL+"?error="+url.QueryEscape("Failed to delete comment"))
+		return
+	}
+	c.Redirect(http.StatusSeeOther, ticketURL)
 }
 
 // ShowEditCommentForm renders the form for editing an existing comment via WebUI.
@@ -205,7 +225,7 @@ func ShowEditCommentForm(c *gin.Context) {
 
 	claims := c.MustGet("user").(*utils.Claims)
 
-	if comment.AuthorID != claims.UserID && claims.Role != "admin" {
+	if !rbac.CanModifyComment(claims.UserID, claims.Role, comment.AuthorID) {
 		c.Redirect(http.StatusSeeOther, "/tickets/"+strconv.Itoa(int(comment.TicketID)))
 		return
 	}
@@ -218,13 +238,24 @@ func ShowEditCommentForm(c *gin.Context) {
 
 // ShowUpdateTicketForm renders a form for technicians to update a ticket.
 func ShowUpdateTicketForm(c *gin.Context) {
-	id := c.Param("id")
-	var ticket models.Ticket
-	if err := config.DB.First(&ticket, id).Error; err != nil {
-		c.String(http.StatusNotFound, "Ticket not found")
+	svc, actor := webTickets(c)
+	id, err := controllers.ParseTicketID(c.Param("id"))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "404.html", nil)
 		return
 	}
+	ticket, err := svc.GetForUpdate(actor, id)
+	if err != nil {
+		renderTicketError(c, err)
+		return
+	}
+
+	renderUpdateTicketForm(c, http.StatusOK, ticket, gin.H{"error": c.Query("error")})
+}
 
+// renderUpdateTicketForm renders the update form for a ticket with its comments, plus any error
+// or conflict details in extra.
+func renderUpdateTicketForm(c *gin.Context, status int, ticket *models.Ticket, extra gin.H) {
 	var rawComments []models.Comment
 	config.DB.Where("ticket_id = ?", ticket.ID).Order("created_at asc").Find(&rawComments)
 
@@ -241,111 +272,120 @@ func ShowUpdateTicketForm(c *gin.Context) {
 		})
 	}
 
-	c.HTML(http.StatusOK, "update_ticket.html", gin.H{
+	// One input per skill the ticket may list, filled with the skills it already needs
+	skills := parseTicketSkills(ticket)
+	for len(skills) < maxSkillInputs {
+		skills = append(skills, "")
+	}
+
+	data := gin.H{
 		"ticket":   ticket,
+		"skills":   skills,
+		"statuses": controllers.TicketStatuses,
 		"comments": displayComments,
-	})
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	c.HTML(status, "update_ticket.html", data)
 }
 
 // HandleUpdateTicket processes ticket update submissions.
 func HandleUpdateTicket(c *gin.Context) {
-	ticketID := c.Param("id")
-	var ticket models.Ticket
-	if err := config.DB.First(&ticket, ticketID).Error; err != nil {
-		c.String(http.StatusNotFound, "Ticket not found")
+	svc, actor := webTickets(c)
+	id, err := controllers.ParseTicketID(c.Param("id"))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "404.html", nil)
 		return
 	}
 
-	newStatus := c.PostForm("status")
-	if newStatus != "" && newStatus != ticket.Status {
-		ticket.Status = newStatus
+	version, _ := strconv.ParseUint(c.PostForm("version"), 10, 64)
+	changes := controllers.UpdateTicketInput{Version: uint(version), Comment: c.PostForm("comment")}
+	if status := c.PostForm("status"); status != "" {
+		changes.Status = &status
 	}
-
-	rawSkills := c.PostFormArray("SkillsNeeded")
-	if len(rawSkills) > 0 {
-		skills := []string{}
-		for _, skill := range rawSkills {
-			trimmed := strings.TrimSpace(skill)
-			if trimmed != "" {
-				skills = append(skills, trimmed)
-			}
-		}
-		if len(skills) > 0 {
-			skillsJSON, err := json.Marshal(skills)
-			if err != nil {
-				c.String(http.StatusInternalServerError, "Invalid skills input")
-				return
-			}
-			ticket.SkillsNeeded = string(skillsJSON)
-		}
+	if skills, ok := c.GetPostFormArray("SkillsNeeded"); ok {
+		changes.Skills = &skills
 	}
 
-	if err := config.DB.Save(&ticket).Error; err != nil {
-		c.String(http.StatusInternalServerError, "Failed to update ticket")
+	ticket, err := svc.Update(actor, id, changes)
+	var conflict *controllers.ConflictError
+	if errors.As(err, &conflict) {
+		// Show the form again with the saved ticket, so resubmitting applies on top of it
+		renderUpdateTicketForm(c, http.StatusConflict, conflict.Current.(*models.Ticket), gin.H{
+			"error":     conflict.Error(),
+			"conflicts": conflict.Diff(),
+			"comment":   c.PostForm("comment"),
+		})
 		return
 	}
-
-	commentText := strings.TrimSpace(c.PostForm("comment"))
-	if commentText != "" {
-		claims := c.MustGet("user").(*utils.Claims)
-
-		comment := models.Comment{
-			TicketID:    ticket.ID,
-			AuthorID:    claims.UserID,
-			AuthorEmail: claims.Email,
-			Content:     commentText,
-			CreatedAt:   time.Now(),
+	if err != nil {
+		if status := controllers.TicketErrorStatus(err); status == http.StatusBadRequest || status == http.StatusPreconditionRequired {
+			c.Redirect(http.StatusFound, fmt.Sprintf("/tickets/update/%d?error=%s", id, url.QueryEscape(err.Error())))
+			return
 		}
-
-		_ = config.DB.Create(&comment)
+		renderTicketError(c, err)
+		return
 	}
 
-	utils.LogInfo(fmt.Sprintf("[UpdateTicket] Ticket %s updated", ticketID))
-	c.Redirect(http.StatusFound, "/tickets/"+ticketID)
+	c.Redirect(http.StatusFound, fmt.Sprintf("/tickets/%d", ticket.ID))
 }
 
 // HandleCreateTicket creates a new ticket from a form submission.
 func HandleCreateTicket(c *gin.Context) {
-	title := c.PostForm("title")
-	description := c.PostForm("description")
-	priority := c.PostForm("priority")
-	status := "initially reported"
-
-	claims := c.MustGet("user").(*utils.Claims)
-
-	rawSkills := c.PostFormArray("skillsNeeded")
-	skills := []string{}
-	for _, skill := range rawSkills {
-		trimmed := strings.TrimSpace(skill)
-		if trimmed != "" {
-			skills = append(skills, trimmed)
-		}
+	svc, actor := webTickets(c)
+	in := controllers.CreateTicketInput{
+		Title:       c.PostForm("title"),
+		Description: c.PostForm("description"),
+		Priority:    c.PostForm("priority"),
+		Skills:      c.PostFormArray("skillsNeeded"),
 	}
 
-	var skillsJSON string
-	if len(skills) > 0 {
-		skillsBytes, err := json.Marshal(skills)
-		if err != nil {
-			c.String(http.StatusBadRequest, "Invalid skills input")
+	if _, err := svc.Create(actor, in); err != nil {
+		if controllers.TicketErrorStatus(err) != http.StatusBadRequest {
+			renderTicketError(c, err)
 			return
 		}
-		skillsJSON = string(skillsBytes)
+		c.HTML(http.StatusBadRequest, "create_ticket.html", gin.H{
+			"error":       err.Error(),
+			"title":       in.Title,
+			"description": in.Description,
+		})
+		return
 	}
 
-	ticket := models.Ticket{
-		Title:        title,
-		Description:  description,
-		Priority:     priority,
-		Status:       status,
-		ClientID:     claims.UserID,
-		SkillsNeeded: skillsJSON,
-	}
+	c.Redirect(http.StatusFound, "/tickets/mine")
+}
 
-	if err := config.DB.Create(&ticket).Error; err != nil {
-		c.String(http.StatusInternalServerError, "Failed to create ticket")
-		return
+// maxSkillInputs is how many skill inputs the update form shows.
+const maxSkillInputs = 5
+
+// webTickets returns the TicketService and actor for a WebUI request.
+func webTickets(c *gin.Context) (*controllers.TicketService, controllers.Actor) {
+	return controllers.NewTicketService(config.DB), controllers.ActorFromClaims(c.MustGet("user").(*utils.Claims), c.ClientIP())
+}
+
+// renderTicketError renders the page matching a TicketService error.
+func renderTicketError(c *gin.Context, err error) {
+	switch status := controllers.TicketErrorStatus(err); status {
+	case http.StatusNotFound:
+		c.HTML(status, "404.html", nil)
+	case http.StatusForbidden:
+		c.HTML(status, "403.html", nil)
+	default:
+		c.String(status, err.Error())
 	}
+}
 
-	utils.LogInfo(fmt.Sprintf("[CreateTicket] Ticket #%d created by user %d", ticket.ID, claims.UserID))
-	c.Redirect(http.StatusFound, "/tickets/mine")
+// parseTicketSkills returns the skills a ticket needs, or none if they cannot be read.
+func parseTicketSkills(ticket *models.Ticket) []string {
+	if ticket.SkillsNeeded == "" {
+		return nil
+	}
+	skills, err := utils.ParseSkills(ticket.SkillsNeeded)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("[TicketWebUI] Failed to parse skillsNeeded for ticket %d", ticket.ID))
+		return nil
+	}
+	return skills
 }
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"RyanForce/config"
+	"RyanForce/controllers"
+	"RyanForce/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ShowTrash handles GET /admin/trash
+// Lists soft-deleted tickets, comments, and accounts that can still be restored.
+func ShowTrash(c *gin.Context) {
+	trash, err := controllers.ListTrash()
+	if err != nil {
+		utils.LogError("[AdminTrash] Failed to load trash", err)
+		c.String(http.StatusInternalServerError, "Failed to load trash")
+		return
+	}
+
+	c.HTML(http.StatusOK, "admin_trash.html", gin.H{
+		"tickets":       trash.Tickets,
+		"comments":      trash.Comments,
+		"accounts":      trash.Accounts,
+		"retentionDays": config.TrashRetentionDays(),
+		"success":       c.Query("success"),
+		"error":         c.Query("error"),
+	})
+}
+
+// RestoreTrashItem handles POST /admin/trash/:type/:id/restore
+// Restores a single trashed ticket, comment, or account.
+func RestoreTrashItem(c *gin.Context) {
+	itemType := c.Param("type")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/admin/trash?error=Invalid+ID")
+		return
+	}
+
+	ip := c.ClientIP()
+	switch itemType {
+	case "tickets":
+		err = controllers.RestoreTicket(uint(id), ip)
+	case "comments":
+		err = controllers.RestoreComment(uint(id), ip)
+	case "accounts":
+		err = controllers.RestoreAccount(uint(id), ip)
+	default:
+		c.HTML(http.StatusNotFound, "404.html", nil)
+		return
+	}
+
+	if errors.Is(err, controllers.ErrNotInTrash) {
+		c.Redirect(http.StatusSeeOther, "/admin/trash?error=Item+is+no+longer+in+the+trash")
+		return
+	}
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/admin/trash?error=Failed+to+restore+item")
+		return
+	}
+
+	claims := c.MustGet("user").(*utils.Claims)
+	utils.LogInfoIP(fmt.Sprintf("[AdminTrash] Admin %d restored %s %d", claims.UserID, itemType, id), ip)
+	c.Redirect(http.StatusSeeOther, "/admin/trash?success=Item+restored")
+}
+
+// PurgeTrash handles POST /admin/trash/purge
+// Permanently removes items that have been in the trash longer than the retention period.
+func PurgeTrash(c *gin.Context) {
+	result, err := controllers.PurgeExpiredTrash()
+	if err != nil {
+		utils.LogError("[AdminTrash] Purge failed", err)
+		c.Redirect(http.StatusSeeOther, "/admin/trash?error=Purge+failed")
+		return
+	}
+
+	msg := fmt.Sprintf("Purged %d tickets, %d comments, %d accounts", result.Tickets, result.Comments, result.Accounts)
+	c.Redirect(http.StatusSeeOther, "/admin/trash?success="+url.QueryEscape(msg))
+}
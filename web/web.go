@@ -4,10 +4,14 @@ import (
 	"RyanForce/config"
 	"RyanForce/controllers"
 	"RyanForce/models"
+	"RyanForce/rbac"
 	"RyanForce/utils"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -24,7 +28,35 @@ func HandleWebLogin(c *gin.Context) {
 
 	utils.LogInfo("[WebUI] Login attempt from IP: " + ip + " — " + email)
 
-	token, err := controllers.Login(email, password)
+	token, err := controllers.Login(email, password, ip, c.Request.UserAgent())
+	if errors.Is(err, controllers.ErrMFARequired) || errors.Is(err, controllers.ErrMFAEnrollmentRequired) ||
+		errors.Is(err, controllers.ErrStepUpRequired) {
+		utils.SetCookie(c, mfaChallengeCookie, token, mfaChallengeMaxAge)
+		if errors.Is(err, controllers.ErrMFAEnrollmentRequired) {
+			c.Redirect(http.StatusSeeOther, "/login/mfa/enroll")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/login/mfa")
+		return
+	}
+	if startPasswordChange(c, token, err) {
+		return
+	}
+	var lockout *controllers.LockoutError
+	if errors.As(err, &lockout) {
+		utils.LogWarning("[WebUI] Login throttled for " + email + " from IP: " + ip)
+		c.HTML(http.StatusTooManyRequests, "login.html", gin.H{"error": "Too many failed attempts. Try again in " + lockout.RetryAfter.Round(time.Second).String() + "."})
+		return
+	}
+	if errors.Is(err, controllers.ErrPasswordLoginDisabled) {
+		c.HTML(http.StatusForbidden, "login.html", gin.H{"error": "Password login is disabled for your account. Use single sign-on."})
+		return
+	}
+	if errors.Is(err, controllers.ErrNetworkNotAllowed) || errors.Is(err, controllers.ErrStepUpUnavailable) {
+		utils.LogWarning("[WebUI] Login refused for " + email + " from IP: " + ip)
+		c.HTML(http.StatusForbidden, "login.html", gin.H{"error": "Sign-in refused: " + err.Error() + "."})
+		return
+	}
 	if err != nil {
 		utils.LogWarning("[WebUI] Login failed for " + email + " from IP: " + ip)
 		c.HTML(http.StatusUnauthorized, "login.html", gin.H{"error": "Invalid credentials"})
@@ -42,14 +74,16 @@ func HandleWebLogin(c *gin.Context) {
 	var confirmUser models.User
 	if err := config.DB.First(&confirmUser, claims.UserID).Error; err != nil {
 		utils.LogWarning(fmt.Sprintf("[WebUI] Login token references missing user ID %d, clearing cookie.", claims.UserID))
-		c.SetCookie("token", "", -1, "/", "", false, true)
-		c.SetCookie("flash", "Session invalid or expired. Please log in again.", 3, "/", "", false, true)
+		utils.ClearCookie(c, "token")
+		utils.SetCookie(c, "flash", "Session invalid or expired. Please log in again.", 3)
 		c.Redirect(http.StatusSeeOther, "/login")
 		return
 	}
 
 	utils.LogInfo("[WebUI] Login successful for " + email + " from IP: " + ip)
-	c.SetCookie("token", token, 3600, "/", "localhost", false, true)
+	utils.ClearCookie(c, utils.CSRFCookie) // Start a new CSRF session for the signed-in user
+	utils.ClearCookie(c, impersonatorCookie)
+	utils.SetCookie(c, "token", token, 3600)
 	c.Redirect(http.StatusFound, "/dashboard")
 }
 
@@ -70,38 +104,50 @@ func ShowDashboard(c *gin.Context) {
 	var user models.User
 	if err := config.DB.First(&user, claims.UserID).Error; err != nil {
 		utils.LogWarning(fmt.Sprintf("[WebUI] User not found for session (ID %d), clearing session.", claims.UserID))
-		c.SetCookie("token", "", -1, "/", "localhost", false, true)
+		utils.ClearCookie(c, "token")
 		c.Redirect(http.StatusFound, "/login")
 		return
 	}
 
 	data := gin.H{
 		"user":      user.Email,
+		"role":      user.Role,
 		"lastLogin": user.LastLogin,
+		"can":       rbac.PermissionMap(user.Role),
 	}
 
-	switch user.Role {
-	case "admin":
+	// Pick the dashboard that matches the widest ticket view the role allows,
+	// so custom roles such as dispatcher or auditor land on the staff dashboard.
+	switch {
+	case rbac.Can(user.Role, rbac.TicketsViewAll):
+		var open, unassigned int64
+		config.DB.Model(&models.Ticket{}).Where("status <> ?", controllers.StatusClosed).Count(&open)
+		config.DB.Model(&models.Ticket{}).Where("status <> ? AND tech_id IS NULL", controllers.StatusClosed).Count(&unassigned)
+		data["openTickets"], data["unassignedTickets"] = open, unassigned
 		c.HTML(http.StatusOK, "admin_dashboard.html", data)
-	case "tech":
+	case rbac.Can(user.Role, rbac.TicketsViewAssigned):
 		c.HTML(http.StatusOK, "tech_dashboard.html", data)
-	case "client":
+	case rbac.Can(user.Role, rbac.TicketsViewOwn):
 		c.HTML(http.StatusOK, "client_dashboard.html", data)
 	default:
-		c.String(http.StatusForbidden, "Invalid role")
+		c.HTML(http.StatusForbidden, "403.html", nil)
 	}
 }
 
-// HandleLogout clears the token cookie and logs the event.
+// HandleLogout clears the token cookie and logs the event. Logging out while impersonating
+// ends the admin's own session too.
 func HandleLogout(c *gin.Context) {
 	token, err := c.Cookie("token")
 	if err == nil {
 		if claims, err := utils.ParseJWT(token); err == nil {
+			controllers.StopImpersonation(claims, c.ClientIP())
 			utils.LogInfo("[Logout] User logged out: " + claims.Email)
 		}
 	}
 
-	c.SetCookie("token", "", -1, "/", "localhost", false, true)
+	utils.ClearCookie(c, "token")
+	utils.ClearCookie(c, impersonatorCookie)
+	utils.ClearCookie(c, utils.CSRFCookie)
 	c.Redirect(http.StatusFound, "/login")
 }
 
@@ -116,14 +162,6 @@ func HandleResetPassword(c *gin.Context) {
 	oldPassword := c.PostForm("old_password")
 	newPassword := c.PostForm("new_password")
 
-	if !utils.IsValidPassword(newPassword) {
-		utils.LogWarning("[Reset] Weak password submitted for " + email)
-		c.HTML(http.StatusBadRequest, "reset_password.html", gin.H{
-			"error": "Password must be 8–32 characters and include a capital letter, number, and special character.",
-		})
-		return
-	}
-
 	err := controllers.ResetPassword(email, oldPassword, newPassword)
 	if err != nil {
 		utils.LogWarning("[Reset] Password reset failed for " + email + ": " + err.Error())
@@ -142,30 +180,12 @@ func ShowAdminResetForm(c *gin.Context) {
 
 // HandleAdminResetPassword allows an admin to reset a user password without the old password
 func HandleAdminResetPassword(c *gin.Context) {
-	token, err := c.Cookie("token")
-	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
-
-	claims, err := utils.ParseJWT(token)
-	if err != nil || claims.Role != "admin" {
-		c.String(http.StatusForbidden, "Unauthorized")
-		return
-	}
+	claims := c.MustGet("user").(*utils.Claims)
 
 	email := c.PostForm("email")
 	newPassword := c.PostForm("new_password")
 
-	if !utils.IsValidPassword(newPassword) {
-		utils.LogWarning(fmt.Sprintf("[AdminReset] Weak password submitted for %s", email))
-		c.HTML(http.StatusBadRequest, "admin_reset_password.html", gin.H{
-			"error": "Password must be 8–32 characters and include a capital letter, number, and special character.",
-		})
-		return
-	}
-
-	err = controllers.AdminResetPassword(claims.UserID, email, newPassword)
+	err := controllers.AdminResetPassword(claims.UserID, email, newPassword)
 	if err != nil {
 		utils.LogWarning("[AdminReset] Failed password reset for " + email)
 		c.HTML(http.StatusBadRequest, "admin_reset_password.html", gin.H{"error": err.Error()})
@@ -183,17 +203,7 @@ func ShowUnlockForm(c *gin.Context) {
 
 // HandleUnlockUser allows admin to unlock a locked-out user account
 func HandleUnlockUser(c *gin.Context) {
-	token, err := c.Cookie("token")
-	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
-
-	claims, err := utils.ParseJWT(token)
-	if err != nil || claims.Role != "admin" {
-		c.String(http.StatusForbidden, "Unauthorized")
-		return
-	}
+	claims := c.MustGet("user").(*utils.Claims)
 
 	email := c.PostForm("email")
 	var user models.User
@@ -202,15 +212,13 @@ func HandleUnlockUser(c *gin.Context) {
 		return
 	}
 
-	if !user.IsLocked {
-		c.HTML(http.StatusOK, "admin_unlock_user.html", gin.H{"success": "Account is already unlocked."})
+	wasLocked, err := controllers.UnlockUser(claims.UserID, &user)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "admin_unlock_user.html", gin.H{"error": "Failed to update user."})
 		return
 	}
-
-	user.IsLocked = false
-	user.FailedAttempts = 0
-	if err := config.DB.Save(&user).Error; err != nil {
-		c.HTML(http.StatusInternalServerError, "admin_unlock_user.html", gin.H{"error": "Failed to update user."})
+	if !wasLocked {
+		c.HTML(http.StatusOK, "admin_unlock_user.html", gin.H{"success": "Account is already unlocked."})
 		return
 	}
 
@@ -219,68 +227,30 @@ func HandleUnlockUser(c *gin.Context) {
 	c.HTML(http.StatusOK, "admin_unlock_user.html", gin.H{"success": "Account successfully unlocked."})
 }
 
-// UpdateTicketStatus allows admins, techs, or clients to update a ticket's status
+// UpdateTicketStatus lets any user whose role may update the ticket change its status
 func UpdateTicketStatus(c *gin.Context) {
-	id := c.Param("id")
-	status := c.PostForm("status")
-
-	var ticket models.Ticket
-	if err := config.DB.First(&ticket, id).Error; err != nil {
-		c.SetCookie("flash", "Ticket not found", 3, "/", "", false, true)
-		c.Redirect(http.StatusSeeOther, "/dashboard")
-		return
+	svc, actor := webTickets(c)
+	id, err := controllers.ParseTicketID(c.Param("id"))
+	if err == nil {
+		status := c.PostForm("status")
+		version, _ := strconv.ParseUint(c.PostForm("version"), 10, 64)
+		_, err = svc.Update(actor, id, controllers.UpdateTicketInput{Status: &status, Version: uint(version)})
 	}
-
-	tokenStr, err := c.Cookie("token")
-	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
+	var conflict *controllers.ConflictError
+	if errors.As(err, &conflict) {
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/tickets/%d?error=%s", id, url.QueryEscape(conflict.Summary())))
 		return
 	}
-
-	claims, err := utils.ParseJWT(tokenStr)
 	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
-
-	// Role enforcement
-	switch claims.Role {
-	case "client":
-		if ticket.ClientID != claims.UserID {
-			c.SetCookie("flash", "Unauthorized to update this ticket", 3, "/", "", false, true)
-			c.Redirect(http.StatusSeeOther, "/dashboard")
-			return
-		}
-	case "tech":
-		if ticket.TechID == nil || *ticket.TechID != claims.UserID {
-			c.SetCookie("flash", "Unauthorized to update this ticket", 3, "/", "", false, true)
+		utils.SetCookie(c, "flash", err.Error(), 3)
+		if errors.Is(err, controllers.ErrTicketNotFound) || errors.Is(err, controllers.ErrTicketForbidden) {
 			c.Redirect(http.StatusSeeOther, "/dashboard")
 			return
 		}
-	case "admin":
-		// Admin always allowed
-	default:
-		c.SetCookie("flash", "Unauthorized role", 3, "/", "", false, true)
-		c.Redirect(http.StatusSeeOther, "/dashboard")
-		return
-	}
-
-	// Update ticket status
-	ticket.Status = status
-	if status == "closed" && ticket.ClosedAt == nil {
-		now := time.Now()
-		ticket.ClosedAt = &now
-	} else if status != "closed" {
-		ticket.ClosedAt = nil
-	}
-
-	if err := config.DB.Save(&ticket).Error; err != nil {
-		utils.LogError(fmt.Sprintf("[WebUI] Failed to update ticket %s", ticket.Title), err)
-		c.SetCookie("flash", "Failed to update ticket status", 3, "/", "", false, true)
-		c.Redirect(http.StatusSeeOther, "/dashboard")
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/tickets/%d", id))
 		return
 	}
 
-	c.SetCookie("flash", "Ticket status updated", 3, "/", "", false, true)
-	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/tickets/%s", id))
+	utils.SetCookie(c, "flash", "Ticket status updated", 3)
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/tickets/%d", id))
 }